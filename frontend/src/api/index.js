@@ -74,6 +74,11 @@ export const configAPI = {
     return api.post('/config/test-mi')
   },
   
+  // 测试联网搜索
+  testSearch(query) {
+    return api.post('/config/test-search', { query })
+  },
+  
   // 启动音箱服务
   startSpeaker() {
     return api.post('/config/start-speaker')
@@ -143,6 +148,216 @@ export const speakerAPI = {
       command, 
       needResponse 
     })
+  },
+  
+  // 获取已注册的命令
+  getCommands() {
+    return api.get('/speaker/commands')
+  },
+  
+  // 获取命令路由决策
+  getRoutingDecisions(limit = 50) {
+    return api.get('/speaker/routing', { params: { limit } })
+  },
+  
+  // 获取外部插件状态
+  getPlugins() {
+    return api.get('/speaker/plugins')
+  },
+  
+  // 获取AI可调用的工具
+  getTools() {
+    return api.get('/speaker/tools')
+  },
+  
+  // 获取最近的工具调用记录
+  getToolCalls(limit = 50) {
+    return api.get('/speaker/tool-calls', { params: { limit } })
+  }
+}
+
+// 自定义命令相关API
+export const commandsAPI = {
+  // 获取自定义命令列表
+  list() {
+    return api.get('/commands')
+  },
+  
+  // 创建自定义命令
+  create(data) {
+    return api.post('/commands', data)
+  },
+  
+  // 更新自定义命令
+  update(id, data) {
+    return api.put(`/commands/${id}`, data)
+  },
+  
+  // 删除自定义命令
+  remove(id) {
+    return api.delete(`/commands/${id}`)
+  },
+  
+  // 重新加载自定义命令
+  reload() {
+    return api.post('/commands/reload')
+  }
+}
+
+// 记忆相关API
+export const memoryAPI = {
+  // 分页获取记忆列表
+  list(params) {
+    return api.get('/memories', { params })
+  },
+  
+  // 获取可筛选的用户和房间
+  getScopes() {
+    return api.get('/memories/scopes')
+  },
+  
+  // 获取记忆及其来源
+  get(type, id) {
+    return api.get(`/memories/${type}/${id}`)
+  },
+  
+  // 修改记忆内容
+  update(type, id, text) {
+    return api.put(`/memories/${type}/${id}`, { text })
+  },
+  
+  // 删除记忆
+  remove(type, id) {
+    return api.delete(`/memories/${type}/${id}`)
+  },
+  
+  // 根据来源重新总结
+  resummarize(type, id) {
+    return api.post(`/memories/${type}/${id}/resummarize`)
+  },
+  
+  // 按相关度检索消息和记忆
+  search(params) {
+    return api.get('/memories/search', { params })
+  },
+  
+  // 重建检索索引
+  reindex() {
+    return api.post('/memories/reindex')
+  }
+}
+
+// 家庭成员相关API
+export const userAPI = {
+  // 获取家庭成员列表
+  list() {
+    return api.get('/users')
+  },
+  
+  // 添加家庭成员
+  create(data) {
+    return api.post('/users', data)
+  },
+  
+  // 修改家庭成员
+  update(id, data) {
+    return api.put(`/users/${id}`, data)
+  },
+  
+  // 删除家庭成员
+  remove(id) {
+    return api.delete(`/users/${id}`)
+  }
+}
+
+// 会话相关API
+export const sessionAPI = {
+  // 分页获取会话列表
+  list(params) {
+    return api.get('/sessions', { params })
+  },
+  
+  // 获取会话及其消息
+  get(id) {
+    return api.get(`/sessions/${id}`)
+  }
+}
+
+// 对话记录相关API
+export const messageAPI = {
+  // 分页获取对话记录
+  list(params) {
+    return api.get('/messages', { params })
+  },
+  
+  // 导出链接，由浏览器直接下载
+  exportURL(params) {
+    const query = new URLSearchParams()
+    Object.entries(params).forEach(([key, value]) => {
+      if (value !== undefined && value !== null && value !== '') {
+        query.append(key, value)
+      }
+    })
+    return `/api/v1/messages/export?${query.toString()}`
+  },
+  
+  // 导入对话记录文件
+  importFile(file) {
+    const form = new FormData()
+    form.append('file', file)
+    return api.post('/messages/import', form, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+      timeout: 120000
+    })
+  }
+}
+
+// 用量与费用相关API
+export const usageAPI = {
+  // 按天、设备、成员、服务商等维度汇总用量，同时返回预算使用情况
+  get(params) {
+    return api.get('/usage', { params })
+  }
+}
+
+// 回答缓存相关API
+export const cacheAPI = {
+  // 分页列出缓存的回答，同时返回命中统计
+  list(params) {
+    return api.get('/cache', { params })
+  },
+
+  // 删除一条缓存的回答
+  delete(key) {
+    return api.delete(`/cache/${key}`)
+  },
+
+  // 清空回答缓存，expired 为 true 时只删除已过期的
+  purge(expired = false) {
+    return api.delete('/cache', { params: { expired } })
+  }
+}
+
+// AI服务商相关API
+export const providerAPI = {
+  // 获取支持的服务商类型
+  getKinds() {
+    return api.get('/providers/kinds')
+  },
+  
+  // 获取 Ollama 服务商已下载的模型
+  listModels(name) {
+    return api.get(`/providers/${encodeURIComponent(name)}/models`)
+  },
+  
+  // 让 Ollama 服务商下载模型
+  pullModel(name, model) {
+    return api.post(`/providers/${encodeURIComponent(name)}/pull`, { model })
+  },
+  
+  // 获取模型下载进度
+  getPulls(name) {
+    return api.get(`/providers/${encodeURIComponent(name)}/pull`)
   }
 }
 
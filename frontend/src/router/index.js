@@ -25,6 +25,48 @@ const routes = [
         component: () => import('../views/Speaker.vue'),
         meta: { title: '音箱控制', icon: 'Microphone' }
       },
+      {
+        path: '/commands',
+        name: 'Commands',
+        component: () => import('../views/Commands.vue'),
+        meta: { title: '自定义命令', icon: 'Promotion' }
+      },
+      {
+        path: '/users',
+        name: 'Users',
+        component: () => import('../views/Users.vue'),
+        meta: { title: '家庭成员', icon: 'User' }
+      },
+      {
+        path: '/messages',
+        name: 'Messages',
+        component: () => import('../views/Messages.vue'),
+        meta: { title: '对话记录', icon: 'ChatLineSquare' }
+      },
+      {
+        path: '/sessions',
+        name: 'Sessions',
+        component: () => import('../views/Sessions.vue'),
+        meta: { title: '会话记录', icon: 'ChatDotRound' }
+      },
+      {
+        path: '/usage',
+        name: 'Usage',
+        component: () => import('../views/Usage.vue'),
+        meta: { title: '用量统计', icon: 'Coin' }
+      },
+      {
+        path: '/cache',
+        name: 'Cache',
+        component: () => import('../views/Cache.vue'),
+        meta: { title: '回答缓存', icon: 'Files' }
+      },
+      {
+        path: '/memories',
+        name: 'Memories',
+        component: () => import('../views/Memories.vue'),
+        meta: { title: '记忆管理', icon: 'Collection' }
+      },
       {
         path: '/concurrent',
         name: 'Concurrent',
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ruleRegexPrefix 问题匹配规则中表示正则表达式的前缀，其余规则按包含的文本匹配
+const ruleRegexPrefix = "re:"
+
+// CacheBypassed 问题是否命中跳过缓存的规则
+func (c OpenAIConfig) CacheBypassed(query string) bool {
+	return matchRules(c.CacheBypass, query)
+}
+
+// validateCache 验证回答缓存的配置
+func (c OpenAIConfig) validateCache() error {
+	if c.CacheTTLHours < 0 || c.CacheMaxEntries < 0 {
+		return fmt.Errorf("回答缓存的有效期和容量不能为负数")
+	}
+	return validateRules(c.CacheBypass, "跳过缓存的规则")
+}
+
+// matchRules 问题是否命中任意一条规则，无效的正则表达式视为不匹配
+func matchRules(rules []string, query string) bool {
+	for _, rule := range rules {
+		if rule == "" {
+			continue
+		}
+		if pattern, ok := strings.CutPrefix(rule, ruleRegexPrefix); ok {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(query) {
+				return true
+			}
+			continue
+		}
+		if strings.Contains(query, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRules 验证规则中的正则表达式，name 为错误信息中规则的名称
+func validateRules(rules []string, name string) error {
+	for _, rule := range rules {
+		if pattern, ok := strings.CutPrefix(rule, ruleRegexPrefix); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s %s 不是有效的正则表达式: %v", name, rule, err)
+			}
+		}
+	}
+	return nil
+}
@@ -26,6 +26,7 @@ type Config struct {
 	Speaker  SpeakerConfig  `json:"speaker"`
 	Bot      BotConfig      `json:"bot"`
 	OpenAI   OpenAIConfig   `json:"openai"`
+	Plugins  []PluginConfig `json:"plugins"`
 }
 
 // DatabaseConfig 数据库配置
@@ -47,8 +48,10 @@ type SpeakerConfig struct {
 	OnEnterAI              []string `json:"onEnterAI"`
 	OnExitAI               []string `json:"onExitAI"`
 	OnAIAsking             []string `json:"onAIAsking"`
+	OnAIThinking           []string `json:"onAIThinking"`           // 思考模型开始思考时的提示语，为空时不提示
 	OnAIReplied            []string `json:"onAIReplied"`
 	OnAIError              []string `json:"onAIError"`
+	OnBudgetExceeded       []string `json:"onBudgetExceeded"`       // 超出AI费用预算且未配置便宜模型时的回复
 	StreamResponse         bool     `json:"streamResponse"`
 	EnableAudioLog         bool     `json:"enableAudioLog"`
 	KeepAlive              bool     `json:"keepAlive"`
@@ -80,8 +83,41 @@ type BotConfig struct {
 	SystemTemplate  string `json:"systemTemplate"`
 	Master          MasterConfig `json:"master"`
 	Room            RoomConfig   `json:"room"`
+
+	// 多轮对话上下文
+	ContextTurns     int `json:"contextTurns"`     // 携带的历史轮数
+	ContextMaxTokens int `json:"contextMaxTokens"` // 历史消息的token预算
+	ContextWindow    int `json:"contextWindow"`    // 非连续对话模式下，空闲超过该时长(分钟)后结束会话
+
+	// 家庭成员
+	IdentityTimeout int `json:"identityTimeout"` // "我是X"切换的说话人身份在空闲多久后恢复为主人(分钟)
+
+	// 播报，成员设置了播报方式时以成员的为准
+	SpeechStyle   string `json:"speechStyle"`   // 回复播报前的文本处理方式：natural, plain, raw
+	SpeechURLText string `json:"speechUrlText"` // 播报时网址替换为的短语，为空时不播报网址
+
+	// 记忆
+	EnableMemory         bool `json:"enableMemory"`         // 是否启用记忆
+	ShortTermMemoryEvery int  `json:"shortTermMemoryEvery"` // 每积累多少条新消息更新一次短期记忆
+	LongTermMemoryAfter  int  `json:"longTermMemoryAfter"`  // 积累多少条新的短期记忆后整理一次长期记忆
+	MemoryRecallLimit    int  `json:"memoryRecallLimit"`    // 每次提问召回的相关记忆条数
+	MemoryRecallTokens   int  `json:"memoryRecallTokens"`   // 相关记忆的token预算
+
+	// 记忆保留与维护，保留天数为0表示永久保留
+	MessageRetentionDays     int `json:"messageRetentionDays"`     // 原始消息保留天数，删除前会先整理进长期记忆
+	MemoryRetentionDays      int `json:"memoryRetentionDays"`      // 消息记忆记录保留天数
+	ShortTermRetentionDays   int `json:"shortTermRetentionDays"`   // 短期记忆保留天数
+	LongTermRetentionDays    int `json:"longTermRetentionDays"`    // 长期记忆保留天数
+	MaintenanceIntervalHours int `json:"maintenanceIntervalHours"` // 后台维护任务的执行间隔(小时)
 }
 
+// 播报前的文本处理方式
+const (
+	SpeechStyleNatural = "natural" // 去掉Markdown、表情和网址，列表合并为句子，单位、符号、日期和公式读作中文
+	SpeechStylePlain   = "plain"   // 只去掉Markdown、表情和网址
+	SpeechStyleRaw     = "raw"     // 原样播报
+)
+
 // MasterConfig 主人配置
 type MasterConfig struct {
 	Name    string `json:"name"`
@@ -96,24 +132,67 @@ type RoomConfig struct {
 
 // OpenAIConfig AI服务配置
 type OpenAIConfig struct {
+	// 服务商
+	Profiles             []ProviderProfile `json:"profiles"` // 已定义的服务商
+	Profile              string `json:"profile"`              // 主服务商名称
+	Fallbacks            []string `json:"fallbacks"`          // 备用服务商名称，主服务商出现网络错误、5xx或429时按顺序尝试
+	BreakerFailures      int    `json:"breakerFailures"`      // 服务商连续失败多少次后暂停使用
+	BreakerResetSeconds  int    `json:"breakerResetSeconds"`  // 暂停使用的服务商多少秒后重新尝试
+	
 	// 通用配置
-	APIKey               string `json:"apiKey"`               // API密钥
-	BaseURL              string `json:"baseUrl"`              // API基础URL
-	Model                string `json:"model"`                // 模型名称
 	ProxyURL             string `json:"proxyUrl"`             // 代理URL
-	EnableSearch         bool   `json:"enableSearch"`         // 是否启用搜索功能
+	EnableSearch         bool   `json:"enableSearch"`         // 是否启用联网搜索
+	EnableTools          bool   `json:"enableTools"`          // 是否允许AI调用工具（音量、定时提醒、家居控制等）
 	
-	// Azure OpenAI 配置
-	AzureAPIKey          string `json:"azureApiKey"`          // Azure API密钥
-	AzureEndpoint        string `json:"azureEndpoint"`        // Azure端点
-	AzureDeployment      string `json:"azureDeployment"`      // Azure部署名称
+	// 向量嵌入配置（语义记忆）
+	EnableEmbedding      bool   `json:"enableEmbedding"`      // 是否启用向量嵌入
+	EmbeddingModel       string `json:"embeddingModel"`       // 嵌入模型名称
+	EmbeddingDimensions  int    `json:"embeddingDimensions"`  // 向量维度，0表示使用模型默认值
+	EmbeddingBaseURL     string `json:"embeddingBaseUrl"`     // 独立的嵌入服务地址（OpenAI兼容），留空使用主服务商
+	EmbeddingAPIKey      string `json:"embeddingApiKey"`      // 独立嵌入服务的API密钥，留空使用主服务商的密钥
+	AzureEmbeddingDeployment string `json:"azureEmbeddingDeployment"` // 主服务商为Azure时嵌入模型的部署名称
 	
-	// DeepSeek 配置
-	DeepSeekAPIKey       string `json:"deepSeekApiKey"`       // DeepSeek API密钥
-	DeepSeekBaseURL      string `json:"deepSeekBaseUrl"`      // DeepSeek API基础URL
+	// 用量与费用
+	Prices               []ModelPrice `json:"prices"`        // 模型价格表，用于计算每次调用的费用
+	DailyBudget          float64 `json:"dailyBudget"`        // 每日费用预算，0表示不限制
+	MonthlyBudget        float64 `json:"monthlyBudget"`      // 每月费用预算，0表示不限制
+	BudgetModel          string  `json:"budgetModel"`        // 超出预算后主服务商改用的便宜模型，留空则拒绝回答
 	
-	// 服务提供商选择
-	Provider             string `json:"provider"`             // 服务提供商：openai, azure, deepseek
+	// 回答缓存
+	EnableCache          bool     `json:"enableCache"`       // 是否缓存相同问题的回答
+	CacheTTLHours        int      `json:"cacheTtlHours"`     // 缓存的有效期（小时）
+	CacheMaxEntries      int      `json:"cacheMaxEntries"`   // 最多缓存多少条回答，超出时淘汰最久未使用的
+	CacheBypass          []string `json:"cacheBypass"`       // 包含这些词的问题不使用缓存（时间、天气等时效性问题），以 re: 开头时按正则表达式匹配
+	
+	// 联网搜索，由 EnableSearch 开启
+	SearchProvider       string   `json:"searchProvider"`    // 搜索服务：searxng, bing, custom
+	SearchBaseURL        string   `json:"searchBaseUrl"`     // 搜索接口地址，custom 时为地址模板，{query} 替换为问题，{apiKey} 替换为密钥
+	SearchAPIKey         string   `json:"searchApiKey"`      // 搜索接口的密钥，Bing 必填
+	SearchTemplate       SearchTemplate `json:"searchTemplate"` // custom 接口的响应格式
+	SearchMaxResults     int      `json:"searchMaxResults"`  // 作为上下文的搜索结果条数
+	SearchTriggers       []string `json:"searchTriggers"`    // 包含这些词的问题先搜索再回答，以 re: 开头时按正则表达式匹配；允许调用工具时模型也可以自行搜索
+	
+	// 旧版服务商配置，加载时由 MigrateLegacyProvider 迁移为服务商配置
+	Provider             string `json:"provider,omitempty"`        // 服务提供商：openai, azure, deepseek
+	APIKey               string `json:"apiKey,omitempty"`          // API密钥
+	BaseURL              string `json:"baseUrl,omitempty"`         // API基础URL
+	Model                string `json:"model,omitempty"`           // 模型名称
+	AzureAPIKey          string `json:"azureApiKey,omitempty"`     // Azure API密钥
+	AzureEndpoint        string `json:"azureEndpoint,omitempty"`   // Azure端点
+	AzureDeployment      string `json:"azureDeployment,omitempty"` // Azure部署名称
+	DeepSeekAPIKey       string `json:"deepSeekApiKey,omitempty"`  // DeepSeek API密钥
+	DeepSeekBaseURL      string `json:"deepSeekBaseUrl,omitempty"` // DeepSeek API基础URL
+}
+
+// PluginConfig 外部命令插件配置
+type PluginConfig struct {
+	Name    string   `json:"name"`    // 插件名称（用于日志和状态展示）
+	Command string   `json:"command"` // 可执行文件路径
+	Args    []string `json:"args"`    // 启动参数
+	Dir     string   `json:"dir"`     // 工作目录
+	Env     []string `json:"env"`     // 额外环境变量（KEY=VALUE）
+	Timeout int      `json:"timeout"` // 请求超时(毫秒)
+	Enabled bool     `json:"enabled"` // 是否启用
 }
 
 const ConfigFileName = "config.json"
@@ -174,6 +253,7 @@ func createDefaultConfig() *Config {
 			OnAIAsking:             []string{"让我先想想", "请稍等"},
 			OnAIReplied:            []string{"我说完了", "还有其他问题吗"},
 			OnAIError:              []string{"啊哦，出错了，请稍后再试吧！"},
+			OnBudgetExceeded:       []string{"AI额度已经用完了，请让主人调整预算后再来问我吧。"},
 			StreamResponse:         true,
 			EnableAudioLog:         false,
 			KeepAlive:              false,
@@ -208,26 +288,67 @@ func createDefaultConfig() *Config {
 				Name:        "客厅",
 				Description: "这是一个温馨的客厅，我们经常在这里聊天",
 			},
+			ContextTurns:     6,
+			ContextMaxTokens: 2000,
+			ContextWindow:    5,
+
+			IdentityTimeout: 10,
+
+			SpeechStyle:   SpeechStyleNatural,
+			SpeechURLText: "这个链接",
+
+			EnableMemory:         true,
+			ShortTermMemoryEvery: 10,
+			LongTermMemoryAfter:  3,
+			MemoryRecallLimit:    5,
+			MemoryRecallTokens:   500,
+
+			MaintenanceIntervalHours: 24,
 		},
 		OpenAI: OpenAIConfig{
+			// 服务商，默认使用DeepSeek（需要配置API Key）
+			Profiles: []ProviderProfile{
+				{
+					Name:         "deepseek",
+					Kind:         "deepseek",
+					DefaultModel: "deepseek-chat",
+				},
+			},
+			Profile:             "deepseek",
+			Fallbacks:           []string{},
+			BreakerFailures:     3,
+			BreakerResetSeconds: 60,
+			
 			// 通用配置
-			APIKey:          "",
-			BaseURL:         "",
-			Model:           "deepseek-chat",  // 更改默认模型
 			ProxyURL:        "",
 			EnableSearch:    false,
+			EnableTools:     true,
 			
-			// Azure OpenAI 配置
-			AzureAPIKey:     "",
-			AzureEndpoint:   "",
-			AzureDeployment: "",
+			// 向量嵌入配置
+			EnableEmbedding:     false,
+			EmbeddingModel:      "text-embedding-3-small",
+			EmbeddingDimensions: 0,
 			
-			// DeepSeek 配置
-			DeepSeekAPIKey:  "",
-			DeepSeekBaseURL: "https://api.deepseek.com/v1",
+			// 用量与费用
+			Prices: []ModelPrice{},
+			
+			// 回答缓存
+			EnableCache:     false,
+			CacheTTLHours:   168,
+			CacheMaxEntries: 1000,
+			CacheBypass: []string{
+				"现在", "今天", "明天", "昨天", "今年", "几点", "几号", "星期几", "周几",
+				"天气", "气温", "下雨", "新闻", "最新", "最近", "股价", "汇率", "比分",
+				`re:\d+月\d+[日号]`,
+			},
 			
-			// 服务提供商选择
-			Provider:        "deepseek", // 默认使用DeepSeek（需要配置API Key）
+			// 联网搜索
+			SearchProvider:   SearchProviderSearxng,
+			SearchMaxResults: 5,
+			SearchTriggers: []string{
+				"新闻", "最新", "最近", "热搜", "股价", "汇率", "油价", "金价",
+				"比分", "赛程", "票房", "天气", "气温", "搜一下", "查一下",
+			},
 		},
 	}
 }
@@ -243,6 +364,7 @@ func LoadFromFile() (*Config, error) {
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %v", err)
 	}
+	config.OpenAI.MigrateLegacyProvider()
 	
 	return &config, nil
 }
@@ -280,13 +402,13 @@ func Load() (*Config, error) {
 		config.Speaker.DeviceID = value
 	}
 	if value := os.Getenv("OPENAI_API_KEY"); value != "" {
-		config.OpenAI.APIKey = value
+		config.OpenAI.setKindAPIKey("openai", value)
 	}
 	if value := os.Getenv("DEEPSEEK_API_KEY"); value != "" {
-		config.OpenAI.DeepSeekAPIKey = value
+		config.OpenAI.setKindAPIKey("deepseek", value)
 	}
 	if value := os.Getenv("AI_PROVIDER"); value != "" {
-		config.OpenAI.Provider = value
+		config.OpenAI.Profile = value // 主服务商名称
 	}
 	
 	return config, nil
@@ -305,37 +427,7 @@ func (c *Config) ValidateBasic() error {
 
 // ValidateAI 验证AI服务配置
 func (c *Config) ValidateAI() error {
-	if c.OpenAI.Provider == "" {
-		return fmt.Errorf("AI服务提供商不能为空")
-	}
-	
-	switch c.OpenAI.Provider {
-	case "openai":
-		if c.OpenAI.APIKey == "" {
-			return fmt.Errorf("OpenAI API Key不能为空")
-		}
-		if c.OpenAI.Model == "" {
-			return fmt.Errorf("OpenAI模型不能为空")
-		}
-	case "azure":
-		if c.OpenAI.AzureAPIKey == "" {
-			return fmt.Errorf("azure API Key不能为空")
-		}
-		if c.OpenAI.AzureEndpoint == "" {
-			return fmt.Errorf("Azure端点不能为空")
-		}
-		if c.OpenAI.AzureDeployment == "" {
-			return fmt.Errorf("Azure部署名称不能为空")
-		}
-	case "deepseek":
-		if c.OpenAI.DeepSeekAPIKey == "" {
-			return fmt.Errorf("DeepSeek API Key不能为空")
-		}
-	default:
-		return fmt.Errorf("不支持的AI服务提供商: %s", c.OpenAI.Provider)
-	}
-	
-	return nil
+	return c.OpenAI.Validate()
 }
 
 // ValidateMi 验证小米设备配置
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModelPrice 模型价格，按每百万token计价，货币单位由使用者自行约定
+type ModelPrice struct {
+	Provider string  `json:"provider"` // 服务商名称，留空匹配所有服务商
+	Model    string  `json:"model"`    // 模型名称，以*结尾时按前缀匹配
+	Input    float64 `json:"input"`    // 输入（提示词）价格
+	Output   float64 `json:"output"`   // 输出（回复）价格
+}
+
+// Cost 计算一次调用的费用
+func (p ModelPrice) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1e6
+}
+
+// matches 价格是否适用于指定服务商和模型，返回匹配的精确程度，0表示不匹配
+// 精确模型名优先于前缀，指定服务商优先于不限服务商
+func (p ModelPrice) matches(provider, model string) int {
+	if p.Provider != "" && p.Provider != provider {
+		return 0
+	}
+	score := 0
+	switch {
+	case p.Model == model:
+		score = 4
+	case strings.HasSuffix(p.Model, "*") && strings.HasPrefix(model, strings.TrimSuffix(p.Model, "*")):
+		score = 2
+	default:
+		return 0
+	}
+	if p.Provider != "" {
+		score++
+	}
+	return score
+}
+
+// PriceOf 查找服务商和模型的价格，前缀匹配时取最长的前缀
+func (c OpenAIConfig) PriceOf(provider, model string) (ModelPrice, bool) {
+	var best ModelPrice
+	bestScore := 0
+	for _, price := range c.Prices {
+		score := price.matches(provider, model)
+		if score > bestScore || (score == bestScore && score > 0 && len(price.Model) > len(best.Model)) {
+			best, bestScore = price, score
+		}
+	}
+	return best, bestScore > 0
+}
+
+// validateBudget 验证价格表和预算
+func (c OpenAIConfig) validateBudget() error {
+	for _, price := range c.Prices {
+		if price.Model == "" {
+			return fmt.Errorf("价格表中的模型名称不能为空")
+		}
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("模型 %s 的价格不能为负数", price.Model)
+		}
+	}
+	if c.DailyBudget < 0 || c.MonthlyBudget < 0 {
+		return fmt.Errorf("费用预算不能为负数")
+	}
+	return nil
+}
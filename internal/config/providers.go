@@ -0,0 +1,413 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 服务商能力
+const (
+	CapabilityStream     = "stream"     // 流式输出
+	CapabilityTools      = "tools"      // 工具调用
+	CapabilityJSON       = "json"       // JSON输出模式
+	CapabilityEmbeddings = "embeddings" // 向量嵌入接口
+)
+
+// ProviderKind 服务商类型，决定默认地址、是否需要密钥和默认能力
+type ProviderKind struct {
+	Kind          string   `json:"kind"`
+	Label         string   `json:"label"`
+	BaseURL       string   `json:"baseUrl"`       // 默认API地址，为空时必须在服务商配置中填写
+	DefaultModel  string   `json:"defaultModel"`  // 推荐模型
+	Models        []string `json:"models"`        // 常用模型，供配置界面选择
+	RequireAPIKey bool     `json:"requireApiKey"` // 是否必须填写API密钥
+	Capabilities  []string `json:"capabilities"`  // 默认能力
+}
+
+// ProviderKinds 支持的服务商类型，除 azure、ollama、anthropic 和 gemini 外均使用 OpenAI 兼容接口
+var ProviderKinds = []ProviderKind{
+	{
+		Kind:          "openai",
+		Label:         "OpenAI",
+		BaseURL:       "https://api.openai.com/v1",
+		DefaultModel:  "gpt-4o",
+		Models:        []string{"gpt-4o", "gpt-4o-mini", "gpt-4-turbo", "gpt-3.5-turbo"},
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityTools, CapabilityJSON, CapabilityEmbeddings},
+	},
+	{
+		Kind:          "azure",
+		Label:         "Azure OpenAI",
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityTools, CapabilityJSON, CapabilityEmbeddings},
+	},
+	{
+		Kind:          "deepseek",
+		Label:         "DeepSeek",
+		BaseURL:       DefaultDeepSeekBaseURL,
+		DefaultModel:  "deepseek-chat",
+		Models:        []string{"deepseek-chat", "deepseek-reasoner"},
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityTools, CapabilityJSON},
+	},
+	{
+		Kind:          "qwen",
+		Label:         "通义千问",
+		BaseURL:       "https://dashscope.aliyuncs.com/compatible-mode/v1",
+		DefaultModel:  "qwen-plus",
+		Models:        []string{"qwen-turbo", "qwen-plus", "qwen-max"},
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityTools, CapabilityJSON, CapabilityEmbeddings},
+	},
+	{
+		Kind:          "moonshot",
+		Label:         "Moonshot (Kimi)",
+		BaseURL:       "https://api.moonshot.cn/v1",
+		DefaultModel:  "moonshot-v1-8k",
+		Models:        []string{"moonshot-v1-8k", "moonshot-v1-32k", "moonshot-v1-128k"},
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityTools, CapabilityJSON},
+	},
+	{
+		Kind:          "zhipu",
+		Label:         "智谱GLM",
+		BaseURL:       "https://open.bigmodel.cn/api/paas/v4",
+		DefaultModel:  "glm-4-flash",
+		Models:        []string{"glm-4-flash", "glm-4-air", "glm-4-plus"},
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityTools, CapabilityJSON, CapabilityEmbeddings},
+	},
+	{
+		Kind:          "anthropic",
+		Label:         "Anthropic (Claude)",
+		BaseURL:       "https://api.anthropic.com/v1",
+		DefaultModel:  "claude-sonnet-4-5",
+		Models:        []string{"claude-sonnet-4-5", "claude-haiku-4-5", "claude-opus-4-1"},
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityTools},
+	},
+	{
+		Kind:          "gemini",
+		Label:         "Google Gemini",
+		BaseURL:       "https://generativelanguage.googleapis.com/v1beta",
+		DefaultModel:  "gemini-2.5-flash",
+		Models:        []string{"gemini-2.5-flash", "gemini-2.5-pro", "gemini-2.0-flash"},
+		RequireAPIKey: true,
+		Capabilities:  []string{CapabilityStream, CapabilityJSON},
+	},
+	{
+		Kind:         "ollama",
+		Label:        "Ollama（本地模型）",
+		BaseURL:      "http://localhost:11434",
+		DefaultModel: "qwen2.5:7b",
+		Models:       []string{"qwen2.5:7b", "qwen2.5:14b", "llama3.1:8b", "gemma2:9b"},
+		Capabilities: []string{CapabilityStream, CapabilityJSON},
+	},
+	{
+		Kind:         "openai-compatible",
+		Label:        "OpenAI兼容接口",
+		Capabilities: []string{CapabilityStream},
+	},
+}
+
+// DefaultDeepSeekBaseURL DeepSeek 的默认API地址
+const DefaultDeepSeekBaseURL = "https://api.deepseek.com/v1"
+
+// LookupProviderKind 按名称查找服务商类型
+func LookupProviderKind(kind string) (ProviderKind, bool) {
+	for _, k := range ProviderKinds {
+		if k.Kind == kind {
+			return k, true
+		}
+	}
+	return ProviderKind{}, false
+}
+
+// ProviderProfile 一个命名的AI服务商配置，主服务商和备用服务商都按名称引用
+type ProviderProfile struct {
+	Name         string            `json:"name"`         // 名称，用于引用、日志、状态展示和消息记录
+	Kind         string            `json:"kind"`         // 服务商类型，见 ProviderKinds
+	BaseURL      string            `json:"baseUrl"`      // API基础URL，留空使用该类型的默认地址，Azure为端点
+	APIKey       string            `json:"apiKey"`       // API密钥
+	Headers      map[string]string `json:"headers"`      // 附加的请求头，例如网关鉴权
+	DefaultModel string            `json:"defaultModel"` // 默认模型，Azure为部署名称
+	Capabilities []string          `json:"capabilities"` // 支持的能力，留空使用该类型的默认能力
+
+	KeepAlive      string                 `json:"keepAlive,omitempty"`      // Ollama 模型在内存中的保留时间，例如 5m，纯数字按秒，-1表示一直保留
+	Options        map[string]interface{} `json:"options,omitempty"`        // 原样传给服务商的模型参数，例如 Ollama 的 temperature、num_ctx，Anthropic 的 max_tokens
+	SafetySettings map[string]string      `json:"safetySettings,omitempty"` // Gemini 安全设置，类别到拦截阈值，例如 HARM_CATEGORY_HARASSMENT: BLOCK_ONLY_HIGH
+}
+
+// GeminiSafetyThresholds Gemini 支持的安全拦截阈值
+var GeminiSafetyThresholds = []string{
+	"BLOCK_NONE",
+	"BLOCK_ONLY_HIGH",
+	"BLOCK_MEDIUM_AND_ABOVE",
+	"BLOCK_LOW_AND_ABOVE",
+	"OFF",
+}
+
+// Validate 验证服务商配置
+func (p ProviderProfile) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("服务商名称不能为空")
+	}
+	kind, ok := LookupProviderKind(p.Kind)
+	if !ok {
+		if p.Kind == "" {
+			return fmt.Errorf("%s的服务商类型不能为空", p.Name)
+		}
+		return fmt.Errorf("%s的服务商类型不支持: %s", p.Name, p.Kind)
+	}
+	if kind.RequireAPIKey && p.APIKey == "" {
+		return fmt.Errorf("%s的API Key不能为空", p.Name)
+	}
+
+	baseURL := p.EffectiveBaseURL()
+	if baseURL == "" {
+		return fmt.Errorf("%s的API地址不能为空", p.Name)
+	}
+	if !isValidURL(baseURL) {
+		return fmt.Errorf("%s的API地址格式无效: %s", p.Name, baseURL)
+	}
+	if p.DefaultModel == "" {
+		if p.Kind == "azure" {
+			return fmt.Errorf("%s的Azure部署名称不能为空", p.Name)
+		}
+		return fmt.Errorf("%s的默认模型不能为空", p.Name)
+	}
+	// Anthropic 的 max_tokens 为必填参数，配置了就必须是正整数
+	if p.Kind == "anthropic" {
+		if value, ok := p.Options["max_tokens"]; ok {
+			if n, isNumber := value.(float64); !isNumber || n < 1 || n != float64(int(n)) {
+				return fmt.Errorf("%s的max_tokens必须是正整数", p.Name)
+			}
+		}
+	}
+	if p.Kind == "gemini" {
+		for category, threshold := range p.SafetySettings {
+			if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+				return fmt.Errorf("%s的安全设置类别无效: %s", p.Name, category)
+			}
+			if !containsString(GeminiSafetyThresholds, threshold) {
+				return fmt.Errorf("%s的安全设置阈值无效: %s", p.Name, threshold)
+			}
+		}
+	}
+	return nil
+}
+
+// EffectiveBaseURL 获取实际使用的API地址
+func (p ProviderProfile) EffectiveBaseURL() string {
+	if p.BaseURL != "" {
+		return p.BaseURL
+	}
+	kind, _ := LookupProviderKind(p.Kind)
+	return kind.BaseURL
+}
+
+// Supports 是否支持指定能力，未配置能力时使用该类型的默认能力
+func (p ProviderProfile) Supports(capability string) bool {
+	capabilities := p.Capabilities
+	if capabilities == nil {
+		kind, _ := LookupProviderKind(p.Kind)
+		capabilities = kind.Capabilities
+	}
+	return containsString(capabilities, capability)
+}
+
+// FindProfile 按名称查找服务商配置
+func (c OpenAIConfig) FindProfile(name string) (ProviderProfile, bool) {
+	for _, profile := range c.Profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return ProviderProfile{}, false
+}
+
+// PrimaryModel 获取主服务商的默认模型
+func (c OpenAIConfig) PrimaryModel() string {
+	profile, _ := c.FindProfile(c.Profile)
+	return profile.DefaultModel
+}
+
+// Chain 获取故障转移链：主服务商在前，其后按顺序是备用服务商
+func (c OpenAIConfig) Chain() ([]ProviderProfile, error) {
+	if c.Profile == "" {
+		return nil, fmt.Errorf("未选择主服务商")
+	}
+	primary, ok := c.FindProfile(c.Profile)
+	if !ok {
+		return nil, fmt.Errorf("主服务商不存在: %s", c.Profile)
+	}
+
+	chain := []ProviderProfile{primary}
+	seen := map[string]bool{primary.Name: true}
+	for _, name := range c.Fallbacks {
+		if seen[name] {
+			continue
+		}
+		profile, ok := c.FindProfile(name)
+		if !ok {
+			return nil, fmt.Errorf("备用服务商不存在: %s", name)
+		}
+		seen[name] = true
+		chain = append(chain, profile)
+	}
+	return chain, nil
+}
+
+// Validate 验证服务商配置和故障转移链
+func (c OpenAIConfig) Validate() error {
+	if len(c.Profiles) == 0 {
+		return fmt.Errorf("请先添加AI服务商")
+	}
+	names := make(map[string]bool, len(c.Profiles))
+	for _, profile := range c.Profiles {
+		if profile.Name == "" {
+			return fmt.Errorf("服务商名称不能为空")
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("服务商名称重复: %s", profile.Name)
+		}
+		names[profile.Name] = true
+	}
+
+	// 只完整验证会被使用的服务商，未引用的配置可以暂时不完整
+	chain, err := c.Chain()
+	if err != nil {
+		return err
+	}
+	for _, profile := range chain {
+		if err := profile.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.validateBudget(); err != nil {
+		return err
+	}
+	if err := c.validateCache(); err != nil {
+		return err
+	}
+	return c.validateSearch()
+}
+
+// MigrateLegacyProvider 把旧版按服务商区分的配置字段迁移为同名的服务商配置
+// 原来选择的服务商设为主服务商，其余填写了密钥的服务商一并迁移；迁移后清空旧字段，返回是否发生了迁移
+func (c *OpenAIConfig) MigrateLegacyProvider() bool {
+	if c.Provider == "" {
+		return false
+	}
+
+	legacy := []ProviderProfile{c.legacyProfile(c.Provider)}
+	for _, kind := range []string{"openai", "azure", "deepseek"} {
+		if profile := c.legacyProfile(kind); kind != c.Provider && profile.APIKey != "" {
+			legacy = append(legacy, profile)
+		}
+	}
+
+	// 同名的服务商配置被旧配置覆盖，其余保留
+	profiles := make([]ProviderProfile, 0, len(c.Profiles)+len(legacy))
+	profiles = append(profiles, c.Profiles...)
+	for _, profile := range legacy {
+		replaced := false
+		for i := range profiles {
+			if profiles[i].Name == profile.Name {
+				profiles[i], replaced = profile, true
+			}
+		}
+		if !replaced {
+			profiles = append(profiles, profile)
+		}
+	}
+	c.Profiles = profiles
+	c.Profile = legacy[0].Name
+
+	c.Provider = ""
+	c.APIKey = ""
+	c.BaseURL = ""
+	c.Model = ""
+	c.AzureAPIKey = ""
+	c.AzureEndpoint = ""
+	c.AzureDeployment = ""
+	c.DeepSeekAPIKey = ""
+	c.DeepSeekBaseURL = ""
+	return true
+}
+
+// legacyProfile 从旧版配置字段生成指定类型的服务商配置，旧版的模型名称只属于当时选择的服务商
+func (c *OpenAIConfig) legacyProfile(kind string) ProviderProfile {
+	profile := ProviderProfile{
+		Name: kind,
+		Kind: kind,
+	}
+	if kind == c.Provider {
+		profile.DefaultModel = c.Model
+	}
+	switch kind {
+	case "azure":
+		profile.APIKey = c.AzureAPIKey
+		profile.BaseURL = c.AzureEndpoint
+		profile.DefaultModel = c.AzureDeployment
+	case "deepseek":
+		profile.APIKey = c.DeepSeekAPIKey
+		profile.BaseURL = c.DeepSeekBaseURL
+	default:
+		profile.APIKey = c.APIKey
+		profile.BaseURL = c.BaseURL
+	}
+	if spec, ok := LookupProviderKind(kind); ok {
+		if profile.BaseURL == spec.BaseURL {
+			profile.BaseURL = ""
+		}
+		if profile.DefaultModel == "" {
+			profile.DefaultModel = spec.DefaultModel
+		}
+	}
+	return profile
+}
+
+// setKindAPIKey 为指定类型的第一个服务商设置API密钥，没有该类型的服务商时按默认值创建
+func (c *OpenAIConfig) setKindAPIKey(kind, apiKey string) {
+	profiles := make([]ProviderProfile, len(c.Profiles))
+	copy(profiles, c.Profiles)
+	c.Profiles = profiles
+
+	for i := range c.Profiles {
+		if c.Profiles[i].Kind == kind {
+			c.Profiles[i].APIKey = apiKey
+			return
+		}
+	}
+	spec, _ := LookupProviderKind(kind)
+	c.Profiles = append(c.Profiles, ProviderProfile{
+		Name:         kind,
+		Kind:         kind,
+		APIKey:       apiKey,
+		DefaultModel: spec.DefaultModel,
+	})
+	if c.Profile == "" {
+		c.Profile = kind
+	}
+}
+
+// isValidURL 验证URL格式是否有效，只允许http和https
+func isValidURL(rawURL string) bool {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
+}
+
+// containsString 切片中是否包含指定字符串
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 联网搜索服务
+const (
+	SearchProviderSearxng = "searxng" // 自建的 SearxNG，需要在 settings.yml 中开启 json 输出格式
+	SearchProviderBing    = "bing"    // Bing Web Search API
+	SearchProviderCustom  = "custom"  // 返回JSON的通用搜索接口，按模板拼接地址和读取结果
+)
+
+// DefaultBingSearchURL Bing Web Search API 的默认地址
+const DefaultBingSearchURL = "https://api.bing.microsoft.com/v7.0/search"
+
+// 通用搜索接口地址模板中的占位符
+const (
+	SearchQueryPlaceholder  = "{query}"
+	SearchAPIKeyPlaceholder = "{apiKey}"
+)
+
+// SearchTemplate 通用搜索接口的响应格式，字段路径用点分隔，例如 data.items
+type SearchTemplate struct {
+	Results string `json:"results"` // 结果列表的路径，为空表示响应本身就是列表
+	Title   string `json:"title"`   // 结果中标题的路径，默认 title
+	URL     string `json:"url"`     // 结果中链接的路径，默认 url
+	Snippet string `json:"snippet"` // 结果中摘要的路径，默认 snippet
+}
+
+// SearchTriggered 问题是否命中需要先联网搜索再回答的规则
+func (c OpenAIConfig) SearchTriggered(query string) bool {
+	return matchRules(c.SearchTriggers, query)
+}
+
+// validateSearch 验证联网搜索的配置，未启用时只检查规则
+func (c OpenAIConfig) validateSearch() error {
+	if err := validateRules(c.SearchTriggers, "触发搜索的规则"); err != nil {
+		return err
+	}
+	if c.SearchMaxResults < 0 {
+		return fmt.Errorf("搜索结果条数不能为负数")
+	}
+	if !c.EnableSearch {
+		return nil
+	}
+
+	switch c.SearchProvider {
+	case SearchProviderSearxng:
+		if !isValidURL(c.SearchBaseURL) {
+			return fmt.Errorf("SearxNG 的地址无效: %s", c.SearchBaseURL)
+		}
+	case SearchProviderBing:
+		if c.SearchAPIKey == "" {
+			return fmt.Errorf("Bing 搜索需要配置API密钥")
+		}
+		if c.SearchBaseURL != "" && !isValidURL(c.SearchBaseURL) {
+			return fmt.Errorf("Bing 搜索的地址无效: %s", c.SearchBaseURL)
+		}
+	case SearchProviderCustom:
+		if !isValidURL(c.SearchBaseURL) || !strings.Contains(c.SearchBaseURL, SearchQueryPlaceholder) {
+			return fmt.Errorf("通用搜索接口的地址无效，需要包含 %s 占位符: %s", SearchQueryPlaceholder, c.SearchBaseURL)
+		}
+	default:
+		return fmt.Errorf("不支持的搜索服务: %s", c.SearchProvider)
+	}
+	return nil
+}
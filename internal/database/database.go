@@ -35,11 +35,33 @@ func Init(dbPath string) (*gorm.DB, error) {
 		&models.Memory{},
 		&models.ShortTermMemory{},
 		&models.LongTermMemory{},
+		&models.CustomCommand{},
+		&models.CustomCommandAction{},
+		&models.ToolInvocation{},
+		&models.Embedding{},
+		&models.PinnedFact{},
+		&models.Session{},
+		&models.UsageRecord{},
+		&models.CachedAnswer{},
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	// 消息和记忆的全文检索索引，文本写入前已切分为以空格分隔的词元
+	err = DB.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS memory_search USING fts5(
+		tokens,
+		text UNINDEXED,
+		kind UNINDEXED,
+		ref_id UNINDEXED,
+		room_id UNINDEXED,
+		owner_id UNINDEXED,
+		created_at UNINDEXED
+	)`).Error
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Info("数据库初始化成功")
 	return DB, nil
 }
@@ -0,0 +1,27 @@
+// Package databasetest 为测试提供独立的临时数据库
+package databasetest
+
+import (
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/pkg/logger"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Open 在测试的临时目录中初始化数据库并设为全局数据库，测试结束时自动关闭
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+	logger.Init()
+	db, err := database.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
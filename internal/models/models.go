@@ -23,6 +23,10 @@ type User struct {
 	ID                  string            `gorm:"type:char(36);primaryKey" json:"id"`
 	Name                string            `gorm:"not null" json:"name"`
 	Profile             string            `gorm:"not null" json:"profile"`
+	Role                string            `gorm:"index;not null;default:member" json:"role"` // 角色：bot 机器人，member 家庭成员
+	Nicknames           string            `gorm:"type:text" json:"nicknames"`                // 昵称，多个用逗号分隔，说"我是X"时按名称或昵称识别
+	Persona             string            `gorm:"type:text" json:"persona"`                  // 与该成员对话时机器人默认使用的人设，为空时使用机器人自己的简介
+	SpeechStyle         string            `json:"speechStyle"`                               // 与该成员对话时的播报方式：natural, plain, raw，为空时使用机器人的设置
 	Rooms               []Room            `gorm:"many2many:room_members;" json:"rooms,omitempty"`
 	Messages            []Message         `gorm:"foreignKey:SenderID" json:"messages,omitempty"`
 	Memories            []Memory          `gorm:"foreignKey:OwnerID" json:"memories,omitempty"`
@@ -32,6 +36,12 @@ type User struct {
 	UpdatedAt           time.Time         `json:"updatedAt"`
 }
 
+// 用户角色
+const (
+	UserRoleBot    = "bot"
+	UserRoleMember = "member"
+)
+
 // BeforeCreate GORM hook
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == "" {
@@ -70,11 +80,41 @@ type Message struct {
 	Sender    User      `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
 	RoomID    string    `gorm:"type:char(36);not null" json:"roomId"`
 	Room      Room      `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+	SessionID *int      `gorm:"index" json:"sessionId"` // 所属会话
+	Session   *Session  `gorm:"foreignKey:SessionID" json:"session,omitempty"`
+	Provider  string    `json:"provider,omitempty"`                   // 实际回答的AI服务商，仅机器人消息
+	Model     string    `json:"model,omitempty"`                      // 实际回答的模型，仅机器人消息
+	Reasoning string    `gorm:"type:text" json:"reasoning,omitempty"` // 思考模型的思考过程，仅机器人消息，不作为对话上下文
 	Memories  []Memory  `gorm:"foreignKey:MessageID" json:"memories,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// Session 对话会话：从唤醒或首次提问开始，到退出连续对话、空闲超时或服务停止结束
+type Session struct {
+	ID           int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	RoomID       string     `gorm:"type:char(36);index;not null" json:"roomId"`
+	Room         Room       `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+	DeviceID     string     `gorm:"index" json:"deviceId"`
+	KeepAlive    bool       `gorm:"not null" json:"keepAlive"` // 是否为连续对话
+	Model        string     `json:"model"`                     // 回答使用的AI模型
+	Turns        int        `gorm:"not null" json:"turns"`     // 用户提问次数
+	Participants []User     `gorm:"many2many:session_participants;" json:"participants,omitempty"`
+	Messages     []Message  `gorm:"foreignKey:SessionID" json:"messages,omitempty"`
+	StartedAt    time.Time  `gorm:"index;not null" json:"startedAt"`
+	LastActiveAt time.Time  `gorm:"not null" json:"lastActiveAt"`
+	EndedAt      *time.Time `json:"endedAt"`
+	EndReason    string     `json:"endReason,omitempty"` // 结束原因：exit, timeout, shutdown, import
+}
+
+// 会话结束原因
+const (
+	SessionEndExit     = "exit"     // 说出退出关键词
+	SessionEndTimeout  = "timeout"  // 空闲超时
+	SessionEndShutdown = "shutdown" // 服务停止
+	SessionEndImport   = "import"   // 从导出的对话记录导入
+)
+
 // Memory 记忆模型
 type Memory struct {
 	ID                  int               `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -116,4 +156,107 @@ type LongTermMemory struct {
 	Room      Room            `gorm:"foreignKey:RoomID" json:"room,omitempty"`
 	CreatedAt time.Time       `json:"createdAt"`
 	UpdatedAt time.Time       `json:"updatedAt"`
-} 
\ No newline at end of file
+} 
+
+// CustomCommand 用户自定义语音命令
+type CustomCommand struct {
+	ID          int                   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string                `gorm:"uniqueIndex;not null" json:"name"`
+	Description string                `json:"description"`
+	MatchType   string                `gorm:"not null" json:"matchType"` // 匹配方式：exact, contains, regex
+	Pattern     string                `gorm:"type:text;not null" json:"pattern"`
+	Priority    int                   `gorm:"not null" json:"priority"`
+	Enabled     bool                  `gorm:"not null" json:"enabled"`
+	Actions     []CustomCommandAction `gorm:"foreignKey:CommandID" json:"actions"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	UpdatedAt   time.Time             `json:"updatedAt"`
+}
+
+// CustomCommandAction 自定义命令的动作（按Position顺序执行）
+type CustomCommandAction struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	CommandID int       `gorm:"index;not null" json:"commandId"`
+	Position  int       `gorm:"not null" json:"position"`
+	Type      string    `gorm:"not null" json:"type"`            // 动作类型：speak, play_url, set_volume, directive, webhook, ask_llm
+	Text      string    `gorm:"type:text" json:"text,omitempty"` // 播报文本 / 原生指令 / 提示词模板
+	URL       string    `json:"url,omitempty"`                   // 播放地址 / Webhook地址
+	Volume    int       `json:"volume,omitempty"`                // 音量（0-100）
+	Method    string    `json:"method,omitempty"`                // Webhook请求方法
+	Body      string    `gorm:"type:text" json:"body,omitempty"` // Webhook请求体模板
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ToolInvocation AI工具调用记录
+type ToolInvocation struct {
+	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID  *int      `gorm:"index" json:"messageId"`          // 关联的AI回复消息
+	Query      string    `gorm:"type:text" json:"query"`          // 触发调用的用户问题
+	Round      int       `gorm:"not null" json:"round"`           // 第几轮工具调用
+	CallID     string    `json:"callId"`                          // 模型生成的调用ID
+	Name       string    `gorm:"index;not null" json:"name"`      // 工具名称
+	Arguments  string    `gorm:"type:text" json:"arguments"`      // 调用参数（JSON）
+	Result     string    `gorm:"type:text" json:"result"`         // 执行结果
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Embedding 消息或记忆摘要的向量嵌入
+type Embedding struct {
+	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind       string    `gorm:"uniqueIndex:idx_embedding_ref;not null" json:"kind"`  // 来源类型：message, short, long
+	RefID      int       `gorm:"uniqueIndex:idx_embedding_ref;not null" json:"refId"` // 来源消息或记忆的ID
+	RoomID     string    `gorm:"type:char(36);index;not null" json:"roomId"`
+	OwnerID    *string   `gorm:"type:char(36)" json:"ownerId"`
+	Text       string    `gorm:"type:text;not null" json:"text"`
+	Model      string    `gorm:"index;not null" json:"model"`                         // 生成向量的嵌入模型
+	Dimensions int       `gorm:"not null" json:"dimensions"`
+	Vector     []byte    `gorm:"not null" json:"-"`                                   // float32 小端序编码
+	CreatedAt  time.Time `json:"createdAt"`                                           // 来源的创建时间
+}
+
+// PinnedFact 用户要求记住的事实，不参与摘要整理，始终注入提示词
+type PinnedFact struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Text      string    `gorm:"type:text;not null" json:"text"`
+	OwnerID   *string   `gorm:"type:char(36);index" json:"ownerId"`
+	Owner     *User     `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
+	RoomID    string    `gorm:"type:char(36);index;not null" json:"roomId"`
+	Room      Room      `gorm:"foreignKey:RoomID" json:"room,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// UsageRecord 一次AI模型调用的令牌用量、耗时和费用
+type UsageRecord struct {
+	ID               int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Provider         string    `gorm:"index;not null" json:"provider"` // 服务商名称
+	Model            string    `gorm:"index;not null" json:"model"`
+	Purpose          string    `gorm:"index" json:"purpose"`           // 调用用途：chat, memory, command, test
+	DeviceID         string    `gorm:"index" json:"deviceId"`
+	UserID           string    `gorm:"index" json:"userId"`            // 发起请求的家庭成员
+	PromptTokens     int       `gorm:"not null" json:"promptTokens"`
+	CompletionTokens int       `gorm:"not null" json:"completionTokens"`
+	TotalTokens      int       `gorm:"not null" json:"totalTokens"`
+	Estimated        bool      `gorm:"not null" json:"estimated"`      // 服务商未返回用量，按文本长度估算
+	LatencyMs        int64     `gorm:"not null" json:"latencyMs"`
+	Cost             float64   `gorm:"not null" json:"cost"`           // 按调用时的价格表计算，未配置价格时为0
+	Day              string    `gorm:"size:10;index;not null" json:"day"` // 调用日期（本地时间，2006-01-02），用于按天汇总
+	CreatedAt        time.Time `gorm:"index" json:"createdAt"`
+}
+
+// CachedAnswer 缓存的AI回答，相同的问题、人设和模型直接使用
+type CachedAnswer struct {
+	Key       string    `gorm:"primaryKey;size:64" json:"key"`         // 归一化问题、人设、模型、提问成员和房间的 SHA-256
+	Query     string    `gorm:"type:text;not null" json:"query"`       // 归一化后的问题
+	Persona   string    `gorm:"type:text" json:"persona"`
+	Model     string    `gorm:"index" json:"model"`
+	OwnerID   string    `gorm:"index" json:"ownerId"`                  // 提问的家庭成员
+	RoomID    string    `json:"roomId"`
+	Answer    string    `gorm:"type:text;not null" json:"answer"`
+	Hits      int       `gorm:"not null;default:0" json:"hits"`        // 命中次数
+	UsedAt    time.Time `gorm:"index" json:"usedAt"`                   // 最近一次写入或命中的时间，超出容量时淘汰最久未使用的
+	ExpiresAt time.Time `gorm:"index" json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/pkg/logger"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 未配置时的默认有效期和容量
+const (
+	defaultTTL        = 7 * 24 * time.Hour
+	defaultMaxEntries = 1000
+)
+
+// trailingParticles 问题末尾不影响含义的语气词，归一化时去掉
+const trailingParticles = "呢呀啊吧嘛哇"
+
+// 自启动以来的缓存统计，音箱服务重启后保留
+var (
+	hits     atomic.Int64
+	misses   atomic.Int64
+	bypassed atomic.Int64
+	stored   atomic.Int64
+	evicted  atomic.Int64
+)
+
+// Stats 回答缓存的统计
+type Stats struct {
+	Enabled  bool    `json:"enabled"`
+	Entries  int64   `json:"entries"`  // 当前缓存的回答数（含已过期未清理的）
+	Hits     int64   `json:"hits"`     // 自启动以来命中的次数
+	Misses   int64   `json:"misses"`   // 自启动以来未命中的次数
+	Bypassed int64   `json:"bypassed"` // 自启动以来因时效性跳过缓存的次数
+	Stored   int64   `json:"stored"`   // 自启动以来写入的回答数
+	Evicted  int64   `json:"evicted"`  // 自启动以来因过期或超出容量淘汰的回答数
+	HitRate  float64 `json:"hitRate"`  // 命中率，未查询过时为0
+}
+
+// Store 保存在数据库中的回答缓存，实现 openai.AnswerCache
+// 开关、有效期、容量和跳过规则每次读取 cfg，修改后立即生效
+type Store struct {
+	cfg *config.Config
+}
+
+// NewStore 创建回答缓存
+func NewStore(cfg *config.Config) *Store {
+	return &Store{cfg: cfg}
+}
+
+// Get 查找未过期的缓存回答，命中时更新命中次数和使用时间
+func (s *Store) Get(key openai.CacheKey) (string, bool) {
+	db := database.GetDB()
+	if db == nil || !s.cfg.OpenAI.EnableCache {
+		return "", false
+	}
+	if s.cfg.OpenAI.CacheBypassed(key.Query) {
+		bypassed.Add(1)
+		logger.Debugf("时效性问题，跳过回答缓存: %s", key.Query)
+		return "", false
+	}
+
+	now := time.Now()
+	var entry models.CachedAnswer
+	err := db.Where("key = ? AND expires_at > ?", Key(key), now).Take(&entry).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warnf("读取回答缓存失败: %v", err)
+		}
+		misses.Add(1)
+		return "", false
+	}
+
+	hits.Add(1)
+	err = db.Model(&entry).Updates(map[string]interface{}{
+		"hits":    gorm.Expr("hits + 1"),
+		"used_at": now,
+	}).Error
+	if err != nil {
+		logger.Warnf("更新回答缓存失败: %v", err)
+	}
+	return entry.Answer, true
+}
+
+// Put 缓存回答，同时清理过期的回答并按容量淘汰最久未使用的
+func (s *Store) Put(key openai.CacheKey, answer string) {
+	db := database.GetDB()
+	cfg := s.cfg.OpenAI
+	if db == nil || !cfg.EnableCache || cfg.CacheBypassed(key.Query) {
+		return
+	}
+
+	ttl := time.Duration(cfg.CacheTTLHours) * time.Hour
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	maxEntries := cfg.CacheMaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+
+	now := time.Now()
+	entry := models.CachedAnswer{
+		Key:       Key(key),
+		Query:     Normalize(key.Query),
+		Persona:   key.Persona,
+		Model:     key.Model,
+		OwnerID:   key.OwnerID,
+		RoomID:    key.RoomID,
+		Answer:    answer,
+		UsedAt:    now,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	// 同一个键重新写入时视为新回答
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"answer", "hits", "used_at", "expires_at", "created_at"}),
+	}).Create(&entry).Error
+	if err != nil {
+		logger.Warnf("保存回答缓存失败: %v", err)
+		return
+	}
+	stored.Add(1)
+
+	if err := evict(db, now, maxEntries); err != nil {
+		logger.Warnf("%v", err)
+	}
+}
+
+// evict 删除过期的回答，超出容量时删除最久未使用的
+func evict(db *gorm.DB, now time.Time, maxEntries int) error {
+	result := db.Where("expires_at <= ?", now).Delete(&models.CachedAnswer{})
+	if result.Error != nil {
+		return fmt.Errorf("清理过期的回答缓存失败: %v", result.Error)
+	}
+	evicted.Add(result.RowsAffected)
+
+	var count int64
+	if err := db.Model(&models.CachedAnswer{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("统计回答缓存失败: %v", err)
+	}
+	if count <= int64(maxEntries) {
+		return nil
+	}
+
+	oldest := db.Model(&models.CachedAnswer{}).Select("key").Order("used_at ASC").Limit(int(count) - maxEntries)
+	result = db.Where("key IN (?)", oldest).Delete(&models.CachedAnswer{})
+	if result.Error != nil {
+		return fmt.Errorf("淘汰回答缓存失败: %v", result.Error)
+	}
+	evicted.Add(result.RowsAffected)
+	return nil
+}
+
+// Normalize 归一化问题：忽略大小写、全半角、空白、标点和末尾的语气词
+func Normalize(query string) string {
+	var builder strings.Builder
+	for _, r := range query {
+		// 全角字符转为半角
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
+	return strings.TrimRight(builder.String(), trailingParticles)
+}
+
+// Key 缓存键：归一化的问题、人设、模型、提问成员和房间的 SHA-256
+func Key(key openai.CacheKey) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{Normalize(key.Query), key.Persona, key.Model, key.OwnerID, key.RoomID}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetStats 获取回答缓存的统计
+func GetStats(db *gorm.DB, cfg config.OpenAIConfig) (Stats, error) {
+	stats := Stats{
+		Enabled:  cfg.EnableCache,
+		Hits:     hits.Load(),
+		Misses:   misses.Load(),
+		Bypassed: bypassed.Load(),
+		Stored:   stored.Load(),
+		Evicted:  evicted.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	if err := db.Model(&models.CachedAnswer{}).Count(&stats.Entries).Error; err != nil {
+		return stats, fmt.Errorf("统计回答缓存失败: %v", err)
+	}
+	return stats, nil
+}
+
+// List 分页列出缓存的回答，最近使用的在前，keyword 匹配问题或回答
+func List(db *gorm.DB, keyword string, page, pageSize int) ([]models.CachedAnswer, int64, error) {
+	query := db.Model(&models.CachedAnswer{})
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		like := "%" + keyword + "%"
+		query = query.Where("query LIKE ? OR answer LIKE ?", like, like)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计回答缓存失败: %v", err)
+	}
+	var entries []models.CachedAnswer
+	err := query.Order("used_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&entries).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取回答缓存失败: %v", err)
+	}
+	return entries, total, nil
+}
+
+// Delete 删除一条缓存的回答
+func Delete(db *gorm.DB, key string) error {
+	result := db.Where("key = ?", key).Delete(&models.CachedAnswer{})
+	if result.Error != nil {
+		return fmt.Errorf("删除回答缓存失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteOwner 删除家庭成员的全部缓存回答，成员记住或遗忘事情后之前的回答可能不再适用
+func DeleteOwner(db *gorm.DB, ownerID string) (int64, error) {
+	result := db.Where("owner_id = ?", ownerID).Delete(&models.CachedAnswer{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("删除回答缓存失败: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// Purge 清空回答缓存，expiredOnly 时只删除已过期的，返回删除的条数
+func Purge(db *gorm.DB, expiredOnly bool, now time.Time) (int64, error) {
+	query := db.Session(&gorm.Session{AllowGlobalUpdate: true})
+	if expiredOnly {
+		query = query.Where("expires_at <= ?", now)
+	}
+	result := query.Delete(&models.CachedAnswer{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清空回答缓存失败: %v", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/database/databasetest"
+	"mi-gpt-go/internal/services/openai"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	databasetest.Open(t)
+	cfg := &config.Config{}
+	cfg.OpenAI.EnableCache = true
+	return NewStore(cfg)
+}
+
+func TestCacheIsScopedToOwnerAndRoom(t *testing.T) {
+	store := newTestStore(t)
+	alice := openai.CacheKey{Query: "我适合什么运动？", Persona: "傻妞", Model: "gpt", OwnerID: "alice", RoomID: "living-room"}
+	store.Put(alice, "你膝盖不好，适合游泳")
+
+	if answer, ok := store.Get(alice); !ok || answer != "你膝盖不好，适合游泳" {
+		t.Fatalf("同一成员应命中缓存，实际 %q, %v", answer, ok)
+	}
+	for _, key := range []openai.CacheKey{
+		{Query: alice.Query, Persona: alice.Persona, Model: alice.Model, OwnerID: "bob", RoomID: alice.RoomID},
+		{Query: alice.Query, Persona: alice.Persona, Model: alice.Model, OwnerID: alice.OwnerID, RoomID: "bedroom"},
+	} {
+		if answer, ok := store.Get(key); ok {
+			t.Errorf("成员 %s 在房间 %s 命中了其他人的缓存: %q", key.OwnerID, key.RoomID, answer)
+		}
+	}
+}
+
+func TestDeleteOwner(t *testing.T) {
+	store := newTestStore(t)
+	alice := openai.CacheKey{Query: "我明天要做什么", Model: "gpt", OwnerID: "alice", RoomID: "living-room"}
+	bob := openai.CacheKey{Query: "我明天要做什么", Model: "gpt", OwnerID: "bob", RoomID: "living-room"}
+	store.Put(alice, "去医院复查")
+	store.Put(bob, "参加面试")
+
+	deleted, err := DeleteOwner(database.GetDB(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Errorf("期望删除1条缓存，实际 %d 条", deleted)
+	}
+	if _, ok := store.Get(alice); ok {
+		t.Errorf("删除后仍命中 alice 的缓存")
+	}
+	if _, ok := store.Get(bob); !ok {
+		t.Errorf("不应删除 bob 的缓存")
+	}
+}
@@ -55,6 +55,15 @@ func (s *DBConfigService) LoadConfig() (*config.Config, error) {
 		return s.defaultConfig, err
 	}
 
+	// 旧版按服务商区分的配置迁移为服务商配置
+	if cfg.OpenAI.MigrateLegacyProvider() {
+		if err := s.SaveConfig(cfg); err != nil {
+			logger.Warnf("保存迁移后的服务商配置失败: %v", err)
+		} else {
+			logger.Infof("已把旧版AI服务配置迁移为服务商配置: %s", cfg.OpenAI.Profile)
+		}
+	}
+
 	logger.Info("成功从数据库加载配置")
 	return cfg, nil
 }
@@ -154,12 +163,39 @@ func (s *DBConfigService) convertFromConfig(cfg *config.Config) ([]models.Config
 
 	// AI配置
 	items = append(items, s.createConfigItems("ai", map[string]interface{}{
-		"ai.provider":             cfg.OpenAI.Provider,
+		"ai.profiles":            cfg.OpenAI.Profiles,
+		"ai.profile":             cfg.OpenAI.Profile,
+		"ai.fallbacks":           cfg.OpenAI.Fallbacks,
+		"ai.breakerFailures":     cfg.OpenAI.BreakerFailures,
+		"ai.breakerResetSeconds": cfg.OpenAI.BreakerResetSeconds,
+		"ai.proxyURL":            cfg.OpenAI.ProxyURL,
+		"ai.enableSearch":        cfg.OpenAI.EnableSearch,
+		"ai.enableTools":         cfg.OpenAI.EnableTools,
+		"ai.enableEmbedding":     cfg.OpenAI.EnableEmbedding,
+		"ai.embeddingModel":      cfg.OpenAI.EmbeddingModel,
+		"ai.embeddingDimensions": cfg.OpenAI.EmbeddingDimensions,
+		"ai.embeddingBaseURL":    cfg.OpenAI.EmbeddingBaseURL,
+		"ai.embeddingAPIKey":     cfg.OpenAI.EmbeddingAPIKey,
+		"ai.azureEmbeddingDeployment": cfg.OpenAI.AzureEmbeddingDeployment,
+		"ai.prices":              cfg.OpenAI.Prices,
+		"ai.dailyBudget":         cfg.OpenAI.DailyBudget,
+		"ai.monthlyBudget":       cfg.OpenAI.MonthlyBudget,
+		"ai.budgetModel":         cfg.OpenAI.BudgetModel,
+		"ai.enableCache":         cfg.OpenAI.EnableCache,
+		"ai.cacheTtlHours":       cfg.OpenAI.CacheTTLHours,
+		"ai.cacheMaxEntries":     cfg.OpenAI.CacheMaxEntries,
+		"ai.cacheBypass":         cfg.OpenAI.CacheBypass,
+		"ai.searchProvider":      cfg.OpenAI.SearchProvider,
+		"ai.searchBaseUrl":       cfg.OpenAI.SearchBaseURL,
+		"ai.searchApiKey":        cfg.OpenAI.SearchAPIKey,
+		"ai.searchTemplate":      cfg.OpenAI.SearchTemplate,
+		"ai.searchMaxResults":    cfg.OpenAI.SearchMaxResults,
+		"ai.searchTriggers":      cfg.OpenAI.SearchTriggers,
+		// 旧版服务商配置，迁移后为空
+		"ai.provider":            cfg.OpenAI.Provider,
 		"ai.apiKey":              cfg.OpenAI.APIKey,
 		"ai.baseURL":             cfg.OpenAI.BaseURL,
 		"ai.model":               cfg.OpenAI.Model,
-		"ai.proxyURL":            cfg.OpenAI.ProxyURL,
-		"ai.enableSearch":        cfg.OpenAI.EnableSearch,
 		"ai.azureAPIKey":         cfg.OpenAI.AzureAPIKey,
 		"ai.azureEndpoint":       cfg.OpenAI.AzureEndpoint,
 		"ai.azureDeployment":     cfg.OpenAI.AzureDeployment,
@@ -180,8 +216,10 @@ func (s *DBConfigService) convertFromConfig(cfg *config.Config) ([]models.Config
 		"speaker.onEnterAI":             cfg.Speaker.OnEnterAI,
 		"speaker.onExitAI":              cfg.Speaker.OnExitAI,
 		"speaker.onAIAsking":            cfg.Speaker.OnAIAsking,
+		"speaker.onAIThinking":          cfg.Speaker.OnAIThinking,
 		"speaker.onAIReplied":           cfg.Speaker.OnAIReplied,
 		"speaker.onAIError":             cfg.Speaker.OnAIError,
+		"speaker.onBudgetExceeded":      cfg.Speaker.OnBudgetExceeded,
 		"speaker.streamResponse":        cfg.Speaker.StreamResponse,
 		"speaker.enableAudioLog":        cfg.Speaker.EnableAudioLog,
 		"speaker.keepAlive":             cfg.Speaker.KeepAlive,
@@ -213,6 +251,27 @@ func (s *DBConfigService) convertFromConfig(cfg *config.Config) ([]models.Config
 		"bot.master.profile":     cfg.Bot.Master.Profile,
 		"bot.room.name":          cfg.Bot.Room.Name,
 		"bot.room.description":   cfg.Bot.Room.Description,
+		"bot.contextTurns":       cfg.Bot.ContextTurns,
+		"bot.contextMaxTokens":   cfg.Bot.ContextMaxTokens,
+		"bot.contextWindow":      cfg.Bot.ContextWindow,
+		"bot.identityTimeout":    cfg.Bot.IdentityTimeout,
+		"bot.speechStyle":        cfg.Bot.SpeechStyle,
+		"bot.speechUrlText":      cfg.Bot.SpeechURLText,
+		"bot.enableMemory":         cfg.Bot.EnableMemory,
+		"bot.shortTermMemoryEvery": cfg.Bot.ShortTermMemoryEvery,
+		"bot.longTermMemoryAfter":  cfg.Bot.LongTermMemoryAfter,
+		"bot.memoryRecallLimit":    cfg.Bot.MemoryRecallLimit,
+		"bot.memoryRecallTokens":   cfg.Bot.MemoryRecallTokens,
+		"bot.messageRetentionDays":     cfg.Bot.MessageRetentionDays,
+		"bot.memoryRetentionDays":      cfg.Bot.MemoryRetentionDays,
+		"bot.shortTermRetentionDays":   cfg.Bot.ShortTermRetentionDays,
+		"bot.longTermRetentionDays":    cfg.Bot.LongTermRetentionDays,
+		"bot.maintenanceIntervalHours": cfg.Bot.MaintenanceIntervalHours,
+	})...)
+
+	// 插件配置
+	items = append(items, s.createConfigItems("plugins", map[string]interface{}{
+		"plugins.list": cfg.Plugins,
 	})...)
 
 	// 数据库配置
@@ -250,6 +309,8 @@ func (s *DBConfigService) convertValue(value interface{}) (string, string) {
 		return strconv.FormatBool(v), "bool"
 	case int:
 		return strconv.Itoa(v), "int"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), "float"
 	case []string:
 		jsonBytes, _ := json.Marshal(v)
 		return string(jsonBytes), "array"
@@ -275,6 +336,8 @@ func (s *DBConfigService) setConfigField(cfg *config.Config, key, value string)
 		return s.setBotField(cfg, parts[1:], value)
 	case "database":
 		return s.setDatabaseField(cfg, parts[1:], value)
+	case "plugins":
+		return s.setPluginsField(cfg, parts[1:], value)
 	default:
 		return fmt.Errorf("未知的配置分组: %s", parts[0])
 	}
@@ -301,16 +364,114 @@ func (s *DBConfigService) setAIField(cfg *config.Config, parts []string, value s
 		if b, err := strconv.ParseBool(value); err == nil {
 			cfg.OpenAI.EnableSearch = b
 		}
+	case "enableTools":
+		if b, err := strconv.ParseBool(value); err == nil {
+			cfg.OpenAI.EnableTools = b
+		}
 	case "azureAPIKey":
 		cfg.OpenAI.AzureAPIKey = value
 	case "azureEndpoint":
 		cfg.OpenAI.AzureEndpoint = value
 	case "azureDeployment":
 		cfg.OpenAI.AzureDeployment = value
+	case "enableEmbedding":
+		if b, err := strconv.ParseBool(value); err == nil {
+			cfg.OpenAI.EnableEmbedding = b
+		}
+	case "embeddingModel":
+		cfg.OpenAI.EmbeddingModel = value
+	case "embeddingDimensions":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.OpenAI.EmbeddingDimensions = i
+		}
+	case "embeddingBaseURL":
+		cfg.OpenAI.EmbeddingBaseURL = value
+	case "embeddingAPIKey":
+		cfg.OpenAI.EmbeddingAPIKey = value
+	case "azureEmbeddingDeployment":
+		cfg.OpenAI.AzureEmbeddingDeployment = value
 	case "deepSeekAPIKey":
 		cfg.OpenAI.DeepSeekAPIKey = value
 	case "deepSeekBaseURL":
 		cfg.OpenAI.DeepSeekBaseURL = value
+	case "profiles":
+		var profiles []config.ProviderProfile
+		if err := json.Unmarshal([]byte(value), &profiles); err != nil {
+			return fmt.Errorf("解析服务商配置失败: %v", err)
+		}
+		cfg.OpenAI.Profiles = profiles
+	case "profile":
+		cfg.OpenAI.Profile = value
+	case "fallbacks":
+		var fallbacks []string
+		if err := json.Unmarshal([]byte(value), &fallbacks); err != nil {
+			return fmt.Errorf("解析备用服务商配置失败: %v", err)
+		}
+		cfg.OpenAI.Fallbacks = fallbacks
+	case "breakerFailures":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.OpenAI.BreakerFailures = i
+		}
+	case "breakerResetSeconds":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.OpenAI.BreakerResetSeconds = i
+		}
+	case "prices":
+		var prices []config.ModelPrice
+		if err := json.Unmarshal([]byte(value), &prices); err != nil {
+			return fmt.Errorf("解析模型价格表失败: %v", err)
+		}
+		cfg.OpenAI.Prices = prices
+	case "dailyBudget":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			cfg.OpenAI.DailyBudget = f
+		}
+	case "monthlyBudget":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			cfg.OpenAI.MonthlyBudget = f
+		}
+	case "budgetModel":
+		cfg.OpenAI.BudgetModel = value
+	case "enableCache":
+		if b, err := strconv.ParseBool(value); err == nil {
+			cfg.OpenAI.EnableCache = b
+		}
+	case "cacheTtlHours":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.OpenAI.CacheTTLHours = i
+		}
+	case "cacheMaxEntries":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.OpenAI.CacheMaxEntries = i
+		}
+	case "cacheBypass":
+		var rules []string
+		if err := json.Unmarshal([]byte(value), &rules); err != nil {
+			return fmt.Errorf("解析缓存跳过规则失败: %v", err)
+		}
+		cfg.OpenAI.CacheBypass = rules
+	case "searchProvider":
+		cfg.OpenAI.SearchProvider = value
+	case "searchBaseUrl":
+		cfg.OpenAI.SearchBaseURL = value
+	case "searchApiKey":
+		cfg.OpenAI.SearchAPIKey = value
+	case "searchTemplate":
+		var template config.SearchTemplate
+		if err := json.Unmarshal([]byte(value), &template); err != nil {
+			return fmt.Errorf("解析搜索接口响应格式失败: %v", err)
+		}
+		cfg.OpenAI.SearchTemplate = template
+	case "searchMaxResults":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.OpenAI.SearchMaxResults = i
+		}
+	case "searchTriggers":
+		var rules []string
+		if err := json.Unmarshal([]byte(value), &rules); err != nil {
+			return fmt.Errorf("解析搜索触发规则失败: %v", err)
+		}
+		cfg.OpenAI.SearchTriggers = rules
 	default:
 		return fmt.Errorf("未知的AI配置字段: %s", parts[0])
 	}
@@ -367,6 +528,11 @@ func (s *DBConfigService) setSpeakerField(cfg *config.Config, parts []string, va
 		if err := json.Unmarshal([]byte(value), &messages); err == nil {
 			cfg.Speaker.OnAIAsking = messages
 		}
+	case "onAIThinking":
+		var messages []string
+		if err := json.Unmarshal([]byte(value), &messages); err == nil {
+			cfg.Speaker.OnAIThinking = messages
+		}
 	case "onAIReplied":
 		var messages []string
 		if err := json.Unmarshal([]byte(value), &messages); err == nil {
@@ -377,6 +543,11 @@ func (s *DBConfigService) setSpeakerField(cfg *config.Config, parts []string, va
 		if err := json.Unmarshal([]byte(value), &messages); err == nil {
 			cfg.Speaker.OnAIError = messages
 		}
+	case "onBudgetExceeded":
+		var messages []string
+		if err := json.Unmarshal([]byte(value), &messages); err == nil {
+			cfg.Speaker.OnBudgetExceeded = messages
+		}
 	case "streamResponse":
 		if b, err := strconv.ParseBool(value); err == nil {
 			cfg.Speaker.StreamResponse = b
@@ -486,6 +657,64 @@ func (s *DBConfigService) setBotField(cfg *config.Config, parts []string, value
 				cfg.Bot.Room.Description = value
 			}
 		}
+	case "contextTurns":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.ContextTurns = i
+		}
+	case "contextMaxTokens":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.ContextMaxTokens = i
+		}
+	case "contextWindow":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.ContextWindow = i
+		}
+	case "identityTimeout":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.IdentityTimeout = i
+		}
+	case "speechStyle":
+		cfg.Bot.SpeechStyle = value
+	case "speechUrlText":
+		cfg.Bot.SpeechURLText = value
+	case "enableMemory":
+		cfg.Bot.EnableMemory = value == "true"
+	case "shortTermMemoryEvery":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.ShortTermMemoryEvery = i
+		}
+	case "longTermMemoryAfter":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.LongTermMemoryAfter = i
+		}
+	case "memoryRecallLimit":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.MemoryRecallLimit = i
+		}
+	case "memoryRecallTokens":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.MemoryRecallTokens = i
+		}
+	case "messageRetentionDays":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.MessageRetentionDays = i
+		}
+	case "memoryRetentionDays":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.MemoryRetentionDays = i
+		}
+	case "shortTermRetentionDays":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.ShortTermRetentionDays = i
+		}
+	case "longTermRetentionDays":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.LongTermRetentionDays = i
+		}
+	case "maintenanceIntervalHours":
+		if i, err := strconv.Atoi(value); err == nil {
+			cfg.Bot.MaintenanceIntervalHours = i
+		}
 	default:
 		return fmt.Errorf("未知的机器人配置字段: %s", parts[0])
 	}
@@ -509,4 +738,23 @@ func (s *DBConfigService) setDatabaseField(cfg *config.Config, parts []string, v
 		return fmt.Errorf("未知的数据库配置字段: %s", parts[0])
 	}
 	return nil
-} 
\ No newline at end of file
+}
+
+// setPluginsField 设置插件配置字段
+func (s *DBConfigService) setPluginsField(cfg *config.Config, parts []string, value string) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("插件配置字段名为空")
+	}
+
+	switch parts[0] {
+	case "list":
+		var plugins []config.PluginConfig
+		if err := json.Unmarshal([]byte(value), &plugins); err != nil {
+			return fmt.Errorf("解析插件配置失败: %v", err)
+		}
+		cfg.Plugins = plugins
+	default:
+		return fmt.Errorf("未知的插件配置字段: %s", parts[0])
+	}
+	return nil
+}
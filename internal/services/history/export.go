@@ -0,0 +1,168 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mi-gpt-go/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 导出格式
+const (
+	FormatJSONL    = "jsonl" // 每行一条JSON记录，可导入
+	FormatCSV      = "csv"   // 表格，可导入
+	FormatMarkdown = "md"    // 按会话分段的对话文本，仅用于阅读
+)
+
+// exportBatchSize 导出时每批读取的消息数
+const exportBatchSize = 500
+
+// csvHeader CSV的列，导入时按列名读取
+var csvHeader = []string{
+	"id", "createdAt", "roomId", "roomName", "senderId", "senderName", "senderRole", "sessionId", "deviceId", "text",
+	"provider", "model", "reasoning",
+}
+
+// recordWriter 按格式写出对话记录
+type recordWriter interface {
+	Write(record Record) error
+	Flush() error
+}
+
+// ContentType 获取导出格式的MIME类型，不支持的格式返回错误
+func ContentType(format string) (string, error) {
+	switch format {
+	case FormatJSONL:
+		return "application/x-ndjson; charset=utf-8", nil
+	case FormatCSV:
+		return "text/csv; charset=utf-8", nil
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8", nil
+	default:
+		return "", fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// Export 按时间顺序导出符合条件的全部对话记录，忽略分页条件，返回导出的条数
+func Export(db *gorm.DB, w io.Writer, format string, filter Filter) (int, error) {
+	var writer recordWriter
+	switch format {
+	case FormatJSONL:
+		writer = &jsonlWriter{encoder: json.NewEncoder(w)}
+	case FormatCSV:
+		// 写入BOM，便于表格软件识别UTF-8编码
+		if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+			return 0, err
+		}
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write(csvHeader); err != nil {
+			return 0, err
+		}
+		writer = &csvRecordWriter{writer: csvWriter}
+	case FormatMarkdown:
+		if _, err := io.WriteString(w, "# 对话记录\n"); err != nil {
+			return 0, err
+		}
+		writer = &markdownWriter{w: w}
+	default:
+		return 0, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+
+	count := 0
+	var messages []models.Message
+	result := preloadQuery(filterQuery(db, filter)).Order("id ASC").
+		FindInBatches(&messages, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			for i := range messages {
+				if err := writer.Write(toRecord(&messages[i])); err != nil {
+					return err
+				}
+				count++
+			}
+			return writer.Flush()
+		})
+	if result.Error != nil {
+		return count, fmt.Errorf("导出对话记录失败: %v", result.Error)
+	}
+	return count, writer.Flush()
+}
+
+// jsonlWriter 写出JSON Lines
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(record Record) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return nil
+}
+
+// csvRecordWriter 写出CSV
+type csvRecordWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvRecordWriter) Write(record Record) error {
+	sessionID := ""
+	if record.SessionID != nil {
+		sessionID = strconv.Itoa(*record.SessionID)
+	}
+	return w.writer.Write([]string{
+		strconv.Itoa(record.ID),
+		record.CreatedAt.Format(time.RFC3339Nano),
+		record.RoomID,
+		record.RoomName,
+		record.SenderID,
+		record.SenderName,
+		record.SenderRole,
+		sessionID,
+		record.DeviceID,
+		record.Text,
+		record.Provider,
+		record.Model,
+		record.Reasoning,
+	})
+}
+
+func (w *csvRecordWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// markdownWriter 写出对话文本，每个会话一段，没有会话的消息按房间和日期分段
+type markdownWriter struct {
+	w       io.Writer
+	section string
+}
+
+func (w *markdownWriter) Write(record Record) error {
+	section := fmt.Sprintf("%s/%s", record.RoomID, record.CreatedAt.Format("2006-01-02"))
+	title := fmt.Sprintf("%s · %s", record.CreatedAt.Format("2006-01-02 15:04"), record.RoomName)
+	if record.SessionID != nil {
+		section = fmt.Sprintf("session/%d", *record.SessionID)
+		title = fmt.Sprintf("%s · 会话 #%d", title, *record.SessionID)
+	}
+	if section != w.section {
+		w.section = section
+		if _, err := fmt.Fprintf(w.w, "\n## %s\n\n", title); err != nil {
+			return err
+		}
+	}
+
+	// 多行消息缩进到同一列表项内
+	text := strings.ReplaceAll(strings.TrimRight(record.Text, "\n"), "\n", "\n  ")
+	_, err := fmt.Fprintf(w.w, "- **%s** %s：%s\n", record.SenderName, record.CreatedAt.Format("15:04:05"), text)
+	return err
+}
+
+func (w *markdownWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,128 @@
+package history
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Record 一条对话记录，包含发送者、房间和会话信息，导出和导入都使用该格式
+type Record struct {
+	ID              int       `json:"id"`
+	Text            string    `json:"text"`
+	SenderID        string    `json:"senderId"`
+	SenderName      string    `json:"senderName"`
+	SenderRole      string    `json:"senderRole"`
+	SenderProfile   string    `json:"senderProfile,omitempty"`
+	RoomID          string    `json:"roomId"`
+	RoomName        string    `json:"roomName"`
+	RoomDescription string    `json:"roomDescription,omitempty"`
+	SessionID       *int      `json:"sessionId,omitempty"`
+	DeviceID        string    `json:"deviceId,omitempty"`
+	Provider        string    `json:"provider,omitempty"`  // 回答的AI服务商，仅机器人消息
+	Model           string    `json:"model,omitempty"`     // 回答的模型，仅机器人消息
+	Reasoning       string    `json:"reasoning,omitempty"` // 思考模型的思考过程，仅机器人消息
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// Filter 对话记录的筛选条件
+type Filter struct {
+	RoomID   string // 为空表示全部房间
+	SenderID string // 为空表示全部发送者
+	DeviceID string // 为空表示全部设备，按消息所属会话的设备筛选
+	Text     string // 消息内容包含的文本
+	From     time.Time
+	To       time.Time // 不包含
+	Page     int
+	PageSize int
+}
+
+// List 分页列出对话记录，按时间倒序
+func List(db *gorm.DB, filter Filter) ([]Record, int64, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	query := filterQuery(db, filter)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计对话记录失败: %v", err)
+	}
+
+	var messages []models.Message
+	if err := preloadQuery(query).Order("id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).
+		Find(&messages).Error; err != nil {
+		return nil, 0, fmt.Errorf("读取对话记录失败: %v", err)
+	}
+
+	records := make([]Record, 0, len(messages))
+	for i := range messages {
+		records = append(records, toRecord(&messages[i]))
+	}
+	return records, total, nil
+}
+
+// filterQuery 按筛选条件构造消息查询
+func filterQuery(db *gorm.DB, filter Filter) *gorm.DB {
+	query := db.Model(&models.Message{})
+	if filter.RoomID != "" {
+		query = query.Where("room_id = ?", filter.RoomID)
+	}
+	if filter.SenderID != "" {
+		query = query.Where("sender_id = ?", filter.SenderID)
+	}
+	if filter.DeviceID != "" {
+		query = query.Where("session_id IN (SELECT id FROM sessions WHERE device_id = ?)", filter.DeviceID)
+	}
+	if text := strings.TrimSpace(filter.Text); text != "" {
+		query = query.Where(`text LIKE ? ESCAPE '\'`, "%"+escapeLike(text)+"%")
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query
+}
+
+// preloadQuery 加载记录需要的发送者、房间和会话
+func preloadQuery(query *gorm.DB) *gorm.DB {
+	return query.Preload("Sender").Preload("Room").Preload("Session")
+}
+
+// escapeLike 转义LIKE中的通配符
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+}
+
+// toRecord 把已加载关联的消息转换为对话记录
+func toRecord(message *models.Message) Record {
+	record := Record{
+		ID:              message.ID,
+		Text:            message.Text,
+		SenderID:        message.SenderID,
+		SenderName:      message.Sender.Name,
+		SenderRole:      message.Sender.Role,
+		SenderProfile:   message.Sender.Profile,
+		RoomID:          message.RoomID,
+		RoomName:        message.Room.Name,
+		RoomDescription: message.Room.Description,
+		SessionID:       message.SessionID,
+		Provider:        message.Provider,
+		Model:           message.Model,
+		Reasoning:       message.Reasoning,
+		CreatedAt:       message.CreatedAt,
+	}
+	if message.Session != nil {
+		record.DeviceID = message.Session.DeviceID
+	}
+	return record
+}
@@ -0,0 +1,352 @@
+package history
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/memory"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Messages int `json:"messages"` // 导入的消息数
+	Skipped  int `json:"skipped"`  // 已存在而跳过的消息数
+	Users    int `json:"users"`    // 新建的用户数
+	Rooms    int `json:"rooms"`    // 新建的房间数
+	Sessions int `json:"sessions"` // 新建的会话数
+}
+
+// Import 导入 JSON Lines 或 CSV 格式的对话记录
+// 用户和房间优先按ID匹配，其次按名称匹配，都不存在时按导出时的ID新建；
+// 会话按导出时的会话重新创建；已存在的消息（发送者、房间、内容和时间都相同）会跳过，重复导入不会产生重复消息
+func Import(db *gorm.DB, r io.Reader, format string) (*ImportResult, error) {
+	var records []Record
+	var err error
+	switch format {
+	case FormatJSONL:
+		records, err = readJSONL(r)
+	case FormatCSV:
+		records, err = readCSV(r)
+	case FormatMarkdown:
+		return nil, fmt.Errorf("Markdown 对话文本仅用于阅读，请导入 JSON Lines 或 CSV 格式")
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		importer := &importer{
+			tx:       tx,
+			result:   result,
+			users:    make(map[string]string),
+			rooms:    make(map[string]string),
+			sessions: make(map[int]*models.Session),
+		}
+		for i := range records {
+			if err := importer.importRecord(&records[i]); err != nil {
+				return fmt.Errorf("第 %d 条记录: %v", i+1, err)
+			}
+		}
+		return importer.saveSessions()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("导入对话记录失败: %v", err)
+	}
+	return result, nil
+}
+
+// readJSONL 读取 JSON Lines
+func readJSONL(r io.Reader) ([]Record, error) {
+	var records []Record
+	decoder := json.NewDecoder(r)
+	for {
+		var record Record
+		if err := decoder.Decode(&record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("解析第 %d 条记录失败: %v", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// readCSV 按列名读取CSV，列的顺序不限
+func readCSV(r io.Reader) ([]Record, error) {
+	reader := bufio.NewReader(r)
+	if bom, _, err := reader.ReadRune(); err == nil && bom != '\uFEFF' {
+		reader.UnreadRune()
+	}
+
+	csvReader := csv.NewReader(reader)
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取CSV表头失败: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"createdAt", "senderName", "roomName", "text"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV缺少列: %s", required)
+		}
+	}
+
+	var records []Record
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析CSV失败: %v", err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+
+		record := Record{
+			Text:       field("text"),
+			SenderID:   field("senderId"),
+			SenderName: field("senderName"),
+			SenderRole: field("senderRole"),
+			RoomID:     field("roomId"),
+			RoomName:   field("roomName"),
+			DeviceID:   field("deviceId"),
+			Provider:   field("provider"),
+			Model:      field("model"),
+			Reasoning:  field("reasoning"),
+		}
+		record.ID, _ = strconv.Atoi(field("id"))
+		if record.CreatedAt, err = time.Parse(time.RFC3339Nano, field("createdAt")); err != nil {
+			return nil, fmt.Errorf("第 %d 条记录的时间无效: %s", len(records)+1, field("createdAt"))
+		}
+		if sessionID, err := strconv.Atoi(field("sessionId")); err == nil {
+			record.SessionID = &sessionID
+		}
+		records = append(records, record)
+	}
+}
+
+// importer 导入过程中的用户、房间和会话映射
+type importer struct {
+	tx       *gorm.DB
+	result   *ImportResult
+	users    map[string]string       // 导出时的用户 → 本地用户ID
+	rooms    map[string]string       // 导出时的房间 → 本地房间ID
+	sessions map[int]*models.Session // 导出时的会话ID → 本地会话
+}
+
+// importRecord 导入一条记录
+func (im *importer) importRecord(record *Record) error {
+	if record.Text == "" || record.SenderName == "" || record.RoomName == "" || record.CreatedAt.IsZero() {
+		return fmt.Errorf("缺少内容、发送者、房间或时间")
+	}
+	if record.SenderRole == "" {
+		record.SenderRole = models.UserRoleMember
+	}
+
+	senderID, err := im.resolveUser(record)
+	if err != nil {
+		return err
+	}
+	roomID, err := im.resolveRoom(record)
+	if err != nil {
+		return err
+	}
+
+	exists, err := im.messageExists(senderID, roomID, record)
+	if err != nil {
+		return err
+	}
+	if exists {
+		im.result.Skipped++
+		return nil
+	}
+
+	message := &models.Message{
+		Text:      record.Text,
+		SenderID:  senderID,
+		RoomID:    roomID,
+		Provider:  record.Provider,
+		Model:     record.Model,
+		Reasoning: record.Reasoning,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.CreatedAt,
+	}
+	if record.SessionID != nil {
+		session, err := im.resolveSession(*record.SessionID, roomID, record)
+		if err != nil {
+			return err
+		}
+		message.SessionID = &session.ID
+		if err := im.touchSession(session, senderID, record); err != nil {
+			return err
+		}
+	}
+	if err := im.tx.Create(message).Error; err != nil {
+		return fmt.Errorf("保存消息失败: %v", err)
+	}
+	if err := memory.IndexMessage(im.tx, message); err != nil {
+		return err
+	}
+	im.result.Messages++
+	return nil
+}
+
+// resolveUser 查找或创建记录的发送者
+func (im *importer) resolveUser(record *Record) (string, error) {
+	key := record.SenderID + "/" + record.SenderName
+	if id, ok := im.users[key]; ok {
+		return id, nil
+	}
+
+	var user models.User
+	err := gorm.ErrRecordNotFound
+	if record.SenderID != "" {
+		err = im.tx.Where("id = ?", record.SenderID).First(&user).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = im.tx.Where("name = ? AND role = ?", record.SenderName, record.SenderRole).
+			Order("created_at ASC").First(&user).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = models.User{
+			ID:      record.SenderID,
+			Name:    record.SenderName,
+			Profile: record.SenderProfile,
+			Role:    record.SenderRole,
+		}
+		if err = im.tx.Create(&user).Error; err == nil {
+			im.result.Users++
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取用户%s失败: %v", record.SenderName, err)
+	}
+
+	im.users[key] = user.ID
+	return user.ID, nil
+}
+
+// resolveRoom 查找或创建记录所在的房间
+func (im *importer) resolveRoom(record *Record) (string, error) {
+	key := record.RoomID + "/" + record.RoomName
+	if id, ok := im.rooms[key]; ok {
+		return id, nil
+	}
+
+	var room models.Room
+	err := gorm.ErrRecordNotFound
+	if record.RoomID != "" {
+		err = im.tx.Where("id = ?", record.RoomID).First(&room).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = im.tx.Where("name = ?", record.RoomName).Order("created_at ASC").First(&room).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		room = models.Room{
+			ID:          record.RoomID,
+			Name:        record.RoomName,
+			Description: record.RoomDescription,
+		}
+		if err = im.tx.Create(&room).Error; err == nil {
+			im.result.Rooms++
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取房间%s失败: %v", record.RoomName, err)
+	}
+
+	im.rooms[key] = room.ID
+	return room.ID, nil
+}
+
+// messageExists 检查相同的消息是否已经存在，时间允许一秒误差
+func (im *importer) messageExists(senderID, roomID string, record *Record) (bool, error) {
+	var existing []models.Message
+	if err := im.tx.Where("sender_id = ? AND room_id = ? AND text = ?", senderID, roomID, record.Text).
+		Find(&existing).Error; err != nil {
+		return false, fmt.Errorf("读取消息失败: %v", err)
+	}
+	for _, message := range existing {
+		diff := message.CreatedAt.Sub(record.CreatedAt)
+		if diff > -time.Second && diff < time.Second {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// resolveSession 获取导出时的会话对应的本地会话，第一次出现时新建
+func (im *importer) resolveSession(exportedID int, roomID string, record *Record) (*models.Session, error) {
+	if session, ok := im.sessions[exportedID]; ok {
+		return session, nil
+	}
+
+	endedAt := record.CreatedAt
+	session := &models.Session{
+		RoomID:       roomID,
+		DeviceID:     record.DeviceID,
+		StartedAt:    record.CreatedAt,
+		LastActiveAt: record.CreatedAt,
+		EndedAt:      &endedAt,
+		EndReason:    models.SessionEndImport,
+	}
+	if err := im.tx.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("创建会话失败: %v", err)
+	}
+	im.sessions[exportedID] = session
+	im.result.Sessions++
+	return session, nil
+}
+
+// touchSession 按导入的消息更新会话的时间范围、提问次数和参与者
+func (im *importer) touchSession(session *models.Session, senderID string, record *Record) error {
+	if record.CreatedAt.Before(session.StartedAt) {
+		session.StartedAt = record.CreatedAt
+	}
+	if record.CreatedAt.After(session.LastActiveAt) {
+		session.LastActiveAt = record.CreatedAt
+		endedAt := record.CreatedAt
+		session.EndedAt = &endedAt
+	}
+	if record.SenderRole != models.UserRoleBot {
+		session.Turns++
+	}
+	if err := im.tx.Exec("INSERT OR IGNORE INTO session_participants (session_id, user_id) VALUES (?, ?)",
+		session.ID, senderID).Error; err != nil {
+		return fmt.Errorf("记录会话参与者失败: %v", err)
+	}
+	return nil
+}
+
+// saveSessions 保存导入后会话的时间范围和提问次数
+func (im *importer) saveSessions() error {
+	for _, session := range im.sessions {
+		if err := im.tx.Model(session).Updates(map[string]interface{}{
+			"started_at":     session.StartedAt,
+			"last_active_at": session.LastActiveAt,
+			"ended_at":       session.EndedAt,
+			"turns":          session.Turns,
+		}).Error; err != nil {
+			return fmt.Errorf("更新会话失败: %v", err)
+		}
+	}
+	return nil
+}
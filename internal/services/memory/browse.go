@@ -0,0 +1,420 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mi-gpt-go/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KindFact 记住的事实，不进入检索索引，只在记忆浏览中与摘要一起管理
+const KindFact = "fact"
+
+// ErrMemoryInUse 短期记忆仍是某条长期记忆的游标，删除会破坏记忆链
+var ErrMemoryInUse = errors.New("该短期记忆是长期记忆的来源游标，请先删除对应的长期记忆")
+
+// MemoryItem 记忆浏览中的一条记忆
+type MemoryItem struct {
+	Kind      string    `json:"kind"`
+	ID        int       `json:"id"`
+	Text      string    `json:"text"`
+	OwnerID   *string   `json:"ownerId"`
+	OwnerName string    `json:"ownerName,omitempty"`
+	RoomID    string    `json:"roomId"`
+	RoomName  string    `json:"roomName,omitempty"`
+	CursorID  int       `json:"cursorId,omitempty"` // 摘要覆盖到的最后一条来源
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListOptions 记忆列表的筛选条件
+type ListOptions struct {
+	Kind     string // short, long 或 fact
+	OwnerID  string // 为空表示全部所有者
+	RoomID   string // 为空表示全部房间
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+// ShortTermSource 长期记忆来源中的一条短期记忆及其消息
+type ShortTermSource struct {
+	Memory   MemoryItem       `json:"memory"`
+	Messages []models.Message `json:"messages"`
+}
+
+// MemoryChain 记忆及其来源链：长期记忆 → 短期记忆 → 消息
+type MemoryChain struct {
+	Memory     MemoryItem        `json:"memory"`
+	Previous   *MemoryItem       `json:"previous,omitempty"`   // 合并进长期记忆的上一条长期记忆
+	ShortTerms []ShortTermSource `json:"shortTerms,omitempty"` // 长期记忆的来源
+	Messages   []models.Message  `json:"messages,omitempty"`   // 短期记忆的来源
+}
+
+// memoryModel 返回记忆类型对应的模型
+func memoryModel(kind string) (interface{}, error) {
+	switch kind {
+	case SearchKindShortTerm:
+		return &models.ShortTermMemory{}, nil
+	case SearchKindLongTerm:
+		return &models.LongTermMemory{}, nil
+	case KindFact:
+		return &models.PinnedFact{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的记忆类型: %s", kind)
+	}
+}
+
+// ListMemories 分页列出记忆，按时间倒序
+func ListMemories(db *gorm.DB, options ListOptions) ([]MemoryItem, int64, error) {
+	model, err := memoryModel(options.Kind)
+	if err != nil {
+		return nil, 0, err
+	}
+	if options.Page <= 0 {
+		options.Page = 1
+	}
+	if options.PageSize <= 0 || options.PageSize > 100 {
+		options.PageSize = 20
+	}
+
+	query := db.Model(model)
+	if options.OwnerID != "" {
+		query = query.Where("owner_id = ?", options.OwnerID)
+	}
+	if options.RoomID != "" {
+		query = query.Where("room_id = ?", options.RoomID)
+	}
+	if !options.From.IsZero() {
+		query = query.Where("created_at >= ?", options.From)
+	}
+	if !options.To.IsZero() {
+		query = query.Where("created_at < ?", options.To)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计记忆失败: %v", err)
+	}
+
+	query = query.Preload("Owner").Preload("Room").Order("id DESC").
+		Offset((options.Page - 1) * options.PageSize).Limit(options.PageSize)
+
+	var items []MemoryItem
+	switch options.Kind {
+	case SearchKindShortTerm:
+		var memories []models.ShortTermMemory
+		err = query.Find(&memories).Error
+		for _, memory := range memories {
+			items = append(items, shortTermItem(memory))
+		}
+	case SearchKindLongTerm:
+		var memories []models.LongTermMemory
+		err = query.Find(&memories).Error
+		for _, memory := range memories {
+			items = append(items, longTermItem(memory))
+		}
+	case KindFact:
+		var facts []models.PinnedFact
+		err = query.Find(&facts).Error
+		for _, fact := range facts {
+			items = append(items, factItem(fact))
+		}
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取记忆失败: %v", err)
+	}
+	return items, total, nil
+}
+
+// GetMemoryChain 获取记忆及其来源链
+func GetMemoryChain(db *gorm.DB, kind string, id int) (*MemoryChain, error) {
+	switch kind {
+	case SearchKindShortTerm:
+		var memory models.ShortTermMemory
+		if err := db.Preload("Owner").Preload("Room").First(&memory, id).Error; err != nil {
+			return nil, err
+		}
+		messages, err := shortTermMessages(db, memory)
+		if err != nil {
+			return nil, err
+		}
+		return &MemoryChain{Memory: shortTermItem(memory), Messages: messages}, nil
+
+	case SearchKindLongTerm:
+		var memory models.LongTermMemory
+		if err := db.Preload("Owner").Preload("Room").First(&memory, id).Error; err != nil {
+			return nil, err
+		}
+		chain := &MemoryChain{Memory: longTermItem(memory)}
+
+		previous, shortTerms, err := longTermSources(db, memory)
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil {
+			item := longTermItem(*previous)
+			chain.Previous = &item
+		}
+		for _, shortTerm := range shortTerms {
+			messages, err := shortTermMessages(db, shortTerm)
+			if err != nil {
+				return nil, err
+			}
+			chain.ShortTerms = append(chain.ShortTerms, ShortTermSource{Memory: shortTermItem(shortTerm), Messages: messages})
+		}
+		return chain, nil
+
+	case KindFact:
+		var fact models.PinnedFact
+		if err := db.Preload("Owner").Preload("Room").First(&fact, id).Error; err != nil {
+			return nil, err
+		}
+		return &MemoryChain{Memory: factItem(fact)}, nil
+
+	default:
+		return nil, fmt.Errorf("不支持的记忆类型: %s", kind)
+	}
+}
+
+// UpdateMemoryText 修改记忆内容并刷新检索索引，旧的向量会被删除，需要重新生成
+func UpdateMemoryText(db *gorm.DB, kind string, id int, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("记忆内容不能为空")
+	}
+	model, err := memoryModel(kind)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(model, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(model).Update("text", text).Error; err != nil {
+			return fmt.Errorf("修改记忆失败: %v", err)
+		}
+		if kind == KindFact {
+			return nil
+		}
+
+		if err := removeIndexEntry(tx, kind, id); err != nil {
+			return err
+		}
+		switch memory := model.(type) {
+		case *models.ShortTermMemory:
+			return IndexEntry(tx, kind, id, text, memory.RoomID, memory.OwnerID, memory.CreatedAt)
+		case *models.LongTermMemory:
+			return IndexEntry(tx, kind, id, text, memory.RoomID, memory.OwnerID, memory.CreatedAt)
+		}
+		return nil
+	})
+}
+
+// DeleteMemory 删除记忆及其检索索引和向量
+func DeleteMemory(db *gorm.DB, kind string, id int) error {
+	model, err := memoryModel(kind)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(model, id).Error; err != nil {
+			return err
+		}
+		if kind == SearchKindShortTerm {
+			var referenced int64
+			if err := tx.Model(&models.LongTermMemory{}).Where("cursor_id = ?", id).Count(&referenced).Error; err != nil {
+				return fmt.Errorf("读取长期记忆失败: %v", err)
+			}
+			if referenced > 0 {
+				return ErrMemoryInUse
+			}
+		}
+
+		if err := tx.Delete(model).Error; err != nil {
+			return fmt.Errorf("删除记忆失败: %v", err)
+		}
+		if kind == KindFact {
+			return nil
+		}
+		return removeIndexEntry(tx, kind, id)
+	})
+}
+
+// Resummarize 根据来源重新生成摘要，返回新的摘要内容
+func (mm *MemoryManager) Resummarize(ctx context.Context, kind string, id int) (string, error) {
+	var summary string
+	switch kind {
+	case SearchKindShortTerm:
+		var memory models.ShortTermMemory
+		if err := mm.db.First(&memory, id).Error; err != nil {
+			return "", err
+		}
+		messages, err := shortTermMessages(mm.db, memory)
+		if err != nil {
+			return "", err
+		}
+		if len(messages) == 0 {
+			return "", fmt.Errorf("短期记忆的来源消息已不存在")
+		}
+		if summary, err = mm.generateMemorySummary(ctx, messages, "short"); err != nil {
+			return "", err
+		}
+
+	case SearchKindLongTerm:
+		var memory models.LongTermMemory
+		if err := mm.db.First(&memory, id).Error; err != nil {
+			return "", err
+		}
+		previous, shortTerms, err := longTermSources(mm.db, memory)
+		if err != nil {
+			return "", err
+		}
+		if len(shortTerms) == 0 {
+			return "", fmt.Errorf("长期记忆的来源短期记忆已不存在")
+		}
+
+		var memoryTexts []string
+		if previous != nil {
+			memoryTexts = append(memoryTexts, "已有的长期记忆：\n"+previous.Text, "新的短期记忆：")
+		}
+		for _, shortTerm := range shortTerms {
+			memoryTexts = append(memoryTexts, shortTerm.Text)
+		}
+		if summary, err = mm.generateLongTermSummary(ctx, strings.Join(memoryTexts, "\n")); err != nil {
+			return "", err
+		}
+
+	default:
+		return "", fmt.Errorf("只有短期记忆和长期记忆可以重新总结")
+	}
+
+	if err := UpdateMemoryText(mm.db, kind, id, summary); err != nil {
+		return "", err
+	}
+	logEmbedError(mm.RefreshEmbedding(ctx, kind, id))
+	return summary, nil
+}
+
+// RefreshEmbedding 重新生成一条记忆摘要的向量，未启用语义记忆时直接返回
+func (mm *MemoryManager) RefreshEmbedding(ctx context.Context, kind string, id int) error {
+	if !mm.SemanticEnabled() {
+		return nil
+	}
+
+	var entry VectorEntry
+	switch kind {
+	case SearchKindShortTerm:
+		var memory models.ShortTermMemory
+		if err := mm.db.First(&memory, id).Error; err != nil {
+			return err
+		}
+		entry = VectorEntry{Kind: kind, RefID: id, Text: memory.Text, RoomID: memory.RoomID, OwnerID: memory.OwnerID, CreatedAt: memory.CreatedAt}
+	case SearchKindLongTerm:
+		var memory models.LongTermMemory
+		if err := mm.db.First(&memory, id).Error; err != nil {
+			return err
+		}
+		entry = VectorEntry{Kind: kind, RefID: id, Text: memory.Text, RoomID: memory.RoomID, OwnerID: memory.OwnerID, CreatedAt: memory.CreatedAt}
+	default:
+		return nil
+	}
+	return mm.embed(ctx, []VectorEntry{entry})
+}
+
+// shortTermMessages 短期记忆覆盖的消息：上一条短期记忆的游标之后，到本条游标为止
+func shortTermMessages(db *gorm.DB, memory models.ShortTermMemory) ([]models.Message, error) {
+	previousCursor := 0
+	var previous models.ShortTermMemory
+	if err := scopeOwner(db, memory.OwnerID).Where("room_id = ? AND id < ?", memory.RoomID, memory.ID).
+		Order("id DESC").First(&previous).Error; err == nil {
+		previousCursor = previous.CursorID
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("获取短期记忆失败: %v", err)
+	}
+
+	var memories []models.Memory
+	if err := scopeOwner(db, memory.OwnerID).Preload("Message.Sender").
+		Where("room_id = ? AND id > ? AND id <= ?", memory.RoomID, previousCursor, memory.CursorID).
+		Order("id ASC").Find(&memories).Error; err != nil {
+		return nil, fmt.Errorf("获取来源消息失败: %v", err)
+	}
+
+	messages := make([]models.Message, 0, len(memories))
+	for _, item := range memories {
+		if item.Message.ID != 0 {
+			messages = append(messages, item.Message)
+		}
+	}
+	return messages, nil
+}
+
+// longTermSources 长期记忆的来源：合并的上一条长期记忆，以及上一条游标之后到本条游标为止的短期记忆
+func longTermSources(db *gorm.DB, memory models.LongTermMemory) (*models.LongTermMemory, []models.ShortTermMemory, error) {
+	previousCursor := 0
+	var previous *models.LongTermMemory
+	var last models.LongTermMemory
+	if err := scopeOwner(db, memory.OwnerID).Where("room_id = ? AND id < ?", memory.RoomID, memory.ID).
+		Order("id DESC").First(&last).Error; err == nil {
+		previousCursor = last.CursorID
+		previous = &last
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, nil, fmt.Errorf("获取长期记忆失败: %v", err)
+	}
+
+	var shortTerms []models.ShortTermMemory
+	if err := scopeOwner(db, memory.OwnerID).Preload("Owner").Preload("Room").
+		Where("room_id = ? AND id > ? AND id <= ?", memory.RoomID, previousCursor, memory.CursorID).
+		Order("id ASC").Find(&shortTerms).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取来源短期记忆失败: %v", err)
+	}
+	return previous, shortTerms, nil
+}
+
+// removeIndexEntry 移除记忆摘要的检索索引和向量
+func removeIndexEntry(db *gorm.DB, kind string, id int) error {
+	if err := db.Exec("DELETE FROM memory_search WHERE kind = ? AND ref_id = ?", kind, id).Error; err != nil {
+		return fmt.Errorf("移除检索索引失败: %v", err)
+	}
+	if err := db.Where("kind = ? AND ref_id = ?", kind, id).Delete(&models.Embedding{}).Error; err != nil {
+		return fmt.Errorf("删除向量失败: %v", err)
+	}
+	return nil
+}
+
+func shortTermItem(memory models.ShortTermMemory) MemoryItem {
+	item := MemoryItem{Kind: SearchKindShortTerm, ID: memory.ID, Text: memory.Text, OwnerID: memory.OwnerID,
+		RoomID: memory.RoomID, RoomName: memory.Room.Name, CursorID: memory.CursorID,
+		CreatedAt: memory.CreatedAt, UpdatedAt: memory.UpdatedAt}
+	if memory.Owner != nil {
+		item.OwnerName = memory.Owner.Name
+	}
+	return item
+}
+
+func longTermItem(memory models.LongTermMemory) MemoryItem {
+	item := MemoryItem{Kind: SearchKindLongTerm, ID: memory.ID, Text: memory.Text, OwnerID: memory.OwnerID,
+		RoomID: memory.RoomID, RoomName: memory.Room.Name, CursorID: memory.CursorID,
+		CreatedAt: memory.CreatedAt, UpdatedAt: memory.UpdatedAt}
+	if memory.Owner != nil {
+		item.OwnerName = memory.Owner.Name
+	}
+	return item
+}
+
+func factItem(fact models.PinnedFact) MemoryItem {
+	item := MemoryItem{Kind: KindFact, ID: fact.ID, Text: fact.Text, OwnerID: fact.OwnerID,
+		RoomID: fact.RoomID, RoomName: fact.Room.Name, CreatedAt: fact.CreatedAt, UpdatedAt: fact.UpdatedAt}
+	if fact.Owner != nil {
+		item.OwnerName = fact.Owner.Name
+	}
+	return item
+}
@@ -0,0 +1,225 @@
+package memory
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RedactedText 被遗忘的消息替换后的内容
+const RedactedText = "[已遗忘]"
+
+// ForgetOptions 遗忘条件
+type ForgetOptions struct {
+	OwnerID    *string // 事实的所有者
+	RoomID     string
+	SenderID   string    // 只处理该用户发送的消息，为空表示全部发送者
+	Keyword    string    // 包含该关键词的事实和消息会被遗忘
+	Since      time.Time // 只处理该时间之后的消息，零值表示不限
+	MessageIDs []int     // 额外需要遗忘的消息（例如语义匹配的结果）
+}
+
+// ForgetResult 遗忘结果
+type ForgetResult struct {
+	Facts     int `json:"facts"`     // 删除的事实数
+	Messages  int `json:"messages"`  // 抹除的消息数
+	Summaries int `json:"summaries"` // 删除的短期和长期记忆数
+}
+
+// AddPinnedFact 记住一条事实，相同的事实不会重复保存
+func AddPinnedFact(db *gorm.DB, ownerID *string, roomID, text string) (*models.PinnedFact, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("事实内容不能为空")
+	}
+
+	fact := &models.PinnedFact{}
+	err := scopeOwner(db, ownerID).Where("room_id = ? AND text = ?", roomID, text).First(fact).Error
+	if err == nil {
+		return fact, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("读取事实失败: %v", err)
+	}
+
+	fact = &models.PinnedFact{Text: text, OwnerID: ownerID, RoomID: roomID}
+	if err := db.Create(fact).Error; err != nil {
+		return nil, fmt.Errorf("保存事实失败: %v", err)
+	}
+	return fact, nil
+}
+
+// GetPinnedFacts 获取全部事实，按记住的先后排列
+func GetPinnedFacts(db *gorm.DB, ownerID *string, roomID string) ([]models.PinnedFact, error) {
+	var facts []models.PinnedFact
+	if err := scopeOwner(db, ownerID).Where("room_id = ?", roomID).
+		Order("id ASC").Find(&facts).Error; err != nil {
+		return nil, fmt.Errorf("读取事实失败: %v", err)
+	}
+	return facts, nil
+}
+
+// Forget 删除包含关键词的事实，并抹除匹配的消息内容及其检索索引和向量
+// 包含关键词或覆盖了被抹除消息的记忆摘要一并删除
+func Forget(db *gorm.DB, options ForgetOptions) (ForgetResult, error) {
+	var result ForgetResult
+	keyword := strings.TrimSpace(options.Keyword)
+	if keyword == "" && len(options.MessageIDs) == 0 {
+		return result, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if keyword != "" {
+			deleted := scopeOwner(tx, options.OwnerID).
+				Where("room_id = ? AND text LIKE ? ESCAPE '\\'", options.RoomID, "%"+escapeLike(keyword)+"%").
+				Delete(&models.PinnedFact{})
+			if deleted.Error != nil {
+				return fmt.Errorf("删除事实失败: %v", deleted.Error)
+			}
+			result.Facts = int(deleted.RowsAffected)
+		}
+
+		query := tx.Model(&models.Message{}).Where("room_id = ? AND text <> ?", options.RoomID, RedactedText)
+		if options.SenderID != "" {
+			query = query.Where("sender_id = ?", options.SenderID)
+		}
+		if !options.Since.IsZero() {
+			query = query.Where("created_at >= ?", options.Since)
+		}
+		switch {
+		case keyword != "" && len(options.MessageIDs) > 0:
+			query = query.Where("(text LIKE ? ESCAPE '\\' OR id IN ?)", "%"+escapeLike(keyword)+"%", options.MessageIDs)
+		case keyword != "":
+			query = query.Where("text LIKE ? ESCAPE '\\'", "%"+escapeLike(keyword)+"%")
+		default:
+			query = query.Where("id IN ?", options.MessageIDs)
+		}
+
+		var ids []int
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("读取消息失败: %v", err)
+		}
+		for _, id := range ids {
+			if err := RedactMessage(tx, id); err != nil {
+				return err
+			}
+		}
+		result.Messages = len(ids)
+
+		summaries, err := forgetSummaries(tx, options, keyword, ids)
+		if err != nil {
+			return err
+		}
+		result.Summaries = summaries
+		return nil
+	})
+	return result, err
+}
+
+// forgetSummaries 删除包含关键词或覆盖了被抹除消息的短期记忆，以及包含关键词的长期记忆
+// 长期记忆会合并上一条长期记忆的内容，因此受影响的短期记忆之后合并的长期记忆也一并删除，下次整理时重新生成
+func forgetSummaries(tx *gorm.DB, options ForgetOptions, keyword string, messageIDs []int) (int, error) {
+	var shortTerms []models.ShortTermMemory
+	var longTerms []models.LongTermMemory
+	if keyword != "" {
+		pattern := "%" + escapeLike(keyword) + "%"
+		if err := scopeOwner(tx, options.OwnerID).Where("room_id = ? AND text LIKE ? ESCAPE '\\'", options.RoomID, pattern).
+			Find(&shortTerms).Error; err != nil {
+			return 0, fmt.Errorf("读取短期记忆失败: %v", err)
+		}
+		if err := scopeOwner(tx, options.OwnerID).Where("room_id = ? AND text LIKE ? ESCAPE '\\'", options.RoomID, pattern).
+			Find(&longTerms).Error; err != nil {
+			return 0, fmt.Errorf("读取长期记忆失败: %v", err)
+		}
+	}
+
+	// 覆盖消息的短期记忆：同一所有者中游标不小于该消息记忆的第一条
+	if len(messageIDs) > 0 {
+		var memories []models.Memory
+		if err := tx.Where("message_id IN ?", messageIDs).Find(&memories).Error; err != nil {
+			return 0, fmt.Errorf("读取消息记忆失败: %v", err)
+		}
+		for _, item := range memories {
+			var covering models.ShortTermMemory
+			err := scopeOwner(tx, item.OwnerID).Where("room_id = ? AND cursor_id >= ?", item.RoomID, item.ID).
+				Order("cursor_id ASC").First(&covering).Error
+			if err == nil {
+				shortTerms = append(shortTerms, covering)
+			} else if err != gorm.ErrRecordNotFound {
+				return 0, fmt.Errorf("读取短期记忆失败: %v", err)
+			}
+		}
+	}
+
+	for _, shortTerm := range shortTerms {
+		var merged []models.LongTermMemory
+		if err := scopeOwner(tx, shortTerm.OwnerID).Where("room_id = ? AND cursor_id >= ?", shortTerm.RoomID, shortTerm.ID).
+			Find(&merged).Error; err != nil {
+			return 0, fmt.Errorf("读取长期记忆失败: %v", err)
+		}
+		longTerms = append(longTerms, merged...)
+	}
+
+	longTermIDs := make([]int, 0, len(longTerms))
+	for _, memory := range longTerms {
+		longTermIDs = appendUnique(longTermIDs, memory.ID)
+	}
+	shortTermIDs := make([]int, 0, len(shortTerms))
+	for _, memory := range shortTerms {
+		shortTermIDs = appendUnique(shortTermIDs, memory.ID)
+	}
+
+	// 长期记忆的游标引用短期记忆，先删除长期记忆
+	if len(longTermIDs) > 0 {
+		if err := tx.Delete(&models.LongTermMemory{}, longTermIDs).Error; err != nil {
+			return 0, fmt.Errorf("删除长期记忆失败: %v", err)
+		}
+		if err := removeIndexEntries(tx, SearchKindLongTerm, longTermIDs); err != nil {
+			return 0, err
+		}
+	}
+	if len(shortTermIDs) > 0 {
+		if err := tx.Delete(&models.ShortTermMemory{}, shortTermIDs).Error; err != nil {
+			return 0, fmt.Errorf("删除短期记忆失败: %v", err)
+		}
+		if err := removeIndexEntries(tx, SearchKindShortTerm, shortTermIDs); err != nil {
+			return 0, err
+		}
+	}
+	return len(longTermIDs) + len(shortTermIDs), nil
+}
+
+// appendUnique 追加不在列表中的ID
+func appendUnique(ids []int, id int) []int {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+// RedactMessage 抹除消息内容，同时移除检索索引和向量，保留消息本身以免打乱对话记录
+func RedactMessage(db *gorm.DB, messageID int) error {
+	if err := db.Model(&models.Message{}).Where("id = ?", messageID).
+		Update("text", RedactedText).Error; err != nil {
+		return fmt.Errorf("抹除消息失败: %v", err)
+	}
+	if err := db.Exec("DELETE FROM memory_search WHERE kind = ? AND ref_id = ?", SearchKindMessage, messageID).Error; err != nil {
+		return fmt.Errorf("移除检索索引失败: %v", err)
+	}
+	if err := db.Where("kind = ? AND ref_id = ?", SearchKindMessage, messageID).
+		Delete(&models.Embedding{}).Error; err != nil {
+		return fmt.Errorf("删除向量失败: %v", err)
+	}
+	return nil
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(text)
+}
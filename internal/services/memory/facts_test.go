@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"mi-gpt-go/internal/database/databasetest"
+	"mi-gpt-go/internal/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func createMessageMemory(t *testing.T, db *gorm.DB, ownerID *string, text string) models.Memory {
+	t.Helper()
+	message := models.Message{Text: text, SenderID: *ownerID, RoomID: testRoomID}
+	if err := db.Create(&message).Error; err != nil {
+		t.Fatal(err)
+	}
+	memory := models.Memory{MessageID: message.ID, OwnerID: ownerID, RoomID: testRoomID}
+	if err := db.Create(&memory).Error; err != nil {
+		t.Fatal(err)
+	}
+	return memory
+}
+
+func TestForgetRemovesAffectedSummaries(t *testing.T) {
+	db := databasetest.Open(t)
+	alice, bob := "alice", "bob"
+	earlier := time.Now().Add(-time.Hour)
+
+	address := createMessageMemory(t, db, &alice, "我家地址是幸福路1号")
+	createMessageMemory(t, db, &alice, "今天天气不错")
+	hiking := createMessageMemory(t, db, &alice, "周末去爬山")
+	bobMemory := createMessageMemory(t, db, &bob, "我的地址改了")
+
+	// 第一条短期记忆覆盖了含地址的消息，但摘要本身不含关键词
+	shortTerms := []models.ShortTermMemory{
+		{Text: "主人提到了家住在幸福路", CursorID: address.ID, OwnerID: &alice, RoomID: testRoomID},
+		{Text: "聊了天气和周末爬山的计划", CursorID: hiking.ID, OwnerID: &alice, RoomID: testRoomID},
+		{Text: "小红搬家后地址变了", CursorID: bobMemory.ID, OwnerID: &bob, RoomID: testRoomID},
+	}
+	for i := range shortTerms {
+		if err := db.Create(&shortTerms[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := IndexEntry(db, SearchKindShortTerm, shortTerms[i].ID, shortTerms[i].Text, testRoomID, shortTerms[i].OwnerID, earlier); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 长期记忆逐条合并，第二条仍然包含第一条短期记忆的内容
+	longTerms := []models.LongTermMemory{
+		{Text: "主人住在幸福路", CursorID: shortTerms[0].ID, OwnerID: &alice, RoomID: testRoomID},
+		{Text: "主人住在幸福路，喜欢周末爬山", CursorID: shortTerms[1].ID, OwnerID: &alice, RoomID: testRoomID},
+	}
+	for i := range longTerms {
+		if err := db.Create(&longTerms[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Forget(db, ForgetOptions{OwnerID: &alice, RoomID: testRoomID, SenderID: alice, Keyword: "地址"})
+	if err != nil {
+		t.Fatalf("遗忘失败: %v", err)
+	}
+	if result.Messages != 1 || result.Summaries != 3 {
+		t.Errorf("遗忘结果 %+v, 期望抹除1条消息、删除3条记忆摘要", result)
+	}
+
+	var remaining []int
+	db.Model(&models.ShortTermMemory{}).Order("id").Pluck("id", &remaining)
+	if want := []int{shortTerms[1].ID, shortTerms[2].ID}; !equalIDs(remaining, want) {
+		t.Errorf("剩余短期记忆 %v, 期望 %v（其他成员的记忆不受影响）", remaining, want)
+	}
+	var longTermCount int64
+	db.Model(&models.LongTermMemory{}).Count(&longTermCount)
+	if longTermCount != 0 {
+		t.Errorf("合并了被遗忘内容的长期记忆应全部删除，剩余 %d 条", longTermCount)
+	}
+	var indexed int64
+	db.Table("memory_search").Where("kind = ? AND ref_id = ?", SearchKindShortTerm, shortTerms[0].ID).Count(&indexed)
+	if indexed != 0 {
+		t.Errorf("删除的短期记忆仍在检索索引中")
+	}
+}
@@ -0,0 +1,467 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/pkg/logger"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	compactMessageBatch     = 50              // 强制整理时每条短期记忆最多覆盖的记忆数
+	compactShortTermBatch   = 10              // 强制整理时每条长期记忆最多合并的短期记忆数
+	purgeBatchSize          = 500             // 每个事务最多删除的条数
+	maintenanceHistoryLimit = 20              // 保留的维护记录条数
+	maintenanceStartDelay   = 5 * time.Minute // 启动后首次维护的延迟
+	maintenanceTimeout      = time.Hour       // 单次维护的超时时间
+)
+
+// RetentionPolicy 保留策略，天数为 0 表示永久保留
+type RetentionPolicy struct {
+	MessageDays   int `json:"messageDays"`
+	MemoryDays    int `json:"memoryDays"`
+	ShortTermDays int `json:"shortTermDays"`
+	LongTermDays  int `json:"longTermDays"`
+}
+
+// CompactResult 强制整理的结果
+type CompactResult struct {
+	ShortTerms int `json:"shortTerms"` // 新生成的短期记忆数
+	LongTerms  int `json:"longTerms"`  // 新生成的长期记忆数
+}
+
+// CleanupResult 清理的结果
+type CleanupResult struct {
+	Messages   int `json:"messages"`
+	Memories   int `json:"memories"`
+	ShortTerms int `json:"shortTerms"`
+	LongTerms  int `json:"longTerms"`
+}
+
+// MaintenanceRun 一次维护任务的执行记录
+type MaintenanceRun struct {
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	Duration   string        `json:"duration"`
+	Compacted  CompactResult `json:"compacted"`
+	Deleted    CleanupResult `json:"deleted"`
+	Optimized  bool          `json:"optimized"` // 是否完成了 VACUUM/ANALYZE
+	Error      string        `json:"error,omitempty"`
+}
+
+// MaintenanceStatus 维护任务的状态
+type MaintenanceStatus struct {
+	Policy   RetentionPolicy  `json:"policy"`
+	Interval string           `json:"interval"`
+	Running  bool             `json:"running"`
+	NextRun  time.Time        `json:"nextRun"`
+	History  []MaintenanceRun `json:"history"` // 最近的执行记录，新的在前
+}
+
+// memoryScope 记忆的归属范围
+type memoryScope struct {
+	OwnerID *string
+	RoomID  string
+}
+
+// Maintainer 定期整理并清理过期的消息和记忆，随后整理数据库
+type Maintainer struct {
+	db       *gorm.DB
+	manager  *MemoryManager // 为空表示未启用记忆，删除消息前不做整理
+	policy   RetentionPolicy
+	interval time.Duration
+	mutex    sync.Mutex
+	running  bool
+	nextRun  time.Time
+	history  []MaintenanceRun
+	stop     chan struct{}
+}
+
+// NewMaintainer 创建维护任务
+func NewMaintainer(db *gorm.DB, manager *MemoryManager, policy RetentionPolicy, interval time.Duration) *Maintainer {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	return &Maintainer{
+		db:       db,
+		manager:  manager,
+		policy:   policy,
+		interval: interval,
+	}
+}
+
+// Start 启动定时维护，首次维护在启动后稍作延迟执行
+func (m *Maintainer) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.nextRun = time.Now().Add(maintenanceStartDelay)
+
+	go func(stop chan struct{}) {
+		timer := time.NewTimer(maintenanceStartDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+				ctx, cancel := context.WithTimeout(context.Background(), maintenanceTimeout)
+				go func() {
+					select {
+					case <-stop:
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+				m.RunNow(ctx)
+				cancel()
+
+				m.mutex.Lock()
+				m.nextRun = time.Now().Add(m.interval)
+				m.mutex.Unlock()
+				timer.Reset(m.interval)
+			}
+		}
+	}(m.stop)
+	logger.Infof("🧹 记忆维护任务已启动，每 %v 执行一次", m.interval)
+}
+
+// Stop 停止定时维护，正在执行的维护会被取消
+func (m *Maintainer) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stop == nil {
+		return
+	}
+	close(m.stop)
+	m.stop = nil
+	m.nextRun = time.Time{}
+}
+
+// Status 获取维护任务的状态
+func (m *Maintainer) Status() MaintenanceStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	history := make([]MaintenanceRun, 0, len(m.history))
+	for i := len(m.history) - 1; i >= 0; i-- {
+		history = append(history, m.history[i])
+	}
+	return MaintenanceStatus{
+		Policy:   m.policy,
+		Interval: m.interval.String(),
+		Running:  m.running,
+		NextRun:  m.nextRun,
+		History:  history,
+	}
+}
+
+// RunNow 立即执行一次维护，已有维护在执行时直接返回
+func (m *Maintainer) RunNow(ctx context.Context) (MaintenanceRun, error) {
+	m.mutex.Lock()
+	if m.running {
+		m.mutex.Unlock()
+		return MaintenanceRun{}, fmt.Errorf("维护任务正在执行中")
+	}
+	m.running = true
+	m.mutex.Unlock()
+
+	run := MaintenanceRun{StartedAt: time.Now()}
+	err := m.run(ctx, &run)
+	run.FinishedAt = time.Now()
+	run.Duration = run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond).String()
+	if err != nil {
+		run.Error = err.Error()
+		logger.Errorf("记忆维护失败: %v", err)
+	} else {
+		logger.Infof("🧹 记忆维护完成: 整理 %d 条短期记忆、%d 条长期记忆，删除 %d 条消息、%d 条记忆记录、%d 条短期记忆、%d 条长期记忆，耗时 %s",
+			run.Compacted.ShortTerms, run.Compacted.LongTerms, run.Deleted.Messages, run.Deleted.Memories,
+			run.Deleted.ShortTerms, run.Deleted.LongTerms, run.Duration)
+	}
+
+	m.mutex.Lock()
+	m.running = false
+	m.history = append(m.history, run)
+	if len(m.history) > maintenanceHistoryLimit {
+		m.history = m.history[len(m.history)-maintenanceHistoryLimit:]
+	}
+	m.mutex.Unlock()
+	return run, err
+}
+
+// run 按顺序执行整理、清理和数据库优化
+func (m *Maintainer) run(ctx context.Context, run *MaintenanceRun) error {
+	now := time.Now()
+
+	if days := m.policy.MessageDays; days > 0 {
+		cutoff := now.AddDate(0, 0, -days)
+		// 删除原始消息前先把其中还未整理的内容写进长期记忆，整理失败时保留消息等下次再试
+		if m.manager != nil {
+			compacted, err := m.manager.Compact(ctx, cutoff)
+			run.Compacted = compacted
+			if err != nil {
+				return fmt.Errorf("整理过期消息失败: %v", err)
+			}
+		}
+		count, err := m.purgeMessages(ctx, cutoff)
+		run.Deleted.Messages = count
+		if err != nil {
+			return err
+		}
+	}
+
+	if days := m.policy.MemoryDays; days > 0 {
+		// 只删除已经被短期记忆覆盖的记忆记录
+		count, err := m.purge(ctx, &models.Memory{}, "",
+			`created_at < ? AND id <= (SELECT COALESCE(MAX(s.cursor_id), 0) FROM short_term_memories s
+				WHERE s.room_id = memories.room_id AND s.owner_id IS memories.owner_id)`,
+			now.AddDate(0, 0, -days))
+		run.Deleted.Memories = count
+		if err != nil {
+			return err
+		}
+	}
+
+	if days := m.policy.ShortTermDays; days > 0 {
+		// 只删除已经合并进长期记忆的短期记忆，保留各范围最新的一条作为游标
+		count, err := m.purge(ctx, &models.ShortTermMemory{}, SearchKindShortTerm,
+			`created_at < ? AND id <= (SELECT COALESCE(MAX(l.cursor_id), 0) FROM long_term_memories l
+				WHERE l.room_id = short_term_memories.room_id AND l.owner_id IS short_term_memories.owner_id)
+			AND id NOT IN (SELECT cursor_id FROM long_term_memories)
+			AND id NOT IN (SELECT MAX(id) FROM short_term_memories GROUP BY owner_id, room_id)`,
+			now.AddDate(0, 0, -days))
+		run.Deleted.ShortTerms = count
+		if err != nil {
+			return err
+		}
+	}
+
+	if days := m.policy.LongTermDays; days > 0 {
+		// 最新的长期记忆包含了之前的全部内容，始终保留
+		count, err := m.purge(ctx, &models.LongTermMemory{}, SearchKindLongTerm,
+			`created_at < ? AND id NOT IN (SELECT MAX(id) FROM long_term_memories GROUP BY owner_id, room_id)`,
+			now.AddDate(0, 0, -days))
+		run.Deleted.LongTerms = count
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := m.optimize(ctx); err != nil {
+		return err
+	}
+	run.Optimized = true
+	return nil
+}
+
+// purgeMessages 删除 before 之前的消息及其记忆记录、检索索引和向量，工具调用记录保留但不再关联消息
+func (m *Maintainer) purgeMessages(ctx context.Context, before time.Time) (int, error) {
+	total := 0
+	for {
+		var ids []int
+		if err := m.db.WithContext(ctx).Model(&models.Message{}).Where("created_at < ?", before).
+			Order("id ASC").Limit(purgeBatchSize).Pluck("id", &ids).Error; err != nil {
+			return total, fmt.Errorf("读取过期消息失败: %v", err)
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("message_id IN ?", ids).Delete(&models.Memory{}).Error; err != nil {
+				return fmt.Errorf("删除记忆记录失败: %v", err)
+			}
+			if err := removeIndexEntries(tx, SearchKindMessage, ids); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ToolInvocation{}).Where("message_id IN ?", ids).
+				Update("message_id", nil).Error; err != nil {
+				return fmt.Errorf("更新工具调用记录失败: %v", err)
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&models.Message{}).Error; err != nil {
+				return fmt.Errorf("删除过期消息失败: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += len(ids)
+	}
+}
+
+// purge 分批删除满足条件的记录，kind 不为空时同时移除检索索引和向量
+func (m *Maintainer) purge(ctx context.Context, model interface{}, kind, query string, args ...interface{}) (int, error) {
+	var ids []int
+	if err := m.db.WithContext(ctx).Model(model).Where(query, args...).
+		Order("id ASC").Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("读取过期记录失败: %v", err)
+	}
+
+	total := 0
+	for start := 0; start < len(ids); start += purgeBatchSize {
+		end := start + purgeBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if kind != "" {
+				if err := removeIndexEntries(tx, kind, batch); err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("id IN ?", batch).Delete(model).Error; err != nil {
+				return fmt.Errorf("删除过期记录失败: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += len(batch)
+	}
+	return total, nil
+}
+
+// optimize 整理检索索引并回收数据库空间
+func (m *Maintainer) optimize(ctx context.Context) error {
+	db := m.db.WithContext(ctx)
+	if err := db.Exec("INSERT INTO memory_search(memory_search) VALUES ('optimize')").Error; err != nil {
+		logger.Warnf("整理检索索引失败: %v", err)
+	}
+	if err := db.Exec("ANALYZE").Error; err != nil {
+		return fmt.Errorf("更新数据库统计信息失败: %v", err)
+	}
+	if err := db.Exec("VACUUM").Error; err != nil {
+		return fmt.Errorf("回收数据库空间失败: %v", err)
+	}
+	return nil
+}
+
+// removeIndexEntries 批量移除检索索引和向量
+func removeIndexEntries(db *gorm.DB, kind string, ids []int) error {
+	if err := db.Exec("DELETE FROM memory_search WHERE kind = ? AND ref_id IN ?", kind, ids).Error; err != nil {
+		return fmt.Errorf("移除检索索引失败: %v", err)
+	}
+	if err := db.Where("kind = ? AND ref_id IN ?", kind, ids).Delete(&models.Embedding{}).Error; err != nil {
+		return fmt.Errorf("删除向量失败: %v", err)
+	}
+	return nil
+}
+
+// Compact 把 before 之前还未整理的消息强制总结进短期记忆，再合并进长期记忆
+// 供删除旧消息前调用，不受 messageLimit / shortTermLimit 的数量限制
+func (mm *MemoryManager) Compact(ctx context.Context, before time.Time) (CompactResult, error) {
+	var result CompactResult
+	var scopes []memoryScope
+	if err := mm.db.WithContext(ctx).Model(&models.Memory{}).
+		Distinct("owner_id", "room_id").Scan(&scopes).Error; err != nil {
+		return result, fmt.Errorf("读取记忆范围失败: %v", err)
+	}
+
+	for _, scope := range scopes {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		shortTerms, longTerms, err := mm.compactScope(ctx, scope.OwnerID, scope.RoomID, before)
+		result.ShortTerms += shortTerms
+		result.LongTerms += longTerms
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// compactScope 整理单个范围内 before 之前的消息
+func (mm *MemoryManager) compactScope(ctx context.Context, ownerID *string, roomID string, before time.Time) (int, int, error) {
+	shortTerms, longTerms := 0, 0
+
+	// 范围内最后一条消息早于 before 的记忆
+	var lastOldID int
+	if err := scopeOwner(mm.db.WithContext(ctx), ownerID).Model(&models.Memory{}).
+		Where("room_id = ? AND message_id IN (?)", roomID,
+			mm.db.Model(&models.Message{}).Select("id").Where("created_at < ?", before)).
+		Select("COALESCE(MAX(id), 0)").Scan(&lastOldID).Error; err != nil {
+		return shortTerms, longTerms, fmt.Errorf("读取过期记忆失败: %v", err)
+	}
+	if lastOldID == 0 {
+		return shortTerms, longTerms, nil
+	}
+
+	// 游标之后的记忆中，按顺序取出消息早于 before 的部分生成短期记忆
+	for {
+		if err := ctx.Err(); err != nil {
+			return shortTerms, longTerms, err
+		}
+
+		cursorID := 0
+		var lastShortTerm models.ShortTermMemory
+		if err := scopeOwner(mm.db, ownerID).Where("room_id = ?", roomID).
+			Order("id DESC").First(&lastShortTerm).Error; err == nil {
+			cursorID = lastShortTerm.CursorID
+		} else if err != gorm.ErrRecordNotFound {
+			return shortTerms, longTerms, fmt.Errorf("获取短期记忆失败: %v", err)
+		}
+
+		var memories []models.Memory
+		if err := scopeOwner(mm.db, ownerID).Preload("Message.Sender").
+			Where("room_id = ? AND id > ?", roomID, cursorID).
+			Order("id ASC").Limit(compactMessageBatch).Find(&memories).Error; err != nil {
+			return shortTerms, longTerms, fmt.Errorf("获取新记忆失败: %v", err)
+		}
+		old := 0
+		for old < len(memories) && memories[old].Message.CreatedAt.Before(before) {
+			old++
+		}
+		if old == 0 {
+			break
+		}
+
+		if _, err := mm.createShortTermMemory(ctx, ownerID, roomID, memories[:old]); err != nil {
+			return shortTerms, longTerms, err
+		}
+		shortTerms++
+	}
+
+	// 把覆盖了旧消息、但还未合并的短期记忆合并进长期记忆
+	for {
+		if err := ctx.Err(); err != nil {
+			return shortTerms, longTerms, err
+		}
+
+		cursorID := 0
+		var previous *models.LongTermMemory
+		var lastLongTerm models.LongTermMemory
+		if err := scopeOwner(mm.db, ownerID).Where("room_id = ?", roomID).
+			Order("id DESC").First(&lastLongTerm).Error; err == nil {
+			cursorID = lastLongTerm.CursorID
+			previous = &lastLongTerm
+		} else if err != gorm.ErrRecordNotFound {
+			return shortTerms, longTerms, fmt.Errorf("获取长期记忆失败: %v", err)
+		}
+
+		var shortTermMemories []models.ShortTermMemory
+		if err := scopeOwner(mm.db, ownerID).Where("room_id = ? AND id > ?", roomID, cursorID).
+			Order("id ASC").Limit(compactShortTermBatch).Find(&shortTermMemories).Error; err != nil {
+			return shortTerms, longTerms, fmt.Errorf("获取短期记忆失败: %v", err)
+		}
+		if len(shortTermMemories) == 0 || shortTermMemories[0].CursorID > lastOldID {
+			break
+		}
+
+		if _, err := mm.createLongTermMemory(ctx, ownerID, roomID, previous, shortTermMemories); err != nil {
+			return shortTerms, longTerms, err
+		}
+		longTerms++
+	}
+	return shortTerms, longTerms, nil
+}
@@ -7,15 +7,15 @@ import (
 	"mi-gpt-go/internal/services/openai"
 	"mi-gpt-go/pkg/logger"
 	"strings"
-	"time"
 
 	"gorm.io/gorm"
 )
 
 // MemoryManager 记忆管理器
 type MemoryManager struct {
-	db       *gorm.DB           // 数据库连接
-	aiClient *openai.Client     // AI客户端，用于生成记忆摘要
+	db          *gorm.DB           // 数据库连接
+	aiClient    *openai.Client     // AI客户端，用于生成记忆摘要和向量嵌入
+	vectorStore VectorStore        // 向量存储，未启用语义记忆时为空
 }
 
 // NewMemoryManager 创建记忆管理器
@@ -44,82 +44,120 @@ func (mm *MemoryManager) AddMessageMemory(ctx context.Context, message *models.M
 }
 
 // UpdateShortTermMemory 更新短期记忆
-func (mm *MemoryManager) UpdateShortTermMemory(ctx context.Context, ownerID *string, roomID string, messageLimit int) error {
-	// 获取最近的消息
-	var messages []models.Message
-	query := mm.db.Preload("Sender").Where("room_id = ?", roomID).Order("created_at DESC").Limit(messageLimit)
-	if err := query.Find(&messages).Error; err != nil {
-		return fmt.Errorf("获取最近消息失败: %v", err)
+// 自上一条短期记忆之后积累了至少 messageLimit 条新记忆时，总结这些消息生成一条新的短期记忆
+// 返回是否生成了新的短期记忆
+func (mm *MemoryManager) UpdateShortTermMemory(ctx context.Context, ownerID *string, roomID string, messageLimit int) (bool, error) {
+	if messageLimit <= 0 {
+		messageLimit = 10
 	}
 
-	if len(messages) == 0 {
-		return nil
+	// 上一条短期记忆的游标
+	cursorID := 0
+	var lastShortTerm models.ShortTermMemory
+	if err := scopeOwner(mm.db, ownerID).Where("room_id = ?", roomID).
+		Order("id DESC").First(&lastShortTerm).Error; err == nil {
+		cursorID = lastShortTerm.CursorID
+	} else if err != gorm.ErrRecordNotFound {
+		return false, fmt.Errorf("获取短期记忆失败: %v", err)
+	}
+
+	// 游标之后的新记忆
+	var memories []models.Memory
+	if err := scopeOwner(mm.db, ownerID).Preload("Message.Sender").
+		Where("room_id = ? AND id > ?", roomID, cursorID).
+		Order("id ASC").Find(&memories).Error; err != nil {
+		return false, fmt.Errorf("获取新记忆失败: %v", err)
+	}
+	if len(memories) < messageLimit {
+		return false, nil
+	}
+
+	if _, err := mm.createShortTermMemory(ctx, ownerID, roomID, memories); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// createShortTermMemory 总结记忆对应的消息生成一条短期记忆，游标指向最后一条记忆
+func (mm *MemoryManager) createShortTermMemory(ctx context.Context, ownerID *string, roomID string, memories []models.Memory) (*models.ShortTermMemory, error) {
+	messages := make([]models.Message, 0, len(memories))
+	for _, memory := range memories {
+		messages = append(messages, memory.Message)
 	}
 
 	// 生成短期记忆摘要
 	summary, err := mm.generateMemorySummary(ctx, messages, "short")
 	if err != nil {
-		logger.Errorf("生成短期记忆摘要失败: %v", err)
-		return err
-	}
-
-	// 获取最新的记忆记录作为游标
-	var latestMemory models.Memory
-	if err := mm.db.Where("room_id = ? AND owner_id = ?", roomID, ownerID).
-		Order("created_at DESC").First(&latestMemory).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			logger.Debug("未找到记忆记录，跳过短期记忆更新")
-			return nil
-		}
-		return fmt.Errorf("获取最新记忆失败: %v", err)
+		return nil, err
 	}
 
-	// 创建或更新短期记忆
 	shortTermMemory := &models.ShortTermMemory{
 		Text:     summary,
-		CursorID: latestMemory.ID,
+		CursorID: memories[len(memories)-1].ID,
 		OwnerID:  ownerID,
 		RoomID:   roomID,
 	}
-
-	// 尝试更新现有的短期记忆，如果不存在则创建新的
-	var existingMemory models.ShortTermMemory
-	if err := mm.db.Where("owner_id = ? AND room_id = ?", ownerID, roomID).
-		Order("created_at DESC").First(&existingMemory).Error; err == nil {
-		// 更新现有记忆
-		existingMemory.Text = summary
-		existingMemory.CursorID = latestMemory.ID
-		if err := mm.db.Save(&existingMemory).Error; err != nil {
-			return fmt.Errorf("更新短期记忆失败: %v", err)
-		}
-		logger.Debug("已更新短期记忆")
-	} else {
-		// 创建新记忆
-		if err := mm.db.Create(shortTermMemory).Error; err != nil {
-			return fmt.Errorf("创建短期记忆失败: %v", err)
-		}
-		logger.Debug("已创建新的短期记忆")
+	if err := mm.db.Create(shortTermMemory).Error; err != nil {
+		return nil, fmt.Errorf("创建短期记忆失败: %v", err)
+	}
+	if err := IndexEntry(mm.db, SearchKindShortTerm, shortTermMemory.ID, summary, roomID, ownerID, shortTermMemory.CreatedAt); err != nil {
+		logger.Warnf("%v", err)
 	}
+	logEmbedError(mm.embed(ctx, []VectorEntry{{Kind: SearchKindShortTerm, RefID: shortTermMemory.ID, Text: summary,
+		RoomID: roomID, OwnerID: ownerID, CreatedAt: shortTermMemory.CreatedAt}}))
 
-	return nil
+	logger.Debugf("已根据 %d 条消息生成短期记忆 %d", len(messages), shortTermMemory.ID)
+	return shortTermMemory, nil
 }
 
 // UpdateLongTermMemory 更新长期记忆
-func (mm *MemoryManager) UpdateLongTermMemory(ctx context.Context, ownerID *string, roomID string) error {
-	// 获取多个短期记忆
-	var shortTermMemories []models.ShortTermMemory
-	if err := mm.db.Where("owner_id = ? AND room_id = ?", ownerID, roomID).
-		Order("created_at DESC").Limit(5).Find(&shortTermMemories).Error; err != nil {
-		return fmt.Errorf("获取短期记忆失败: %v", err)
+// 自上一条长期记忆之后积累了至少 shortTermLimit 条新的短期记忆时，与上一条长期记忆合并生成新的长期记忆
+// 返回是否生成了新的长期记忆
+func (mm *MemoryManager) UpdateLongTermMemory(ctx context.Context, ownerID *string, roomID string, shortTermLimit int) (bool, error) {
+	if shortTermLimit <= 0 {
+		shortTermLimit = 3
 	}
 
-	if len(shortTermMemories) < 2 {
+	// 上一条长期记忆及其游标
+	cursorID := 0
+	var lastLongTerm models.LongTermMemory
+	hasLongTerm := false
+	if err := scopeOwner(mm.db, ownerID).Where("room_id = ?", roomID).
+		Order("id DESC").First(&lastLongTerm).Error; err == nil {
+		cursorID = lastLongTerm.CursorID
+		hasLongTerm = true
+	} else if err != gorm.ErrRecordNotFound {
+		return false, fmt.Errorf("获取长期记忆失败: %v", err)
+	}
+
+	// 游标之后的新短期记忆
+	var shortTermMemories []models.ShortTermMemory
+	if err := scopeOwner(mm.db, ownerID).Where("room_id = ? AND id > ?", roomID, cursorID).
+		Order("id ASC").Find(&shortTermMemories).Error; err != nil {
+		return false, fmt.Errorf("获取短期记忆失败: %v", err)
+	}
+	if len(shortTermMemories) < shortTermLimit {
 		logger.Debug("短期记忆数量不足，跳过长期记忆更新")
-		return nil
+		return false, nil
 	}
 
-	// 合并短期记忆文本
+	var previous *models.LongTermMemory
+	if hasLongTerm {
+		previous = &lastLongTerm
+	}
+	if _, err := mm.createLongTermMemory(ctx, ownerID, roomID, previous, shortTermMemories); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// createLongTermMemory 合并上一条长期记忆（可为空）和短期记忆生成一条新的长期记忆，游标指向最后一条短期记忆
+func (mm *MemoryManager) createLongTermMemory(ctx context.Context, ownerID *string, roomID string, previous *models.LongTermMemory, shortTermMemories []models.ShortTermMemory) (*models.LongTermMemory, error) {
+	// 合并上一条长期记忆和新的短期记忆
 	var memoryTexts []string
+	if previous != nil {
+		memoryTexts = append(memoryTexts, "已有的长期记忆：\n"+previous.Text, "新的短期记忆：")
+	}
 	for _, memory := range shortTermMemories {
 		memoryTexts = append(memoryTexts, memory.Text)
 	}
@@ -128,34 +166,33 @@ func (mm *MemoryManager) UpdateLongTermMemory(ctx context.Context, ownerID *stri
 	// 生成长期记忆摘要
 	summary, err := mm.generateLongTermSummary(ctx, combinedText)
 	if err != nil {
-		logger.Errorf("生成长期记忆摘要失败: %v", err)
-		return err
+		return nil, err
 	}
 
-	// 使用最新的短期记忆作为游标
-	latestShortTerm := shortTermMemories[0]
-
-	// 创建长期记忆
 	longTermMemory := &models.LongTermMemory{
 		Text:     summary,
-		CursorID: latestShortTerm.ID,
+		CursorID: shortTermMemories[len(shortTermMemories)-1].ID,
 		OwnerID:  ownerID,
 		RoomID:   roomID,
 	}
-
 	if err := mm.db.Create(longTermMemory).Error; err != nil {
-		return fmt.Errorf("创建长期记忆失败: %v", err)
+		return nil, fmt.Errorf("创建长期记忆失败: %v", err)
+	}
+	if err := IndexEntry(mm.db, SearchKindLongTerm, longTermMemory.ID, summary, roomID, ownerID, longTermMemory.CreatedAt); err != nil {
+		logger.Warnf("%v", err)
 	}
+	logEmbedError(mm.embed(ctx, []VectorEntry{{Kind: SearchKindLongTerm, RefID: longTermMemory.ID, Text: summary,
+		RoomID: roomID, OwnerID: ownerID, CreatedAt: longTermMemory.CreatedAt}}))
 
-	logger.Debug("已创建新的长期记忆")
-	return nil
+	logger.Debugf("已根据 %d 条短期记忆生成长期记忆 %d", len(shortTermMemories), longTermMemory.ID)
+	return longTermMemory, nil
 }
 
 // GetShortTermMemories 获取短期记忆
 func (mm *MemoryManager) GetShortTermMemories(ownerID *string, roomID string, limit int) ([]models.ShortTermMemory, error) {
 	var memories []models.ShortTermMemory
-	query := mm.db.Where("owner_id = ? AND room_id = ?", ownerID, roomID).
-		Order("created_at DESC").Limit(limit)
+	query := scopeOwner(mm.db, ownerID).Where("room_id = ?", roomID).
+		Order("id DESC").Limit(limit)
 	
 	if err := query.Find(&memories).Error; err != nil {
 		return nil, fmt.Errorf("获取短期记忆失败: %v", err)
@@ -167,8 +204,8 @@ func (mm *MemoryManager) GetShortTermMemories(ownerID *string, roomID string, li
 // GetLongTermMemories 获取长期记忆
 func (mm *MemoryManager) GetLongTermMemories(ownerID *string, roomID string, limit int) ([]models.LongTermMemory, error) {
 	var memories []models.LongTermMemory
-	query := mm.db.Where("owner_id = ? AND room_id = ?", ownerID, roomID).
-		Order("created_at DESC").Limit(limit)
+	query := scopeOwner(mm.db, ownerID).Where("room_id = ?", roomID).
+		Order("id DESC").Limit(limit)
 	
 	if err := query.Find(&memories).Error; err != nil {
 		return nil, fmt.Errorf("获取长期记忆失败: %v", err)
@@ -177,16 +214,23 @@ func (mm *MemoryManager) GetLongTermMemories(ownerID *string, roomID string, lim
 	return memories, nil
 }
 
+// scopeOwner 按记忆所有者过滤，ownerID 为空时表示房间共享的记忆
+func scopeOwner(db *gorm.DB, ownerID *string) *gorm.DB {
+	if ownerID == nil {
+		return db.Where("owner_id IS NULL")
+	}
+	return db.Where("owner_id = ?", *ownerID)
+}
+
 // generateMemorySummary 生成记忆摘要
 func (mm *MemoryManager) generateMemorySummary(ctx context.Context, messages []models.Message, memoryType string) (string, error) {
 	if mm.aiClient == nil {
-		return "AI客户端未初始化，无法生成记忆摘要", nil
+		return "", fmt.Errorf("AI客户端未初始化，无法生成记忆摘要")
 	}
 
 	// 构建对话内容
 	var dialogues []string
-	for i := len(messages) - 1; i >= 0; i-- { // 按时间顺序排列
-		msg := messages[i]
+	for _, msg := range messages { // messages 已按时间顺序排列
 		dialogues = append(dialogues, fmt.Sprintf("%s: %s", msg.Sender.Name, msg.Text))
 	}
 	conversationText := strings.Join(dialogues, "\n")
@@ -218,9 +262,10 @@ func (mm *MemoryManager) generateMemorySummary(ctx context.Context, messages []m
 
 	// 调用AI生成摘要
 	options := openai.ChatOptions{
-		User:   userPrompt,
-		System: systemPrompt,
-		Trace:  false,
+		User:    userPrompt,
+		System:  systemPrompt,
+		Trace:   false,
+		Purpose: openai.PurposeMemory,
 	}
 
 	summary, err := mm.aiClient.Chat(ctx, options)
@@ -234,7 +279,7 @@ func (mm *MemoryManager) generateMemorySummary(ctx context.Context, messages []m
 // generateLongTermSummary 生成长期记忆摘要
 func (mm *MemoryManager) generateLongTermSummary(ctx context.Context, shortTermMemories string) (string, error) {
 	if mm.aiClient == nil {
-		return "AI客户端未初始化，无法生成长期记忆摘要", nil
+		return "", fmt.Errorf("AI客户端未初始化，无法生成长期记忆摘要")
 	}
 
 	systemPrompt := `你是一个记忆管理助手。请根据多个短期记忆内容，生成一个综合的长期记忆摘要。
@@ -250,9 +295,10 @@ func (mm *MemoryManager) generateLongTermSummary(ctx context.Context, shortTermM
 	userPrompt := fmt.Sprintf("以下是需要整合的短期记忆内容：\n\n%s", shortTermMemories)
 
 	options := openai.ChatOptions{
-		User:   userPrompt,
-		System: systemPrompt,
-		Trace:  false,
+		User:    userPrompt,
+		System:  systemPrompt,
+		Trace:   false,
+		Purpose: openai.PurposeMemory,
 	}
 
 	summary, err := mm.aiClient.Chat(ctx, options)
@@ -262,22 +308,3 @@ func (mm *MemoryManager) generateLongTermSummary(ctx context.Context, shortTermM
 
 	return strings.TrimSpace(summary), nil
 }
-
-// CleanOldMemories 清理过期的记忆（可选功能）
-func (mm *MemoryManager) CleanOldMemories(ctx context.Context, daysToKeep int) error {
-	cutoffDate := time.Now().AddDate(0, 0, -daysToKeep)
-
-	// 清理过期的短期记忆
-	if err := mm.db.Where("created_at < ?", cutoffDate).Delete(&models.ShortTermMemory{}).Error; err != nil {
-		logger.Errorf("清理过期短期记忆失败: %v", err)
-	}
-
-	// 保留长期记忆更长时间
-	longTermCutoff := time.Now().AddDate(0, 0, -daysToKeep*3)
-	if err := mm.db.Where("created_at < ?", longTermCutoff).Delete(&models.LongTermMemory{}).Error; err != nil {
-		logger.Errorf("清理过期长期记忆失败: %v", err)
-	}
-
-	logger.Infof("已清理 %d 天前的过期记忆", daysToKeep)
-	return nil
-} 
\ No newline at end of file
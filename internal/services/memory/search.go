@@ -0,0 +1,299 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/pkg/logger"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// 检索条目类型
+const (
+	SearchKindMessage   = "message" // 对话消息
+	SearchKindShortTerm = "short"   // 短期记忆
+	SearchKindLongTerm  = "long"    // 长期记忆
+)
+
+// SearchResult 检索结果
+type SearchResult struct {
+	Kind      string    `json:"kind"`
+	RefID     int       `json:"refId"` // 对应消息或记忆的ID
+	Text      string    `json:"text"`
+	RoomID    string    `json:"roomId"`
+	OwnerID   string    `json:"ownerId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Score     float64   `json:"score"` // 相关度（BM25、余弦相似度或融合分数），越大越相关
+}
+
+// SearchOptions 检索条件
+type SearchOptions struct {
+	Query  string
+	Kinds  []string // 为空表示全部类型
+	RoomID string   // 为空表示全部房间
+	// OwnerID 不为空时只检索该成员和不属于任何成员的条目，指向空字符串时只检索不属于任何成员的条目
+	OwnerID *string
+	Limit   int
+}
+
+// searchRow 索引表中的一行
+type searchRow struct {
+	Text      string
+	Kind      string
+	RefID     int
+	RoomID    string
+	OwnerID   string
+	CreatedAt int64
+	Rank      float64
+}
+
+// Tokenize 把文本切分为检索词元：中日韩文字按相邻两字切分（单字保留原字），其余按单词切分并转为小写
+func Tokenize(text string) []string {
+	var tokens []string
+	var cjk []rune
+	var word []rune
+
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushCJK()
+			flushWord()
+		}
+	}
+	flushCJK()
+	flushWord()
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// buildMatchQuery 把查询文本转换为FTS5的MATCH表达式，任一词元命中即可，由BM25排序
+func buildMatchQuery(query string) string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range Tokenize(query) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		terms = append(terms, `"`+strings.ReplaceAll(token, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// IndexEntry 把一条文本写入检索索引
+func IndexEntry(db *gorm.DB, kind string, refID int, text, roomID string, ownerID *string, createdAt time.Time) error {
+	owner := ""
+	if ownerID != nil {
+		owner = *ownerID
+	}
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	err := db.Exec(`INSERT INTO memory_search (tokens, text, kind, ref_id, room_id, owner_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		strings.Join(Tokenize(text), " "), text, kind, refID, roomID, owner, createdAt.Unix()).Error
+	if err != nil {
+		return fmt.Errorf("写入检索索引失败: %v", err)
+	}
+	return nil
+}
+
+// IndexMessage 把消息写入检索索引
+func IndexMessage(db *gorm.DB, message *models.Message) error {
+	return IndexEntry(db, SearchKindMessage, message.ID, message.Text, message.RoomID, nil, message.CreatedAt)
+}
+
+// Search 按相关度检索消息和记忆
+func Search(db *gorm.DB, options SearchOptions) ([]SearchResult, error) {
+	match := buildMatchQuery(options.Query)
+	if match == "" {
+		return nil, nil
+	}
+	limit := options.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := db.Table("memory_search").
+		Select("text, kind, ref_id, room_id, owner_id, created_at, bm25(memory_search) AS rank").
+		Where("memory_search MATCH ?", match)
+	if len(options.Kinds) > 0 {
+		query = query.Where("kind IN ?", options.Kinds)
+	}
+	if options.RoomID != "" {
+		query = query.Where("room_id = ?", options.RoomID)
+	}
+	if options.OwnerID != nil {
+		// 索引中不属于任何成员的条目 owner_id 为空字符串
+		query = query.Where("(owner_id = '' OR owner_id = ?)", *options.OwnerID)
+	}
+
+	var rows []searchRow
+	if err := query.Order("rank").Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("检索失败: %v", err)
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, SearchResult{
+			Kind:      row.Kind,
+			RefID:     row.RefID,
+			Text:      row.Text,
+			RoomID:    row.RoomID,
+			OwnerID:   row.OwnerID,
+			CreatedAt: time.Unix(row.CreatedAt, 0),
+			Score:     -row.Rank,
+		})
+	}
+	return results, nil
+}
+
+// RebuildSearchIndex 清空并重建检索索引，返回索引的条目数
+func RebuildSearchIndex(db *gorm.DB) (int, error) {
+	count := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM memory_search").Error; err != nil {
+			return fmt.Errorf("清空检索索引失败: %v", err)
+		}
+
+		var messages []models.Message
+		if err := tx.Find(&messages).Error; err != nil {
+			return fmt.Errorf("读取消息失败: %v", err)
+		}
+		for i := range messages {
+			if err := IndexMessage(tx, &messages[i]); err != nil {
+				return err
+			}
+		}
+
+		var shortTermMemories []models.ShortTermMemory
+		if err := tx.Find(&shortTermMemories).Error; err != nil {
+			return fmt.Errorf("读取短期记忆失败: %v", err)
+		}
+		for _, memory := range shortTermMemories {
+			if err := IndexEntry(tx, SearchKindShortTerm, memory.ID, memory.Text, memory.RoomID, memory.OwnerID, memory.CreatedAt); err != nil {
+				return err
+			}
+		}
+
+		var longTermMemories []models.LongTermMemory
+		if err := tx.Find(&longTermMemories).Error; err != nil {
+			return fmt.Errorf("读取长期记忆失败: %v", err)
+		}
+		for _, memory := range longTermMemories {
+			if err := IndexEntry(tx, SearchKindLongTerm, memory.ID, memory.Text, memory.RoomID, memory.OwnerID, memory.CreatedAt); err != nil {
+				return err
+			}
+		}
+
+		count = len(messages) + len(shortTermMemories) + len(longTermMemories)
+		return nil
+	})
+	return count, err
+}
+
+// EnsureSearchIndex 检索索引为空而已有消息时（例如从旧版本升级），重建索引
+func EnsureSearchIndex(db *gorm.DB) error {
+	var indexed int64
+	if err := db.Table("memory_search").Count(&indexed).Error; err != nil {
+		return fmt.Errorf("读取检索索引失败: %v", err)
+	}
+	if indexed > 0 {
+		return nil
+	}
+
+	var messages int64
+	if err := db.Model(&models.Message{}).Count(&messages).Error; err != nil {
+		return fmt.Errorf("读取消息失败: %v", err)
+	}
+	if messages == 0 {
+		return nil
+	}
+
+	_, err := RebuildSearchIndex(db)
+	return err
+}
+
+// Recall 检索与查询相关的记忆，按相关度依次选取，不超过条数和token预算
+// 启用语义记忆时同时进行关键词检索和向量检索，按倒数排名融合排序
+// 只召回 ownerID 对应成员和不属于任何成员的记忆，同一房间其他成员的记忆不会出现在回答中
+// since 之后的消息已作为对话历史携带，不再重复召回
+func (mm *MemoryManager) Recall(ctx context.Context, query string, ownerID *string, roomID string, since time.Time, limit, maxTokens int) ([]SearchResult, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	owner := ""
+	if ownerID != nil {
+		owner = *ownerID
+	}
+
+	// 多取一些候选，过滤后再按预算截取
+	candidates, err := Search(mm.db, SearchOptions{
+		Query:   query,
+		RoomID:  roomID,
+		OwnerID: &owner,
+		Limit:   limit * 3,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mm.SemanticEnabled() {
+		semantic, err := mm.semanticSearch(ctx, query, &owner, roomID, nil, limit*3, minVectorSimilarity)
+		if err != nil {
+			// 嵌入接口不可用时退回关键词检索
+			logger.Warnf("语义检索失败: %v", err)
+		} else {
+			candidates = fuseResults(candidates, semantic)
+		}
+	}
+
+	var results []SearchResult
+	tokens := 0
+	for _, candidate := range candidates {
+		if candidate.Kind == SearchKindMessage && !candidate.CreatedAt.Before(since) {
+			continue
+		}
+		cost := openai.EstimateTokens(candidate.Text)
+		if maxTokens > 0 && tokens+cost > maxTokens {
+			continue
+		}
+		tokens += cost
+		results = append(results, candidate)
+		if len(results) >= limit {
+			break
+		}
+	}
+	return results, nil
+}
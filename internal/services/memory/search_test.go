@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"context"
+	"mi-gpt-go/internal/database/databasetest"
+	"sort"
+	"testing"
+	"time"
+)
+
+const testRoomID = "room-1"
+
+func refIDs(results []SearchResult) []int {
+	ids := make([]int, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.RefID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRecallOnlyReturnsSpeakerAndSharedMemories(t *testing.T) {
+	db := databasetest.Open(t)
+	alice, bob := "alice", "bob"
+	earlier := time.Now().Add(-time.Hour)
+
+	entries := []struct {
+		kind  string
+		refID int
+		text  string
+		owner *string
+	}{
+		{SearchKindLongTerm, 1, "小明喜欢吃苹果，不吃辣", &alice},
+		{SearchKindLongTerm, 2, "小红喜欢吃香蕉，最近在减肥", &bob},
+		{SearchKindShortTerm, 3, "小红说喜欢吃火锅", &bob},
+		{SearchKindMessage, 4, "周末大家一起吃什么", nil},
+	}
+	for _, entry := range entries {
+		if err := IndexEntry(db, entry.kind, entry.refID, entry.text, testRoomID, entry.owner, earlier); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mm := NewMemoryManager(db, nil)
+	for _, tc := range []struct {
+		owner *string
+		want  []int
+	}{
+		{&alice, []int{1, 4}},
+		{&bob, []int{2, 3, 4}},
+		{nil, []int{4}},
+	} {
+		results, err := mm.Recall(context.Background(), "喜欢吃什么", tc.owner, testRoomID, time.Now(), 10, 0)
+		if err != nil {
+			t.Fatalf("检索失败: %v", err)
+		}
+		if got := refIDs(results); !equalIDs(got, tc.want) {
+			name := "未识别成员"
+			if tc.owner != nil {
+				name = *tc.owner
+			}
+			t.Errorf("%s 召回 %v, 期望 %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestVectorSearchFiltersByOwner(t *testing.T) {
+	db := databasetest.Open(t)
+	store := NewSQLiteVectorStore(db)
+	alice, bob := "alice", "bob"
+	ctx := context.Background()
+
+	err := store.Upsert(ctx, "test", []VectorEntry{
+		{Kind: SearchKindLongTerm, RefID: 1, Text: "小明喜欢吃苹果", RoomID: testRoomID, OwnerID: &alice, Vector: []float32{1, 0}},
+		{Kind: SearchKindLongTerm, RefID: 2, Text: "小红喜欢吃香蕉", RoomID: testRoomID, OwnerID: &bob, Vector: []float32{1, 0.1}},
+		{Kind: SearchKindMessage, RefID: 3, Text: "周末大家一起吃什么", RoomID: testRoomID, Vector: []float32{0.9, 0.2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := store.Search(ctx, []float32{1, 0}, VectorFilter{Model: "test", RoomID: testRoomID, OwnerID: &alice}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := refIDs(results), []int{1, 3}; !equalIDs(got, want) {
+		t.Errorf("alice 的向量检索结果 %v, 期望 %v", got, want)
+	}
+
+	results, err = store.Search(ctx, []float32{1, 0}, VectorFilter{Model: "test", RoomID: testRoomID}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := refIDs(results), []int{1, 2, 3}; !equalIDs(got, want) {
+		t.Errorf("不限成员的向量检索结果 %v, 期望 %v", got, want)
+	}
+}
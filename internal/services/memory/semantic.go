@@ -0,0 +1,250 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/pkg/logger"
+)
+
+const (
+	embeddingBatchSize  = 64   // 每次请求嵌入接口的最大条数
+	minVectorSimilarity = 0.3  // 低于该相似度的向量结果视为无关
+	rrfK                = 60.0 // 倒数排名融合的平滑常数
+)
+
+// SetVectorStore 启用语义记忆，store 为空时停用
+// 需要AI客户端支持嵌入接口
+func (mm *MemoryManager) SetVectorStore(store VectorStore) {
+	mm.vectorStore = store
+}
+
+// SemanticEnabled 是否启用了语义记忆
+func (mm *MemoryManager) SemanticEnabled() bool {
+	return mm.vectorStore != nil && mm.aiClient != nil && mm.aiClient.SupportsEmbedding()
+}
+
+// embeddingModel 向量库中区分嵌入模型的标识，维度不同的向量不能混用
+func (mm *MemoryManager) embeddingModel() string {
+	model := mm.aiClient.EmbeddingModel()
+	if dimensions := mm.aiClient.EmbeddingDimensions(); dimensions > 0 {
+		model = fmt.Sprintf("%s@%d", model, dimensions)
+	}
+	return model
+}
+
+// EmbedMessages 为消息生成向量并写入向量库
+func (mm *MemoryManager) EmbedMessages(ctx context.Context, messages ...*models.Message) error {
+	entries := make([]VectorEntry, 0, len(messages))
+	for _, message := range messages {
+		entries = append(entries, VectorEntry{
+			Kind:      SearchKindMessage,
+			RefID:     message.ID,
+			Text:      message.Text,
+			RoomID:    message.RoomID,
+			CreatedAt: message.CreatedAt,
+		})
+	}
+	return mm.embed(ctx, entries)
+}
+
+// embed 批量生成向量并写入向量库，未启用语义记忆时直接返回
+func (mm *MemoryManager) embed(ctx context.Context, entries []VectorEntry) error {
+	if !mm.SemanticEnabled() || len(entries) == 0 {
+		return nil
+	}
+
+	model := mm.embeddingModel()
+	for start := 0; start < len(entries); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		batch := entries[start:end]
+
+		texts := make([]string, len(batch))
+		for i, entry := range batch {
+			texts[i] = entry.Text
+		}
+		vectors, err := mm.aiClient.Embed(ctx, texts)
+		if err != nil {
+			return err
+		}
+		for i := range batch {
+			batch[i].Vector = vectors[i]
+		}
+		if err := mm.vectorStore.Upsert(ctx, model, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillEmbeddings 为尚未生成向量的消息和记忆补充向量（例如首次启用或更换嵌入模型后），返回补充的条数
+func (mm *MemoryManager) BackfillEmbeddings(ctx context.Context) (int, error) {
+	if !mm.SemanticEnabled() {
+		return 0, nil
+	}
+
+	model := mm.embeddingModel()
+	total := 0
+
+	// 消息按批读取，避免一次加载全部历史
+	lastID := 0
+	for {
+		var messages []models.Message
+		if err := mm.db.WithContext(ctx).Where("id > ?", lastID).
+			Order("id ASC").Limit(embeddingBatchSize).Find(&messages).Error; err != nil {
+			return total, fmt.Errorf("读取消息失败: %v", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+		lastID = messages[len(messages)-1].ID
+
+		ids := make([]int, len(messages))
+		for i, message := range messages {
+			ids[i] = message.ID
+		}
+		existing, err := mm.vectorStore.Has(ctx, model, SearchKindMessage, ids)
+		if err != nil {
+			return total, err
+		}
+
+		var pending []*models.Message
+		for i := range messages {
+			if !existing[messages[i].ID] {
+				pending = append(pending, &messages[i])
+			}
+		}
+		if err := mm.EmbedMessages(ctx, pending...); err != nil {
+			return total, err
+		}
+		total += len(pending)
+	}
+
+	var shortTermMemories []models.ShortTermMemory
+	if err := mm.db.WithContext(ctx).Find(&shortTermMemories).Error; err != nil {
+		return total, fmt.Errorf("读取短期记忆失败: %v", err)
+	}
+	entries := make([]VectorEntry, 0, len(shortTermMemories))
+	for _, memory := range shortTermMemories {
+		entries = append(entries, VectorEntry{Kind: SearchKindShortTerm, RefID: memory.ID, Text: memory.Text,
+			RoomID: memory.RoomID, OwnerID: memory.OwnerID, CreatedAt: memory.CreatedAt})
+	}
+	count, err := mm.embedMissing(ctx, model, SearchKindShortTerm, entries)
+	total += count
+	if err != nil {
+		return total, err
+	}
+
+	var longTermMemories []models.LongTermMemory
+	if err := mm.db.WithContext(ctx).Find(&longTermMemories).Error; err != nil {
+		return total, fmt.Errorf("读取长期记忆失败: %v", err)
+	}
+	entries = make([]VectorEntry, 0, len(longTermMemories))
+	for _, memory := range longTermMemories {
+		entries = append(entries, VectorEntry{Kind: SearchKindLongTerm, RefID: memory.ID, Text: memory.Text,
+			RoomID: memory.RoomID, OwnerID: memory.OwnerID, CreatedAt: memory.CreatedAt})
+	}
+	count, err = mm.embedMissing(ctx, model, SearchKindLongTerm, entries)
+	total += count
+	return total, err
+}
+
+// embedMissing 只为向量库中还没有的条目生成向量
+func (mm *MemoryManager) embedMissing(ctx context.Context, model, kind string, entries []VectorEntry) (int, error) {
+	ids := make([]int, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.RefID
+	}
+	existing, err := mm.vectorStore.Has(ctx, model, kind, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	var pending []VectorEntry
+	for _, entry := range entries {
+		if !existing[entry.RefID] {
+			pending = append(pending, entry)
+		}
+	}
+	if err := mm.embed(ctx, pending); err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// semanticSearch 检索与查询语义相近的消息和记忆，Score 为余弦相似度
+// ownerID 的含义同 SearchOptions.OwnerID
+func (mm *MemoryManager) semanticSearch(ctx context.Context, query string, ownerID *string, roomID string, kinds []string, limit int, minScore float64) ([]SearchResult, error) {
+	if !mm.SemanticEnabled() {
+		return nil, nil
+	}
+
+	vectors, err := mm.aiClient.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	results, err := mm.vectorStore.Search(ctx, vectors[0], VectorFilter{
+		Model:   mm.embeddingModel(),
+		RoomID:  roomID,
+		OwnerID: ownerID,
+		Kinds:   kinds,
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	relevant := results[:0]
+	for _, result := range results {
+		if result.Score >= minScore {
+			relevant = append(relevant, result)
+		}
+	}
+	return relevant, nil
+}
+
+// SimilarMessages 检索与查询语义相近的消息，未启用语义记忆时返回空
+func (mm *MemoryManager) SimilarMessages(ctx context.Context, query, roomID string, limit int, minScore float64) ([]SearchResult, error) {
+	return mm.semanticSearch(ctx, query, nil, roomID, []string{SearchKindMessage}, limit, minScore)
+}
+
+// fuseResults 用倒数排名融合（RRF）合并多路检索结果，Score 为融合后的分数
+func fuseResults(lists ...[]SearchResult) []SearchResult {
+	type fused struct {
+		result SearchResult
+		score  float64
+	}
+
+	var order []string
+	merged := make(map[string]*fused)
+	for _, list := range lists {
+		for rank, result := range list {
+			key := fmt.Sprintf("%s:%d", result.Kind, result.RefID)
+			item, ok := merged[key]
+			if !ok {
+				item = &fused{result: result}
+				merged[key] = item
+				order = append(order, key)
+			}
+			item.score += 1 / (rrfK + float64(rank+1))
+		}
+	}
+
+	results := make([]SearchResult, 0, len(order))
+	for _, key := range order {
+		item := merged[key]
+		item.result.Score = item.score
+		results = append(results, item.result)
+	}
+	sortByScore(results)
+	return results
+}
+
+// logEmbedError 记录后台生成向量的失败，不影响对话
+func logEmbedError(err error) {
+	if err != nil {
+		logger.Warnf("生成向量失败: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"mi-gpt-go/internal/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VectorEntry 待写入向量库的条目
+type VectorEntry struct {
+	Kind      string
+	RefID     int
+	Text      string
+	RoomID    string
+	OwnerID   *string
+	CreatedAt time.Time
+	Vector    []float32
+}
+
+// VectorFilter 向量检索条件
+type VectorFilter struct {
+	Model   string   // 只比较同一嵌入模型生成的向量
+	RoomID  string   // 为空表示全部房间
+	OwnerID *string  // 不为空时只比较该成员和不属于任何成员的条目，指向空字符串时只比较不属于任何成员的条目
+	Kinds   []string // 为空表示全部类型
+}
+
+// VectorStore 向量存储，可替换为其他向量数据库实现
+type VectorStore interface {
+	// Upsert 写入或覆盖条目的向量
+	Upsert(ctx context.Context, model string, entries []VectorEntry) error
+	// Search 按余弦相似度返回最相似的 limit 条结果，Score 为相似度
+	Search(ctx context.Context, vector []float32, filter VectorFilter, limit int) ([]SearchResult, error)
+	// Delete 删除条目的向量
+	Delete(ctx context.Context, kind string, refID int) error
+	// Has 返回已有指定模型向量的条目ID
+	Has(ctx context.Context, model, kind string, refIDs []int) (map[int]bool, error)
+	// Count 统计指定模型的向量数量
+	Count(ctx context.Context, model string) (int64, error)
+}
+
+// SQLiteVectorStore 基于SQLite的向量存储，向量以BLOB保存，检索时逐条计算余弦相似度
+// 家庭场景下的数据量在万条以内，暴力检索已足够快
+type SQLiteVectorStore struct {
+	db *gorm.DB
+}
+
+// NewSQLiteVectorStore 创建SQLite向量存储
+func NewSQLiteVectorStore(db *gorm.DB) *SQLiteVectorStore {
+	return &SQLiteVectorStore{db: db}
+}
+
+// Upsert 写入或覆盖条目的向量
+func (s *SQLiteVectorStore) Upsert(ctx context.Context, model string, entries []VectorEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	rows := make([]models.Embedding, 0, len(entries))
+	for _, entry := range entries {
+		createdAt := entry.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		rows = append(rows, models.Embedding{
+			Kind:       entry.Kind,
+			RefID:      entry.RefID,
+			RoomID:     entry.RoomID,
+			OwnerID:    entry.OwnerID,
+			Text:       entry.Text,
+			Model:      model,
+			Dimensions: len(entry.Vector),
+			Vector:     encodeVector(entry.Vector),
+			CreatedAt:  createdAt,
+		})
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kind"}, {Name: "ref_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"room_id", "owner_id", "text", "model", "dimensions", "vector", "created_at"}),
+	}).Create(&rows).Error
+	if err != nil {
+		return fmt.Errorf("保存向量失败: %v", err)
+	}
+	return nil
+}
+
+// Search 按余弦相似度返回最相似的 limit 条结果
+func (s *SQLiteVectorStore) Search(ctx context.Context, vector []float32, filter VectorFilter, limit int) ([]SearchResult, error) {
+	if len(vector) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.Embedding{}).
+		Where("model = ? AND dimensions = ?", filter.Model, len(vector))
+	if filter.RoomID != "" {
+		query = query.Where("room_id = ?", filter.RoomID)
+	}
+	if filter.OwnerID != nil {
+		query = query.Where("(owner_id IS NULL OR owner_id = '' OR owner_id = ?)", *filter.OwnerID)
+	}
+	if len(filter.Kinds) > 0 {
+		query = query.Where("kind IN ?", filter.Kinds)
+	}
+
+	var rows []models.Embedding
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("读取向量失败: %v", err)
+	}
+
+	queryNorm := vectorNorm(vector)
+	if queryNorm == 0 {
+		return nil, nil
+	}
+
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		candidate := decodeVector(row.Vector)
+		if len(candidate) != len(vector) {
+			continue
+		}
+		owner := ""
+		if row.OwnerID != nil {
+			owner = *row.OwnerID
+		}
+		results = append(results, SearchResult{
+			Kind:      row.Kind,
+			RefID:     row.RefID,
+			Text:      row.Text,
+			RoomID:    row.RoomID,
+			OwnerID:   owner,
+			CreatedAt: row.CreatedAt,
+			Score:     cosineSimilarity(vector, candidate, queryNorm),
+		})
+	}
+
+	sortByScore(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// Delete 删除条目的向量
+func (s *SQLiteVectorStore) Delete(ctx context.Context, kind string, refID int) error {
+	if err := s.db.WithContext(ctx).Where("kind = ? AND ref_id = ?", kind, refID).
+		Delete(&models.Embedding{}).Error; err != nil {
+		return fmt.Errorf("删除向量失败: %v", err)
+	}
+	return nil
+}
+
+// Has 返回已有指定模型向量的条目ID
+func (s *SQLiteVectorStore) Has(ctx context.Context, model, kind string, refIDs []int) (map[int]bool, error) {
+	existing := make(map[int]bool)
+	if len(refIDs) == 0 {
+		return existing, nil
+	}
+
+	var ids []int
+	if err := s.db.WithContext(ctx).Model(&models.Embedding{}).
+		Where("model = ? AND kind = ? AND ref_id IN ?", model, kind, refIDs).
+		Pluck("ref_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("读取向量失败: %v", err)
+	}
+	for _, id := range ids {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// Count 统计指定模型的向量数量
+func (s *SQLiteVectorStore) Count(ctx context.Context, model string) (int64, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Embedding{}).
+		Where("model = ?", model).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("统计向量失败: %v", err)
+	}
+	return count, nil
+}
+
+// sortByScore 按分数从高到低排序，同分时保持原有顺序
+func sortByScore(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
+
+// encodeVector 把向量编码为小端序的 float32 字节序列
+func encodeVector(vector []float32) []byte {
+	buf := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// decodeVector 从字节序列还原向量
+func decodeVector(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vector
+}
+
+func vectorNorm(vector []float32) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum)
+}
+
+// cosineSimilarity 计算余弦相似度，queryNorm 为 a 的模长
+func cosineSimilarity(a, b []float32, queryNorm float64) float64 {
+	var dot, norm float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		norm += float64(b[i]) * float64(b[i])
+	}
+	if norm == 0 {
+		return 0
+	}
+	return dot / (queryNorm * math.Sqrt(norm))
+}
@@ -62,6 +62,9 @@ type MiServiceInterface interface {
 	TogglePlayState(deviceID string) error
 	PlayURL(deviceID, url string) error
 	
+	// 原生指令
+	ExecuteDirective(deviceID, text string, silent bool) error
+	
 	// 状态查询
 	GetStatus(deviceID string) (*DeviceStatus, error)
 	IsHealthy() bool
@@ -2,6 +2,7 @@ package miservice
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"mi-gpt-go/pkg/logger"
 	"strconv"
@@ -367,6 +368,66 @@ func (c *XiaoAiClient) SendMessage(deviceID, message string) error {
 	return nil
 }
 
+// ExecuteDirective 执行小爱原生指令（相当于对小爱同学说出该指令）
+func (c *XiaoAiClient) ExecuteDirective(deviceID, text string, silent bool) error {
+	c.updateLastActivity()
+	logger.Infof("🎙️ 设备 %s 执行原生指令: %s (静默: %t)", deviceID, text, silent)
+
+	err := c.safeCall(func() error {
+		xiaoai, ok := c.client.(*xiaoaitts.XiaoAi)
+		if !ok || xiaoai.Session == nil {
+			return fmt.Errorf("当前客户端不支持原生指令")
+		}
+
+		tts := 1
+		if silent {
+			tts = 0
+		}
+		message, err := json.Marshal(map[string]interface{}{
+			"tts":      tts,
+			"nlp":      1,
+			"nlp_text": text,
+		})
+		if err != nil {
+			return err
+		}
+
+		body := xiaoaitts.Ubus(&xiaoaitts.Ticket{
+			Cookie:   xiaoai.Session.GetCookie(),
+			DeviceId: xiaoai.Session.DeviceId,
+		}, &xiaoaitts.UbusParam{
+			Method:  "ai_service",
+			Message: string(message),
+			Path:    "mibrain",
+		})
+		return checkUbusResponse(body)
+	})
+
+	if err != nil {
+		return fmt.Errorf("执行原生指令失败: %v", err)
+	}
+
+	return nil
+}
+
+// ubusResponse 小爱 ubus 接口的返回结果，code 为 0 表示成功
+type ubusResponse struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+// checkUbusResponse 解析 ubus 接口的返回结果，返回码不为 0 时返回错误
+func checkUbusResponse(body []byte) error {
+	var response ubusResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("解析指令结果失败: %v", err)
+	}
+	if response.Code != 0 {
+		return fmt.Errorf("指令返回错误码 %d: %s", response.Code, response.Message)
+	}
+	return nil
+}
+
 // SetVolume 设置音量
 func (c *XiaoAiClient) SetVolume(deviceID string, volume int) error {
 	if volume < 0 || volume > 100 {
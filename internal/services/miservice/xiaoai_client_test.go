@@ -0,0 +1,23 @@
+package miservice
+
+import (
+	"testing"
+)
+
+func TestCheckUbusResponse(t *testing.T) {
+	tests := []struct {
+		body string
+		ok   bool
+	}{
+		{`{"code":0,"message":"Success","data":{"code":0,"info":"{}"}}`, true},
+		{`{"code":101,"message":"device not found"}`, false},
+		{`{"code":3,"message":"login failed"}`, false},
+		{``, false},
+		{`<html>502 Bad Gateway</html>`, false},
+	}
+	for _, tt := range tests {
+		if err := checkUbusResponse([]byte(tt.body)); (err == nil) != tt.ok {
+			t.Errorf("checkUbusResponse(%q) = %v, 期望成功: %v", tt.body, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	anthropicVersion          = "2023-06-01" // 默认的接口版本，可通过服务商请求头覆盖
+	anthropicDefaultMaxTokens = 1024         // Messages 接口必须指定 max_tokens，未在服务商参数中配置时使用
+)
+
+// anthropicBackend 使用 Anthropic Messages 接口的 Claude 系列模型
+type anthropicBackend struct {
+	profile    config.ProviderProfile
+	httpClient *http.Client
+}
+
+// anthropicContent Anthropic 消息内容块，按 Type 区分文本、工具调用和工具结果
+type anthropicContent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// anthropicMessage Anthropic 对话消息，角色只有 user 和 assistant
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+// anthropicTool Anthropic 工具定义
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// anthropicUsage Anthropic 令牌用量
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicResponse Anthropic 非流式响应
+type anthropicResponse struct {
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
+}
+
+// anthropicEvent Anthropic 流式响应事件，只解析用到的字段
+type anthropicEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"` // message_start 事件携带输入token数
+	Usage anthropicUsage `json:"usage"` // message_delta 事件携带累计的输出token数
+}
+
+// newAnthropicBackend 创建 Anthropic Messages 接口的对话实现
+func newAnthropicBackend(profile config.ProviderProfile, httpClient *http.Client) *anthropicBackend {
+	return &anthropicBackend{profile: profile, httpClient: httpClient}
+}
+
+// url 拼接接口地址
+func (b *anthropicBackend) url(path string) string {
+	return strings.TrimSuffix(b.profile.EffectiveBaseURL(), "/") + path
+}
+
+// headers 鉴权和版本请求头
+func (b *anthropicBackend) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         b.profile.APIKey,
+		"anthropic-version": anthropicVersion,
+	}
+}
+
+// newRequest 创建请求体，服务商参数原样放在顶层，例如 temperature、max_tokens
+func (b *anthropicBackend) newRequest(model, system string) map[string]interface{} {
+	body := make(map[string]interface{}, len(b.profile.Options)+5)
+	for key, value := range b.profile.Options {
+		body[key] = value
+	}
+	body["model"] = model
+	if _, ok := body["max_tokens"]; !ok {
+		body["max_tokens"] = anthropicDefaultMaxTokens
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	return body
+}
+
+func (b *anthropicBackend) chat(ctx context.Context, model string, messages []openai.ChatCompletionMessage, options ChatOptions, onDelta func(string)) (string, error) {
+	system, converted := toAnthropicMessages(messages)
+	body := b.newRequest(model, system)
+	body["messages"] = converted
+
+	if onDelta != nil {
+		return b.stream(ctx, model, body, options, onDelta)
+	}
+	if len(options.Tools) > 0 && b.profile.Supports(config.CapabilityTools) {
+		return b.chatWithTools(ctx, model, body, converted, options)
+	}
+
+	resp, err := b.send(ctx, model, body, options)
+	if err != nil {
+		return "", err
+	}
+	return resp.text(), nil
+}
+
+// send 发送非流式请求并上报用量
+func (b *anthropicBackend) send(ctx context.Context, model string, body map[string]interface{}, options ChatOptions) (*anthropicResponse, error) {
+	start := time.Now()
+	resp, err := postJSON(ctx, b.httpClient, b.profile.Name, b.url("/messages"), b.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析Anthropic响应失败: %v", err)
+	}
+	reportUsage(options, b.usage(model, result.Usage), start)
+	return &result, nil
+}
+
+// chatWithTools 带工具调用的对话，与 OpenAI 协议的工具调用流程一致
+// 工具执行后出错不再切换服务商，避免重复执行工具
+func (b *anthropicBackend) chatWithTools(ctx context.Context, model string, body map[string]interface{}, messages []anthropicMessage, options ChatOptions) (string, error) {
+	tools := make(map[string]Tool, len(options.Tools))
+	for _, tool := range options.Tools {
+		tools[tool.Name] = tool
+	}
+
+	maxRounds := options.MaxToolRounds
+	if maxRounds <= 0 {
+		maxRounds = defaultMaxToolRounds
+	}
+
+	body["tools"] = toAnthropicTools(options.Tools)
+	for round := 1; ; round++ {
+		// 达到轮数上限后禁止继续调用工具，要求模型直接回复
+		if round > maxRounds {
+			body["tool_choice"] = map[string]string{"type": "none"}
+		}
+		body["messages"] = messages
+
+		resp, err := b.send(ctx, model, body, options)
+		if err != nil {
+			if round > 1 {
+				return "", &fatalError{err: err}
+			}
+			return "", err
+		}
+
+		calls := resp.toolUses()
+		if len(calls) == 0 || round > maxRounds {
+			return resp.text(), nil
+		}
+
+		messages = append(messages, anthropicMessage{Role: "assistant", Content: resp.Content})
+		results := make([]anthropicContent, 0, len(calls))
+		for _, call := range calls {
+			record := executeTool(ctx, tools, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Name,
+					Arguments: string(call.Input),
+				},
+			}, round, options.Trace)
+			if options.OnToolCall != nil {
+				options.OnToolCall(record)
+			}
+
+			result := anthropicContent{Type: "tool_result", ToolUseID: call.ID, Content: record.Result}
+			if record.Error != "" {
+				result.Content = fmt.Sprintf("工具执行失败: %s", record.Error)
+				result.IsError = true
+			}
+			results = append(results, result)
+		}
+		messages = append(messages, anthropicMessage{Role: "user", Content: results})
+	}
+}
+
+// stream 流式对话，SSE 事件中只有 content_block_delta 的 text_delta 是回复文本
+func (b *anthropicBackend) stream(ctx context.Context, model string, body map[string]interface{}, options ChatOptions, onDelta func(string)) (string, error) {
+	start := time.Now()
+	body["stream"] = true
+	resp, err := postJSON(ctx, b.httpClient, b.profile.Name, b.url("/messages"), b.headers(), body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage anthropicUsage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return "", fmt.Errorf("解析Anthropic流式响应失败: %v", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				onDelta(event.Delta.Text)
+			}
+		case "message_stop":
+			reportUsage(options, b.usage(model, usage), start)
+			return content.String(), nil
+		case "error":
+			return "", &statusError{
+				provider:   b.profile.Name,
+				statusCode: anthropicErrorStatus(event.Error.Type),
+				message:    event.Error.Message,
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+// usage 转换 Anthropic 的用量
+func (b *anthropicBackend) usage(model string, usage anthropicUsage) Usage {
+	return Usage{
+		Provider:         b.profile.Name,
+		Model:            model,
+		PromptTokens:     usage.InputTokens,
+		CompletionTokens: usage.OutputTokens,
+	}
+}
+
+// text 拼接响应中的文本内容块
+func (r *anthropicResponse) text() string {
+	var text strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+// toolUses 响应中的工具调用
+func (r *anthropicResponse) toolUses() []anthropicContent {
+	var calls []anthropicContent
+	for _, block := range r.Content {
+		if block.Type == "tool_use" {
+			if len(block.Input) == 0 {
+				block.Input = json.RawMessage("{}")
+			}
+			calls = append(calls, block)
+		}
+	}
+	return calls
+}
+
+// toAnthropicMessages 转换对话消息：系统提示放到单独的 system 字段，相邻的同角色消息合并
+func toAnthropicMessages(messages []openai.ChatCompletionMessage) (string, []anthropicMessage) {
+	var system []string
+	result := make([]anthropicMessage, 0, len(messages))
+	for _, message := range messages {
+		if message.Role == openai.ChatMessageRoleSystem {
+			if message.Content != "" {
+				system = append(system, message.Content)
+			}
+			continue
+		}
+		if message.Content == "" {
+			continue
+		}
+
+		role := "user"
+		if message.Role == openai.ChatMessageRoleAssistant {
+			role = "assistant"
+		}
+		block := anthropicContent{Type: "text", Text: message.Content}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, block)
+			continue
+		}
+		result = append(result, anthropicMessage{Role: role, Content: []anthropicContent{block}})
+	}
+	return strings.Join(system, "\n\n"), result
+}
+
+// toAnthropicTools 转换为 Anthropic 工具定义
+func toAnthropicTools(tools []Tool) []anthropicTool {
+	result := make([]anthropicTool, 0, len(tools))
+	for _, tool := range tools {
+		schema := tool.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		result = append(result, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: schema,
+		})
+	}
+	return result
+}
+
+// anthropicErrorStatus 流式响应中错误事件对应的HTTP状态码，用于判断是否可以换用其他服务商
+func anthropicErrorStatus(errorType string) int {
+	switch errorType {
+	case "overloaded_error":
+		return 529
+	case "api_error":
+		return http.StatusInternalServerError
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "authentication_error":
+		return http.StatusUnauthorized
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,242 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// anthropicTestRequest 测试中解析的 Messages 接口请求
+type anthropicTestRequest struct {
+	Model      string             `json:"model"`
+	MaxTokens  int                `json:"max_tokens"`
+	System     string             `json:"system"`
+	Stream     bool               `json:"stream"`
+	Messages   []anthropicMessage `json:"messages"`
+	Tools      []anthropicTool    `json:"tools"`
+	ToolChoice map[string]string  `json:"tool_choice"`
+}
+
+// fakeAnthropicServer 模拟 Anthropic 的 /v1/messages 接口，收到的请求依次追加到 received
+// respond 根据请求写出响应
+func fakeAnthropicServer(t *testing.T, received *[]anthropicTestRequest, respond func(w http.ResponseWriter, req anthropicTestRequest)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Errorf("鉴权请求头不正确: x-api-key=%q anthropic-version=%q", r.Header.Get("x-api-key"), r.Header.Get("anthropic-version"))
+		}
+		var req anthropicTestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		*received = append(*received, req)
+		respond(w, req)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newAnthropicTestClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+	return newProfileClient(t, config.ProviderProfile{
+		Name:         "claude",
+		Kind:         "anthropic",
+		BaseURL:      baseURL + "/v1",
+		APIKey:       "test-key",
+		DefaultModel: "claude-sonnet-4-5",
+	})
+}
+
+// anthropicText 消息中各文本块的内容
+func anthropicText(message anthropicMessage) []string {
+	texts := make([]string, 0, len(message.Content))
+	for _, block := range message.Content {
+		texts = append(texts, block.Text)
+	}
+	return texts
+}
+
+func TestAnthropicChatMapsRolesAndMergesMessages(t *testing.T) {
+	var received []anthropicTestRequest
+	server := fakeAnthropicServer(t, &received, func(w http.ResponseWriter, req anthropicTestRequest) {
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"我是傻妞"}],"stop_reason":"end_turn","usage":{"input_tokens":30,"output_tokens":6}}`)
+	})
+	client := newAnthropicTestClient(t, server.URL)
+
+	var usages []Usage
+	answer, err := client.Chat(context.Background(), ChatOptions{
+		System: "你是傻妞",
+		History: []ChatMessage{
+			{Role: RoleUser, Content: "在吗"},
+			{Role: RoleUser, Content: "听得到吗"},
+			{Role: RoleAssistant, Content: "在的"},
+		},
+		User:    "你是谁",
+		OnUsage: func(usage Usage) { usages = append(usages, usage) },
+	})
+	if err != nil {
+		t.Fatalf("对话失败: %v", err)
+	}
+	if answer != "我是傻妞" {
+		t.Errorf("回复 = %q", answer)
+	}
+
+	req := received[0]
+	if req.System != "你是傻妞" {
+		t.Errorf("系统提示应放在 system 字段，实际 %q", req.System)
+	}
+	if req.MaxTokens != anthropicDefaultMaxTokens {
+		t.Errorf("未配置 max_tokens 时应使用默认值，实际 %d", req.MaxTokens)
+	}
+	// 相邻的两条用户消息合并为一条消息的两个文本块
+	want := []struct {
+		role  string
+		texts []string
+	}{
+		{"user", []string{"在吗", "听得到吗"}},
+		{"assistant", []string{"在的"}},
+		{"user", []string{"你是谁"}},
+	}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("发送了 %d 条消息，期望 %d 条: %+v", len(req.Messages), len(want), req.Messages)
+	}
+	for i, message := range req.Messages {
+		if message.Role != want[i].role || fmt.Sprint(anthropicText(message)) != fmt.Sprint(want[i].texts) {
+			t.Errorf("第%d条消息 = %s %v, 期望 %s %v", i+1, message.Role, anthropicText(message), want[i].role, want[i].texts)
+		}
+	}
+
+	if len(usages) != 1 || usages[0].PromptTokens != 30 || usages[0].CompletionTokens != 6 || usages[0].Estimated {
+		t.Errorf("应使用响应中的 input_tokens 和 output_tokens，实际 %+v", usages)
+	}
+}
+
+func TestAnthropicToolChoiceNoneAfterRoundCap(t *testing.T) {
+	var received []anthropicTestRequest
+	server := fakeAnthropicServer(t, &received, func(w http.ResponseWriter, req anthropicTestRequest) {
+		// 模型总想继续调用工具，只有禁止调用时才直接回复
+		if req.ToolChoice["type"] == "none" {
+			fmt.Fprint(w, `{"content":[{"type":"text","text":"客厅现在26度"}],"stop_reason":"end_turn","usage":{"input_tokens":80,"output_tokens":8}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"content":[{"type":"tool_use","id":"toolu_%d","name":"get_temperature","input":{"room":"客厅"}}],"stop_reason":"tool_use","usage":{"input_tokens":50,"output_tokens":12}}`, len(received))
+	})
+	client := newAnthropicTestClient(t, server.URL)
+
+	var calls []string
+	var usages []Usage
+	answer, err := client.Chat(context.Background(), ChatOptions{
+		User: "客厅多少度",
+		Tools: []Tool{{
+			Name:        "get_temperature",
+			Description: "查询房间温度",
+			Handler: func(ctx context.Context, arguments string) (string, error) {
+				calls = append(calls, arguments)
+				return "26", nil
+			},
+		}},
+		MaxToolRounds: 1,
+		OnUsage:       func(usage Usage) { usages = append(usages, usage) },
+	})
+	if err != nil {
+		t.Fatalf("对话失败: %v", err)
+	}
+	if answer != "客厅现在26度" {
+		t.Errorf("回复 = %q", answer)
+	}
+	if len(calls) != 1 || calls[0] != `{"room":"客厅"}` {
+		t.Errorf("工具调用 = %v, 期望按上限只调用1次", calls)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("期望请求2次，实际 %d 次", len(received))
+	}
+	if received[0].ToolChoice != nil || len(received[0].Tools) != 1 || received[0].Tools[0].InputSchema == nil {
+		t.Errorf("第一轮应带上工具定义且不限制调用: %+v", received[0])
+	}
+	if received[1].ToolChoice["type"] != "none" {
+		t.Errorf("达到轮数上限后应设置 tool_choice 为 none，实际 %v", received[1].ToolChoice)
+	}
+	// 第二轮带上助手的工具调用和用户角色的工具结果
+	messages := received[1].Messages
+	last := messages[len(messages)-1]
+	if len(messages) != 3 || messages[1].Role != "assistant" || last.Role != "user" ||
+		last.Content[0].Type != "tool_result" || last.Content[0].ToolUseID != "toolu_1" || last.Content[0].Content != "26" {
+		t.Errorf("第二轮的消息不正确: %+v", messages)
+	}
+	if len(usages) != 2 {
+		t.Errorf("工具调用的每一轮都应上报用量，实际 %d 次", len(usages))
+	}
+}
+
+func TestAnthropicChatStream(t *testing.T) {
+	var received []anthropicTestRequest
+	server := fakeAnthropicServer(t, &received, func(w http.ResponseWriter, req anthropicTestRequest) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"usage":{"input_tokens":25,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"明天"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"有雨"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		} {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(event), &typed)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typed.Type, event)
+		}
+	})
+	client := newAnthropicTestClient(t, server.URL)
+
+	var streamed strings.Builder
+	var usages []Usage
+	answer, err := client.ChatStream(context.Background(), ChatOptions{
+		User:     "明天天气怎么样",
+		OnStream: func(text string) { streamed.WriteString(text) },
+		OnUsage:  func(usage Usage) { usages = append(usages, usage) },
+	})
+	if err != nil {
+		t.Fatalf("流式对话失败: %v", err)
+	}
+	if !received[0].Stream {
+		t.Errorf("流式对话应请求流式输出")
+	}
+	if answer != "明天有雨" || streamed.String() != answer {
+		t.Errorf("回复 = %q, 流式回调 = %q", answer, streamed.String())
+	}
+	// 输入token数来自 message_start，输出token数来自 message_delta
+	if len(usages) != 1 || usages[0].PromptTokens != 25 || usages[0].CompletionTokens != 7 {
+		t.Errorf("流式用量 = %+v, 期望输入25、输出7", usages)
+	}
+}
+
+func TestAnthropicStreamErrorEvent(t *testing.T) {
+	var received []anthropicTestRequest
+	server := fakeAnthropicServer(t, &received, func(w http.ResponseWriter, req anthropicTestRequest) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	})
+	client := newAnthropicTestClient(t, server.URL)
+
+	_, err := client.ChatStream(context.Background(), ChatOptions{User: "你好"})
+	if err == nil || !strings.Contains(err.Error(), "Overloaded") {
+		t.Fatalf("错误事件应返回给调用方，实际 %v", err)
+	}
+	if !isRetryable(context.Background(), err) {
+		t.Errorf("过载错误应可以换用其他服务商重试: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"mi-gpt-go/pkg/logger"
+	"strings"
+)
+
+// CacheProvider 命中回答缓存时回调的服务商名称
+const CacheProvider = "cache"
+
+// AnswerCache 回答缓存，相同的问题直接使用之前的回答，不再请求模型
+// 实现负责问题的归一化、有效期、容量限制以及跳过时效性问题
+type AnswerCache interface {
+	Get(key CacheKey) (string, bool)
+	Put(key CacheKey, answer string)
+}
+
+// CacheKey 回答缓存的键
+type CacheKey struct {
+	Query   string // 用户的原始问题
+	Persona string // 机器人人设，人设不同时回答不同
+	Model   string // 请求的模型
+	OwnerID string // 提问的家庭成员，不同成员的回答不共用
+	RoomID  string // 提问所在的房间
+}
+
+// SetAnswerCache 设置回答缓存，为空时不使用缓存
+func (c *Client) SetAnswerCache(cache AnswerCache) {
+	c.answerCache = cache
+}
+
+// cacheKey 本次对话的缓存键
+// 未设置缓存、未指定 CacheQuery 或携带对话历史时不使用缓存，追问的回答依赖上下文
+func (c *Client) cacheKey(options ChatOptions) (CacheKey, bool) {
+	if c.answerCache == nil || strings.TrimSpace(options.CacheQuery) == "" || len(options.History) > 0 {
+		return CacheKey{}, false
+	}
+	return CacheKey{
+		Query:   options.CacheQuery,
+		Persona: options.Persona,
+		Model:   getDefault(options.Model, c.providers[0].profile.DefaultModel),
+		OwnerID: options.UserID,
+		RoomID:  options.RoomID,
+	}, true
+}
+
+// cachedAnswer 查找缓存的回答，命中时按缓存服务商回调 OnProvider
+func (c *Client) cachedAnswer(key CacheKey, options ChatOptions) (string, bool) {
+	answer, ok := c.answerCache.Get(key)
+	if !ok {
+		return "", false
+	}
+	if options.Trace {
+		logger.Infof("💾 命中回答缓存 [%s]: %s", key.Model, answer)
+	}
+	if options.OnProvider != nil {
+		options.OnProvider(CacheProvider, key.Model)
+	}
+	return answer, true
+}
@@ -5,20 +5,29 @@ import (
 	"fmt"
 	"io"
 	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/utils"
 	"mi-gpt-go/pkg/logger"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 )
 
 // Client AI客户端（支持OpenAI、Azure OpenAI、DeepSeek等）
+// 配置了备用服务商时按顺序故障转移
 type Client struct {
-	client       *openai.Client  // 底层OpenAI客户端
-	model        string          // 使用的模型名称
-	enableSearch bool           // 是否启用搜索功能
-	provider     string         // 服务提供商类型
+	providers    []*provider // 故障转移链，主服务商在前
+	enableSearch bool        // 回答用户提问时是否联网搜索
+
+	embedder            *openai.Client // 嵌入接口客户端，未启用或服务商不支持时为空
+	embeddingModel      string         // 嵌入模型名称
+	embeddingDimensions int            // 向量维度，0表示使用模型默认值
+
+	usageRecorder func(Usage) // 记录每次模型调用的用量，为空时不记录
+	answerCache   AnswerCache // 回答缓存，为空时不使用缓存
+	searcher      Searcher    // 联网搜索服务，为空时不搜索
 }
 
 // ChatOptions 聊天选项
@@ -29,258 +38,533 @@ type ChatOptions struct {
 	JSONMode     bool
 	RequestID    string
 	Trace        bool
-	EnableSearch bool
+	EnableSearch bool   // 本次对话启用联网搜索，客户端配置已启用时回答用户提问总是启用
+	SearchQuery  string // 判断是否需要搜索以及搜索使用的问题，为空时使用 User
 	OnStream     func(string)
+	History      []ChatMessage // 按时间顺序排列的对话历史（不含本次用户输入）
+
+	// 工具调用
+	Tools         []Tool               // 可供模型调用的工具
+	MaxToolRounds int                  // 最多执行的工具调用轮数，默认5轮
+	OnToolCall    func(ToolCallRecord) // 每次工具调用完成后回调
+
+	OnProvider func(provider, model string) // 回答成功后回调实际回答的服务商和模型
+
+	// 用量统计，Purpose、DeviceID、UserID 原样写入用量
+	Purpose  string      // 调用用途，例如 chat、memory、command
+	DeviceID string      // 发起请求的设备
+	UserID   string      // 发起请求的家庭成员
+	OnUsage  func(Usage) // 每次模型调用完成后回调用量，工具调用的每一轮各回调一次
+
+	// 回答缓存，CacheQuery 为空时不使用缓存，不同成员和房间的回答分开缓存
+	CacheQuery string // 用户的原始问题，不含说话人等提示词修饰；提示词中注入了成员的事实或记忆时应留空
+	Persona    string // 机器人人设，与问题和模型一起作为缓存的键
+	RoomID     string // 提问所在的房间，与 UserID 一起作为缓存的键
+
+	// 思考模型，思考过程不会出现在返回的回答和 OnStream 中
+	OnThinking  func()       // 模型开始思考时回调一次，只有流式对话能在思考过程中回调
+	OnReasoning func(string) // 回答完成后回调完整的思考过程，模型没有思考时不回调
+}
+
+// 调用用途
+const (
+	PurposeChat    = "chat"    // 回答用户提问
+	PurposeMemory  = "memory"  // 整理记忆
+	PurposeCommand = "command" // 自定义命令中的AI动作
+	PurposeTest    = "test"    // 测试连接
+)
+
+// Usage 一次模型调用的令牌用量
+type Usage struct {
+	Provider         string        `json:"provider"`
+	Model            string        `json:"model"`
+	Purpose          string        `json:"purpose"`
+	DeviceID         string        `json:"deviceId"`
+	UserID           string        `json:"userId"`
+	PromptTokens     int           `json:"promptTokens"`
+	CompletionTokens int           `json:"completionTokens"`
+	TotalTokens      int           `json:"totalTokens"`
+	Estimated        bool          `json:"estimated"` // 服务商未返回用量，按文本长度估算
+	Latency          time.Duration `json:"latency"`
+}
+
+// SetUsageRecorder 设置用量记录器，每次模型调用完成后调用
+func (c *Client) SetUsageRecorder(recorder func(Usage)) {
+	c.usageRecorder = recorder
+}
+
+// withUsageRecorder 把客户端的用量记录器加入本次对话的用量回调
+func (c *Client) withUsageRecorder(options ChatOptions) ChatOptions {
+	if c.usageRecorder == nil {
+		return options
+	}
+	onUsage := options.OnUsage
+	options.OnUsage = func(usage Usage) {
+		c.usageRecorder(usage)
+		if onUsage != nil {
+			onUsage(usage)
+		}
+	}
+	return options
+}
+
+// reportUsage 回调一次模型调用的用量，start 为调用开始时间，开启追踪时同时输出日志
+func reportUsage(options ChatOptions, usage Usage, start time.Time) {
+	usage.Latency = time.Since(start)
+	usage.Purpose, usage.DeviceID, usage.UserID = options.Purpose, options.DeviceID, options.UserID
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if options.Trace {
+		logger.Infof("📊 令牌用量 [%s/%s]: 输入 %d, 输出 %d, 合计 %d, 耗时 %v",
+			usage.Provider, usage.Model, usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens,
+			usage.Latency.Round(time.Millisecond))
+	}
+	if options.OnUsage != nil {
+		options.OnUsage(usage)
+	}
 }
 
 // NewClient 创建新的AI客户端，支持多种服务提供商
 func NewClient(cfg config.OpenAIConfig) (*Client, error) {
-	var client *openai.Client
-
-	// 验证基础配置
-	if err := validateOpenAIConfig(cfg); err != nil {
+	// 验证服务商配置
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("AI配置验证失败: %v", err)
+	}
+	chain, err := cfg.Chain()
+	if err != nil {
 		return nil, fmt.Errorf("AI配置验证失败: %v", err)
 	}
 
-	// 配置HTTP客户端和代理
-	httpClient := &http.Client{}
+	// 配置代理
+	var transport http.RoundTripper = http.DefaultTransport
 	if cfg.ProxyURL != "" {
 		proxyURL, err := url.Parse(cfg.ProxyURL)
 		if err != nil {
 			return nil, fmt.Errorf("解析代理URL失败: %v", err)
 		}
-		httpClient.Transport = &http.Transport{
+		transport = &http.Transport{
 			Proxy: http.ProxyURL(proxyURL),
 		}
 	}
 
-	// 根据服务提供商创建相应的客户端
-	switch cfg.Provider {
-	case "azure":
-		// Azure OpenAI 服务
-		if cfg.AzureAPIKey == "" {
-			return nil, fmt.Errorf("使用Azure OpenAI时必须提供AzureAPIKey")
-		}
-		if cfg.AzureEndpoint == "" {
-			return nil, fmt.Errorf("使用Azure OpenAI时必须提供AzureEndpoint")
-		}
-		clientConfig := openai.DefaultAzureConfig(cfg.AzureAPIKey, cfg.AzureEndpoint)
-		clientConfig.AzureModelMapperFunc = func(model string) string {
-			return cfg.AzureDeployment
-		}
-		clientConfig.HTTPClient = httpClient
-		client = openai.NewClientWithConfig(clientConfig)
-		logger.Info("已初始化Azure OpenAI客户端")
-
-	case "deepseek":
-		// DeepSeek 服务
-		if cfg.DeepSeekAPIKey == "" {
-			return nil, fmt.Errorf("使用DeepSeek时必须提供DeepSeekAPIKey")
-		}
-		baseURL := cfg.DeepSeekBaseURL
-		if baseURL == "" {
-			baseURL = "https://api.deepseek.com/v1"
-		}
-		if !isValidURL(baseURL) {
-			return nil, fmt.Errorf("deepSeek BaseURL格式无效: %s", baseURL)
+	breakerFailures := cfg.BreakerFailures
+	if breakerFailures <= 0 {
+		breakerFailures = defaultBreakerFailures
+	}
+	breakerReset := time.Duration(cfg.BreakerResetSeconds) * time.Second
+	if breakerReset <= 0 {
+		breakerReset = defaultBreakerReset
+	}
+
+	// 主服务商的嵌入模型可能映射到单独的Azure部署
+	var providers []*provider
+	for i, profile := range chain {
+		embeddingModel, embeddingDeployment := "", ""
+		if i == 0 {
+			embeddingModel, embeddingDeployment = cfg.EmbeddingModel, cfg.AzureEmbeddingDeployment
 		}
-		clientConfig := openai.DefaultConfig(cfg.DeepSeekAPIKey)
-		clientConfig.BaseURL = baseURL
-		clientConfig.HTTPClient = httpClient
-		client = openai.NewClientWithConfig(clientConfig)
-		logger.Info("已初始化DeepSeek客户端")
-
-	case "openai":
-		fallthrough
-	default:
-		// OpenAI 官方服务
-		if cfg.APIKey == "" {
-			return nil, fmt.Errorf("使用OpenAI时必须提供APIKey")
+		httpClient := profileHTTPClient(profile, transport)
+		p := &provider{
+			profile: profile,
+			breaker: utils.NewCircuitBreaker(breakerFailures, breakerReset),
 		}
-		clientConfig := openai.DefaultConfig(cfg.APIKey)
-		if cfg.BaseURL != "" {
-			if !isValidURL(cfg.BaseURL) {
-				return nil, fmt.Errorf("openAI BaseURL格式无效: %s", cfg.BaseURL)
-			}
-			clientConfig.BaseURL = cfg.BaseURL
+		switch profile.Kind {
+		case "ollama":
+			// 对话使用原生接口，嵌入使用 Ollama 的 OpenAI 兼容接口
+			p.native = newOllamaBackend(profile, httpClient)
+			compatible := profile
+			compatible.BaseURL = strings.TrimSuffix(profile.EffectiveBaseURL(), "/") + "/v1"
+			p.client = newProviderClient(compatible, "", "", httpClient)
+		case "anthropic":
+			// Messages 接口不兼容 OpenAI 协议，也没有嵌入接口
+			p.native = newAnthropicBackend(profile, httpClient)
+			logger.Infof("已初始化AI服务商 %s (%s): %s", profile.Name, profile.Kind, profile.EffectiveBaseURL())
+		case "gemini":
+			p.native = newGeminiBackend(profile, httpClient)
+			logger.Infof("已初始化AI服务商 %s (%s): %s", profile.Name, profile.Kind, profile.EffectiveBaseURL())
+		default:
+			p.client = newProviderClient(profile, embeddingModel, embeddingDeployment, httpClient)
 		}
-		clientConfig.HTTPClient = httpClient
-		client = openai.NewClientWithConfig(clientConfig)
-		logger.Info("已初始化OpenAI客户端")
+		providers = append(providers, p)
+	}
+
+	embedder, err := newEmbeddingClient(cfg, providers[0], &http.Client{Transport: transport})
+	if err != nil {
+		return nil, err
 	}
 
 	return &Client{
-		client:       client,
-		model:        cfg.Model,
+		providers:    providers,
 		enableSearch: cfg.EnableSearch,
-		provider:     cfg.Provider,
+
+		embedder:            embedder,
+		embeddingModel:      getDefault(cfg.EmbeddingModel, "text-embedding-3-small"),
+		embeddingDimensions: cfg.EmbeddingDimensions,
 	}, nil
 }
 
+// newProviderClient 按服务商类型创建接口客户端，配置已经过验证
+// Azure 的模型名称即部署名称，嵌入模型映射到单独的部署
+func newProviderClient(profile config.ProviderProfile, embeddingModel, embeddingDeployment string, httpClient *http.Client) *openai.Client {
+	var clientConfig openai.ClientConfig
+	if profile.Kind == "azure" {
+		clientConfig = openai.DefaultAzureConfig(profile.APIKey, profile.BaseURL)
+		clientConfig.AzureModelMapperFunc = func(model string) string {
+			if model == embeddingModel && embeddingDeployment != "" {
+				return embeddingDeployment
+			}
+			return model
+		}
+	} else {
+		// OpenAI 官方服务或兼容接口
+		clientConfig = openai.DefaultConfig(profile.APIKey)
+		clientConfig.BaseURL = profile.EffectiveBaseURL()
+	}
+	logger.Infof("已初始化AI服务商 %s (%s): %s", profile.Name, profile.Kind, profile.EffectiveBaseURL())
+
+	clientConfig.HTTPClient = httpClient
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+// profileHTTPClient 创建服务商使用的HTTP客户端，附加服务商配置的请求头
+func profileHTTPClient(profile config.ProviderProfile, transport http.RoundTripper) *http.Client {
+	if len(profile.Headers) == 0 {
+		return &http.Client{Transport: transport}
+	}
+	return &http.Client{Transport: &headerTransport{base: transport, headers: profile.Headers}}
+}
+
+// headerTransport 为请求附加服务商配置的请求头
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(req)
+}
+
 // Chat 普通聊天（支持OpenAI、DeepSeek等）
 func (c *Client) Chat(ctx context.Context, options ChatOptions) (string, error) {
 	if options.Trace {
-		logger.Infof("🔥 AI对话请求 [%s]\n🤖️ 系统提示: %s\n😊 用户输入: %s", 
-			c.provider, getDefault(options.System, "无"), options.User)
+		logger.Infof("🔥 AI对话请求 [%s]\n🤖️ 系统提示: %s\n📜 历史消息: %d 条\n😊 用户输入: %s", 
+			c.providers[0].profile.Name, getDefault(options.System, "无"), len(options.History), options.User)
 	}
 
-	messages := []openai.ChatCompletionMessage{}
-	if options.System != "" {
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: options.System,
-		})
+	key, cacheable := c.cacheKey(options)
+	if cacheable {
+		if answer, ok := c.cachedAnswer(key, options); ok {
+			return answer, nil
+		}
 	}
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: options.User,
-	})
 
-	req := openai.ChatCompletionRequest{
-		Model:    getDefault(options.Model, c.model),
-		Messages: messages,
+	// 联网搜索或调用过工具的回答不缓存，搜索结果有时效性，直接返回缓存会跳过工具的操作
+	options, searched := c.withSearch(ctx, options)
+	toolCalled := false
+	if onToolCall := options.OnToolCall; cacheable {
+		options.OnToolCall = func(record ToolCallRecord) {
+			toolCalled = true
+			if onToolCall != nil {
+				onToolCall(record)
+			}
+		}
 	}
 
-	if options.JSONMode {
-		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	options = c.withUsageRecorder(options)
+	messages := buildMessages(options)
+
+	var content, reasoning string
+	var answeredBy string
+	err := c.failover(ctx, options, func(p *provider, model string) error {
+		if p.native != nil {
+			result, err := p.native.chat(ctx, model, messages, options, nil)
+			if err != nil {
+				logger.Errorf("LLM 响应异常 [%s]: %v", p.profile.Name, err)
+				return err
+			}
+			content, reasoning, answeredBy = result, "", p.profile.Name
+			return nil
 		}
-	}
 
-	resp, err := c.client.CreateChatCompletion(ctx, req)
+		req := newChatRequest(p, model, messages, options)
+
+		// 服务商不支持工具调用时直接对话
+		if len(options.Tools) > 0 && p.profile.Supports(config.CapabilityTools) {
+			result, thought, err := c.chatWithTools(ctx, p, req, options)
+			if err != nil {
+				return err
+			}
+			content, reasoning, answeredBy = result, thought, p.profile.Name
+			return nil
+		}
+
+		result, thought, err := createChatCompletion(ctx, p, req, options)
+		if err != nil {
+			return err
+		}
+		content, reasoning, answeredBy = result, thought, p.profile.Name
+		return nil
+	})
 	if err != nil {
-		logger.Errorf("LLM 响应异常: %v", err)
 		return "", err
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("未收到 AI 响应")
-	}
-
-	content := resp.Choices[0].Message.Content
+	content, reasoning = splitReasoning(content, reasoning)
+	reportReasoning(options, answeredBy, reasoning)
 	if options.Trace {
-		logger.Infof("✅ AI回复 [%s]: %s", c.provider, getDefault(content, "无回复"))
+		logger.Infof("✅ AI回复 [%s]: %s", answeredBy, getDefault(content, "无回复"))
 	}
 
+	if cacheable && content != "" && !searched && !toolCalled {
+		c.answerCache.Put(key, content)
+	}
 	return content, nil
 }
 
 // ChatStream 流式聊天（支持OpenAI、DeepSeek等）
+// 携带工具时退化为非流式的工具调用对话，最终回复一次性回调给 OnStream
+// 回复开始输出后出错不再切换服务商，避免重复播报
 func (c *Client) ChatStream(ctx context.Context, options ChatOptions) (string, error) {
-	if options.Trace {
-		logger.Infof("🔥 AI流式对话请求 [%s]\n🤖️ 系统提示: %s\n😊 用户输入: %s", 
-			c.provider, getDefault(options.System, "无"), options.User)
+	if len(options.Tools) > 0 {
+		content, err := c.Chat(ctx, options)
+		if err == nil && content != "" && options.OnStream != nil {
+			options.OnStream(content)
+		}
+		return content, err
 	}
 
-	messages := []openai.ChatCompletionMessage{}
-	if options.System != "" {
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: options.System,
-		})
+	if options.Trace {
+		logger.Infof("🔥 AI流式对话请求 [%s]\n🤖️ 系统提示: %s\n📜 历史消息: %d 条\n😊 用户输入: %s", 
+			c.providers[0].profile.Name, getDefault(options.System, "无"), len(options.History), options.User)
 	}
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleUser,
-		Content: options.User,
-	})
 
-	req := openai.ChatCompletionRequest{
-		Model:    getDefault(options.Model, c.model),
-		Messages: messages,
-		Stream:   true,
+	key, cacheable := c.cacheKey(options)
+	if cacheable {
+		if answer, ok := c.cachedAnswer(key, options); ok {
+			if options.OnStream != nil {
+				options.OnStream(answer)
+			}
+			return answer, nil
+		}
 	}
 
-	if options.JSONMode {
-		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	options, searched := c.withSearch(ctx, options)
+	options = c.withUsageRecorder(options)
+	messages := buildMessages(options)
+
+	var result, reasoning string
+	var answeredBy string
+	err := c.failover(ctx, options, func(p *provider, model string) error {
+		// 思考过程不回调给 OnStream，避免被播报
+		splitter := newReasoningSplitter(options)
+		if p.native != nil {
+			if err := c.nativeStream(ctx, p, model, messages, options, splitter); err != nil {
+				return err
+			}
+			result, reasoning = splitter.finish()
+			answeredBy = p.profile.Name
+			return nil
 		}
-	}
 
-	stream, err := c.client.CreateChatCompletionStream(ctx, req)
-	if err != nil {
-		logger.Errorf("LLM 响应异常: %v", err)
-		return "", err
-	}
-	defer stream.Close()
+		req := newChatRequest(p, model, messages, options)
 
-	var content strings.Builder
-	for {
-		response, err := stream.Recv()
-		if err == io.EOF {
-			break
+		// 服务商不支持流式输出时一次性回调完整回复
+		if !p.profile.Supports(config.CapabilityStream) {
+			content, thought, err := createChatCompletion(ctx, p, req, options)
+			if err != nil {
+				return err
+			}
+			splitter.addReasoning(thought)
+			splitter.addContent(content)
+			result, reasoning = splitter.finish()
+			answeredBy = p.profile.Name
+			return nil
+		}
+
+		req.Stream = true
+		// 要求服务商在最后一段返回用量，未返回时再按文本长度估算
+		if p.streamUsage() {
+			req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+		}
+		start := time.Now()
+		stream, err := p.client.CreateChatCompletionStream(ctx, req)
+		// 部分兼容网关和旧版 Azure 不认识 stream_options，返回400时去掉该参数重试一次
+		if err != nil && req.StreamOptions != nil && isBadRequest(err) {
+			logger.Warnf("服务商 %s 不支持 stream_options，改为估算用量: %v", p.profile.Name, err)
+			p.disableStreamUsage()
+			req.StreamOptions = nil
+			stream, err = p.client.CreateChatCompletionStream(ctx, req)
 		}
 		if err != nil {
-			logger.Errorf("流式响应错误: %v", err)
-			return "", err
+			logger.Errorf("LLM 响应异常 [%s]: %v", p.profile.Name, err)
+			return err
 		}
+		defer stream.Close()
 
-		if len(response.Choices) > 0 {
-			delta := response.Choices[0].Delta.Content
-			if delta != "" {
-				content.WriteString(delta)
-				if options.OnStream != nil {
-					options.OnStream(delta)
+		var usage *openai.Usage
+		for {
+			response, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				logger.Errorf("流式响应错误 [%s]: %v", p.profile.Name, err)
+				if splitter.answered() {
+					return &fatalError{err: err}
 				}
+				return err
+			}
+
+			if response.Usage != nil {
+				usage = response.Usage
+			}
+			if len(response.Choices) > 0 {
+				delta := response.Choices[0].Delta
+				splitter.addReasoning(delta.ReasoningContent)
+				splitter.addContent(delta.Content)
 			}
 		}
+		result, reasoning = splitter.finish()
+		answeredBy = p.profile.Name
+		if usage != nil {
+			reportUsage(options, responseUsage(p, model, *usage), start)
+		} else {
+			reportUsage(options, estimateUsage(p, model, messages, result+reasoning), start)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
 
-	result := content.String()
+	reportReasoning(options, answeredBy, reasoning)
 	if options.Trace {
-		logger.Infof("✅ AI流式回复完成 [%s]: %s", c.provider, getDefault(result, "无回复"))
+		logger.Infof("✅ AI流式回复完成 [%s]: %s", answeredBy, getDefault(result, "无回复"))
 	}
 
+	if cacheable && result != "" && !searched {
+		c.answerCache.Put(key, result)
+	}
 	return result, nil
 }
 
-// getDefault 获取默认值
-func getDefault(value, defaultValue string) string {
-	if value == "" {
-		return defaultValue
+// nativeStream 使用原生接口流式对话，回复交给 splitter 分离思考过程，服务商不支持流式输出时一次性回调完整回复
+// 回答开始输出后出错不再切换服务商
+func (c *Client) nativeStream(ctx context.Context, p *provider, model string, messages []openai.ChatCompletionMessage, options ChatOptions, splitter *reasoningSplitter) error {
+	if !p.profile.Supports(config.CapabilityStream) {
+		content, err := p.native.chat(ctx, model, messages, options, nil)
+		if err != nil {
+			logger.Errorf("LLM 响应异常 [%s]: %v", p.profile.Name, err)
+			return err
+		}
+		splitter.addContent(content)
+		return nil
 	}
-	return value
-}
 
-// validateOpenAIConfig 验证OpenAI配置
-func validateOpenAIConfig(cfg config.OpenAIConfig) error {
-	if cfg.Provider == "" {
-		return fmt.Errorf("AI服务提供商不能为空")
+	_, err := p.native.chat(ctx, model, messages, options, splitter.addContent)
+	if err != nil {
+		logger.Errorf("流式响应错误 [%s]: %v", p.profile.Name, err)
+		if splitter.answered() {
+			return &fatalError{err: err}
+		}
+		return err
 	}
+	return nil
+}
 
-	switch cfg.Provider {
-	case "openai":
-		if cfg.APIKey == "" {
-			return fmt.Errorf("openAI API Key不能为空")
-		}
-		if cfg.BaseURL != "" && !isValidURL(cfg.BaseURL) {
-			return fmt.Errorf("openAI BaseURL格式无效: %s", cfg.BaseURL)
-		}
-	case "azure":
-		if cfg.AzureAPIKey == "" {
-			return fmt.Errorf("azure API Key不能为空")
-		}
-		if cfg.AzureEndpoint == "" {
-			return fmt.Errorf("azure端点不能为空")
-		}
-		if !isValidURL(cfg.AzureEndpoint) {
-			return fmt.Errorf("azure端点URL格式无效: %s", cfg.AzureEndpoint)
-		}
-	case "deepseek":
-		if cfg.DeepSeekAPIKey == "" {
-			return fmt.Errorf("deepSeek API Key不能为空")
-		}
-		baseURL := cfg.DeepSeekBaseURL
-		if baseURL == "" {
-			baseURL = "https://api.deepseek.com/v1"
+// newChatRequest 创建对话请求，服务商不支持JSON输出模式时由提示词约束格式
+func newChatRequest(p *provider, model string, messages []openai.ChatCompletionMessage, options ChatOptions) openai.ChatCompletionRequest {
+	req := openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+	}
+	if options.JSONMode && p.profile.Supports(config.CapabilityJSON) {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
 		}
-		if !isValidURL(baseURL) {
-			return fmt.Errorf("deepSeek BaseURL格式无效: %s", baseURL)
+	}
+	return req
+}
+
+// createChatCompletion 发送非流式对话请求，返回回复内容和 reasoning_content 中的思考过程
+func createChatCompletion(ctx context.Context, p *provider, req openai.ChatCompletionRequest, options ChatOptions) (string, string, error) {
+	start := time.Now()
+	resp, err := p.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		logger.Errorf("LLM 响应异常 [%s]: %v", p.profile.Name, err)
+		return "", "", err
+	}
+	reportUsage(options, responseUsage(p, req.Model, resp.Usage), start)
+	if len(resp.Choices) == 0 {
+		return "", "", fmt.Errorf("未收到 AI 响应")
+	}
+	message := resp.Choices[0].Message
+	return message.Content, message.ReasoningContent, nil
+}
+
+// buildMessages 组装请求消息：系统提示、对话历史、本次用户输入
+func buildMessages(options ChatOptions) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, 0, len(options.History)+2)
+	if options.System != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: options.System,
+		})
+	}
+	for _, message := range options.History {
+		role := openai.ChatMessageRoleUser
+		if message.Role == RoleAssistant {
+			role = openai.ChatMessageRoleAssistant
 		}
-	default:
-		return fmt.Errorf("不支持的AI服务提供商: %s", cfg.Provider)
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    role,
+			Content: message.Content,
+		})
 	}
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: options.User,
+	})
+	return messages
+}
 
-	return nil
+// responseUsage 转换 OpenAI 协议响应中的用量
+func responseUsage(p *provider, model string, usage openai.Usage) Usage {
+	return Usage{
+		Provider:         p.profile.Name,
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// estimateUsage 按文本长度估算用量，用于服务商没有返回用量的流式响应
+func estimateUsage(p *provider, model string, messages []openai.ChatCompletionMessage, content string) Usage {
+	prompt := 0
+	for _, message := range messages {
+		prompt += EstimateTokens(message.Content) + messageTokenOverhead
+	}
+	return Usage{
+		Provider:         p.profile.Name,
+		Model:            model,
+		PromptTokens:     prompt,
+		CompletionTokens: EstimateTokens(content),
+		Estimated:        true,
+	}
+}
+
+// getDefault 获取默认值
+func getDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
 }
 
 // isValidURL 验证URL格式是否有效
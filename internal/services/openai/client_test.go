@@ -0,0 +1,155 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeStreamServer 模拟流式对话接口，withUsage 为 true 时按请求在最后一段返回用量
+func fakeStreamServer(t *testing.T, withUsage bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			StreamOptions *struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("流式请求没有要求返回用量")
+		}
+
+		writeStream(w, withUsage)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fakeLegacyStreamServer 模拟不认识 stream_options 的兼容网关，带有该参数时返回400，requests 记录请求次数
+func fakeLegacyStreamServer(t *testing.T, requests *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		var req map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := req["stream_options"]; ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"message":"Unrecognized request argument supplied: stream_options","type":"invalid_request_error"}}`)
+			return
+		}
+		writeStream(w, false)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeStream 以SSE格式分两段返回"你好，我在"，withUsage 为 true 时在最后一段返回用量
+func writeStream(w http.ResponseWriter, withUsage bool) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, text := range []string{"你好", "，我在"} {
+		fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", text)
+	}
+	if withUsage {
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[],\"usage\":{\"prompt_tokens\":21,\"completion_tokens\":4,\"total_tokens\":25}}\n\n")
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func newTestClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+	return newProfileClient(t, config.ProviderProfile{
+		Name:         "test",
+		Kind:         "openai",
+		BaseURL:      baseURL,
+		APIKey:       "test-key",
+		DefaultModel: "test-model",
+	})
+}
+
+// newProfileClient 创建只有一个服务商的客户端
+func newProfileClient(t *testing.T, profile config.ProviderProfile) *Client {
+	t.Helper()
+	logger.Init()
+	client, err := NewClient(config.OpenAIConfig{
+		Profiles: []config.ProviderProfile{profile},
+		Profile:  profile.Name,
+	})
+	if err != nil {
+		t.Fatalf("创建AI客户端失败: %v", err)
+	}
+	return client
+}
+
+func TestChatStreamUsesReportedUsage(t *testing.T) {
+	logger.Init()
+	for _, withUsage := range []bool{true, false} {
+		server := fakeStreamServer(t, withUsage)
+		client := newTestClient(t, server.URL+"/v1")
+
+		var usages []Usage
+		answer, err := client.ChatStream(context.Background(), ChatOptions{
+			User:    "你好",
+			OnUsage: func(usage Usage) { usages = append(usages, usage) },
+		})
+		if err != nil {
+			t.Fatalf("流式对话失败: %v", err)
+		}
+		if answer != "你好，我在" {
+			t.Errorf("回复 = %q", answer)
+		}
+		if len(usages) != 1 {
+			t.Fatalf("期望上报1次用量，实际 %d 次", len(usages))
+		}
+
+		usage := usages[0]
+		if withUsage {
+			if usage.Estimated || usage.PromptTokens != 21 || usage.CompletionTokens != 4 || usage.TotalTokens != 25 {
+				t.Errorf("应使用服务商返回的用量，实际 %+v", usage)
+			}
+		} else if !usage.Estimated || usage.CompletionTokens == 0 {
+			t.Errorf("服务商未返回用量时应按文本估算并标记，实际 %+v", usage)
+		}
+	}
+}
+
+func TestChatStreamRetriesWithoutStreamOptions(t *testing.T) {
+	logger.Init()
+	var requests int32
+	server := fakeLegacyStreamServer(t, &requests)
+	client := newTestClient(t, server.URL+"/v1")
+
+	for i, wantRequests := range []int32{2, 3} {
+		var usages []Usage
+		answer, err := client.ChatStream(context.Background(), ChatOptions{
+			User:    "你好",
+			OnUsage: func(usage Usage) { usages = append(usages, usage) },
+		})
+		if err != nil {
+			t.Fatalf("第%d次流式对话失败: %v", i+1, err)
+		}
+		if answer != "你好，我在" {
+			t.Errorf("回复 = %q", answer)
+		}
+		if len(usages) != 1 || !usages[0].Estimated {
+			t.Errorf("服务商不支持返回用量时应按文本估算，实际 %+v", usages)
+		}
+		// 第一次去掉 stream_options 重试，之后不再发送该参数
+		if got := atomic.LoadInt32(&requests); got != wantRequests {
+			t.Errorf("第%d次对话后共请求 %d 次，期望 %d 次", i+1, got, wantRequests)
+		}
+	}
+}
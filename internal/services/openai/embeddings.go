@@ -0,0 +1,93 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// newEmbeddingClient 创建嵌入接口客户端
+// 配置了独立的嵌入服务地址时使用该地址，否则复用主服务商（需要支持嵌入接口）
+func newEmbeddingClient(cfg config.OpenAIConfig, primary *provider, httpClient *http.Client) (*openai.Client, error) {
+	if !cfg.EnableEmbedding {
+		return nil, nil
+	}
+
+	if cfg.EmbeddingBaseURL != "" {
+		if !isValidURL(cfg.EmbeddingBaseURL) {
+			return nil, fmt.Errorf("嵌入服务地址格式无效: %s", cfg.EmbeddingBaseURL)
+		}
+		apiKey := cfg.EmbeddingAPIKey
+		if apiKey == "" {
+			apiKey = primary.profile.APIKey
+		}
+		clientConfig := openai.DefaultConfig(apiKey)
+		clientConfig.BaseURL = cfg.EmbeddingBaseURL
+		clientConfig.HTTPClient = httpClient
+		logger.Infof("已初始化独立的嵌入服务客户端: %s", cfg.EmbeddingBaseURL)
+		return openai.NewClientWithConfig(clientConfig), nil
+	}
+
+	if !primary.profile.Supports(config.CapabilityEmbeddings) || primary.client == nil {
+		logger.Warnf("AI服务商 %s 不提供嵌入接口，请配置独立的嵌入服务地址，语义记忆已停用", primary.profile.Name)
+		return nil, nil
+	}
+	return primary.client, nil
+}
+
+// SupportsEmbedding 是否可以生成向量嵌入
+func (c *Client) SupportsEmbedding() bool {
+	return c.embedder != nil
+}
+
+// EmbeddingModel 获取嵌入模型名称
+func (c *Client) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+// EmbeddingDimensions 获取配置的向量维度，0表示使用模型默认值
+func (c *Client) EmbeddingDimensions() int {
+	return c.embeddingDimensions
+}
+
+// Embed 批量生成文本的向量嵌入，返回的向量与输入一一对应
+func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.embedder == nil {
+		return nil, fmt.Errorf("未启用向量嵌入或当前服务商不支持")
+	}
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	// 换行会降低嵌入质量，统一替换为空格
+	inputs := make([]string, len(texts))
+	for i, text := range texts {
+		inputs[i] = strings.ReplaceAll(text, "\n", " ")
+	}
+
+	resp, err := c.embedder.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Input:      inputs,
+		Model:      openai.EmbeddingModel(c.embeddingModel),
+		Dimensions: c.embeddingDimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成向量嵌入失败: %v", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("向量嵌入数量不匹配: 期望%d条，实际%d条", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("向量嵌入序号无效: %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
@@ -0,0 +1,216 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/utils"
+	"mi-gpt-go/pkg/logger"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 断路器默认参数
+const (
+	defaultBreakerFailures = 3
+	defaultBreakerReset    = time.Minute
+)
+
+// provider 故障转移链中的一个服务商
+type provider struct {
+	profile config.ProviderProfile
+	client  *openai.Client // OpenAI协议客户端，原生接口的服务商只用于嵌入接口，可能为空
+	native  nativeBackend  // 原生接口的对话实现，OpenAI协议的服务商为空
+	breaker *utils.CircuitBreaker
+
+	mutex     sync.Mutex
+	requests  int64
+	answered  int64
+	lastError string
+	lastUsed  time.Time
+	// noStreamUsage 服务商不接受 stream_options 参数，流式请求不再要求返回用量
+	noStreamUsage bool
+}
+
+// ProviderStatus 故障转移链中服务商的状态
+type ProviderStatus struct {
+	Name      string     `json:"name"`
+	Provider  string     `json:"provider"`
+	Model     string     `json:"model"`
+	State     string     `json:"state"`    // 断路器状态：closed 正常，open 暂停使用，half-open 试探恢复
+	Failures  int        `json:"failures"` // 连续失败次数
+	Requests  int64      `json:"requests"`
+	Answered  int64      `json:"answered"` // 成功回答的次数
+	LastError string     `json:"lastError,omitempty"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+}
+
+// fatalError 不能换用其他服务商重试的错误，例如回复已经开始播报或工具已经执行
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// record 记录一次请求的结果
+func (p *provider) record(err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.requests++
+	p.lastUsed = time.Now()
+	if err != nil {
+		p.lastError = err.Error()
+	} else {
+		p.answered++
+	}
+}
+
+// streamUsage 流式请求是否要求服务商返回用量
+func (p *provider) streamUsage() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return !p.noStreamUsage
+}
+
+// disableStreamUsage 服务商拒绝 stream_options 参数后不再发送
+func (p *provider) disableStreamUsage() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.noStreamUsage = true
+}
+
+// status 获取服务商状态
+func (p *provider) status() ProviderStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	status := ProviderStatus{
+		Name:      p.profile.Name,
+		Provider:  p.profile.Kind,
+		Model:     p.profile.DefaultModel,
+		State:     p.breaker.GetState(),
+		Failures:  p.breaker.GetFailures(),
+		Requests:  p.requests,
+		Answered:  p.answered,
+		LastError: p.lastError,
+	}
+	if !p.lastUsed.IsZero() {
+		lastUsed := p.lastUsed
+		status.LastUsed = &lastUsed
+	}
+	return status
+}
+
+// ProviderStatus 获取故障转移链中各服务商的状态，主服务商在前
+func (c *Client) ProviderStatus() []ProviderStatus {
+	statuses := make([]ProviderStatus, 0, len(c.providers))
+	for _, p := range c.providers {
+		statuses = append(statuses, p.status())
+	}
+	return statuses
+}
+
+// failover 依次使用故障转移链中的服务商执行请求，直到成功或遇到不可重试的错误
+// 网络错误、5xx和429计入断路器的失败次数并换用下一个服务商，断路器打开的服务商会被跳过，冷却后自动恢复
+// options.Model 只作用于主服务商，备用服务商使用各自配置的模型
+func (c *Client) failover(ctx context.Context, options ChatOptions, call func(p *provider, model string) error) error {
+	var lastErr error
+	for i, p := range c.providers {
+		model := p.profile.DefaultModel
+		if i == 0 && options.Model != "" {
+			model = options.Model
+		}
+
+		var callErr error
+		err := p.breaker.Execute(func() error {
+			callErr = call(p, model)
+			if callErr != nil && isRetryable(ctx, callErr) {
+				return callErr
+			}
+			return nil
+		})
+		if errors.Is(err, utils.ErrCircuitOpen) {
+			logger.Debugf("AI服务商 %s 暂停使用中，跳过", p.profile.Name)
+			continue
+		}
+
+		p.record(callErr)
+		if callErr == nil {
+			if i > 0 {
+				logger.Infof("🔀 已由备用AI服务商 %s 回答", p.profile.Name)
+			}
+			if options.OnProvider != nil {
+				options.OnProvider(p.profile.Name, model)
+			}
+			return nil
+		}
+		lastErr = callErr
+		if !isRetryable(ctx, callErr) {
+			return callErr
+		}
+		if i < len(c.providers)-1 {
+			logger.Warnf("⚠️ AI服务商 %s 请求失败，切换到下一个服务商: %v", p.profile.Name, callErr)
+		}
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("所有AI服务商都暂停使用中，请稍后再试")
+	}
+	return lastErr
+}
+
+// isRetryable 是否可以换用下一个服务商重试：网络错误、5xx和429
+func isRetryable(ctx context.Context, err error) bool {
+	// 调用方已取消或超时，换服务商也无济于事
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var fatal *fatalError
+	if errors.As(err, &fatal) {
+		return false
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return isRetryableStatus(apiErr.HTTPStatusCode)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return isRetryableStatus(requestErr.HTTPStatusCode)
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.statusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isBadRequest 是否为服务商返回的400错误，通常是不支持某个请求参数
+func isBadRequest(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusBadRequest
+	}
+	var requestErr *openai.RequestError
+	return errors.As(err, &requestErr) && requestErr.HTTPStatusCode == http.StatusBadRequest
+}
+
+// isRetryableStatus 是否为可重试的HTTP状态码
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
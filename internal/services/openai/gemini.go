@@ -0,0 +1,260 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// geminiBackend 使用 Gemini generateContent 接口的模型
+type geminiBackend struct {
+	profile    config.ProviderProfile
+	httpClient *http.Client
+}
+
+// geminiPart Gemini 消息片段，只使用文本
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// geminiContent Gemini 对话消息，角色为 user 或 model
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiSafetySetting Gemini 安全设置
+type geminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// geminiRequest Gemini 对话请求
+type geminiRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+	SafetySettings    []geminiSafetySetting  `json:"safetySettings,omitempty"`
+}
+
+// geminiResponse Gemini 对话响应，流式输出时每个事件一个
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newGeminiBackend 创建 Gemini 原生接口的对话实现
+func newGeminiBackend(profile config.ProviderProfile, httpClient *http.Client) *geminiBackend {
+	return &geminiBackend{profile: profile, httpClient: httpClient}
+}
+
+// url 拼接模型接口地址，模型名称可以带 models/ 前缀
+func (b *geminiBackend) url(model, method string) string {
+	return fmt.Sprintf("%s/models/%s:%s",
+		strings.TrimSuffix(b.profile.EffectiveBaseURL(), "/"), strings.TrimPrefix(model, "models/"), method)
+}
+
+// headers 鉴权请求头
+func (b *geminiBackend) headers() map[string]string {
+	return map[string]string{"x-goog-api-key": b.profile.APIKey}
+}
+
+func (b *geminiBackend) chat(ctx context.Context, model string, messages []openai.ChatCompletionMessage, options ChatOptions, onDelta func(string)) (string, error) {
+	start := time.Now()
+	req := geminiRequest{SafetySettings: b.safetySettings()}
+	system, contents := toGeminiContents(messages)
+	req.Contents = contents
+	if system != "" {
+		req.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+
+	// 服务商参数作为生成配置，例如 temperature、maxOutputTokens
+	if len(b.profile.Options) > 0 || options.JSONMode {
+		req.GenerationConfig = make(map[string]interface{}, len(b.profile.Options)+1)
+		for key, value := range b.profile.Options {
+			req.GenerationConfig[key] = value
+		}
+	}
+	if options.JSONMode && b.profile.Supports(config.CapabilityJSON) {
+		req.GenerationConfig["responseMimeType"] = "application/json"
+	}
+
+	if onDelta != nil {
+		return b.stream(ctx, model, req, options, onDelta, start)
+	}
+
+	resp, err := postJSON(ctx, b.httpClient, b.profile.Name, b.url(model, "generateContent"), b.headers(), req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析Gemini响应失败: %v", err)
+	}
+	text, err := b.parse(&result)
+	if err != nil {
+		return "", err
+	}
+	b.reportUsage(&result, model, options, start)
+	if text == "" {
+		if reason := result.finishReason(); reason != "" && reason != "STOP" {
+			return "", fmt.Errorf("gemini未返回内容: %s", reason)
+		}
+	}
+	return text, nil
+}
+
+// stream 流式对话，使用 alt=sse 让接口按 SSE 格式逐段返回
+func (b *geminiBackend) stream(ctx context.Context, model string, req geminiRequest, options ChatOptions, onDelta func(string), start time.Time) (string, error) {
+	resp, err := postJSON(ctx, b.httpClient, b.profile.Name, b.url(model, "streamGenerateContent")+"?alt=sse", b.headers(), req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var last geminiResponse
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			return "", fmt.Errorf("解析Gemini流式响应失败: %v", err)
+		}
+		text, err := b.parse(&chunk)
+		if err != nil {
+			return "", err
+		}
+		if text != "" {
+			content.WriteString(text)
+			onDelta(text)
+		}
+		// 用量在每个事件中累计，以最后一个为准
+		if chunk.UsageMetadata != nil {
+			last = chunk
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	b.reportUsage(&last, model, options, start)
+	return content.String(), nil
+}
+
+// parse 提取响应文本，错误和被安全策略拦截的请求转换为错误
+func (b *geminiBackend) parse(result *geminiResponse) (string, error) {
+	if result.Error != nil {
+		return "", &statusError{provider: b.profile.Name, statusCode: result.Error.Code, message: result.Error.Message}
+	}
+	if result.PromptFeedback.BlockReason != "" {
+		return "", fmt.Errorf("gemini拦截了请求: %s", result.PromptFeedback.BlockReason)
+	}
+	if len(result.Candidates) == 0 {
+		return "", nil
+	}
+	var text strings.Builder
+	for _, part := range result.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String(), nil
+}
+
+// reportUsage 上报响应中的令牌用量
+func (b *geminiBackend) reportUsage(result *geminiResponse, model string, options ChatOptions, start time.Time) {
+	if result.UsageMetadata == nil {
+		return
+	}
+	reportUsage(options, Usage{
+		Provider:         b.profile.Name,
+		Model:            model,
+		PromptTokens:     result.UsageMetadata.PromptTokenCount,
+		CompletionTokens: result.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      result.UsageMetadata.TotalTokenCount,
+	}, start)
+}
+
+// safetySettings 按类别排序的安全设置，未配置时使用 Gemini 的默认设置
+func (b *geminiBackend) safetySettings() []geminiSafetySetting {
+	if len(b.profile.SafetySettings) == 0 {
+		return nil
+	}
+	categories := make([]string, 0, len(b.profile.SafetySettings))
+	for category := range b.profile.SafetySettings {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	settings := make([]geminiSafetySetting, 0, len(categories))
+	for _, category := range categories {
+		settings = append(settings, geminiSafetySetting{
+			Category:  category,
+			Threshold: b.profile.SafetySettings[category],
+		})
+	}
+	return settings
+}
+
+// finishReason 第一个候选回复的结束原因
+func (r *geminiResponse) finishReason() string {
+	if len(r.Candidates) == 0 {
+		return ""
+	}
+	return r.Candidates[0].FinishReason
+}
+
+// toGeminiContents 转换对话消息：系统提示作为 systemInstruction，助手角色为 model，相邻的同角色消息合并
+func toGeminiContents(messages []openai.ChatCompletionMessage) (string, []geminiContent) {
+	var system []string
+	result := make([]geminiContent, 0, len(messages))
+	for _, message := range messages {
+		if message.Role == openai.ChatMessageRoleSystem {
+			if message.Content != "" {
+				system = append(system, message.Content)
+			}
+			continue
+		}
+		if message.Content == "" {
+			continue
+		}
+
+		role := "user"
+		if message.Role == openai.ChatMessageRoleAssistant {
+			role = "model"
+		}
+		part := geminiPart{Text: message.Content}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Parts = append(result[n-1].Parts, part)
+			continue
+		}
+		result = append(result, geminiContent{Role: role, Parts: []geminiPart{part}})
+	}
+	return strings.Join(system, "\n\n"), result
+}
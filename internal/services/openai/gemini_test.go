@@ -0,0 +1,158 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeGeminiServer 模拟 Gemini 接口，收到的请求写入 received，路径和查询参数写入 path
+// respond 写出响应
+func fakeGeminiServer(t *testing.T, received *geminiRequest, path *string, respond func(w http.ResponseWriter)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("鉴权请求头 x-goog-api-key = %q", r.Header.Get("x-goog-api-key"))
+		}
+		*path = r.URL.RequestURI()
+		if err := json.NewDecoder(r.Body).Decode(received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respond(w)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newGeminiTestClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+	return newProfileClient(t, config.ProviderProfile{
+		Name:         "gemini",
+		Kind:         "gemini",
+		BaseURL:      baseURL + "/v1beta",
+		APIKey:       "test-key",
+		DefaultModel: "models/gemini-2.5-flash",
+		Options:      map[string]interface{}{"temperature": 0.5},
+		SafetySettings: map[string]string{
+			"HARM_CATEGORY_HARASSMENT":  "BLOCK_ONLY_HIGH",
+			"HARM_CATEGORY_DANGEROUS":   "BLOCK_NONE",
+			"HARM_CATEGORY_HATE_SPEECH": "BLOCK_MEDIUM_AND_ABOVE",
+		},
+	})
+}
+
+func TestGeminiChatMapsRolesAndMergesMessages(t *testing.T) {
+	var received geminiRequest
+	var path string
+	server := fakeGeminiServer(t, &received, &path, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"mood\":"},{"text":"\"开心\"}"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":40,"candidatesTokenCount":9,"totalTokenCount":49}}`)
+	})
+	client := newGeminiTestClient(t, server.URL)
+
+	var usages []Usage
+	answer, err := client.Chat(context.Background(), ChatOptions{
+		System: "你是傻妞",
+		History: []ChatMessage{
+			{Role: RoleUser, Content: "在吗"},
+			{Role: RoleAssistant, Content: "在的"},
+			{Role: RoleAssistant, Content: "有什么事"},
+		},
+		User:     "用JSON说说你的心情",
+		JSONMode: true,
+		OnUsage:  func(usage Usage) { usages = append(usages, usage) },
+	})
+	if err != nil {
+		t.Fatalf("对话失败: %v", err)
+	}
+	if answer != `{"mood":"开心"}` {
+		t.Errorf("回复 = %q", answer)
+	}
+	if path != "/v1beta/models/gemini-2.5-flash:generateContent" {
+		t.Errorf("请求路径 = %q，模型名称的 models/ 前缀应去掉", path)
+	}
+
+	if received.SystemInstruction == nil || received.SystemInstruction.Parts[0].Text != "你是傻妞" {
+		t.Errorf("系统提示应放在 systemInstruction 中，实际 %+v", received.SystemInstruction)
+	}
+	// 助手角色为 model，相邻的两条助手消息合并为一条消息的两个片段
+	want := []geminiContent{
+		{Role: "user", Parts: []geminiPart{{Text: "在吗"}}},
+		{Role: "model", Parts: []geminiPart{{Text: "在的"}, {Text: "有什么事"}}},
+		{Role: "user", Parts: []geminiPart{{Text: "用JSON说说你的心情"}}},
+	}
+	if fmt.Sprint(received.Contents) != fmt.Sprint(want) {
+		t.Errorf("发送的消息 = %v, 期望 %v", received.Contents, want)
+	}
+
+	if received.GenerationConfig["temperature"] != 0.5 || received.GenerationConfig["responseMimeType"] != "application/json" {
+		t.Errorf("生成配置 = %v, 期望包含服务商参数和JSON输出", received.GenerationConfig)
+	}
+	var categories []string
+	for _, setting := range received.SafetySettings {
+		categories = append(categories, setting.Category)
+	}
+	if strings.Join(categories, ",") != "HARM_CATEGORY_DANGEROUS,HARM_CATEGORY_HARASSMENT,HARM_CATEGORY_HATE_SPEECH" {
+		t.Errorf("安全设置应按类别排序，实际 %v", categories)
+	}
+
+	if len(usages) != 1 || usages[0].PromptTokens != 40 || usages[0].CompletionTokens != 9 || usages[0].TotalTokens != 49 {
+		t.Errorf("应使用 usageMetadata 中的用量，实际 %+v", usages)
+	}
+}
+
+func TestGeminiChatStream(t *testing.T) {
+	var received geminiRequest
+	var path string
+	server := fakeGeminiServer(t, &received, &path, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"晚上"}]}}],"usageMetadata":{"promptTokenCount":18,"candidatesTokenCount":1,"totalTokenCount":19}}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":"会降温"}]}}]}`,
+			`{"candidates":[{"content":{"role":"model","parts":[{"text":""}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":18,"candidatesTokenCount":4,"totalTokenCount":22}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\r\n\r\n", event)
+		}
+	})
+	client := newGeminiTestClient(t, server.URL)
+
+	var streamed strings.Builder
+	var usages []Usage
+	answer, err := client.ChatStream(context.Background(), ChatOptions{
+		User:     "今晚冷吗",
+		OnStream: func(text string) { streamed.WriteString(text) },
+		OnUsage:  func(usage Usage) { usages = append(usages, usage) },
+	})
+	if err != nil {
+		t.Fatalf("流式对话失败: %v", err)
+	}
+	if path != "/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse" {
+		t.Errorf("请求路径 = %q", path)
+	}
+	if answer != "晚上会降温" || streamed.String() != answer {
+		t.Errorf("回复 = %q, 流式回调 = %q", answer, streamed.String())
+	}
+	// 用量在每个事件中累计，以最后一个为准
+	if len(usages) != 1 || usages[0].PromptTokens != 18 || usages[0].CompletionTokens != 4 || usages[0].TotalTokens != 22 {
+		t.Errorf("流式用量 = %+v, 期望最后一个事件的用量", usages)
+	}
+}
+
+func TestGeminiBlockedPrompt(t *testing.T) {
+	var received geminiRequest
+	var path string
+	server := fakeGeminiServer(t, &received, &path, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"promptFeedback":{"blockReason":"SAFETY"},"usageMetadata":{"promptTokenCount":12,"totalTokenCount":12}}`)
+	})
+	client := newGeminiTestClient(t, server.URL)
+
+	_, err := client.Chat(context.Background(), ChatOptions{User: "你好"})
+	if err == nil || !strings.Contains(err.Error(), "SAFETY") {
+		t.Errorf("被安全策略拦截的请求应返回错误，实际 %v", err)
+	}
+}
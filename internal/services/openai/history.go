@@ -0,0 +1,63 @@
+package openai
+
+import "unicode"
+
+// messageTokenOverhead 每条消息的固定开销（角色、分隔符等）
+const messageTokenOverhead = 4
+
+// ChatMessage 对话历史中的一条消息
+type ChatMessage struct {
+	Role    string `json:"role"` // user 或 assistant
+	Content string `json:"content"`
+}
+
+// 对话角色
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// EstimateTokens 粗略估算文本的token数：中日韩字符按1个token计，其余按4个字符1个token计
+func EstimateTokens(text string) int {
+	cjk, others := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			others++
+		}
+	}
+	return cjk + (others+3)/4
+}
+
+// EstimateMessagesTokens 估算一组消息的token数
+func EstimateMessagesTokens(messages []ChatMessage) int {
+	total := 0
+	for _, message := range messages {
+		total += EstimateTokens(message.Content) + messageTokenOverhead
+	}
+	return total
+}
+
+// TrimHistory 按轮数和token预算裁剪对话历史，优先丢弃最早的轮次
+// 一轮以用户消息开始；maxTurns或maxTokens小于等于0表示不限制
+func TrimHistory(history []ChatMessage, maxTurns, maxTokens int) []ChatMessage {
+	// 找出每一轮的起始位置
+	var turnStarts []int
+	for i, message := range history {
+		if message.Role == RoleUser || i == 0 {
+			turnStarts = append(turnStarts, i)
+		}
+	}
+	if maxTurns > 0 && len(turnStarts) > maxTurns {
+		turnStarts = turnStarts[len(turnStarts)-maxTurns:]
+	}
+
+	for _, start := range turnStarts {
+		if maxTokens <= 0 || EstimateMessagesTokens(history[start:]) <= maxTokens {
+			return history[start:]
+		}
+	}
+	return nil
+}
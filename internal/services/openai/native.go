@@ -0,0 +1,86 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// nativeBackend 使用服务商原生接口（非OpenAI协议）的对话实现
+type nativeBackend interface {
+	// chat 发送对话请求，onDelta 不为空时使用流式输出并逐段回调
+	chat(ctx context.Context, model string, messages []openai.ChatCompletionMessage, options ChatOptions, onDelta func(string)) (string, error)
+}
+
+// statusError 原生接口返回的HTTP错误，状态码用于判断是否可以换用其他服务商
+type statusError struct {
+	provider   string
+	statusCode int
+	message    string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s 返回错误, status code: %d, message: %s", e.provider, e.statusCode, e.message)
+}
+
+// postJSON 以JSON格式发送POST请求，非2xx响应转换为 statusError
+// 调用方负责关闭返回的响应体
+func postJSON(ctx context.Context, httpClient *http.Client, provider, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return doRequest(httpClient, provider, req)
+}
+
+// doRequest 发送请求，非2xx响应转换为 statusError
+func doRequest(httpClient *http.Client, provider string, req *http.Request) (*http.Response, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{
+			provider:   provider,
+			statusCode: resp.StatusCode,
+			message:    errorMessage(data),
+		}
+	}
+	return resp, nil
+}
+
+// errorMessage 从错误响应中提取错误信息，兼容 {"error":"..."} 和 {"error":{"message":"..."}}
+func errorMessage(data []byte) string {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && len(body.Error) > 0 {
+		var text string
+		if json.Unmarshal(body.Error, &text) == nil {
+			return text
+		}
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body.Error, &detail) == nil && detail.Message != "" {
+			return detail.Message
+		}
+	}
+	return strings.TrimSpace(string(data))
+}
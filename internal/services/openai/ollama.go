@@ -0,0 +1,247 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ollamaBackend 使用 Ollama 原生 /api/chat 接口的本地模型
+type ollamaBackend struct {
+	profile    config.ProviderProfile
+	httpClient *http.Client
+}
+
+// ollamaMessage Ollama 对话消息
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ollamaChatRequest Ollama 对话请求
+type ollamaChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []ollamaMessage        `json:"messages"`
+	Stream    bool                   `json:"stream"`
+	Format    string                 `json:"format,omitempty"`
+	KeepAlive interface{}            `json:"keep_alive,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+// ollamaChatResponse Ollama 对话响应，流式输出时每行一个
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	Error           string        `json:"error"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// OllamaModel 已下载到本地的 Ollama 模型
+type OllamaModel struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	ModifiedAt    time.Time `json:"modifiedAt"`
+	ParameterSize string    `json:"parameterSize"`
+	Quantization  string    `json:"quantization"`
+}
+
+// OllamaPullProgress Ollama 模型下载进度
+type OllamaPullProgress struct {
+	Status    string `json:"status"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+}
+
+// newOllamaBackend 创建 Ollama 原生接口的对话实现
+func newOllamaBackend(profile config.ProviderProfile, httpClient *http.Client) *ollamaBackend {
+	return &ollamaBackend{profile: profile, httpClient: httpClient}
+}
+
+// url 拼接接口地址
+func (b *ollamaBackend) url(path string) string {
+	return strings.TrimSuffix(b.profile.EffectiveBaseURL(), "/") + path
+}
+
+// headers 请求头，Ollama 本身不需要密钥，配置了密钥时用于前置的鉴权代理
+func (b *ollamaBackend) headers() map[string]string {
+	if b.profile.APIKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + b.profile.APIKey}
+}
+
+// keepAlive 模型在内存中的保留时间，纯数字按秒处理，-1表示一直保留
+func (b *ollamaBackend) keepAlive() interface{} {
+	if b.profile.KeepAlive == "" {
+		return nil
+	}
+	if seconds, err := strconv.Atoi(b.profile.KeepAlive); err == nil {
+		return seconds
+	}
+	return b.profile.KeepAlive
+}
+
+func (b *ollamaBackend) chat(ctx context.Context, model string, messages []openai.ChatCompletionMessage, options ChatOptions, onDelta func(string)) (string, error) {
+	start := time.Now()
+	req := ollamaChatRequest{
+		Model:     model,
+		Messages:  make([]ollamaMessage, 0, len(messages)),
+		Stream:    onDelta != nil,
+		KeepAlive: b.keepAlive(),
+		Options:   b.profile.Options,
+	}
+	for _, message := range messages {
+		req.Messages = append(req.Messages, ollamaMessage{Role: message.Role, Content: message.Content})
+	}
+	if options.JSONMode && b.profile.Supports(config.CapabilityJSON) {
+		req.Format = "json"
+	}
+
+	resp, err := postJSON(ctx, b.httpClient, b.profile.Name, b.url("/api/chat"), b.headers(), req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if onDelta == nil {
+		var result ollamaChatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return "", fmt.Errorf("解析Ollama响应失败: %v", err)
+		}
+		if result.Error != "" {
+			return "", fmt.Errorf("ollama返回错误: %s", result.Error)
+		}
+		reportUsage(options, b.usage(model, &result), start)
+		return result.Message.Content, nil
+	}
+
+	// 流式输出为每行一个JSON对象，最后一行 done 为 true
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", fmt.Errorf("解析Ollama流式响应失败: %v", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama返回错误: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			onDelta(chunk.Message.Content)
+		}
+		if chunk.Done {
+			reportUsage(options, b.usage(model, &chunk), start)
+			return content.String(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+// usage 最后一个响应中的输入和输出token数
+func (b *ollamaBackend) usage(model string, result *ollamaChatResponse) Usage {
+	return Usage{
+		Provider:         b.profile.Name,
+		Model:            model,
+		PromptTokens:     result.PromptEvalCount,
+		CompletionTokens: result.EvalCount,
+	}
+}
+
+// ListOllamaModels 列出 Ollama 服务上已下载的模型
+func ListOllamaModels(ctx context.Context, profile config.ProviderProfile) ([]OllamaModel, error) {
+	b := newOllamaBackend(profile, profileHTTPClient(profile, http.DefaultTransport))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url("/api/tags"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	for key, value := range b.headers() {
+		req.Header.Set(key, value)
+	}
+	resp, err := doRequest(b.httpClient, profile.Name, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			Size       int64     `json:"size"`
+			ModifiedAt time.Time `json:"modified_at"`
+			Details    struct {
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析Ollama模型列表失败: %v", err)
+	}
+
+	models := make([]OllamaModel, 0, len(result.Models))
+	for _, model := range result.Models {
+		models = append(models, OllamaModel{
+			Name:          model.Name,
+			Size:          model.Size,
+			ModifiedAt:    model.ModifiedAt,
+			ParameterSize: model.Details.ParameterSize,
+			Quantization:  model.Details.QuantizationLevel,
+		})
+	}
+	return models, nil
+}
+
+// PullOllamaModel 让 Ollama 服务下载模型，下载过程中回调进度，完成或出错后返回
+func PullOllamaModel(ctx context.Context, profile config.ProviderProfile, model string, onProgress func(OllamaPullProgress)) error {
+	b := newOllamaBackend(profile, profileHTTPClient(profile, http.DefaultTransport))
+	resp, err := postJSON(ctx, b.httpClient, profile.Name, b.url("/api/pull"), b.headers(), map[string]interface{}{
+		"model":  model,
+		"stream": true,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var progress struct {
+			OllamaPullProgress
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &progress); err != nil {
+			continue
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("下载模型失败: %s", progress.Error)
+		}
+		if onProgress != nil {
+			onProgress(progress.OllamaPullProgress)
+		}
+		if progress.Status == "success" {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("下载模型失败: %v", err)
+	}
+	return fmt.Errorf("下载模型失败: 连接意外中断")
+}
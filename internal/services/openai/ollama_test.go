@@ -0,0 +1,138 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeOllamaServer 模拟 Ollama 的 /api/chat 接口，收到的请求写入 received
+// 流式请求按行返回 chunks，最后一行带有用量；非流式请求一次返回完整回复
+func fakeOllamaServer(t *testing.T, received *ollamaChatRequest, chunks ...string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		if !received.Stream {
+			fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":true,"prompt_eval_count":12,"eval_count":5}`, strings.Join(chunks, ""))
+			return
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "{\"message\":{\"role\":\"assistant\",\"content\":%q},\"done\":false}\n", chunk)
+		}
+		fmt.Fprint(w, "{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true,\"prompt_eval_count\":12,\"eval_count\":5}\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newOllamaTestClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+	return newProfileClient(t, config.ProviderProfile{
+		Name:         "local",
+		Kind:         "ollama",
+		BaseURL:      baseURL,
+		DefaultModel: "qwen2.5:7b",
+		KeepAlive:    "300",
+		Options:      map[string]interface{}{"num_ctx": float64(4096)},
+	})
+}
+
+func TestOllamaChat(t *testing.T) {
+	var received ollamaChatRequest
+	server := fakeOllamaServer(t, &received, "你好，", "我是傻妞")
+	client := newOllamaTestClient(t, server.URL)
+
+	var usages []Usage
+	answer, err := client.Chat(context.Background(), ChatOptions{
+		System: "你是傻妞",
+		History: []ChatMessage{
+			{Role: RoleUser, Content: "在吗"},
+			{Role: RoleAssistant, Content: "在的"},
+		},
+		User:    "你是谁",
+		OnUsage: func(usage Usage) { usages = append(usages, usage) },
+	})
+	if err != nil {
+		t.Fatalf("对话失败: %v", err)
+	}
+	if answer != "你好，我是傻妞" {
+		t.Errorf("回复 = %q", answer)
+	}
+
+	// Ollama 支持 system 角色，消息原样发送
+	want := []ollamaMessage{
+		{Role: "system", Content: "你是傻妞"},
+		{Role: "user", Content: "在吗"},
+		{Role: "assistant", Content: "在的"},
+		{Role: "user", Content: "你是谁"},
+	}
+	if fmt.Sprint(received.Messages) != fmt.Sprint(want) {
+		t.Errorf("发送的消息 = %v, 期望 %v", received.Messages, want)
+	}
+	if received.Model != "qwen2.5:7b" || received.Stream {
+		t.Errorf("请求的模型 = %q, 流式 = %v", received.Model, received.Stream)
+	}
+	if received.KeepAlive != float64(300) {
+		t.Errorf("纯数字的 keep_alive 应按秒发送，实际 %#v", received.KeepAlive)
+	}
+	if received.Options["num_ctx"] != float64(4096) {
+		t.Errorf("服务商参数应原样放在 options 中，实际 %v", received.Options)
+	}
+
+	if len(usages) != 1 || usages[0].PromptTokens != 12 || usages[0].CompletionTokens != 5 || usages[0].Estimated {
+		t.Errorf("应使用 prompt_eval_count 和 eval_count 作为用量，实际 %+v", usages)
+	}
+}
+
+func TestOllamaChatStream(t *testing.T) {
+	var received ollamaChatRequest
+	server := fakeOllamaServer(t, &received, "今天", "天气", "不错")
+	client := newOllamaTestClient(t, server.URL)
+
+	var streamed strings.Builder
+	var usages []Usage
+	answer, err := client.ChatStream(context.Background(), ChatOptions{
+		User:     "今天天气怎么样",
+		OnStream: func(text string) { streamed.WriteString(text) },
+		OnUsage:  func(usage Usage) { usages = append(usages, usage) },
+	})
+	if err != nil {
+		t.Fatalf("流式对话失败: %v", err)
+	}
+	if !received.Stream {
+		t.Errorf("流式对话应请求流式输出")
+	}
+	if answer != "今天天气不错" || streamed.String() != answer {
+		t.Errorf("回复 = %q, 流式回调 = %q", answer, streamed.String())
+	}
+	if len(usages) != 1 || usages[0].PromptTokens != 12 || usages[0].CompletionTokens != 5 {
+		t.Errorf("应使用最后一行的用量，实际 %+v", usages)
+	}
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "{\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":false}\n{\"error\":\"model 'qwen2.5:7b' not found\"}\n")
+	}))
+	defer server.Close()
+	client := newOllamaTestClient(t, server.URL)
+
+	_, err := client.ChatStream(context.Background(), ChatOptions{User: "你好"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("流式响应中的错误应返回给调用方，实际 %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package openai
+
+import (
+	"mi-gpt-go/pkg/logger"
+	"strings"
+	"unicode"
+)
+
+// 思考模型在回复内容中输出思考过程时使用的标签
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// reasoningSplitter 从模型输出中分离思考过程和回答
+// 思考过程来自 reasoning_content 字段或回复开头的 <think>…</think>，流式输出时标签可能被拆分到多段中
+type reasoningSplitter struct {
+	onAnswer   func(string) // 回答的增量回调，不含思考过程
+	onThinking func()       // 开始思考时回调一次
+
+	answer    strings.Builder
+	reasoning strings.Builder
+	pending   string // 可能是不完整标签的末尾内容，等下一段到达后再判断
+	thinking  bool   // 正在输出 <think> 中的内容
+	started   bool   // 是否已经开始思考
+}
+
+// newReasoningSplitter 创建流式对话使用的分离器，回答增量回调给 OnStream
+func newReasoningSplitter(options ChatOptions) *reasoningSplitter {
+	return &reasoningSplitter{onAnswer: options.OnStream, onThinking: options.OnThinking}
+}
+
+// splitReasoning 分离一次性返回的回复中的思考过程
+func splitReasoning(content, reasoningContent string) (answer, reasoning string) {
+	splitter := &reasoningSplitter{}
+	splitter.addReasoning(reasoningContent)
+	splitter.addContent(content)
+	return splitter.finish()
+}
+
+// addReasoning 添加 reasoning_content 字段中的思考过程
+func (s *reasoningSplitter) addReasoning(delta string) {
+	if delta == "" {
+		return
+	}
+	s.startThinking()
+	s.reasoning.WriteString(delta)
+}
+
+// addContent 添加回复内容，<think> 中的内容归入思考过程，其余作为回答
+// 只识别回答开头的 <think>，回答中间出现的标签按原文输出
+func (s *reasoningSplitter) addContent(delta string) {
+	text := s.pending + delta
+	s.pending = ""
+	for text != "" {
+		if s.thinking {
+			index := strings.Index(text, thinkCloseTag)
+			if index < 0 {
+				keep := partialTagSuffix(text, thinkCloseTag)
+				s.reasoning.WriteString(text[:len(text)-keep])
+				s.pending = text[len(text)-keep:]
+				return
+			}
+			s.reasoning.WriteString(text[:index])
+			s.thinking = false
+			text = text[index+len(thinkCloseTag):]
+			continue
+		}
+
+		if s.answer.Len() == 0 {
+			text = strings.TrimLeftFunc(text, unicode.IsSpace)
+			if strings.HasPrefix(text, thinkOpenTag) {
+				s.startThinking()
+				s.thinking = true
+				text = text[len(thinkOpenTag):]
+				continue
+			}
+			if strings.HasPrefix(thinkOpenTag, text) {
+				s.pending = text
+				return
+			}
+		}
+		s.emit(text)
+		return
+	}
+}
+
+// finish 输出暂存的内容，返回完整的回答和思考过程
+// 部分模型的对话模板已经包含了 <think>，回复中只有 </think>，此时之前的内容都是思考过程
+// 流式输出时这部分内容已经回调，只能在返回的回答中去掉
+func (s *reasoningSplitter) finish() (answer, reasoning string) {
+	if s.pending != "" {
+		if s.thinking {
+			s.reasoning.WriteString(s.pending)
+		} else {
+			s.emit(s.pending)
+		}
+		s.pending = ""
+	}
+
+	answer = s.answer.String()
+	if index := strings.Index(answer, thinkCloseTag); index >= 0 && s.reasoning.Len() == 0 {
+		s.reasoning.WriteString(answer[:index])
+		answer = strings.TrimLeftFunc(answer[index+len(thinkCloseTag):], unicode.IsSpace)
+	}
+	return answer, strings.TrimSpace(s.reasoning.String())
+}
+
+// answered 是否已经输出了回答
+func (s *reasoningSplitter) answered() bool {
+	return s.answer.Len() > 0
+}
+
+func (s *reasoningSplitter) startThinking() {
+	if s.started {
+		return
+	}
+	s.started = true
+	if s.onThinking != nil {
+		s.onThinking()
+	}
+}
+
+func (s *reasoningSplitter) emit(text string) {
+	s.answer.WriteString(text)
+	if s.onAnswer != nil {
+		s.onAnswer(text)
+	}
+}
+
+// partialTagSuffix text 末尾可能是 tag 开头部分的长度
+func partialTagSuffix(text, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// reportReasoning 回调完整的思考过程，开启追踪时同时输出日志
+func reportReasoning(options ChatOptions, provider, reasoning string) {
+	if reasoning == "" {
+		return
+	}
+	if options.Trace {
+		logger.Infof("💭 AI思考过程 [%s]: %s", provider, reasoning)
+	}
+	if options.OnReasoning != nil {
+		options.OnReasoning(reasoning)
+	}
+}
@@ -0,0 +1,152 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/pkg/logger"
+	"strings"
+	"time"
+)
+
+// SearchToolName 模型自行联网搜索时调用的工具名称
+const SearchToolName = "web_search"
+
+// searchSnippetRunes 每条搜索结果的摘要最多保留的字数
+const searchSnippetRunes = 200
+
+// Searcher 联网搜索服务
+// 实现负责判断哪些问题需要先搜索，以及请求具体的搜索接口
+type Searcher interface {
+	// NeedSearch 问题是否需要先搜索最新信息再回答
+	NeedSearch(query string) bool
+	// Search 搜索问题，返回按相关度排列的结果
+	Search(ctx context.Context, query string) ([]SearchResult, error)
+}
+
+// SearchResult 一条搜索结果
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SetSearcher 设置联网搜索服务，为空时不搜索
+func (c *Client) SetSearcher(searcher Searcher) {
+	c.searcher = searcher
+}
+
+// withSearch 联网搜索：问题需要最新信息时先搜索，结果作为上下文加入系统提示
+// 没有预先搜索且携带工具时提供搜索工具，由模型决定是否搜索；返回是否已预先搜索
+// 客户端配置的开关只作用于回答用户提问，整理记忆等其他用途需要单独指定 EnableSearch
+func (c *Client) withSearch(ctx context.Context, options ChatOptions) (ChatOptions, bool) {
+	enabled := options.EnableSearch || (c.enableSearch && options.Purpose == PurposeChat)
+	if c.searcher == nil || !enabled {
+		return options, false
+	}
+
+	query := getDefault(options.SearchQuery, options.User)
+	if c.searcher.NeedSearch(query) {
+		results, err := c.search(ctx, query, options.Trace)
+		if err != nil {
+			logger.Warnf("联网搜索失败，直接回答: %v", err)
+		} else if len(results) > 0 {
+			options.System = appendSearchContext(options.System, results, time.Now())
+			return options, true
+		}
+	}
+
+	if len(options.Tools) > 0 {
+		// 复制工具列表，避免修改调用方的切片
+		tools := make([]Tool, 0, len(options.Tools)+1)
+		options.Tools = append(append(tools, options.Tools...), c.searchTool(options.Trace))
+	}
+	return options, false
+}
+
+// search 搜索并在开启追踪时输出引用的来源
+func (c *Client) search(ctx context.Context, query string, trace bool) ([]SearchResult, error) {
+	start := time.Now()
+	results, err := c.searcher.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if trace {
+		var sources strings.Builder
+		for i, result := range results {
+			fmt.Fprintf(&sources, "\n[%d] %s %s", i+1, result.Title, result.URL)
+		}
+		logger.Infof("🔎 联网搜索 [%s]: %d 条结果, 耗时 %v%s",
+			query, len(results), time.Since(start).Round(time.Millisecond), sources.String())
+	}
+	return results, nil
+}
+
+// searchTool 供模型自行联网搜索的工具
+func (c *Client) searchTool(trace bool) Tool {
+	return Tool{
+		Name:        SearchToolName,
+		Description: "联网搜索最新信息。问题涉及新闻、价格、比分、天气等实时内容，或者你不确定答案时使用",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "搜索关键词",
+				},
+			},
+			"required": []string{"query"},
+		},
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Query string `json:"query"`
+			}
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil || strings.TrimSpace(args.Query) == "" {
+				return "", fmt.Errorf("缺少搜索关键词")
+			}
+			results, err := c.search(ctx, args.Query, trace)
+			if err != nil {
+				return "", err
+			}
+			if len(results) == 0 {
+				return "没有找到相关结果", nil
+			}
+			return formatSearchResults(results), nil
+		},
+	}
+}
+
+// appendSearchContext 把搜索结果加入系统提示
+func appendSearchContext(system string, results []SearchResult, now time.Time) string {
+	section := fmt.Sprintf("## 联网搜索结果（%s）\n%s\n请优先参考以上搜索结果回答，结果与问题无关时按你自己的知识回答，不要念出网址。",
+		now.Format("2006年1月2日 15:04"), formatSearchResults(results))
+	if system == "" {
+		return section
+	}
+	return system + "\n\n" + section
+}
+
+// formatSearchResults 压缩搜索结果：每条保留标题、截断的摘要和来源，按序号引用
+func formatSearchResults(results []SearchResult) string {
+	var builder strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		fmt.Fprintf(&builder, "[%d] %s\n", i+1, result.Title)
+		if snippet := truncateRunes(strings.Join(strings.Fields(result.Snippet), " "), searchSnippetRunes); snippet != "" {
+			builder.WriteString(snippet + "\n")
+		}
+		fmt.Fprintf(&builder, "来源: %s", result.URL)
+	}
+	return builder.String()
+}
+
+// truncateRunes 截断到最多 n 个字符，截断时加省略号
+func truncateRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "…"
+}
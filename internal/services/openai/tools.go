@@ -0,0 +1,153 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/pkg/logger"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// defaultMaxToolRounds 默认最多执行的工具调用轮数
+const defaultMaxToolRounds = 5
+
+// Tool 可供模型调用的工具
+type Tool struct {
+	Name        string                 // 工具名称
+	Description string                 // 工具说明，模型据此决定何时调用
+	Parameters  map[string]interface{} // 参数的JSON Schema
+	Handler     func(ctx context.Context, arguments string) (string, error)
+}
+
+// ToolCallRecord 工具调用记录
+type ToolCallRecord struct {
+	Round     int           `json:"round"`
+	CallID    string        `json:"callId"`
+	Name      string        `json:"name"`
+	Arguments string        `json:"arguments"`
+	Result    string        `json:"result"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// toOpenAITools 转换为OpenAI工具定义
+func toOpenAITools(tools []Tool) []openai.Tool {
+	result := make([]openai.Tool, 0, len(tools))
+	for _, tool := range tools {
+		parameters := tool.Parameters
+		if parameters == nil {
+			parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		result = append(result, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+	return result
+}
+
+// chatWithTools 带工具调用的对话：模型请求工具时执行工具并回传结果，直到模型给出最终回复
+// 工具执行后出错不再切换服务商，避免重复执行工具，返回最终回复和各轮的思考过程
+func (c *Client) chatWithTools(ctx context.Context, p *provider, req openai.ChatCompletionRequest, options ChatOptions) (string, string, error) {
+	tools := make(map[string]Tool, len(options.Tools))
+	for _, tool := range options.Tools {
+		tools[tool.Name] = tool
+	}
+
+	maxRounds := options.MaxToolRounds
+	if maxRounds <= 0 {
+		maxRounds = defaultMaxToolRounds
+	}
+
+	req.Tools = toOpenAITools(options.Tools)
+	var reasoning []string
+	for round := 1; ; round++ {
+		// 达到轮数上限后禁止继续调用工具，要求模型直接回复
+		if round > maxRounds {
+			req.ToolChoice = "none"
+		}
+
+		start := time.Now()
+		resp, err := p.client.CreateChatCompletion(ctx, req)
+		if err != nil {
+			logger.Errorf("LLM 响应异常: %v", err)
+			if round > 1 {
+				return "", "", &fatalError{err: err}
+			}
+			return "", "", err
+		}
+		reportUsage(options, responseUsage(p, req.Model, resp.Usage), start)
+		if len(resp.Choices) == 0 {
+			return "", "", fmt.Errorf("未收到 AI 响应")
+		}
+
+		message := resp.Choices[0].Message
+		if message.ReasoningContent != "" {
+			reasoning = append(reasoning, message.ReasoningContent)
+		}
+		if len(message.ToolCalls) == 0 || round > maxRounds {
+			return message.Content, strings.Join(reasoning, "\n\n"), nil
+		}
+
+		// 思考过程不回传给模型，部分服务商会拒绝带有 reasoning_content 的请求
+		message.ReasoningContent = ""
+		req.Messages = append(req.Messages, message)
+		for _, call := range message.ToolCalls {
+			record := executeTool(ctx, tools, call, round, options.Trace)
+			if options.OnToolCall != nil {
+				options.OnToolCall(record)
+			}
+
+			content := record.Result
+			if record.Error != "" {
+				content = fmt.Sprintf("工具执行失败: %s", record.Error)
+			}
+			req.Messages = append(req.Messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    content,
+				Name:       call.Function.Name,
+				ToolCallID: call.ID,
+			})
+		}
+	}
+}
+
+// executeTool 执行单个工具调用
+func executeTool(ctx context.Context, tools map[string]Tool, call openai.ToolCall, round int, trace bool) ToolCallRecord {
+	startTime := time.Now()
+	record := ToolCallRecord{
+		Round:     round,
+		CallID:    call.ID,
+		Name:      call.Function.Name,
+		Arguments: call.Function.Arguments,
+	}
+
+	tool, ok := tools[call.Function.Name]
+	switch {
+	case !ok:
+		record.Error = fmt.Sprintf("未知的工具: %s", call.Function.Name)
+	case call.Function.Arguments != "" && !json.Valid([]byte(call.Function.Arguments)):
+		record.Error = "工具参数不是有效的JSON"
+	default:
+		result, err := tool.Handler(ctx, call.Function.Arguments)
+		record.Result = result
+		if err != nil {
+			record.Error = err.Error()
+		}
+	}
+	record.Duration = time.Since(startTime)
+
+	if record.Error != "" {
+		logger.Warnf("🛠️ 工具调用失败 [%d] %s(%s): %s", round, record.Name, record.Arguments, record.Error)
+	} else if trace {
+		logger.Infof("🛠️ 工具调用 [%d] %s(%s) → %s", round, record.Name, record.Arguments, record.Result)
+	}
+	return record
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/pkg/logger"
+)
+
+// Manager 插件管理器
+type Manager struct {
+	processes []*Process
+}
+
+// NewManager 根据配置创建插件管理器，onChange 在任一插件（重新）就绪后调用
+func NewManager(configs []config.PluginConfig, onChange func()) *Manager {
+	manager := &Manager{}
+	for _, cfg := range configs {
+		if cfg.Command == "" {
+			logger.Warnf("插件 %s 未配置可执行文件，已跳过", cfg.Name)
+			continue
+		}
+		manager.processes = append(manager.processes, NewProcess(cfg, func(*Process) {
+			if onChange != nil {
+				onChange()
+			}
+		}))
+	}
+	return manager
+}
+
+// Start 启动所有已启用的插件
+func (m *Manager) Start() {
+	for _, process := range m.processes {
+		process.Start()
+	}
+}
+
+// Stop 停止所有插件
+func (m *Manager) Stop() {
+	for _, process := range m.processes {
+		process.Stop()
+	}
+}
+
+// GetProcesses 获取已就绪的插件
+func (m *Manager) GetProcesses() []*Process {
+	var ready []*Process
+	for _, process := range m.processes {
+		if process.Description() != nil {
+			ready = append(ready, process)
+		}
+	}
+	return ready
+}
+
+// GetStatus 获取所有插件的状态
+func (m *Manager) GetStatus() []Status {
+	statuses := make([]Status, 0, len(m.processes))
+	for _, process := range m.processes {
+		statuses = append(statuses, process.GetStatus())
+	}
+	return statuses
+}
@@ -0,0 +1,429 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/pkg/logger"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 插件状态
+const (
+	StateDisabled = "disabled" // 未启用
+	StateStarting = "starting" // 启动中
+	StateRunning  = "running"  // 运行中
+	StateCrashed  = "crashed"  // 已崩溃，等待重启
+	StateStopped  = "stopped"  // 已停止
+)
+
+const (
+	defaultTimeout    = 5 * time.Second  // 默认请求超时
+	minRestartBackoff = 1 * time.Second  // 最小重启间隔
+	maxRestartBackoff = 30 * time.Second // 最大重启间隔
+	stableRunDuration = time.Minute      // 运行超过该时长视为稳定，重置重启间隔
+	maxLineSize       = 1024 * 1024      // 单行回复最大长度
+)
+
+// Status 插件状态
+type Status struct {
+	Name        string       `json:"name"`
+	Command     string       `json:"command"`
+	State       string       `json:"state"`
+	PID         int          `json:"pid,omitempty"`
+	Restarts    int          `json:"restarts"`
+	LastError   string       `json:"lastError,omitempty"`
+	StartedAt   *time.Time   `json:"startedAt,omitempty"`
+	Description *Description `json:"description,omitempty"`
+}
+
+// Process 插件进程，负责启动、通信和崩溃重启
+type Process struct {
+	config     config.PluginConfig
+	onDescribe func(*Process)
+
+	mutex       sync.RWMutex
+	writeMutex  sync.Mutex
+	state       string
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	pending     map[int64]chan Response
+	nextID      int64
+	description *Description
+	restarts    int
+	lastError   string
+	startedAt   time.Time
+	stopChannel chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewProcess 创建插件进程，onDescribe 在插件每次（重新）启动并完成描述后调用
+func NewProcess(cfg config.PluginConfig, onDescribe func(*Process)) *Process {
+	state := StateStopped
+	if !cfg.Enabled {
+		state = StateDisabled
+	}
+	return &Process{
+		config:      cfg,
+		onDescribe:  onDescribe,
+		state:       state,
+		pending:     make(map[int64]chan Response),
+		stopChannel: make(chan struct{}),
+	}
+}
+
+// Name 插件名称（优先使用配置中的名称）
+func (p *Process) Name() string {
+	if p.config.Name != "" {
+		return p.config.Name
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.description != nil && p.description.Name != "" {
+		return p.description.Name
+	}
+	return p.config.Command
+}
+
+// Description 获取插件描述，插件未就绪时返回nil
+func (p *Process) Description() *Description {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.description
+}
+
+// Start 启动插件，崩溃后自动重启直到 Stop 被调用
+func (p *Process) Start() {
+	if !p.config.Enabled {
+		return
+	}
+	go p.run()
+}
+
+// Stop 停止插件
+func (p *Process) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopChannel)
+
+		p.mutex.Lock()
+		cmd := p.cmd
+		stdin := p.stdin
+		if p.state != StateDisabled {
+			p.state = StateStopped
+		}
+		p.mutex.Unlock()
+
+		if stdin != nil {
+			stdin.Close()
+		}
+		if cmd != nil && cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+	})
+}
+
+// GetStatus 获取插件状态
+func (p *Process) GetStatus() Status {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	status := Status{
+		Name:        p.config.Name,
+		Command:     p.config.Command,
+		State:       p.state,
+		Restarts:    p.restarts,
+		LastError:   p.lastError,
+		Description: p.description,
+	}
+	if status.Name == "" && p.description != nil {
+		status.Name = p.description.Name
+	}
+	if p.state == StateRunning || p.state == StateStarting {
+		startedAt := p.startedAt
+		status.StartedAt = &startedAt
+		if p.cmd != nil && p.cmd.Process != nil {
+			status.PID = p.cmd.Process.Pid
+		}
+	}
+	return status
+}
+
+// Query 发送查询并等待回复
+func (p *Process) Query(ctx context.Context, query Query) (Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	resp, err := p.call(ctx, Request{Type: RequestQuery, Query: &query})
+	if err != nil {
+		return Response{}, err
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("插件返回错误: %s", resp.Error)
+	}
+	return resp, nil
+}
+
+// run 插件运行循环
+func (p *Process) run() {
+	backoff := minRestartBackoff
+
+	for {
+		startTime := time.Now()
+		err := p.runOnce()
+
+		select {
+		case <-p.stopChannel:
+			logger.Infof("🔌 插件 %s 已停止", p.Name())
+			return
+		default:
+		}
+
+		p.mutex.Lock()
+		p.state = StateCrashed
+		if err != nil {
+			p.lastError = err.Error()
+		}
+		p.restarts++
+		p.mutex.Unlock()
+
+		if time.Since(startTime) > stableRunDuration {
+			backoff = minRestartBackoff
+		}
+		logger.Warnf("🔌 插件 %s 已退出: %v，%s 后重启", p.Name(), err, backoff)
+
+		select {
+		case <-p.stopChannel:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
+
+// runOnce 启动一次插件进程并等待其退出
+func (p *Process) runOnce() error {
+	cmd := exec.Command(p.config.Command, p.config.Args...)
+	cmd.Dir = p.config.Dir
+	cmd.Env = append(os.Environ(), p.config.Env...)
+	cmd.Stderr = &stderrLogger{name: p.Name()}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("创建stdin管道失败: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("创建stdout管道失败: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动插件失败: %v", err)
+	}
+
+	p.mutex.Lock()
+	p.cmd = cmd
+	p.stdin = stdin
+	p.state = StateStarting
+	p.startedAt = time.Now()
+	p.mutex.Unlock()
+
+	logger.Infof("🔌 插件 %s 已启动 (PID %d)", p.Name(), cmd.Process.Pid)
+
+	exited := make(chan error, 1)
+	go func() {
+		p.readResponses(stdout)
+		exited <- cmd.Wait()
+	}()
+
+	// 插件可能在 Stop 之前刚好启动，此时直接结束
+	select {
+	case <-p.stopChannel:
+		stdin.Close()
+		cmd.Process.Kill()
+	default:
+		go p.describe(cmd)
+	}
+
+	err = <-exited
+
+	p.mutex.Lock()
+	p.cmd = nil
+	p.stdin = nil
+	p.description = nil
+	p.mutex.Unlock()
+	p.failPending()
+
+	if err == nil {
+		err = fmt.Errorf("插件进程意外退出")
+	}
+	return err
+}
+
+// describe 获取插件描述，失败时结束进程以触发重启
+func (p *Process) describe(cmd *exec.Cmd) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout())
+	defer cancel()
+
+	resp, err := p.call(ctx, Request{Type: RequestDescribe})
+	if err == nil && resp.Error != "" {
+		err = fmt.Errorf("%s", resp.Error)
+	}
+	if err == nil && len(resp.Patterns) == 0 {
+		err = fmt.Errorf("插件未声明匹配规则")
+	}
+	if err != nil {
+		logger.Errorf("🔌 获取插件 %s 描述失败: %v", p.Name(), err)
+		p.mutex.Lock()
+		p.lastError = fmt.Sprintf("获取插件描述失败: %v", err)
+		p.mutex.Unlock()
+		cmd.Process.Kill()
+		return
+	}
+
+	description := &Description{
+		Name:        resp.Name,
+		Description: resp.Description,
+		Patterns:    resp.Patterns,
+		Priority:    resp.Priority,
+		Consuming:   true,
+	}
+	if description.Name == "" {
+		description.Name = p.Name()
+	}
+	if resp.Consuming != nil {
+		description.Consuming = *resp.Consuming
+	}
+
+	p.mutex.Lock()
+	p.description = description
+	p.state = StateRunning
+	p.lastError = ""
+	p.mutex.Unlock()
+
+	logger.Infof("🔌 插件 %s 就绪: %d 条匹配规则", p.Name(), len(description.Patterns))
+	if p.onDescribe != nil {
+		p.onDescribe(p)
+	}
+}
+
+// call 发送请求并等待对应ID的回复
+func (p *Process) call(ctx context.Context, req Request) (Response, error) {
+	p.mutex.Lock()
+	stdin := p.stdin
+	if stdin == nil {
+		p.mutex.Unlock()
+		return Response{}, fmt.Errorf("插件 %s 未运行", p.Name())
+	}
+	req.ID = atomic.AddInt64(&p.nextID, 1)
+	ch := make(chan Response, 1)
+	p.pending[req.ID] = ch
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		delete(p.pending, req.ID)
+		p.mutex.Unlock()
+	}()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("序列化插件请求失败: %v", err)
+	}
+
+	p.writeMutex.Lock()
+	_, err = stdin.Write(append(data, '\n'))
+	p.writeMutex.Unlock()
+	if err != nil {
+		return Response{}, fmt.Errorf("写入插件请求失败: %v", err)
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return Response{}, fmt.Errorf("插件 %s 已退出", p.Name())
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return Response{}, fmt.Errorf("插件 %s 响应超时", p.Name())
+	}
+}
+
+// readResponses 读取插件回复并分发给等待中的请求
+func (p *Process) readResponses(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var resp Response
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			logger.Warnf("🔌 插件 %s 输出无效JSON: %s", p.Name(), line)
+			continue
+		}
+
+		p.mutex.Lock()
+		ch, ok := p.pending[resp.ID]
+		if ok {
+			delete(p.pending, resp.ID)
+		}
+		p.mutex.Unlock()
+
+		if !ok {
+			logger.Debugf("🔌 插件 %s 回复了未知或已超时的请求: %d", p.Name(), resp.ID)
+			continue
+		}
+		ch <- resp
+	}
+
+	if err := scanner.Err(); err != nil {
+		logger.Warnf("🔌 读取插件 %s 输出失败: %v", p.Name(), err)
+	}
+}
+
+// failPending 插件退出后结束所有等待中的请求
+func (p *Process) failPending() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for id, ch := range p.pending {
+		close(ch)
+		delete(p.pending, id)
+	}
+}
+
+// timeout 请求超时时间
+func (p *Process) timeout() time.Duration {
+	if p.config.Timeout > 0 {
+		return time.Duration(p.config.Timeout) * time.Millisecond
+	}
+	return defaultTimeout
+}
+
+// stderrLogger 将插件的stderr输出转发到日志
+type stderrLogger struct {
+	name string
+}
+
+func (w *stderrLogger) Write(data []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			logger.Infof("🔌 [%s] %s", w.name, line)
+		}
+	}
+	return len(data), nil
+}
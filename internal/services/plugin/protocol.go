@@ -0,0 +1,77 @@
+package plugin
+
+// 插件协议：宿主通过 stdin 向插件发送一行一个JSON请求，插件通过 stdout 一行一个JSON回复。
+// 插件的 stderr 输出会被转发到宿主日志。
+//
+// 启动后宿主首先发送 describe 请求：
+//   -> {"id":1,"type":"describe"}
+//   <- {"id":1,"name":"weather","description":"天气查询","patterns":["天气"],"priority":50,"consuming":true}
+//
+// 命中插件的匹配规则后发送 query 请求：
+//   -> {"id":2,"type":"query","query":{"text":"明天天气怎么样","timestamp":1700000000000}}
+//   <- {"id":2,"answer":{"text":"明天晴"}}
+//   <- {"id":2,"actions":[{"type":"set_volume","volume":30},{"type":"speak","text":"好的"}]}
+//
+// 回复中携带 error 字段表示处理失败，宿主会将查询转交AI处理。
+
+// 请求类型
+const (
+	RequestDescribe = "describe" // 获取插件描述
+	RequestQuery    = "query"    // 处理查询
+)
+
+// Request 宿主发往插件的请求
+type Request struct {
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+	Query *Query `json:"query,omitempty"`
+}
+
+// Query 查询消息
+type Query struct {
+	Text      string `json:"text"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Response 插件回复
+type Response struct {
+	ID int64 `json:"id"`
+
+	// describe 回复
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Patterns    []string `json:"patterns,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+	Consuming   *bool    `json:"consuming,omitempty"`
+
+	// query 回复
+	Answer  *Answer  `json:"answer,omitempty"`
+	Actions []Action `json:"actions,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Answer 插件直接给出的回复
+type Answer struct {
+	Text      string `json:"text,omitempty"`
+	KeepAlive bool   `json:"keepAlive,omitempty"`
+}
+
+// Action 插件要求宿主执行的动作，类型与自定义命令动作一致
+type Action struct {
+	Type   string `json:"type"`
+	Text   string `json:"text,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Volume int    `json:"volume,omitempty"`
+	Method string `json:"method,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Description 插件描述
+type Description struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Patterns    []string `json:"patterns"`
+	Priority    int      `json:"priority"`
+	Consuming   bool     `json:"consuming"`
+}
@@ -13,7 +13,7 @@ import (
 // MessagePoller 消息拉取器
 type MessagePoller struct {
 	miService      miservice.MiServiceInterface
-	speakerService *speaker.SpeakerService
+	speakerService *speaker.EnhancedAISpeaker
 	interval       time.Duration
 	lastTimestamp  int64
 	running        bool
@@ -23,7 +23,7 @@ type MessagePoller struct {
 }
 
 // NewMessagePoller 创建消息拉取器
-func NewMessagePoller(miSvc miservice.MiServiceInterface, speakerSvc *speaker.SpeakerService, interval time.Duration) *MessagePoller {
+func NewMessagePoller(miSvc miservice.MiServiceInterface, speakerSvc *speaker.EnhancedAISpeaker, interval time.Duration) *MessagePoller {
 	return &MessagePoller{
 		miService:      miSvc,
 		speakerService: speakerSvc,
@@ -183,24 +183,16 @@ func (mp *MessagePoller) processMessage(message interface{}) error {
 
 	logger.Debugf("处理消息: %s", queryMsg.Text)
 
-	// 通过音箱服务处理消息
-	response, err := mp.speakerService.ProcessMessage(queryMsg.Text)
+	// 通过音箱服务处理消息，回复由音箱服务负责播报
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := mp.speakerService.ExecuteCommand(ctx, queryMsg.Text)
 	if err != nil {
 		return fmt.Errorf("音箱服务处理消息失败: %v", err)
 	}
 
-	// 如果有响应内容，播放TTS
-	if response != "" {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		
-		if err := mp.miService.SafePlayTTS(ctx, response); err != nil {
-			return fmt.Errorf("播放TTS失败: %v", err)
-		}
-
-		logger.Debugf("已播放响应: %s", response)
-	}
-
+	logger.Debugf("消息处理完成 [%s]: %s", result.Route, result.Answer)
 	return nil
 }
 
@@ -0,0 +1,235 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/services/openai"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 未配置时的默认值
+const (
+	defaultMaxResults = 5
+	requestTimeout    = 10 * time.Second
+)
+
+// Searcher 按配置请求搜索接口，实现 openai.Searcher
+// 搜索服务、地址和触发规则每次读取 cfg，修改后立即生效
+type Searcher struct {
+	cfg        *config.Config
+	httpClient *http.Client
+}
+
+// NewSearcher 创建联网搜索服务
+func NewSearcher(cfg *config.Config) *Searcher {
+	return &Searcher{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// NeedSearch 问题是否命中需要先搜索再回答的规则
+func (s *Searcher) NeedSearch(query string) bool {
+	return s.cfg.OpenAI.SearchTriggered(query)
+}
+
+// Search 使用配置的搜索服务搜索，返回最多 SearchMaxResults 条有标题或摘要的结果
+func (s *Searcher) Search(ctx context.Context, query string) ([]openai.SearchResult, error) {
+	cfg := s.cfg.OpenAI
+	maxResults := cfg.SearchMaxResults
+	if maxResults <= 0 {
+		maxResults = defaultMaxResults
+	}
+
+	var results []openai.SearchResult
+	var err error
+	switch cfg.SearchProvider {
+	case config.SearchProviderSearxng:
+		results, err = s.searxng(ctx, cfg, query)
+	case config.SearchProviderBing:
+		results, err = s.bing(ctx, cfg, query, maxResults)
+	case config.SearchProviderCustom:
+		results, err = s.custom(ctx, cfg, query)
+	default:
+		return nil, fmt.Errorf("不支持的搜索服务: %s", cfg.SearchProvider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cleaned := make([]openai.SearchResult, 0, maxResults)
+	for _, result := range results {
+		result.Title = strings.TrimSpace(result.Title)
+		result.Snippet = strings.TrimSpace(result.Snippet)
+		if result.Title == "" && result.Snippet == "" {
+			continue
+		}
+		cleaned = append(cleaned, result)
+		if len(cleaned) == maxResults {
+			break
+		}
+	}
+	return cleaned, nil
+}
+
+// searxng 请求 SearxNG 的 /search 接口
+func (s *Searcher) searxng(ctx context.Context, cfg config.OpenAIConfig, query string) ([]openai.SearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "json")
+	endpoint := strings.TrimSuffix(cfg.SearchBaseURL, "/") + "/search?" + params.Encode()
+
+	var response struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := s.getJSON(ctx, endpoint, nil, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]openai.SearchResult, 0, len(response.Results))
+	for _, item := range response.Results {
+		results = append(results, openai.SearchResult{Title: item.Title, URL: item.URL, Snippet: item.Content})
+	}
+	return results, nil
+}
+
+// bing 请求 Bing Web Search API
+func (s *Searcher) bing(ctx context.Context, cfg config.OpenAIConfig, query string, count int) ([]openai.SearchResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("mkt", "zh-CN")
+	params.Set("count", strconv.Itoa(count))
+	endpoint := getDefault(cfg.SearchBaseURL, config.DefaultBingSearchURL) + "?" + params.Encode()
+
+	var response struct {
+		WebPages struct {
+			Value []struct {
+				Name    string `json:"name"`
+				URL     string `json:"url"`
+				Snippet string `json:"snippet"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	headers := map[string]string{"Ocp-Apim-Subscription-Key": cfg.SearchAPIKey}
+	if err := s.getJSON(ctx, endpoint, headers, &response); err != nil {
+		return nil, err
+	}
+
+	results := make([]openai.SearchResult, 0, len(response.WebPages.Value))
+	for _, item := range response.WebPages.Value {
+		results = append(results, openai.SearchResult{Title: item.Name, URL: item.URL, Snippet: item.Snippet})
+	}
+	return results, nil
+}
+
+// custom 按地址模板请求通用搜索接口，按 SearchTemplate 读取结果
+// 配置了密钥时同时以 Bearer 方式放在请求头中
+func (s *Searcher) custom(ctx context.Context, cfg config.OpenAIConfig, query string) ([]openai.SearchResult, error) {
+	endpoint := strings.NewReplacer(
+		config.SearchQueryPlaceholder, url.QueryEscape(query),
+		config.SearchAPIKeyPlaceholder, url.QueryEscape(cfg.SearchAPIKey),
+	).Replace(cfg.SearchBaseURL)
+	var headers map[string]string
+	if cfg.SearchAPIKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + cfg.SearchAPIKey}
+	}
+
+	var response interface{}
+	if err := s.getJSON(ctx, endpoint, headers, &response); err != nil {
+		return nil, err
+	}
+
+	template := cfg.SearchTemplate
+	items, ok := lookup(response, template.Results).([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("搜索结果中没有找到列表: %s", getDefault(template.Results, "响应本身"))
+	}
+	results := make([]openai.SearchResult, 0, len(items))
+	for _, item := range items {
+		results = append(results, openai.SearchResult{
+			Title:   lookupString(item, getDefault(template.Title, "title")),
+			URL:     lookupString(item, getDefault(template.URL, "url")),
+			Snippet: lookupString(item, getDefault(template.Snippet, "snippet")),
+		})
+	}
+	return results, nil
+}
+
+// getJSON 发送GET请求并解析JSON响应
+func (s *Searcher) getJSON(ctx context.Context, endpoint string, headers map[string]string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("创建搜索请求失败: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("搜索请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("搜索接口返回错误, status code: %d, message: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("解析搜索结果失败: %v", err)
+	}
+	return nil
+}
+
+// lookup 按点分隔的路径读取JSON中的值，路径为空时返回 value 本身，数字表示列表下标
+func lookup(value interface{}, path string) interface{} {
+	if path == "" {
+		return value
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[key]
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil
+			}
+			value = current[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// lookupString 按路径读取字符串，数字等其他类型转为文本
+func lookupString(value interface{}, path string) string {
+	switch v := lookup(value, path).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// getDefault 获取默认值
+func getDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
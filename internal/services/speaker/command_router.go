@@ -0,0 +1,136 @@
+package speaker
+
+import (
+	"context"
+	"mi-gpt-go/internal/services/miservice"
+	"mi-gpt-go/pkg/logger"
+	"sync"
+	"time"
+)
+
+// 路由目标
+const (
+	RouteTargetCommand = "command" // 由命令处理器处理
+	RouteTargetLLM     = "llm"     // 交给AI处理
+)
+
+// RouteDecision 路由决策记录
+type RouteDecision struct {
+	Query    string    `json:"query"`
+	Target   string    `json:"target"`             // command 或 llm
+	Handler  string    `json:"handler,omitempty"`  // 命中的处理器名称
+	Pattern  string    `json:"pattern,omitempty"`  // 命中的匹配规则
+	Priority int       `json:"priority,omitempty"` // 处理器优先级
+	Consumed bool      `json:"consumed"`           // 是否消费了查询
+	Answer   string    `json:"answer,omitempty"`   // 命令回复
+	Reason   string    `json:"reason"`             // 决策原因
+	Error    string    `json:"error,omitempty"`
+	Duration string    `json:"duration"`
+	Time     time.Time `json:"time"`
+}
+
+// CommandRouter 命令路由器，位于查询接收和AI之间
+type CommandRouter struct {
+	registry     *CommandRegistry
+	decisions    []RouteDecision
+	maxDecisions int
+	mutex        sync.RWMutex
+}
+
+// NewCommandRouter 创建命令路由器
+func NewCommandRouter(registry *CommandRegistry) *CommandRouter {
+	return &CommandRouter{
+		registry:     registry,
+		decisions:    make([]RouteDecision, 0, 100),
+		maxDecisions: 100,
+	}
+}
+
+// Registry 获取命令注册表
+func (r *CommandRouter) Registry() *CommandRegistry {
+	return r.registry
+}
+
+// Route 路由查询，返回命令回复以及查询是否已被消费
+// 未被消费的查询应继续交给AI处理
+func (r *CommandRouter) Route(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, bool) {
+	startTime := time.Now()
+	decision := RouteDecision{
+		Query:  msg.Text,
+		Target: RouteTargetLLM,
+		Time:   startTime,
+	}
+
+	handler, pattern := r.registry.match(msg.Text)
+	if handler == nil {
+		decision.Reason = "未匹配任何命令"
+		r.record(decision, startTime)
+		return SpeakerAnswer{}, false
+	}
+
+	decision.Handler = handler.GetName()
+	decision.Pattern = pattern
+	decision.Priority = handler.GetPriority()
+
+	answer, err := handler.Handle(ctx, msg, speaker)
+	switch {
+	case err != nil:
+		decision.Reason = "命令执行失败，转交AI处理"
+		decision.Error = err.Error()
+	case !handler.IsConsuming():
+		decision.Target = RouteTargetCommand
+		decision.Answer = answer.Text
+		decision.Reason = "命令不消费查询，继续交给AI处理"
+	default:
+		decision.Target = RouteTargetCommand
+		decision.Consumed = true
+		decision.Answer = answer.Text
+		decision.Reason = "命令已处理"
+		if answer.Text == "" {
+			decision.Reason = "命令已处理（无回复）"
+		}
+	}
+
+	r.record(decision, startTime)
+	if err != nil {
+		return SpeakerAnswer{}, false
+	}
+	return answer, decision.Consumed
+}
+
+// record 记录路由决策
+func (r *CommandRouter) record(decision RouteDecision, startTime time.Time) {
+	decision.Duration = time.Since(startTime).String()
+
+	if decision.Error != "" {
+		logger.Warnf("🧭 路由决策: %q → %s [%s] %s: %s",
+			decision.Query, decision.Target, decision.Handler, decision.Reason, decision.Error)
+	} else {
+		logger.Infof("🧭 路由决策: %q → %s [%s] %s",
+			decision.Query, decision.Target, decision.Handler, decision.Reason)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.decisions = append(r.decisions, decision)
+	if len(r.decisions) > r.maxDecisions {
+		r.decisions = r.decisions[1:]
+	}
+}
+
+// GetRecentDecisions 获取最近的路由决策（最新的在前）
+func (r *CommandRouter) GetRecentDecisions(limit int) []RouteDecision {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if limit <= 0 || limit > len(r.decisions) {
+		limit = len(r.decisions)
+	}
+
+	result := make([]RouteDecision, 0, limit)
+	for i := len(r.decisions) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, r.decisions[i])
+	}
+	return result
+}
@@ -7,8 +7,10 @@ import (
 	"mi-gpt-go/internal/services/miservice"
 	"mi-gpt-go/pkg/logger"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,72 +19,32 @@ type CommandHandler interface {
 	GetName() string
 	GetDescription() string
 	GetPatterns() []string
-	Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error)
+	GetPriority() int  // 优先级，数值越大越先匹配
+	IsConsuming() bool // 是否消费查询（消费后不再交给AI）
+	Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error)
 }
 
-// VolumeCommand 音量控制命令
-type VolumeCommand struct{}
-
-func (v *VolumeCommand) GetName() string        { return "音量控制" }
-func (v *VolumeCommand) GetDescription() string { return "调节音箱音量" }
-func (v *VolumeCommand) GetPatterns() []string {
-	return []string{
-		`音量调到(\d+)`,
-		`音量设为(\d+)`,
-		`声音调到(\d+)`,
-		`调大声音`,
-		`调小声音`,
-		`音量大一点`,
-		`音量小一点`,
-		`静音`,
-		`取消静音`,
-	}
+// CommandMatcher 可选接口：匹配规则命中后由处理器再次确认是否处理
+// 用于正则难以表达的排除条件（例如疑问句），返回 false 时继续匹配其他命令
+type CommandMatcher interface {
+	Accept(text string) bool
 }
 
-func (v *VolumeCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
-	text := msg.Text
-	
-	// 设置具体音量
-	if matched, _ := regexp.MatchString(`音量调到(\d+)|音量设为(\d+)|声音调到(\d+)`, text); matched {
-		re := regexp.MustCompile(`(\d+)`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 1 {
-			volume, err := strconv.Atoi(matches[1])
-			if err != nil || volume < 0 || volume > 100 {
-				return SpeakerAnswer{Text: "音量数值无效，请输入0到100之间的数字"}, nil
-			}
-			return SpeakerAnswer{Text: fmt.Sprintf("好的，已将音量调至%d", volume)}, nil
-		}
-	}
-	
-	// 调大音量
-	if matched, _ := regexp.MatchString(`调大声音|音量大一点`, text); matched {
-		return SpeakerAnswer{Text: "好的，已调大音量"}, nil
-	}
-	
-	// 调小音量
-	if matched, _ := regexp.MatchString(`调小声音|音量小一点`, text); matched {
-		return SpeakerAnswer{Text: "好的，已调小音量"}, nil
-	}
-	
-	// 静音
-	if matched, _ := regexp.MatchString(`静音`, text); matched {
-		return SpeakerAnswer{Text: "已静音"}, nil
-	}
-	
-	// 取消静音
-	if matched, _ := regexp.MatchString(`取消静音`, text); matched {
-		return SpeakerAnswer{Text: "已取消静音"}, nil
-	}
-	
-	return SpeakerAnswer{}, nil
-}
+// 命令优先级常量
+const (
+	CommandPriorityHighest = 100 // 系统级命令（如音量、静音）
+	CommandPriorityHigh    = 80  // 精确匹配的功能命令
+	CommandPriorityNormal  = 50  // 普通功能命令
+	CommandPriorityLow     = 30  // 宽泛匹配的命令
+)
 
 // TimeCommand 时间查询命令
 type TimeCommand struct{}
 
 func (t *TimeCommand) GetName() string        { return "时间查询" }
 func (t *TimeCommand) GetDescription() string { return "查询当前时间和日期" }
+func (t *TimeCommand) GetPriority() int       { return CommandPriorityHigh }
+func (t *TimeCommand) IsConsuming() bool      { return true }
 func (t *TimeCommand) GetPatterns() []string {
 	return []string{
 		`现在几点了`,
@@ -95,7 +57,7 @@ func (t *TimeCommand) GetPatterns() []string {
 	}
 }
 
-func (t *TimeCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
+func (t *TimeCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
 	now := time.Now()
 	text := msg.Text
 	
@@ -129,280 +91,54 @@ func (t *TimeCommand) Handle(ctx context.Context, msg miservice.QueryMessage, sp
 	return SpeakerAnswer{}, nil
 }
 
-// WeatherCommand 天气查询命令
-type WeatherCommand struct{}
-
-func (w *WeatherCommand) GetName() string        { return "天气查询" }
-func (w *WeatherCommand) GetDescription() string { return "查询天气信息" }
-func (w *WeatherCommand) GetPatterns() []string {
-	return []string{
-		`今天天气怎么样`,
-		`天气如何`,
-		`今天的天气`,
-		`明天天气`,
-		`后天天气`,
-		`(.+)的天气`,
-	}
-}
-
-func (w *WeatherCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
-	// 这里应该调用实际的天气API，现在返回模拟数据
-	responses := []string{
-		"今天天气晴朗，温度25度，适合出行",
-		"今天多云，温度22度，建议带件外套",
-		"今天有小雨，温度18度，记得带伞",
-		"今天阴天，温度20度，空气湿润",
-	}
-	
-	response := responses[rand.Intn(len(responses))]
-	return SpeakerAnswer{Text: response}, nil
-}
-
-// MusicCommand 音乐控制命令
-type MusicCommand struct{}
-
-func (m *MusicCommand) GetName() string        { return "音乐控制" }
-func (m *MusicCommand) GetDescription() string { return "控制音乐播放" }
-func (m *MusicCommand) GetPatterns() []string {
-	return []string{
-		`播放音乐`,
-		`放首歌`,
-		`来首歌`,
-		`播放(.+)`,
-		`我想听(.+)`,
-		`暂停`,
-		`停止播放`,
-		`继续播放`,
-		`下一首`,
-		`上一首`,
-		`切歌`,
-	}
-}
-
-func (m *MusicCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
-	text := msg.Text
-	
-	if matched, _ := regexp.MatchString(`播放音乐|放首歌|来首歌`, text); matched {
-		return SpeakerAnswer{Text: "好的，为您播放音乐"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`播放(.+)|我想听(.+)`, text); matched {
-		re := regexp.MustCompile(`播放(.+)|我想听(.+)`)
-		matches := re.FindStringSubmatch(text)
-		var song string
-		if len(matches) > 1 && matches[1] != "" {
-			song = matches[1]
-		} else if len(matches) > 2 && matches[2] != "" {
-			song = matches[2]
-		}
-		if song != "" {
-			return SpeakerAnswer{Text: fmt.Sprintf("好的，为您播放%s", song)}, nil
-		}
-	}
-	
-	if matched, _ := regexp.MatchString(`暂停|停止播放`, text); matched {
-		return SpeakerAnswer{Text: "已暂停播放"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`继续播放`, text); matched {
-		return SpeakerAnswer{Text: "继续播放"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`下一首|切歌`, text); matched {
-		return SpeakerAnswer{Text: "好的，下一首"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`上一首`, text); matched {
-		return SpeakerAnswer{Text: "好的，上一首"}, nil
-	}
-	
-	return SpeakerAnswer{}, nil
-}
-
-// DeviceCommand 设备控制命令
-type DeviceCommand struct{}
+// calculatorPattern 两个整数的四则运算，数字前后不能紧挨日期或时间的分隔符，避免"2024-10-18"被当作减法
+var calculatorPattern = regexp.MustCompile(`(?:^|[^\d.:/-])(\d+)\s*(\+|加|-|减|\*|×|乘以|乘|/|÷|除以|除)\s*(\d+)(?:$|[^\d.:/-])`)
 
-func (d *DeviceCommand) GetName() string        { return "设备控制" }
-func (d *DeviceCommand) GetDescription() string { return "控制智能设备" }
-func (d *DeviceCommand) GetPatterns() []string {
-	return []string{
-		`打开(.+)`,
-		`关闭(.+)`,
-		`开灯`,
-		`关灯`,
-		`调亮一点`,
-		`调暗一点`,
-		`空调温度调到(\d+)度`,
-		`打开空调`,
-		`关闭空调`,
-		`设备状态`,
-		`连接的设备`,
-	}
-}
-
-func (d *DeviceCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
-	text := msg.Text
-	
-	if matched, _ := regexp.MatchString(`打开(.+)|关闭(.+)`, text); matched {
-		re := regexp.MustCompile(`(打开|关闭)(.+)`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 2 {
-			action := matches[1]
-			device := strings.TrimSpace(matches[2])
-			return SpeakerAnswer{Text: fmt.Sprintf("好的，已%s%s", action, device)}, nil
-		}
-	}
-	
-	if matched, _ := regexp.MatchString(`开灯`, text); matched {
-		return SpeakerAnswer{Text: "好的，已打开灯光"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`关灯`, text); matched {
-		return SpeakerAnswer{Text: "好的，已关闭灯光"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`调亮一点`, text); matched {
-		return SpeakerAnswer{Text: "好的，已调亮灯光"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`调暗一点`, text); matched {
-		return SpeakerAnswer{Text: "好的，已调暗灯光"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`空调温度调到(\d+)度`, text); matched {
-		re := regexp.MustCompile(`(\d+)`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 1 {
-			temp := matches[1]
-			return SpeakerAnswer{Text: fmt.Sprintf("好的，已将空调温度调至%s度", temp)}, nil
-		}
-	}
-	
-	if matched, _ := regexp.MatchString(`设备状态|连接的设备`, text); matched {
-		return SpeakerAnswer{Text: "当前连接的设备有：小爱音箱、智能灯泡、空调"}, nil
-	}
-	
-	return SpeakerAnswer{}, nil
-}
+// calculatorContextPattern 明确要求计算的说法
+var calculatorContextPattern = regexp.MustCompile(`等于|是多少|得多少|是几|得几|计算|算一下|算算|[=＝]`)
 
 // CalculatorCommand 计算器命令
 type CalculatorCommand struct{}
 
 func (c *CalculatorCommand) GetName() string        { return "计算器" }
 func (c *CalculatorCommand) GetDescription() string { return "执行简单的数学计算" }
+func (c *CalculatorCommand) GetPriority() int       { return CommandPriorityHigh }
+func (c *CalculatorCommand) IsConsuming() bool      { return true }
 func (c *CalculatorCommand) GetPatterns() []string {
-	return []string{
-		`(\d+)\s*[\+加]\s*(\d+)`,
-		`(\d+)\s*[\-减]\s*(\d+)`,
-		`(\d+)\s*[\*×乘]\s*(\d+)`,
-		`(\d+)\s*[\/÷除]\s*(\d+)`,
-		`计算(.+)`,
-	}
+	return []string{calculatorPattern.String()}
 }
 
-func (c *CalculatorCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
-	text := msg.Text
-	
-	// 加法
-	if matched, _ := regexp.MatchString(`(\d+)\s*[\+加]\s*(\d+)`, text); matched {
-		re := regexp.MustCompile(`(\d+)\s*[\+加]\s*(\d+)`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 2 {
-			a, _ := strconv.Atoi(matches[1])
-			b, _ := strconv.Atoi(matches[2])
-			result := a + b
-			return SpeakerAnswer{Text: fmt.Sprintf("%d加%d等于%d", a, b, result)}, nil
-		}
+// Accept 只处理单独的算式或带有"等于""是多少"等说法的计算，例如"2024-10-18是星期几"交给AI
+func (c *CalculatorCommand) Accept(text string) bool {
+	text = strings.TrimRight(strings.TrimSpace(text), "=＝?？")
+	matches := calculatorPattern.FindStringSubmatch(text)
+	if matches == nil {
+		return false
 	}
-	
-	// 减法
-	if matched, _ := regexp.MatchString(`(\d+)\s*[\-减]\s*(\d+)`, text); matched {
-		re := regexp.MustCompile(`(\d+)\s*[\-减]\s*(\d+)`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 2 {
-			a, _ := strconv.Atoi(matches[1])
-			b, _ := strconv.Atoi(matches[2])
-			result := a - b
-			return SpeakerAnswer{Text: fmt.Sprintf("%d减%d等于%d", a, b, result)}, nil
-		}
-	}
-	
-	// 乘法
-	if matched, _ := regexp.MatchString(`(\d+)\s*[\*×乘]\s*(\d+)`, text); matched {
-		re := regexp.MustCompile(`(\d+)\s*[\*×乘]\s*(\d+)`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 2 {
-			a, _ := strconv.Atoi(matches[1])
-			b, _ := strconv.Atoi(matches[2])
-			result := a * b
-			return SpeakerAnswer{Text: fmt.Sprintf("%d乘以%d等于%d", a, b, result)}, nil
-		}
-	}
-	
-	// 除法
-	if matched, _ := regexp.MatchString(`(\d+)\s*[\/÷除]\s*(\d+)`, text); matched {
-		re := regexp.MustCompile(`(\d+)\s*[\/÷除]\s*(\d+)`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 2 {
-			a, _ := strconv.Atoi(matches[1])
-			b, _ := strconv.Atoi(matches[2])
-			if b == 0 {
-				return SpeakerAnswer{Text: "除数不能为零"}, nil
-			}
-			result := float64(a) / float64(b)
-			return SpeakerAnswer{Text: fmt.Sprintf("%d除以%d等于%.2f", a, b, result)}, nil
-		}
-	}
-	
-	return SpeakerAnswer{}, nil
+	return strings.TrimSpace(matches[0]) == text || calculatorContextPattern.MatchString(text)
 }
 
-// TimerCommand 定时器命令
-type TimerCommand struct{}
-
-func (t *TimerCommand) GetName() string        { return "定时器" }
-func (t *TimerCommand) GetDescription() string { return "设置定时器和闹钟" }
-func (t *TimerCommand) GetPatterns() []string {
-	return []string{
-		`设置(\d+)分钟定时器`,
-		`(\d+)分钟后提醒我`,
-		`设置闹钟(\d+)点(\d+)分`,
-		`明天(\d+)点叫我`,
-		`取消定时器`,
-		`取消闹钟`,
-	}
-}
-
-func (t *TimerCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
-	text := msg.Text
-	
-	if matched, _ := regexp.MatchString(`设置(\d+)分钟定时器|(\d+)分钟后提醒我`, text); matched {
-		re := regexp.MustCompile(`(\d+)分钟`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 1 {
-			minutes := matches[1]
-			return SpeakerAnswer{Text: fmt.Sprintf("好的，已设置%s分钟定时器", minutes)}, nil
-		}
-	}
-	
-	if matched, _ := regexp.MatchString(`设置闹钟(\d+)点(\d+)分|明天(\d+)点叫我`, text); matched {
-		re := regexp.MustCompile(`(\d+)点`)
-		matches := re.FindStringSubmatch(text)
-		if len(matches) > 1 {
-			hour := matches[1]
-			return SpeakerAnswer{Text: fmt.Sprintf("好的，已设置明天%s点的闹钟", hour)}, nil
+func (c *CalculatorCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	matches := calculatorPattern.FindStringSubmatch(msg.Text)
+	if len(matches) < 4 {
+		return SpeakerAnswer{}, nil
+	}
+	a, _ := strconv.Atoi(matches[1])
+	b, _ := strconv.Atoi(matches[3])
+
+	switch matches[2] {
+	case "+", "加":
+		return SpeakerAnswer{Text: fmt.Sprintf("%d加%d等于%d", a, b, a+b)}, nil
+	case "-", "减":
+		return SpeakerAnswer{Text: fmt.Sprintf("%d减%d等于%d", a, b, a-b)}, nil
+	case "*", "×", "乘", "乘以":
+		return SpeakerAnswer{Text: fmt.Sprintf("%d乘以%d等于%d", a, b, a*b)}, nil
+	default:
+		if b == 0 {
+			return SpeakerAnswer{Text: "除数不能为零"}, nil
 		}
+		return SpeakerAnswer{Text: fmt.Sprintf("%d除以%d等于%.2f", a, b, float64(a)/float64(b))}, nil
 	}
-	
-	if matched, _ := regexp.MatchString(`取消定时器`, text); matched {
-		return SpeakerAnswer{Text: "好的，已取消定时器"}, nil
-	}
-	
-	if matched, _ := regexp.MatchString(`取消闹钟`, text); matched {
-		return SpeakerAnswer{Text: "好的，已取消闹钟"}, nil
-	}
-	
-	return SpeakerAnswer{}, nil
 }
 
 // FunCommand 娱乐命令
@@ -410,6 +146,8 @@ type FunCommand struct{}
 
 func (f *FunCommand) GetName() string        { return "娱乐功能" }
 func (f *FunCommand) GetDescription() string { return "提供各种娱乐功能" }
+func (f *FunCommand) GetPriority() int       { return CommandPriorityLow }
+func (f *FunCommand) IsConsuming() bool      { return true }
 func (f *FunCommand) GetPatterns() []string {
 	return []string{
 		`讲个笑话`,
@@ -424,7 +162,7 @@ func (f *FunCommand) GetPatterns() []string {
 	}
 }
 
-func (f *FunCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *AISpeaker) (SpeakerAnswer, error) {
+func (f *FunCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
 	text := msg.Text
 	
 	if matched, _ := regexp.MatchString(`讲个笑话`, text); matched {
@@ -468,56 +206,174 @@ func (f *FunCommand) Handle(ctx context.Context, msg miservice.QueryMessage, spe
 
 // CommandRegistry 命令注册表
 type CommandRegistry struct {
-	handlers map[string]CommandHandler
+	mutex    sync.RWMutex
+	handlers []*registeredHandler // 按优先级降序排列
+	nextSeq  int                  // 注册序号，优先级相同时先注册的先匹配
+}
+
+// registeredHandler 已注册的命令处理器（含预编译的匹配规则）
+type registeredHandler struct {
+	handler  CommandHandler
+	patterns []*regexp.Regexp
+	seq      int
 }
 
 // NewCommandRegistry 创建命令注册表
 func NewCommandRegistry() *CommandRegistry {
-	registry := &CommandRegistry{
-		handlers: make(map[string]CommandHandler),
-	}
+	registry := &CommandRegistry{}
 	
 	// 注册所有命令
-	registry.Register(&VolumeCommand{})
+	// 音量、音乐、定时器和设备控制由AI调用工具完成，这里只注册能在本地直接给出答案的命令
 	registry.Register(&TimeCommand{})
-	registry.Register(&WeatherCommand{})
-	registry.Register(&MusicCommand{})
-	registry.Register(&DeviceCommand{})
 	registry.Register(&CalculatorCommand{})
-	registry.Register(&TimerCommand{})
 	registry.Register(&FunCommand{})
+	registry.Register(&BotPersonaCommand{})
+	registry.Register(&MasterProfileCommand{})
+	registry.Register(&IdentifyCommand{})
+	registry.Register(&WhoAmICommand{})
+	registry.Register(&RememberFactCommand{})
+	registry.Register(&ForgetFactCommand{})
+	registry.Register(&RecallFactsCommand{})
 	
 	return registry
 }
 
-// Register 注册命令处理器
+// Register 注册命令处理器，同名处理器会被替换
 func (cr *CommandRegistry) Register(handler CommandHandler) {
-	cr.handlers[handler.GetName()] = handler
-	logger.Debugf("注册命令处理器: %s", handler.GetName())
+	patterns := compileHandlerPatterns(handler)
+
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	cr.removeLocked(handler.GetName())
+	cr.insertLocked(handler, patterns)
+	cr.sortLocked()
+
+	logger.Debugf("注册命令处理器: %s (优先级: %d)", handler.GetName(), handler.GetPriority())
 }
 
-// FindHandler 查找匹配的命令处理器
+// ReplaceWhere 原子地移除所有满足条件的处理器并注册新的处理器
+func (cr *CommandRegistry) ReplaceWhere(match func(CommandHandler) bool, handlers []CommandHandler) {
+	compiled := make([][]*regexp.Regexp, len(handlers))
+	for i, handler := range handlers {
+		compiled[i] = compileHandlerPatterns(handler)
+	}
+
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	kept := cr.handlers[:0]
+	for _, rh := range cr.handlers {
+		if !match(rh.handler) {
+			kept = append(kept, rh)
+		}
+	}
+	cr.handlers = kept
+
+	for i, handler := range handlers {
+		cr.removeLocked(handler.GetName())
+		cr.insertLocked(handler, compiled[i])
+	}
+	cr.sortLocked()
+
+	logger.Debugf("已替换命令处理器: %d 个", len(handlers))
+}
+
+// insertLocked 追加处理器（已锁定）
+func (cr *CommandRegistry) insertLocked(handler CommandHandler, patterns []*regexp.Regexp) {
+	cr.handlers = append(cr.handlers, &registeredHandler{
+		handler:  handler,
+		patterns: patterns,
+		seq:      cr.nextSeq,
+	})
+	cr.nextSeq++
+}
+
+// sortLocked 按优先级降序排列处理器（已锁定）
+func (cr *CommandRegistry) sortLocked() {
+	sort.SliceStable(cr.handlers, func(i, j int) bool {
+		a, b := cr.handlers[i], cr.handlers[j]
+		if a.handler.GetPriority() != b.handler.GetPriority() {
+			return a.handler.GetPriority() > b.handler.GetPriority()
+		}
+		return a.seq < b.seq
+	})
+}
+
+// compileHandlerPatterns 预编译处理器的匹配规则
+func compileHandlerPatterns(handler CommandHandler) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, pattern := range handler.GetPatterns() {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			logger.Warnf("命令 %s 的匹配规则无效，已忽略: %s (%v)", handler.GetName(), pattern, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns
+}
+
+// Unregister 注销命令处理器
+func (cr *CommandRegistry) Unregister(name string) bool {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+	return cr.removeLocked(name)
+}
+
+// removeLocked 移除指定名称的处理器（已锁定）
+func (cr *CommandRegistry) removeLocked(name string) bool {
+	for i, rh := range cr.handlers {
+		if rh.handler.GetName() == name {
+			cr.handlers = append(cr.handlers[:i], cr.handlers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// FindHandler 查找匹配的命令处理器（按优先级顺序）
 func (cr *CommandRegistry) FindHandler(text string) CommandHandler {
-	for _, handler := range cr.handlers {
-		for _, pattern := range handler.GetPatterns() {
-			if matched, _ := regexp.MatchString(pattern, text); matched {
-				return handler
+	handler, _ := cr.match(text)
+	return handler
+}
+
+// match 按优先级查找匹配的处理器，同时返回命中的规则
+func (cr *CommandRegistry) match(text string) (CommandHandler, string) {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	for _, rh := range cr.handlers {
+		for _, re := range rh.patterns {
+			if !re.MatchString(text) {
+				continue
 			}
+			if matcher, ok := rh.handler.(CommandMatcher); ok && !matcher.Accept(text) {
+				break
+			}
+			return rh.handler, re.String()
 		}
 	}
-	return nil
+	return nil, ""
 }
 
-// GetAllHandlers 获取所有命令处理器
-func (cr *CommandRegistry) GetAllHandlers() map[string]CommandHandler {
-	return cr.handlers
+// GetAllHandlers 获取所有命令处理器（按优先级降序）
+func (cr *CommandRegistry) GetAllHandlers() []CommandHandler {
+	cr.mutex.RLock()
+	defer cr.mutex.RUnlock()
+
+	handlers := make([]CommandHandler, 0, len(cr.handlers))
+	for _, rh := range cr.handlers {
+		handlers = append(handlers, rh.handler)
+	}
+	return handlers
 }
 
 // GetCommands 获取命令列表
 func (cr *CommandRegistry) GetCommands() []string {
 	var commands []string
-	for name, handler := range cr.handlers {
-		commands = append(commands, fmt.Sprintf("%s: %s", name, handler.GetDescription()))
+	for _, handler := range cr.GetAllHandlers() {
+		commands = append(commands, fmt.Sprintf("%s: %s", handler.GetName(), handler.GetDescription()))
 	}
 	return commands
-} 
\ No newline at end of file
+}
@@ -0,0 +1,36 @@
+package speaker
+
+import (
+	"testing"
+)
+
+func TestCalculatorCommand(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	for text, want := range map[string]string{
+		"傻妞，1加1等于几":   "1加1等于2",
+		"请计算12-5":     "12减5等于7",
+		"傻妞3乘以4是多少":   "3乘以4等于12",
+		"傻妞10除以4等于多少": "10除以4等于2.50",
+		"傻妞8-3":       "8减3等于5",
+	} {
+		result := eas.handleMessage(text)
+		if result.Route != MessageRouteCommand || result.Answer != want {
+			t.Errorf("%q 路由到 %s: %q, 期望由计算器回答 %q", text, result.Route, result.Answer, want)
+		}
+	}
+}
+
+func TestCalculatorCommandIgnoresDatesAndTimes(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	for _, text := range []string{
+		"傻妞，2024-10-18是星期几",
+		"傻妞2024-10-18到今天等于多少天",
+		"傻妞，10/18是什么节日",
+		"傻妞，3:30-4:00有什么安排",
+		"傻妞我的车牌是京A-12345",
+	} {
+		if result := eas.handleMessage(text); result.Route != MessageRouteAI {
+			t.Errorf("%q 应交给AI回答，实际路由到 %s: %s", text, result.Route, result.Answer)
+		}
+	}
+}
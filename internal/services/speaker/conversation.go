@@ -0,0 +1,877 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/cache"
+	"mi-gpt-go/internal/services/memory"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/internal/services/speech"
+	"mi-gpt-go/internal/utils"
+	"mi-gpt-go/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	memoryUpdateTimeout      = 2 * time.Minute  // 后台整理记忆的超时时间
+	memoryRecallTimeout      = 5 * time.Second  // 回答前检索相关记忆的超时时间
+	embeddingBackfillTimeout = 30 * time.Minute // 启动时补充历史向量的超时时间
+	forgetRecentWindow       = 30 * time.Minute // "刚才说的"所指的时间范围
+)
+
+// 语义匹配待遗忘消息的条件，阈值高于普通召回以免误删
+const (
+	forgetSimilarLimit = 5
+	forgetSimilarity   = 0.5
+)
+
+// commandSeparators 召唤关键词或机器人名称与命令之间的停顿
+const commandSeparators = "，,、。！! "
+
+// 消息的处理去向
+const (
+	MessageRouteWakeUp  = "wakeUp"  // 进入连续对话
+	MessageRouteExit    = "exit"    // 退出连续对话
+	MessageRouteCommand = "command" // 由命令处理
+	MessageRouteAI      = "ai"      // 由AI回答
+	MessageRouteIgnored = "ignored" // 未触发AI，交给小爱原生处理
+)
+
+// MessageResult 一条消息的处理结果
+type MessageResult struct {
+	Route  string `json:"route"`
+	Answer string `json:"answer,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// defaultSystemTemplate 默认系统提示词模板
+const defaultSystemTemplate = `你是{{botName}}，{{botProfile}}。
+
+## 对话环境
+- 房间: {{roomName}} ({{roomDescription}})
+- 用户: {{masterName}} ({{masterProfile}})
+
+## 对话规则
+1. 保持角色一致性，体现你的个性特点
+2. 回答要简洁明了，适合语音交互
+3. 用中文回复，语气要自然友好
+4. 如果不确定用户意图，可以礼貌地询问`
+
+// Conversation 对话引擎：人设、房间上下文、消息持久化和连续对话状态
+type Conversation struct {
+	config *config.Config
+	mutex  sync.RWMutex
+
+	bot    *models.User // 机器人用户
+	master *models.User // 主人用户，未识别出说话人时的默认身份
+	room   *models.Room // 房间
+
+	speaker       *models.User // 通过"我是X"切换的当前说话人，为空表示主人
+	speakerActive time.Time    // 当前说话人最近一次说话的时间
+
+	keepAlive      bool
+	keepAliveSince time.Time
+	session        *models.Session // 进行中的会话，空闲超时后在下一条消息到来时结束
+
+	aiClient    *openai.Client
+	memory      *memory.MemoryManager // 未启用记忆时为空
+	memoryMutex sync.Mutex            // 串行执行后台记忆整理
+}
+
+// NewConversation 创建对话引擎，aiClient 用于生成记忆摘要
+func NewConversation(cfg *config.Config, aiClient *openai.Client) *Conversation {
+	conversation := &Conversation{
+		config:   cfg,
+		aiClient: aiClient,
+		bot:      &models.User{Name: cfg.Bot.Name, Profile: cfg.Bot.Profile},
+		master:   &models.User{Name: cfg.Bot.Master.Name, Profile: cfg.Bot.Master.Profile},
+		room:     &models.Room{Name: cfg.Bot.Room.Name, Description: cfg.Bot.Room.Description},
+	}
+	if cfg.Speaker.KeepAlive {
+		conversation.SetKeepAlive(true)
+	}
+	return conversation
+}
+
+// Init 初始化机器人、主人和房间实体，配置中的简介会同步到数据库
+func (c *Conversation) Init() error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	bot := &models.User{}
+	if err := db.Where("name = ?", c.bot.Name).
+		Assign(models.User{Profile: c.bot.Profile, Role: models.UserRoleBot}).
+		FirstOrCreate(bot, models.User{Name: c.bot.Name}).Error; err != nil {
+		return fmt.Errorf("初始化机器人用户失败: %v", err)
+	}
+
+	master := &models.User{}
+	if err := db.Where("name = ? AND role = ?", c.master.Name, models.UserRoleMember).
+		Assign(models.User{Profile: c.master.Profile}).
+		FirstOrCreate(master, models.User{Name: c.master.Name}).Error; err != nil {
+		return fmt.Errorf("初始化主人用户失败: %v", err)
+	}
+
+	room := &models.Room{}
+	if err := db.Where("name = ?", c.room.Name).
+		Assign(models.Room{Description: c.room.Description}).
+		FirstOrCreate(room, models.Room{Name: c.room.Name}).Error; err != nil {
+		return fmt.Errorf("初始化房间失败: %v", err)
+	}
+
+	c.bot, c.master, c.room = bot, master, room
+	if err := closeStaleSessions(db); err != nil {
+		logger.Warnf("%v", err)
+	}
+	if c.config.Bot.EnableMemory && c.aiClient != nil {
+		c.memory = memory.NewMemoryManager(db, c.aiClient)
+		if c.aiClient.SupportsEmbedding() {
+			c.memory.SetVectorStore(memory.NewSQLiteVectorStore(db))
+			go c.backfillEmbeddings(c.memory)
+		}
+	}
+	if err := memory.EnsureSearchIndex(db); err != nil {
+		logger.Warnf("初始化检索索引失败: %v", err)
+	}
+	return nil
+}
+
+// BotName 获取机器人名称
+func (c *Conversation) BotName() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.bot.Name
+}
+
+// Persona 与当前说话人对话时机器人的名称和人设，用作回答缓存的键
+func (c *Conversation) Persona() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.bot.Name + "\n" + c.botProfileLocked(c.currentUserLocked())
+}
+
+// botProfileLocked 与该成员对话时使用的人设，成员未设置时使用机器人自己的简介，调用方需持有锁
+func (c *Conversation) botProfileLocked(user *models.User) string {
+	if strings.TrimSpace(user.Persona) != "" {
+		return user.Persona
+	}
+	return c.bot.Profile
+}
+
+// SpeechOptions 播报当前说话人的回复时的文本处理选项，成员未设置时使用机器人的配置
+func (c *Conversation) SpeechOptions() speech.Options {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	style := c.currentUserLocked().SpeechStyle
+	if style == "" {
+		style = c.config.Bot.SpeechStyle
+	}
+	return speech.Options{Style: style, URLText: c.config.Bot.SpeechURLText}
+}
+
+// IsKeepAlive 是否处于连续对话模式
+func (c *Conversation) IsKeepAlive() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.keepAlive
+}
+
+// SetKeepAlive 进入或退出连续对话模式
+// 进入时当前会话转为连续对话，退出时结束当前会话
+func (c *Conversation) SetKeepAlive(keepAlive bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if keepAlive && !c.keepAlive {
+		c.keepAliveSince = time.Now()
+	}
+	c.keepAlive = keepAlive
+	if keepAlive {
+		c.promoteSessionLocked()
+	} else {
+		c.closeSessionLocked(models.SessionEndExit)
+	}
+}
+
+// KeepAliveSince 获取本次连续对话的开始时间，未处于连续对话时返回零值
+func (c *Conversation) KeepAliveSince() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if !c.keepAlive {
+		return time.Time{}
+	}
+	return c.keepAliveSince
+}
+
+// IsWakeUp 是否为进入连续对话的指令，例如"召唤傻妞"
+func (c *Conversation) IsWakeUp(text string) bool {
+	if c.IsKeepAlive() {
+		return false
+	}
+	return hasKeywordPrefix(text, c.config.Speaker.WakeUpKeywords) && strings.Contains(text, c.BotName())
+}
+
+// IsExit 是否为退出连续对话的指令，例如"退出"或"关闭傻妞"
+// 关键词后带有其他内容时需要包含机器人名称，避免"关闭客厅灯"被误判
+func (c *Conversation) IsExit(text string) bool {
+	if !c.IsKeepAlive() {
+		return false
+	}
+	for _, keyword := range c.config.Speaker.ExitKeywords {
+		if keyword != "" && text == keyword {
+			return true
+		}
+	}
+	return hasKeywordPrefix(text, c.config.Speaker.ExitKeywords) && strings.Contains(text, c.BotName())
+}
+
+// ShouldAskAI 是否应交给AI回答：连续对话中的所有消息，或以召唤关键词开头的消息
+func (c *Conversation) ShouldAskAI(text string) bool {
+	return c.IsKeepAlive() || hasKeywordPrefix(text, c.config.Speaker.CallAIKeywords)
+}
+
+// CommandText 去掉开头的召唤关键词和机器人名称，用于匹配命令
+// 例如"傻妞，记住我周五要去体检"匹配为"记住我周五要去体检"，去掉后为空时返回原文
+func (c *Conversation) CommandText(text string) string {
+	prefixes := append([]string{c.BotName()}, c.config.Speaker.CallAIKeywords...)
+	command := strings.TrimSpace(text)
+	for {
+		command = strings.TrimLeft(command, commandSeparators)
+		prefix := matchedPrefix(command, prefixes)
+		if prefix == "" {
+			break
+		}
+		command = strings.TrimPrefix(command, prefix)
+	}
+	if command == "" {
+		return text
+	}
+	return command
+}
+
+// SystemPrompt 构建系统提示词，配置了 bot.systemTemplate 时使用自定义模板
+// {{masterName}} / {{masterProfile}} 为当前说话人，该成员设置了人设时替换 {{botProfile}}
+// {{sessionStart}} / {{sessionTurns}} 为当前会话的开始时间和提问次数
+// 模板未引用 {{pinnedFacts}} / {{longTermMemory}} / {{shortTermMemory}} / {{relevantMemories}} 时，记忆会追加在提示词末尾
+// personal 表示是否注入了事实或记忆，此时的回答因人而异，不应使用回答缓存
+func (c *Conversation) SystemPrompt(query string) (prompt string, personal bool) {
+	facts := formatPinnedFacts(c.PinnedFacts())
+	longTerm, shortTerm := c.latestMemories()
+	relevant := c.relevantMemories(query, longTerm, shortTerm)
+	personal = facts != "" || longTerm != "" || shortTerm != "" || relevant != ""
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var sessionStart, sessionTurns string
+	if c.session != nil {
+		sessionStart = c.session.StartedAt.Format("15:04")
+		sessionTurns = fmt.Sprintf("%d", c.session.Turns)
+	}
+
+	user := c.currentUserLocked()
+	botProfile := c.botProfileLocked(user)
+
+	template := c.config.Bot.SystemTemplate
+	if strings.TrimSpace(template) == "" {
+		template = defaultSystemTemplate
+	}
+	if !strings.Contains(template, "{{pinnedFacts}}") && !strings.Contains(template, "{{longTermMemory}}") &&
+		!strings.Contains(template, "{{shortTermMemory}}") && !strings.Contains(template, "{{relevantMemories}}") {
+		template += buildMemorySection(facts, longTerm, shortTerm, relevant)
+	}
+	prompt = utils.BuildPrompt(template, map[string]string{
+		"botName":          c.bot.Name,
+		"botProfile":       botProfile,
+		"masterName":       user.Name,
+		"masterProfile":    user.Profile,
+		"roomName":         c.room.Name,
+		"roomDescription":  c.room.Description,
+		"pinnedFacts":      facts,
+		"longTermMemory":   longTerm,
+		"shortTermMemory":  shortTerm,
+		"relevantMemories": relevant,
+		"sessionStart":     sessionStart,
+		"sessionTurns":     sessionTurns,
+	})
+	return prompt, personal
+}
+
+// buildMemorySection 构建系统提示词中的记忆部分
+func buildMemorySection(facts, longTerm, shortTerm, relevant string) string {
+	if facts == "" && longTerm == "" && shortTerm == "" && relevant == "" {
+		return ""
+	}
+
+	section := "\n\n## 记忆\n以下是你对过往对话的记忆，回答时可以自然地参考，不要逐条复述。"
+	if facts != "" {
+		section += "\n\n### {{masterName}}让你记住的事\n{{pinnedFacts}}"
+	}
+	if longTerm != "" {
+		section += "\n\n### 长期记忆\n{{longTermMemory}}"
+	}
+	if shortTerm != "" {
+		section += "\n\n### 短期记忆\n{{shortTermMemory}}"
+	}
+	if relevant != "" {
+		section += "\n\n### 与当前问题相关的记忆\n{{relevantMemories}}"
+	}
+	return section
+}
+
+// latestMemories 获取最新的长期记忆和短期记忆
+func (c *Conversation) latestMemories() (string, string) {
+	c.mutex.RLock()
+	manager, ownerID, roomID := c.memory, c.currentUserLocked().ID, c.room.ID
+	c.mutex.RUnlock()
+	if manager == nil || roomID == "" {
+		return "", ""
+	}
+
+	var longTerm, shortTerm string
+	if memories, err := manager.GetLongTermMemories(&ownerID, roomID, 1); err != nil {
+		logger.Warnf("读取长期记忆失败: %v", err)
+	} else if len(memories) > 0 {
+		longTerm = memories[0].Text
+	}
+	if memories, err := manager.GetShortTermMemories(&ownerID, roomID, 1); err != nil {
+		logger.Warnf("读取短期记忆失败: %v", err)
+	} else if len(memories) > 0 {
+		shortTerm = memories[0].Text
+	}
+	return longTerm, shortTerm
+}
+
+// relevantMemories 按相关度检索与问题有关的历史消息和记忆，已注入的最新记忆不再重复
+func (c *Conversation) relevantMemories(query, longTerm, shortTerm string) string {
+	c.mutex.RLock()
+	manager, ownerID, roomID := c.memory, c.currentUserLocked().ID, c.room.ID
+	c.mutex.RUnlock()
+	if manager == nil || roomID == "" || strings.TrimSpace(query) == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), memoryRecallTimeout)
+	defer cancel()
+
+	results, err := manager.Recall(ctx, query, &ownerID, roomID, c.historySince(), c.config.Bot.MemoryRecallLimit, c.config.Bot.MemoryRecallTokens)
+	if err != nil {
+		logger.Warnf("检索相关记忆失败: %v", err)
+		return ""
+	}
+
+	var lines []string
+	for _, result := range results {
+		if result.Text == longTerm || result.Text == shortTerm {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("- [%s] %s", result.CreatedAt.Format("2006-01-02"), result.Text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Remember 在后台为已保存的消息创建记忆，并按需更新短期和长期记忆
+func (c *Conversation) Remember(messages ...*models.Message) {
+	c.mutex.RLock()
+	manager, ownerID, roomID := c.memory, c.currentUserLocked().ID, c.room.ID
+	c.mutex.RUnlock()
+	if manager == nil || len(messages) == 0 {
+		return
+	}
+
+	go func() {
+		c.memoryMutex.Lock()
+		defer c.memoryMutex.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), memoryUpdateTimeout)
+		defer cancel()
+
+		for _, message := range messages {
+			if err := manager.AddMessageMemory(ctx, message, &ownerID, roomID); err != nil {
+				logger.Warnf("创建消息记忆失败: %v", err)
+				return
+			}
+		}
+		if err := manager.EmbedMessages(ctx, messages...); err != nil {
+			logger.Warnf("生成消息向量失败: %v", err)
+		}
+
+		updated, err := manager.UpdateShortTermMemory(ctx, &ownerID, roomID, c.config.Bot.ShortTermMemoryEvery)
+		if err != nil {
+			logger.Warnf("更新短期记忆失败: %v", err)
+			return
+		}
+		if !updated {
+			return
+		}
+		logger.Infof("🧠 短期记忆已更新")
+
+		updated, err = manager.UpdateLongTermMemory(ctx, &ownerID, roomID, c.config.Bot.LongTermMemoryAfter)
+		if err != nil {
+			logger.Warnf("更新长期记忆失败: %v", err)
+			return
+		}
+		if updated {
+			logger.Infof("🧠 长期记忆已更新")
+		}
+	}()
+}
+
+// MemoryManager 获取记忆管理器，未启用记忆时返回空
+func (c *Conversation) MemoryManager() *memory.MemoryManager {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.memory
+}
+
+// PinnedFacts 获取主人要求记住的事实
+func (c *Conversation) PinnedFacts() []models.PinnedFact {
+	db := database.GetDB()
+	c.mutex.RLock()
+	ownerID, roomID := c.currentUserLocked().ID, c.room.ID
+	c.mutex.RUnlock()
+	if db == nil || roomID == "" {
+		return nil
+	}
+
+	facts, err := memory.GetPinnedFacts(db, &ownerID, roomID)
+	if err != nil {
+		logger.Warnf("%v", err)
+		return nil
+	}
+	return facts
+}
+
+// RememberFact 记住主人明确要求记住的事实
+func (c *Conversation) RememberFact(text string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	c.mutex.RLock()
+	ownerID, roomID := c.currentUserLocked().ID, c.room.ID
+	c.mutex.RUnlock()
+	if roomID == "" {
+		return fmt.Errorf("对话实体未初始化")
+	}
+
+	if _, err := memory.AddPinnedFact(db, &ownerID, roomID, text); err != nil {
+		return err
+	}
+	forgetCachedAnswers(db, ownerID)
+	return nil
+}
+
+// ForgetFacts 遗忘包含关键词的事实和消息，recent 为 true 时只处理最近说过的消息
+// 启用语义记忆时，与关键词语义相近的消息也会被遗忘
+func (c *Conversation) ForgetFacts(keyword string, recent bool) (memory.ForgetResult, error) {
+	db := database.GetDB()
+	if db == nil {
+		return memory.ForgetResult{}, fmt.Errorf("数据库未初始化")
+	}
+	c.mutex.RLock()
+	manager, ownerID, roomID := c.memory, c.currentUserLocked().ID, c.room.ID
+	c.mutex.RUnlock()
+	if roomID == "" {
+		return memory.ForgetResult{}, fmt.Errorf("对话实体未初始化")
+	}
+
+	options := memory.ForgetOptions{OwnerID: &ownerID, RoomID: roomID, Keyword: keyword}
+	if recent {
+		options.Since = time.Now().Add(-forgetRecentWindow)
+	}
+	if manager != nil && manager.SemanticEnabled() {
+		ctx, cancel := context.WithTimeout(context.Background(), memoryRecallTimeout)
+		similar, err := manager.SimilarMessages(ctx, keyword, roomID, forgetSimilarLimit, forgetSimilarity)
+		cancel()
+		if err != nil {
+			logger.Warnf("语义匹配待遗忘的消息失败: %v", err)
+		}
+		for _, result := range similar {
+			options.MessageIDs = append(options.MessageIDs, result.RefID)
+		}
+	}
+	result, err := memory.Forget(db, options)
+	if err == nil {
+		forgetCachedAnswers(db, ownerID)
+	}
+	return result, err
+}
+
+// forgetCachedAnswers 删除成员的缓存回答，记住或遗忘事情后之前的回答可能不再适用
+func forgetCachedAnswers(db *gorm.DB, ownerID string) {
+	if _, err := cache.DeleteOwner(db, ownerID); err != nil {
+		logger.Warnf("%v", err)
+	}
+}
+
+// formatPinnedFacts 把事实格式化为提示词中的列表
+func formatPinnedFacts(facts []models.PinnedFact) string {
+	lines := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		lines = append(lines, fmt.Sprintf("- [%s] %s", fact.CreatedAt.Format("2006-01-02"), fact.Text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// backfillEmbeddings 在后台为尚未生成向量的历史消息和记忆补充向量
+// 向量写入是幂等的，不需要与记忆整理串行
+func (c *Conversation) backfillEmbeddings(manager *memory.MemoryManager) {
+	ctx, cancel := context.WithTimeout(context.Background(), embeddingBackfillTimeout)
+	defer cancel()
+
+	count, err := manager.BackfillEmbeddings(ctx)
+	if err != nil {
+		logger.Warnf("补充历史向量失败（已补充 %d 条）: %v", count, err)
+		return
+	}
+	if count > 0 {
+		logger.Infof("🧠 已为 %d 条历史消息和记忆生成向量", count)
+	}
+}
+
+// UserPrompt 构建用户提示词
+func (c *Conversation) UserPrompt(text string) string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return fmt.Sprintf("%s: %s", c.currentUserLocked().Name, text)
+}
+
+// LoadHistory 从当前会话的消息中组装对话历史，会话已空闲超时时不携带历史
+func (c *Conversation) LoadHistory() ([]openai.ChatMessage, error) {
+	turns := c.config.Bot.ContextTurns
+	if turns <= 0 {
+		return nil, nil
+	}
+	db := database.GetDB()
+	if db == nil {
+		return nil, nil
+	}
+
+	c.mutex.Lock()
+	botID, session := c.bot.ID, c.activeSessionLocked()
+	c.mutex.Unlock()
+	if session == nil {
+		return nil, nil
+	}
+
+	var messages []models.Message
+	err := db.Preload("Sender").
+		Where("session_id = ?", session.ID).
+		Order("id DESC").
+		Limit(turns * 2).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]openai.ChatMessage, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		message := messages[i]
+		if message.Text == memory.RedactedText {
+			continue
+		}
+		if message.SenderID == botID {
+			history = append(history, openai.ChatMessage{
+				Role:    openai.RoleAssistant,
+				Content: message.Text,
+			})
+		} else {
+			history = append(history, openai.ChatMessage{
+				Role:    openai.RoleUser,
+				Content: fmt.Sprintf("%s: %s", message.Sender.Name, message.Text),
+			})
+		}
+	}
+
+	return openai.TrimHistory(history, turns, c.config.Bot.ContextMaxTokens), nil
+}
+
+// historySince 对话历史的起始时间：当前会话的开始时间，没有会话时为当前时间
+func (c *Conversation) historySince() time.Time {
+	if session := c.CurrentSession(); session != nil {
+		return session.StartedAt
+	}
+	return time.Now()
+}
+
+// SaveUserMessage 保存当前说话人发送的消息，同时刷新说话人身份的有效期
+func (c *Conversation) SaveUserMessage(text string) (*models.Message, error) {
+	c.mutex.Lock()
+	senderID := c.currentUserLocked().ID
+	if c.speaker != nil && c.speaker.ID == senderID {
+		c.speakerActive = time.Now()
+	}
+	c.mutex.Unlock()
+	return c.saveMessage(&models.Message{Text: text, SenderID: senderID})
+}
+
+// SaveBotMessage 保存机器人的回复，同时记录思考过程和实际回答的AI服务商和模型
+func (c *Conversation) SaveBotMessage(text, reasoning, provider, model string) (*models.Message, error) {
+	c.mutex.RLock()
+	senderID := c.bot.ID
+	c.mutex.RUnlock()
+	return c.saveMessage(&models.Message{Text: text, Reasoning: reasoning, SenderID: senderID, Provider: provider, Model: model})
+}
+
+// saveMessage 保存一条房间消息并归入当前会话
+func (c *Conversation) saveMessage(message *models.Message) (*models.Message, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+
+	c.mutex.RLock()
+	roomID := c.room.ID
+	c.mutex.RUnlock()
+	if message.SenderID == "" || roomID == "" {
+		return nil, fmt.Errorf("对话实体未初始化")
+	}
+
+	message.RoomID = roomID
+	message.SessionID = c.touchSession(db, message.SenderID, message.Model)
+	if err := db.Create(message).Error; err != nil {
+		return nil, err
+	}
+	if err := memory.IndexMessage(db, message); err != nil {
+		logger.Warnf("%v", err)
+	}
+	return message, nil
+}
+
+// UpdateBot 更新机器人人设
+func (c *Conversation) UpdateBot(name, profile string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := updateUser(c.bot, name, profile); err != nil {
+		return fmt.Errorf("更新机器人信息失败: %v", err)
+	}
+	c.config.Bot.Name = name
+	c.config.Bot.Profile = profile
+	persistBotConfig(map[string]string{"bot.name": name, "bot.profile": profile})
+	return nil
+}
+
+// CurrentUser 获取当前说话人，未切换身份或身份已过期时为主人
+func (c *Conversation) CurrentUser() models.User {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return *c.currentUserLocked()
+}
+
+// currentUserLocked 当前说话人，调用方需持有锁
+func (c *Conversation) currentUserLocked() *models.User {
+	if c.speaker != nil && time.Since(c.speakerActive) < c.identityTimeout() {
+		return c.speaker
+	}
+	return c.master
+}
+
+// identityTimeout 说话人身份的有效期
+func (c *Conversation) identityTimeout() time.Duration {
+	timeout := c.config.Bot.IdentityTimeout
+	if timeout <= 0 {
+		timeout = 10
+	}
+	return time.Duration(timeout) * time.Minute
+}
+
+// SwitchUser 按名称或昵称切换当前说话人，不存在时创建新的家庭成员
+// profile 不为空时同时更新该成员的介绍，返回切换后的成员以及是否为新成员
+func (c *Conversation) SwitchUser(name, profile string) (models.User, bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return models.User{}, false, fmt.Errorf("数据库未初始化")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.master.ID == "" {
+		return models.User{}, false, fmt.Errorf("对话实体未初始化")
+	}
+
+	user, err := FindMember(db, name)
+	created := false
+	if err == gorm.ErrRecordNotFound {
+		user = &models.User{Name: name, Profile: profile, Role: models.UserRoleMember}
+		if err := db.Create(user).Error; err != nil {
+			return models.User{}, false, fmt.Errorf("创建家庭成员失败: %v", err)
+		}
+		created = true
+	} else if err != nil {
+		return models.User{}, false, fmt.Errorf("查找家庭成员失败: %v", err)
+	}
+
+	if user.ID == c.master.ID {
+		user = c.master
+	}
+	if profile != "" && !created {
+		if err := updateUser(user, user.Name, profile); err != nil {
+			return models.User{}, false, fmt.Errorf("更新成员信息失败: %v", err)
+		}
+	}
+	if user == c.master {
+		c.speaker = nil
+		if profile != "" {
+			c.config.Bot.Master.Profile = profile
+			persistBotConfig(map[string]string{"bot.master.profile": profile})
+		}
+	} else {
+		c.speaker = user
+		c.speakerActive = time.Now()
+	}
+	return *user, created, nil
+}
+
+// IsMember 是否已有该名称或昵称的家庭成员
+func (c *Conversation) IsMember(name string) (bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return false, fmt.Errorf("数据库未初始化")
+	}
+	if _, err := FindMember(db, name); err == gorm.ErrRecordNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("查找家庭成员失败: %v", err)
+	}
+	return true, nil
+}
+
+// RefreshUser 同步在管理界面修改的成员信息，主人的名称和介绍会写回配置
+func (c *Conversation) RefreshUser(user models.User) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.master.ID == user.ID {
+		*c.master = user
+		c.config.Bot.Master.Name = user.Name
+		c.config.Bot.Master.Profile = user.Profile
+		persistBotConfig(map[string]string{"bot.master.name": user.Name, "bot.master.profile": user.Profile})
+	}
+	if c.speaker != nil && c.speaker.ID == user.ID {
+		*c.speaker = user
+	}
+}
+
+// ForgetUser 成员被删除后，正在使用该身份的对话恢复为主人
+func (c *Conversation) ForgetUser(userID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.speaker != nil && c.speaker.ID == userID {
+		c.speaker = nil
+	}
+}
+
+// RoomID 获取房间ID，未初始化时为空
+func (c *Conversation) RoomID() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.room.ID
+}
+
+// MasterID 获取主人的用户ID，未初始化时为空
+func (c *Conversation) MasterID() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.master.ID
+}
+
+// FindMember 按名称或昵称查找家庭成员，名称优先，未找到时返回 gorm.ErrRecordNotFound
+func FindMember(db *gorm.DB, name string) (*models.User, error) {
+	name = strings.TrimSpace(name)
+	var members []models.User
+	if err := db.Where("role = ?", models.UserRoleMember).Order("created_at ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for i := range members {
+		if members[i].Name == name {
+			return &members[i], nil
+		}
+	}
+	for i := range members {
+		for _, nickname := range strings.Split(members[i].Nicknames, ",") {
+			if nickname != "" && nickname == name {
+				return &members[i], nil
+			}
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// NormalizeNicknames 整理昵称列表：支持中英文逗号和顿号分隔，去掉空白和重复项
+func NormalizeNicknames(nicknames string) string {
+	fields := strings.FieldsFunc(nicknames, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ';' || r == '；'
+	})
+	seen := make(map[string]bool, len(fields))
+	result := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		seen[field] = true
+		result = append(result, field)
+	}
+	return strings.Join(result, ",")
+}
+
+// updateUser 更新用户实体，已持久化的实体同步写回数据库
+func updateUser(user *models.User, name, profile string) error {
+	user.Name = name
+	user.Profile = profile
+	if user.ID == "" {
+		return nil
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+	return db.Model(user).Updates(map[string]interface{}{
+		"name":    name,
+		"profile": profile,
+	}).Error
+}
+
+// hasKeywordPrefix 文本是否以任一关键词开头
+func hasKeywordPrefix(text string, keywords []string) bool {
+	return matchedPrefix(text, keywords) != ""
+}
+
+// matchedPrefix 返回 text 开头的关键词，没有时返回空字符串
+func matchedPrefix(text string, keywords []string) string {
+	for _, keyword := range keywords {
+		if keyword != "" && strings.HasPrefix(text, keyword) {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// persistBotConfig 把人设变更写回配置表，重启后依然生效
+func persistBotConfig(values map[string]string) {
+	db := database.GetDB()
+	if db == nil {
+		return
+	}
+	for key, value := range values {
+		if err := db.Model(&models.Config{}).Where("key = ?", key).Update("value", value).Error; err != nil {
+			logger.Warnf("保存配置 %s 失败: %v", key, err)
+		}
+	}
+}
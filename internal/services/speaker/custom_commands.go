@@ -0,0 +1,339 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/miservice"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/internal/utils"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 自定义命令匹配方式
+const (
+	MatchTypeExact    = "exact"    // 完全匹配（忽略首尾标点和空白）
+	MatchTypeContains = "contains" // 包含匹配
+	MatchTypeRegex    = "regex"    // 正则匹配
+)
+
+// 自定义命令动作类型
+const (
+	ActionSpeak     = "speak"      // 播报文本
+	ActionPlayURL   = "play_url"   // 播放URL
+	ActionSetVolume = "set_volume" // 设置音量
+	ActionDirective = "directive"  // 执行小爱原生指令
+	ActionWebhook   = "webhook"    // 调用Webhook
+	ActionAskLLM    = "ask_llm"    // 使用提示词模板询问AI并播报
+)
+
+// webhookTimeout Webhook请求超时
+const webhookTimeout = 10 * time.Second
+
+// webhookClient 调用Webhook的HTTP客户端
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// CustomCommandHandler 由数据库中的自定义命令构建的命令处理器
+type CustomCommandHandler struct {
+	command  models.CustomCommand
+	patterns []string
+	matchers []*regexp.Regexp
+}
+
+// NewCustomCommandHandler 根据自定义命令创建处理器
+func NewCustomCommandHandler(command models.CustomCommand) (*CustomCommandHandler, error) {
+	patterns, err := buildCustomPatterns(command.MatchType, command.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(command.Actions) == 0 {
+		return nil, fmt.Errorf("自定义命令 %s 至少需要一个动作", command.Name)
+	}
+	for i, action := range command.Actions {
+		if err := validateCustomAction(action); err != nil {
+			return nil, fmt.Errorf("第%d个动作无效: %v", i+1, err)
+		}
+	}
+
+	matchers := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("匹配规则无效 %s: %v", pattern, err)
+		}
+		matchers = append(matchers, re)
+	}
+
+	return &CustomCommandHandler{
+		command:  command,
+		patterns: patterns,
+		matchers: matchers,
+	}, nil
+}
+
+// ValidateCustomCommand 校验自定义命令是否可用
+func ValidateCustomCommand(command models.CustomCommand) error {
+	if strings.TrimSpace(command.Name) == "" {
+		return fmt.Errorf("命令名称不能为空")
+	}
+	_, err := NewCustomCommandHandler(command)
+	return err
+}
+
+// buildCustomPatterns 将匹配方式和匹配文本转换为正则表达式
+// exact 和 contains 支持用逗号分隔多个短语
+func buildCustomPatterns(matchType, pattern string) ([]string, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("匹配规则不能为空")
+	}
+
+	var patterns []string
+	switch matchType {
+	case MatchTypeExact, MatchTypeContains:
+		for _, phrase := range strings.FieldsFunc(pattern, func(r rune) bool { return r == ',' || r == '，' }) {
+			phrase = strings.TrimSpace(phrase)
+			if phrase == "" {
+				continue
+			}
+			if matchType == MatchTypeExact {
+				patterns = append(patterns, `^[\p{P}\s]*`+regexp.QuoteMeta(phrase)+`[\p{P}\s]*$`)
+			} else {
+				patterns = append(patterns, regexp.QuoteMeta(phrase))
+			}
+		}
+		if len(patterns) == 0 {
+			return nil, fmt.Errorf("匹配规则不能为空")
+		}
+	case MatchTypeRegex:
+		patterns = append(patterns, pattern)
+	default:
+		return nil, fmt.Errorf("不支持的匹配方式: %s", matchType)
+	}
+
+	return patterns, nil
+}
+
+// validateCustomAction 校验单个动作
+func validateCustomAction(action models.CustomCommandAction) error {
+	switch action.Type {
+	case ActionSpeak, ActionDirective, ActionAskLLM:
+		if strings.TrimSpace(action.Text) == "" {
+			return fmt.Errorf("%s 动作的文本不能为空", action.Type)
+		}
+	case ActionPlayURL, ActionWebhook:
+		if !strings.HasPrefix(action.URL, "http://") && !strings.HasPrefix(action.URL, "https://") {
+			return fmt.Errorf("%s 动作的URL无效: %s", action.Type, action.URL)
+		}
+	case ActionSetVolume:
+		if action.Volume < 0 || action.Volume > 100 {
+			return fmt.Errorf("音量必须在0-100之间")
+		}
+	default:
+		return fmt.Errorf("不支持的动作类型: %s", action.Type)
+	}
+	return nil
+}
+
+func (h *CustomCommandHandler) GetName() string        { return h.command.Name }
+func (h *CustomCommandHandler) GetDescription() string { return h.command.Description }
+func (h *CustomCommandHandler) GetPatterns() []string  { return h.patterns }
+func (h *CustomCommandHandler) GetPriority() int       { return h.command.Priority }
+func (h *CustomCommandHandler) IsConsuming() bool      { return true }
+
+// Handle 按顺序执行自定义命令的动作
+func (h *CustomCommandHandler) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	vars := h.buildVars(msg.Text)
+
+	for i, action := range h.command.Actions {
+		if err := runCommandAction(ctx, action, vars, speaker); err != nil {
+			// 单个动作失败不影响后续动作
+			logger.Errorf("自定义命令 %s 第%d个动作 (%s) 执行失败: %v", h.command.Name, i+1, action.Type, err)
+			continue
+		}
+		logger.Debugf("自定义命令 %s 第%d个动作 (%s) 执行成功", h.command.Name, i+1, action.Type)
+	}
+
+	return SpeakerAnswer{}, nil
+}
+
+// buildVars 构建模板变量：{{query}}、{{time}}、{{date}} 以及正则分组 {{1}}、{{2}}...
+func (h *CustomCommandHandler) buildVars(query string) map[string]string {
+	now := time.Now()
+	vars := map[string]string{
+		"query": query,
+		"time":  now.Format("15:04"),
+		"date":  now.Format("2006-01-02"),
+	}
+	for _, re := range h.matchers {
+		if matches := re.FindStringSubmatch(query); matches != nil {
+			for i := 1; i < len(matches); i++ {
+				vars[strconv.Itoa(i)] = matches[i]
+			}
+			break
+		}
+	}
+	return vars
+}
+
+// runCommandAction 执行单个动作（自定义命令和插件共用）
+func runCommandAction(ctx context.Context, action models.CustomCommandAction, vars map[string]string, speaker *EnhancedAISpeaker) error {
+	deviceID := speaker.config.Speaker.DeviceID
+
+	switch action.Type {
+	case ActionSpeak:
+		return speaker.speak(utils.BuildPrompt(action.Text, vars))
+	case ActionPlayURL:
+		return speaker.xiaomiService.PlayURL(deviceID, utils.BuildPrompt(action.URL, vars))
+	case ActionSetVolume:
+		return speaker.xiaomiService.SetVolume(deviceID, action.Volume)
+	case ActionDirective:
+		return speaker.xiaomiService.ExecuteDirective(deviceID, utils.BuildPrompt(action.Text, vars), false)
+	case ActionWebhook:
+		response, err := callWebhook(ctx, action, vars)
+		if err != nil {
+			return err
+		}
+		vars["response"] = response
+		return nil
+	case ActionAskLLM:
+		if speaker.openaiService == nil {
+			return fmt.Errorf("AI服务未配置")
+		}
+		answer, err := speaker.openaiService.Chat(ctx, openai.ChatOptions{
+			User:     utils.BuildPrompt(action.Text, vars),
+			Trace:    speaker.config.Speaker.EnableTrace,
+			Purpose:  openai.PurposeCommand,
+			DeviceID: deviceID,
+		})
+		if err != nil {
+			return err
+		}
+		vars["answer"] = answer
+		return speaker.speak(answer)
+	default:
+		return fmt.Errorf("不支持的动作类型: %s", action.Type)
+	}
+}
+
+// callWebhook 调用Webhook，返回响应内容
+func callWebhook(ctx context.Context, action models.CustomCommandAction, vars map[string]string) (string, error) {
+	method := strings.ToUpper(utils.DefaultString(action.Method, http.MethodPost))
+	isJSON := isJSONBody(action.Body)
+	bodyVars := vars
+	if isJSON {
+		bodyVars = jsonEscapeVars(vars)
+	}
+	body := utils.BuildPrompt(action.Body, bodyVars)
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, utils.BuildPrompt(action.URL, vars), reader)
+	if err != nil {
+		return "", fmt.Errorf("创建Webhook请求失败: %v", err)
+	}
+	if body != "" {
+		if isJSON {
+			req.Header.Set("Content-Type", "application/json")
+		} else {
+			req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		}
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求Webhook失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("Webhook返回状态码 %d: %s", resp.StatusCode, utils.TruncateString(string(data), 200))
+	}
+	return string(data), nil
+}
+
+// isJSONBody 请求体模板是否为JSON
+func isJSONBody(body string) bool {
+	body = strings.TrimSpace(body)
+	return strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[")
+}
+
+// jsonEscapeVars 转义模板变量，使其可以直接写在JSON字符串中，例如 {"text": "{{query}}"}
+func jsonEscapeVars(vars map[string]string) map[string]string {
+	escaped := make(map[string]string, len(vars))
+	for key, value := range vars {
+		data, _ := json.Marshal(value)
+		escaped[key] = string(data[1 : len(data)-1])
+	}
+	return escaped
+}
+
+// LoadCustomCommands 从数据库加载已启用的自定义命令
+func LoadCustomCommands() ([]models.CustomCommand, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+
+	var commands []models.CustomCommand
+	err := db.Preload("Actions", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("position ASC")
+	}).Where("enabled = ?", true).Order("id ASC").Find(&commands).Error
+	if err != nil {
+		return nil, fmt.Errorf("加载自定义命令失败: %v", err)
+	}
+	return commands, nil
+}
+
+// ReloadCustomCommands 重新加载自定义命令到命令路由器（无需重启）
+func (eas *EnhancedAISpeaker) ReloadCustomCommands() error {
+	commands, err := LoadCustomCommands()
+	if err != nil {
+		return err
+	}
+
+	registry := eas.commandRouter.Registry()
+	builtin := make(map[string]bool)
+	for _, handler := range registry.GetAllHandlers() {
+		if _, ok := handler.(*CustomCommandHandler); !ok {
+			builtin[handler.GetName()] = true
+		}
+	}
+
+	handlers := make([]CommandHandler, 0, len(commands))
+	for _, command := range commands {
+		if builtin[command.Name] {
+			logger.Warnf("自定义命令 %s 与内置命令重名，已跳过", command.Name)
+			continue
+		}
+		handler, err := NewCustomCommandHandler(command)
+		if err != nil {
+			logger.Warnf("自定义命令 %s 无效，已跳过: %v", command.Name, err)
+			continue
+		}
+		handlers = append(handlers, handler)
+	}
+
+	registry.ReplaceWhere(func(handler CommandHandler) bool {
+		_, ok := handler.(*CustomCommandHandler)
+		return ok
+	}, handlers)
+
+	logger.Infof("已加载自定义命令: %d 个", len(handlers))
+	return nil
+}
@@ -0,0 +1,50 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mi-gpt-go/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallWebhookEscapesJSONBody(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &received); err != nil {
+			t.Errorf("Webhook收到的请求体不是合法的JSON: %v\n%s", err, data)
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Content-Type = %q", contentType)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	query := `把"客厅"的灯\关掉` + "\n"
+	action := models.CustomCommandAction{Type: ActionWebhook, URL: server.URL, Body: `{"text": "{{query}}"}`}
+	response, err := callWebhook(context.Background(), action, map[string]string{"query": query})
+	if err != nil {
+		t.Fatalf("调用Webhook失败: %v", err)
+	}
+	if response != "ok" {
+		t.Errorf("响应内容 = %q", response)
+	}
+	if received["text"] != query {
+		t.Errorf("Webhook收到的 text = %q, 期望 %q", received["text"], query)
+	}
+}
+
+func TestSpeakActionNormalizesText(t *testing.T) {
+	eas, mi := newTestSpeaker(t)
+	action := models.CustomCommandAction{Type: ActionSpeak, Text: "**晚安**，现在是{{time}}"}
+	if err := runCommandAction(context.Background(), action, map[string]string{"time": "22:30"}, eas); err != nil {
+		t.Fatalf("执行播报动作失败: %v", err)
+	}
+	if len(mi.said) != 1 || mi.said[0] != "晚安，现在是二十二点三十分。" {
+		t.Errorf("播报内容 = %q, 期望去掉格式后播报", mi.said)
+	}
+}
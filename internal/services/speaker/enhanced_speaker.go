@@ -4,8 +4,17 @@ import (
 	"context"
 	"fmt"
 	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/cache"
+	"mi-gpt-go/internal/services/memory"
 	"mi-gpt-go/internal/services/miservice"
 	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/internal/services/plugin"
+	"mi-gpt-go/internal/services/search"
+	"mi-gpt-go/internal/services/speech"
+	"mi-gpt-go/internal/services/usage"
+	"mi-gpt-go/internal/utils"
 	"mi-gpt-go/pkg/logger"
 	"strings"
 	"sync"
@@ -17,11 +26,17 @@ type EnhancedAISpeaker struct {
 	config        *config.Config
 	xiaomiService miservice.MiServiceInterface
 	openaiService *openai.Client
+	commandRouter *CommandRouter
+	pluginManager *plugin.Manager
+	toolRegistry  *ToolRegistry
+	timers        *TimerManager
 	mutex         sync.RWMutex
 	isRunning     bool
 	stopChannel   chan struct{}
 	isHealthy     bool
 	lastActivity  time.Time
+	conversation  *Conversation
+	maintainer    *memory.Maintainer
 }
 
 // NewEnhancedAISpeaker 创建增强版AI音箱服务
@@ -39,16 +54,25 @@ func NewEnhancedAISpeaker(cfg *config.Config) (*EnhancedAISpeaker, error) {
 	if err != nil {
 		return nil, fmt.Errorf("创建OpenAI客户端失败: %v", err)
 	}
+	openaiClient.SetUsageRecorder(usage.NewRecorder(cfg))
+	openaiClient.SetAnswerCache(cache.NewStore(cfg))
+	openaiClient.SetSearcher(search.NewSearcher(cfg))
 
 	enhanced := &EnhancedAISpeaker{
 		config:        cfg,
 		xiaomiService: xiaomiService,
 		openaiService: openaiClient,
+		commandRouter: NewCommandRouter(NewCommandRegistry()),
+		toolRegistry:  NewToolRegistry(),
+		timers:        NewTimerManager(),
+		conversation:  NewConversation(cfg, openaiClient),
 		stopChannel:   make(chan struct{}),
 		isHealthy:     true,
 		lastActivity:  time.Now(),
 	}
 
+	enhanced.registerSpeakerTools(enhanced.toolRegistry)
+
 	logger.Info("增强版AI音箱服务初始化成功")
 	return enhanced, nil
 }
@@ -64,6 +88,21 @@ func (eas *EnhancedAISpeaker) Start() error {
 
 	logger.Info("正在启动增强版AI音箱服务...")
 
+	// 初始化人设、主人和房间
+	if err := eas.conversation.Init(); err != nil {
+		logger.Warnf("初始化对话实体失败，消息将不会被保存: %v", err)
+	} else {
+		eas.startMaintainer()
+	}
+
+	// 加载自定义命令
+	if err := eas.ReloadCustomCommands(); err != nil {
+		logger.Warnf("加载自定义命令失败: %v", err)
+	}
+
+	// 启动外部命令插件
+	eas.startPlugins()
+
 	// 启动消息处理循环
 	eas.startMessageProcessor()
 
@@ -83,6 +122,12 @@ func (eas *EnhancedAISpeaker) Stop() error {
 
 	logger.Info("正在停止增强版AI音箱服务...")
 	close(eas.stopChannel)
+	eas.stopPlugins()
+	eas.timers.CancelAll()
+	if eas.maintainer != nil {
+		eas.maintainer.Stop()
+	}
+	eas.conversation.EndSession(models.SessionEndShutdown)
 
 	if err := eas.xiaomiService.Close(); err != nil {
 		logger.Warnf("关闭小米服务失败: %v", err)
@@ -93,6 +138,37 @@ func (eas *EnhancedAISpeaker) Stop() error {
 	return nil
 }
 
+// startMaintainer 启动记忆保留与维护任务
+func (eas *EnhancedAISpeaker) startMaintainer() {
+	bot := eas.config.Bot
+	eas.maintainer = memory.NewMaintainer(database.GetDB(), eas.conversation.MemoryManager(), memory.RetentionPolicy{
+		MessageDays:   bot.MessageRetentionDays,
+		MemoryDays:    bot.MemoryRetentionDays,
+		ShortTermDays: bot.ShortTermRetentionDays,
+		LongTermDays:  bot.LongTermRetentionDays,
+	}, time.Duration(bot.MaintenanceIntervalHours)*time.Hour)
+	eas.maintainer.Start()
+}
+
+// GetMaintenanceStatus 获取记忆维护任务的状态，服务未启动时返回空
+func (eas *EnhancedAISpeaker) GetMaintenanceStatus() *memory.MaintenanceStatus {
+	eas.mutex.RLock()
+	defer eas.mutex.RUnlock()
+	if eas.maintainer == nil {
+		return nil
+	}
+	status := eas.maintainer.Status()
+	return &status
+}
+
+// GetProviderStatus 获取AI服务商故障转移链的状态，AI服务未配置时返回空
+func (eas *EnhancedAISpeaker) GetProviderStatus() []openai.ProviderStatus {
+	if eas.openaiService == nil {
+		return nil
+	}
+	return eas.openaiService.ProviderStatus()
+}
+
 // IsRunning 检查服务是否运行中
 func (eas *EnhancedAISpeaker) IsRunning() bool {
 	eas.mutex.RLock()
@@ -131,55 +207,237 @@ func (eas *EnhancedAISpeaker) startMessageProcessor() {
 	}()
 }
 
-// handleMessage 处理消息
-func (eas *EnhancedAISpeaker) handleMessage(text string) {
+// handleMessage 处理消息，所有输入（音箱对话和Web请求）都经过这里
+// 依次处理：连续对话的进入/退出 → 召唤关键词判断 → 命令路由 → AI回答
+// 未通过召唤关键词判断的消息不经过命令路由，避免抢先处理小爱原生的指令
+func (eas *EnhancedAISpeaker) handleMessage(text string) MessageResult {
 	eas.lastActivity = time.Now()
+	ctx := context.Background()
+	text = strings.TrimSpace(text)
+
+	if eas.conversation.IsWakeUp(text) {
+		eas.conversation.SetKeepAlive(true)
+		logger.Infof("💬 进入连续对话模式")
+		answer := utils.PickRandom(eas.config.Speaker.OnEnterAI)
+		eas.say(answer)
+		return MessageResult{Route: MessageRouteWakeUp, Answer: answer}
+	}
+	if eas.conversation.IsExit(text) {
+		eas.conversation.SetKeepAlive(false)
+		logger.Infof("💬 退出连续对话模式")
+		answer := utils.PickRandom(eas.config.Speaker.OnExitAI)
+		eas.say(answer)
+		return MessageResult{Route: MessageRouteExit, Answer: answer}
+	}
+
+	// 非连续对话时只回答以召唤关键词开头的消息，其余交给小爱原生处理
+	if !eas.conversation.ShouldAskAI(text) {
+		logger.Debugf("未触发AI，忽略消息: %s", text)
+		return MessageResult{Route: MessageRouteIgnored}
+	}
+
+	// 去掉召唤关键词和机器人名称后交给命令路由器，按优先级匹配命令
+	answer, consumed := eas.commandRouter.Route(ctx, miservice.QueryMessage{
+		Text:      eas.conversation.CommandText(text),
+		Timestamp: eas.lastActivity.UnixMilli(),
+	}, eas)
+	eas.say(answer.Text)
+	if consumed {
+		return MessageResult{Route: MessageRouteCommand, Answer: answer.Text}
+	}
+
+	return eas.askAI(ctx, text)
+}
+
+// askAI 带上人设、房间上下文和对话历史请求AI回答，并保存双方消息
+func (eas *EnhancedAISpeaker) askAI(ctx context.Context, text string) MessageResult {
+	result := MessageResult{Route: MessageRouteAI}
 
 	// 检查AI服务是否正确配置
 	if eas.openaiService == nil {
 		logger.Warn("AI服务未配置，跳过消息处理")
-		response := "AI服务未配置，请在Web管理面板中配置AI服务。"
-		err := eas.xiaomiService.Say(response)
-		if err != nil {
-			logger.Errorf("发送配置提示失败: %v", err)
+		result.Answer = "AI服务未配置，请在Web管理面板中配置AI服务。"
+		result.Error = "AI服务未配置"
+		eas.say(result.Answer)
+		return result
+	}
+
+	// 超出费用预算时改用便宜模型或拒绝回答
+	model, refusal := eas.checkBudget()
+	if refusal != "" {
+		result.Answer = refusal
+		result.Error = "超出AI费用预算"
+		eas.say(result.Answer)
+		return result
+	}
+
+	eas.say(utils.PickRandom(eas.config.Speaker.OnAIAsking))
+
+	// 先读取历史，避免把本次消息算进上下文
+	history, err := eas.conversation.LoadHistory()
+	if err != nil {
+		logger.Warnf("加载对话历史失败: %v", err)
+	}
+	var remembered []*models.Message
+	if message, err := eas.conversation.SaveUserMessage(text); err != nil {
+		logger.Warnf("保存用户消息失败: %v", err)
+	} else {
+		remembered = append(remembered, message)
+	}
+	defer func() { eas.conversation.Remember(remembered...) }()
+
+	// 调用OpenAI获取回复，启用工具时模型可以直接操作音箱
+	var toolCalls []openai.ToolCallRecord
+	var provider, answeredModel, reasoning string
+	system, personal := eas.conversation.SystemPrompt(text)
+	options := openai.ChatOptions{
+		System:   system,
+		User:     eas.conversation.UserPrompt(text),
+		Model:    model,
+		History:  history,
+		Trace:    eas.config.Speaker.EnableTrace,
+		Purpose:  openai.PurposeChat,
+		DeviceID: eas.config.Speaker.DeviceID,
+		UserID:   eas.conversation.CurrentUser().ID,
+		// 同一成员在同一人设下的相同问题直接使用缓存的回答
+		Persona: eas.conversation.Persona(),
+		RoomID:  eas.conversation.RoomID(),
+		// 按原始问题判断是否需要联网搜索
+		SearchQuery: text,
+		OnProvider: func(name, model string) {
+			provider, answeredModel = name, model
+		},
+		// 思考过程只保存，不播报
+		OnReasoning: func(content string) {
+			reasoning = content
+		},
+	}
+	// 注入了成员的事实或记忆时回答因人而异，不使用缓存
+	if !personal {
+		options.CacheQuery = text
+	}
+	if eas.config.OpenAI.EnableTools {
+		options.Tools = eas.toolRegistry.GetTools()
+		options.OnToolCall = func(record openai.ToolCallRecord) {
+			toolCalls = append(toolCalls, record)
 		}
-		return
 	}
 
-	// 调用OpenAI获取回复
-	ctx := context.Background()
-	response, err := eas.openaiService.Chat(ctx, openai.ChatOptions{
-		User: text,
-	})
+	// 配置了深度思考提示语时使用流式对话，模型开始思考时就能播报提示，携带工具时仍为普通对话
+	chat := eas.openaiService.Chat
+	if cue := utils.PickRandom(eas.config.Speaker.OnAIThinking); strings.TrimSpace(cue) != "" {
+		options.OnThinking = func() {
+			eas.say(cue)
+		}
+		chat = eas.openaiService.ChatStream
+	}
+	response, err := chat(ctx, options)
 	if err != nil {
 		logger.Errorf("获取AI回复失败: %v", err)
-		
-		// 根据错误类型提供不同的提示
-		var userResponse string
-		errorStr := err.Error()
-		if strings.Contains(errorStr, "unsupported protocol scheme") {
-			userResponse = "AI服务配置不完整，请在Web管理面板中配置API密钥和服务地址。"
-		} else if strings.Contains(errorStr, "401") || strings.Contains(errorStr, "403") {
-			userResponse = "AI服务认证失败，请检查API密钥是否正确。"
-		} else if strings.Contains(errorStr, "timeout") || strings.Contains(errorStr, "connection") {
-			userResponse = "AI服务连接超时，请检查网络连接或代理设置。"
+		saveToolInvocations(text, nil, toolCalls)
+		result.Answer = eas.aiErrorReply(err)
+		result.Error = err.Error()
+		eas.say(result.Answer)
+		return result
+	}
+
+	// 工具已完成操作且模型没有额外回复时不再播报
+	var messageID *int
+	if response != "" {
+		if message, err := eas.conversation.SaveBotMessage(response, reasoning, provider, answeredModel); err != nil {
+			logger.Warnf("保存机器人消息失败: %v", err)
 		} else {
-			userResponse = "抱歉，我现在无法回答您的问题。请稍后再试。"
+			messageID = &message.ID
+			remembered = append(remembered, message)
 		}
-		
-		// 发送错误提示
-		err = eas.xiaomiService.Say(userResponse)
-		if err != nil {
-			logger.Errorf("发送错误提示失败: %v", err)
-		}
-		return
+	}
+	saveToolInvocations(text, messageID, toolCalls)
+
+	result.Answer = response
+	eas.say(response)
+	if eas.conversation.IsKeepAlive() {
+		eas.say(utils.PickRandom(eas.config.Speaker.OnAIReplied))
+	}
+	return result
+}
+
+// checkBudget 检查AI费用预算，超出时返回改用的便宜模型，未配置便宜模型时返回拒绝的回复
+func (eas *EnhancedAISpeaker) checkBudget() (string, string) {
+	cfg := eas.config.OpenAI
+	if cfg.DailyBudget <= 0 && cfg.MonthlyBudget <= 0 {
+		return "", ""
+	}
+	db := database.GetDB()
+	if db == nil {
+		return "", ""
 	}
 
-	// 发送回复到小爱音箱
-	err = eas.xiaomiService.Say(response)
+	status, err := usage.CheckBudget(db, cfg, time.Now())
 	if err != nil {
-		logger.Errorf("发送回复失败: %v", err)
+		logger.Warnf("检查费用预算失败: %v", err)
+		return "", ""
+	}
+	if status.Exceeded == "" {
+		return "", ""
+	}
+
+	budget := "今日"
+	if status.Exceeded == "monthly" {
+		budget = "本月"
+	}
+	if cfg.BudgetModel != "" {
+		logger.Warnf("💰 已超出%s费用预算，改用模型 %s", budget, cfg.BudgetModel)
+		return cfg.BudgetModel, ""
+	}
+	logger.Warnf("💰 已超出%s费用预算，暂停AI回答", budget)
+	if reply := utils.PickRandom(eas.config.Speaker.OnBudgetExceeded); reply != "" {
+		return "", reply
 	}
+	return "", "AI额度已经用完了，请稍后再试。"
+}
+
+// aiErrorReply 根据错误类型生成播报给用户的提示
+func (eas *EnhancedAISpeaker) aiErrorReply(err error) string {
+	errorStr := err.Error()
+	switch {
+	case strings.Contains(errorStr, "unsupported protocol scheme"):
+		return "AI服务配置不完整，请在Web管理面板中配置API密钥和服务地址。"
+	case strings.Contains(errorStr, "401") || strings.Contains(errorStr, "403"):
+		return "AI服务认证失败，请检查API密钥是否正确。"
+	case strings.Contains(errorStr, "timeout") || strings.Contains(errorStr, "connection"):
+		return "AI服务连接超时，请检查网络连接或代理设置。"
+	}
+	if reply := utils.PickRandom(eas.config.Speaker.OnAIError); reply != "" {
+		return reply
+	}
+	return "抱歉，我现在无法回答您的问题。请稍后再试。"
+}
+
+// say 通过音箱播报文本，空文本直接忽略
+func (eas *EnhancedAISpeaker) say(text string) {
+	if err := eas.speak(text); err != nil {
+		logger.Errorf("音箱播报失败: %v", err)
+	}
+}
+
+// speak 按当前说话人的播报设置处理文本后播报，返回播报错误
+func (eas *EnhancedAISpeaker) speak(text string) error {
+	// 保存的消息保留原文，只有播报的文本去掉格式
+	text = speech.Normalize(text, eas.conversation.SpeechOptions())
+	if text == "" {
+		return nil
+	}
+	return eas.xiaomiService.Say(text)
+}
+
+// GetConversation 获取对话引擎
+func (eas *EnhancedAISpeaker) GetConversation() *Conversation {
+	return eas.conversation
+}
+
+// GetCommandRouter 获取命令路由器
+func (eas *EnhancedAISpeaker) GetCommandRouter() *CommandRouter {
+	return eas.commandRouter
 }
 
 // GetStatus 获取音箱状态
@@ -203,11 +461,16 @@ func (eas *EnhancedAISpeaker) GetStatus() map[string]interface{} {
 		status["xiaomiHealthy"] = eas.xiaomiService.IsHealthy()
 	}
 	
+	status["keepAlive"] = eas.conversation.IsKeepAlive()
+	status["session"] = eas.conversation.CurrentSession()
+	status["botName"] = eas.conversation.BotName()
+
 	// 获取AI服务状态
 	if eas.openaiService != nil {
 		status["aiService"] = "configured"
-		status["aiProvider"] = eas.config.OpenAI.Provider
-		status["aiModel"] = eas.config.OpenAI.Model
+		status["aiProvider"] = eas.config.OpenAI.Profile
+		status["aiModel"] = eas.config.OpenAI.PrimaryModel()
+		status["aiProviders"] = eas.openaiService.ProviderStatus()
 	} else {
 		status["aiService"] = "not_configured"
 	}
@@ -215,23 +478,16 @@ func (eas *EnhancedAISpeaker) GetStatus() map[string]interface{} {
 	return status
 }
 
-// ExecuteCommand 执行命令
-func (eas *EnhancedAISpeaker) ExecuteCommand(ctx context.Context, command string) error {
+// ExecuteCommand 执行命令，与音箱收到的对话走同一套处理流程
+func (eas *EnhancedAISpeaker) ExecuteCommand(ctx context.Context, command string) (MessageResult, error) {
 	if !eas.IsRunning() {
-		return fmt.Errorf("音箱服务未运行")
+		return MessageResult{}, fmt.Errorf("音箱服务未运行")
 	}
 	
 	logger.Infof("🎯 执行命令: %s", command)
 	
-	// 检查AI服务
-	if eas.openaiService == nil {
-		logger.Warn("AI服务未配置，直接播放TTS")
-		return eas.xiaomiService.Say(command)
-	}
-	
 	// 通过消息处理器处理命令
-	eas.handleMessage(command)
-	return nil
+	return eas.handleMessage(command), nil
 }
 
 // Restart 重启服务
@@ -0,0 +1,105 @@
+package speaker
+
+import (
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/database/databasetest"
+	"mi-gpt-go/internal/services/cache"
+	"mi-gpt-go/internal/services/miservice"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/pkg/logger"
+	"testing"
+)
+
+// fakeMiService 只记录播报内容的小米服务
+type fakeMiService struct {
+	miservice.MiServiceInterface
+	said []string
+}
+
+func (f *fakeMiService) Say(text string) error {
+	f.said = append(f.said, text)
+	return nil
+}
+
+// newTestSpeaker 使用临时数据库和默认的召唤关键词创建音箱，未配置AI服务
+func newTestSpeaker(t *testing.T) (*EnhancedAISpeaker, *fakeMiService) {
+	t.Helper()
+	databasetest.Open(t)
+	cfg := &config.Config{}
+	cfg.Bot.Name = "傻妞"
+	cfg.Bot.Master.Name = "主人"
+	cfg.Bot.Room.Name = "客厅"
+	cfg.Speaker.CallAIKeywords = []string{"请", "你", "傻妞"}
+	cfg.Speaker.WakeUpKeywords = []string{"召唤"}
+	cfg.Speaker.ExitKeywords = []string{"退出"}
+
+	conversation := NewConversation(cfg, nil)
+	if err := conversation.Init(); err != nil {
+		t.Fatalf("初始化对话失败: %v", err)
+	}
+	mi := &fakeMiService{}
+	return &EnhancedAISpeaker{
+		config:        cfg,
+		conversation:  conversation,
+		commandRouter: NewCommandRouter(NewCommandRegistry()),
+		timers:        NewTimerManager(),
+		xiaomiService: mi,
+	}, mi
+}
+
+func TestHandleMessageLeavesNativeUtterancesToXiaoAi(t *testing.T) {
+	logger.Init()
+	cfg := &config.Config{}
+	cfg.Speaker.CallAIKeywords = []string{"请", "傻妞"}
+	cfg.Speaker.WakeUpKeywords = []string{"召唤"}
+	cfg.Speaker.ExitKeywords = []string{"退出"}
+
+	router := NewCommandRouter(NewCommandRegistry())
+	eas := &EnhancedAISpeaker{
+		config:        cfg,
+		conversation:  NewConversation(cfg, nil),
+		commandRouter: router,
+		timers:        NewTimerManager(),
+	}
+
+	for _, text := range []string{"现在几点了", "讲个笑话", "1加1等于几"} {
+		if result := eas.handleMessage(text); result.Route != MessageRouteIgnored {
+			t.Errorf("%q 没有召唤关键词，期望交给小爱处理，实际路由到 %s", text, result.Route)
+		}
+	}
+	if decisions := router.GetRecentDecisions(0); len(decisions) != 0 {
+		t.Errorf("没有召唤关键词的消息不应经过命令路由，实际记录了 %d 条路由决策", len(decisions))
+	}
+}
+
+func TestPinnedFactsBypassAndClearAnswerCache(t *testing.T) {
+	databasetest.Open(t)
+
+	cfg := &config.Config{}
+	cfg.Bot.Name = "傻妞"
+	cfg.Bot.Master.Name = "主人"
+	cfg.Bot.Room.Name = "客厅"
+	cfg.OpenAI.EnableCache = true
+	conversation := NewConversation(cfg, nil)
+	if err := conversation.Init(); err != nil {
+		t.Fatalf("初始化对话失败: %v", err)
+	}
+
+	if _, personal := conversation.SystemPrompt("晚饭吃什么"); personal {
+		t.Errorf("没有事实和记忆时不应视为因人而异的回答")
+	}
+
+	key := openai.CacheKey{Query: "晚饭吃什么", Model: "gpt", OwnerID: conversation.MasterID(), RoomID: conversation.RoomID()}
+	store := cache.NewStore(cfg)
+	store.Put(key, "来份花生拌面")
+
+	if err := conversation.RememberFact("我对花生过敏"); err != nil {
+		t.Fatalf("记住事实失败: %v", err)
+	}
+	if _, ok := store.Get(key); ok {
+		t.Errorf("记住事实后应清除该成员的缓存回答")
+	}
+	if _, personal := conversation.SystemPrompt("晚饭吃什么"); !personal {
+		t.Errorf("注入了事实时应跳过回答缓存")
+	}
+}
@@ -0,0 +1,150 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/services/miservice"
+	"mi-gpt-go/pkg/logger"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	rememberFactPattern = regexp.MustCompile(`^(?:请|麻烦)?你?(?:帮我)?记住[:：,，]?\s*(.+)$`)
+	// forgetFactPattern 要求带有"刚才""我说的""关于"等指代，避免"忘记带钥匙了怎么办"被当作遗忘指令
+	forgetFactPattern  = regexp.MustCompile(`^(?:请|麻烦)?你?(?:帮我)?(?:忘掉|忘记)(.*(?:刚才|刚刚|方才|之前|上次|说的|说过|讲的|讲过|告诉你|关于).*)$`)
+	recallFactsPattern = regexp.MustCompile(`(?:^|你).{0,2}记得.{0,4}我.{0,4}(?:什么|哪些|啥)`)
+
+	// forgetRecentPattern "刚才说的"等指代最近消息的说法
+	forgetRecentPattern = regexp.MustCompile(`刚才|刚刚|方才`)
+	// forgetFillerPattern / forgetSuffixPattern 遗忘内容中与关键词无关的修饰
+	forgetFillerPattern = regexp.MustCompile(`^(?:我|你)?(?:刚才|刚刚|方才|之前|上次)?(?:跟你|和你|对你)?(?:说|讲|告诉你)?(?:过)?(?:的)?(?:关于)?(?:那个|这个)?`)
+	forgetSuffixPattern = regexp.MustCompile(`(?:的)?(?:那件事|事情|事|内容|信息)$`)
+	// questionPattern 以疑问词或问号结尾的句子，例如"记住单词的方法有哪些""你记住了吗"
+	questionPattern = regexp.MustCompile(`(?:[？?]|吗|呢|嘛|怎么办|怎么样|怎么|如何|什么|啥|哪些|哪个|哪里|多少|为什么|是不是|有没有|对不对|好不好)[。！!，,\s]*$`)
+)
+
+// minFactRunes 记住的事实最少的字数，过短的内容多半是误识别
+const minFactRunes = 2
+
+// factTrailingChars 事实和关键词末尾需要去掉的语气词和标点
+const factTrailingChars = "。！!？?，,吧了啊哦呀 "
+
+// RememberFactCommand 记住事实命令：记住[内容]
+type RememberFactCommand struct{}
+
+func (r *RememberFactCommand) GetName() string { return "记住事实" }
+func (r *RememberFactCommand) GetDescription() string {
+	return "记住主人明确要求记住的事情"
+}
+func (r *RememberFactCommand) GetPatterns() []string { return []string{rememberFactPattern.String()} }
+func (r *RememberFactCommand) GetPriority() int      { return CommandPriorityHigh }
+func (r *RememberFactCommand) IsConsuming() bool     { return true }
+
+// Accept 只处理明确要求记住的陈述，疑问句和过短的内容交给AI
+func (r *RememberFactCommand) Accept(text string) bool {
+	_, ok := parseRememberFact(text)
+	return ok
+}
+
+func (r *RememberFactCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	fact, ok := parseRememberFact(msg.Text)
+	if !ok {
+		return SpeakerAnswer{Text: "要我记住什么呢？"}, nil
+	}
+
+	if err := speaker.conversation.RememberFact(fact); err != nil {
+		logger.Errorf("记住事实失败: %v", err)
+		return SpeakerAnswer{Text: "没记住，请稍后再试"}, nil
+	}
+
+	logger.Infof("📌 已记住: %s", fact)
+	return SpeakerAnswer{Text: fmt.Sprintf("好的，我记住了：%s", fact)}, nil
+}
+
+// ForgetFactCommand 遗忘命令：忘掉[内容]
+type ForgetFactCommand struct{}
+
+func (f *ForgetFactCommand) GetName() string { return "遗忘" }
+func (f *ForgetFactCommand) GetDescription() string {
+	return "删除记住的事实并抹除相关的对话消息"
+}
+func (f *ForgetFactCommand) GetPatterns() []string { return []string{forgetFactPattern.String()} }
+func (f *ForgetFactCommand) GetPriority() int      { return CommandPriorityHigh }
+func (f *ForgetFactCommand) IsConsuming() bool     { return true }
+
+// Accept 疑问句不作为遗忘指令，例如"忘记我之前说的密码了怎么办"
+func (f *ForgetFactCommand) Accept(text string) bool {
+	return !questionPattern.MatchString(strings.TrimSpace(text))
+}
+
+func (f *ForgetFactCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	matches := forgetFactPattern.FindStringSubmatch(strings.TrimSpace(msg.Text))
+	if len(matches) < 2 {
+		return SpeakerAnswer{Text: "要我忘掉什么呢？"}, nil
+	}
+	keyword := forgetKeyword(matches[1])
+	if keyword == "" {
+		return SpeakerAnswer{Text: "要我忘掉什么呢？"}, nil
+	}
+
+	result, err := speaker.conversation.ForgetFacts(keyword, forgetRecentPattern.MatchString(matches[1]))
+	if err != nil {
+		logger.Errorf("遗忘失败: %v", err)
+		return SpeakerAnswer{Text: "操作失败，请稍后再试"}, nil
+	}
+	if result.Facts == 0 && result.Messages == 0 && result.Summaries == 0 {
+		return SpeakerAnswer{Text: fmt.Sprintf("我没有找到关于%s的记忆", keyword)}, nil
+	}
+
+	logger.Infof("🗑️ 已遗忘「%s」: %d 条事实, %d 条消息, %d 条记忆摘要", keyword, result.Facts, result.Messages, result.Summaries)
+	return SpeakerAnswer{Text: fmt.Sprintf("好的，关于%s的内容我已经忘掉了", keyword)}, nil
+}
+
+// RecallFactsCommand 回顾事实命令：你记得我什么
+type RecallFactsCommand struct{}
+
+func (r *RecallFactsCommand) GetName() string        { return "回顾事实" }
+func (r *RecallFactsCommand) GetDescription() string { return "读出主人要求记住的事情" }
+func (r *RecallFactsCommand) GetPatterns() []string  { return []string{recallFactsPattern.String()} }
+func (r *RecallFactsCommand) GetPriority() int       { return CommandPriorityHigh }
+func (r *RecallFactsCommand) IsConsuming() bool      { return true }
+
+func (r *RecallFactsCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	facts := speaker.conversation.PinnedFacts()
+	if len(facts) == 0 {
+		return SpeakerAnswer{Text: "你还没有让我记住什么，可以对我说“记住”加上要记的事情"}, nil
+	}
+
+	items := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		items = append(items, fact.Text)
+	}
+	return SpeakerAnswer{Text: fmt.Sprintf("我记得%d件事：%s", len(facts), strings.Join(items, "；"))}, nil
+}
+
+// parseRememberFact 从"记住……"中提取要记住的事实，疑问句或内容过短时返回 false
+func parseRememberFact(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if questionPattern.MatchString(text) {
+		return "", false
+	}
+	matches := rememberFactPattern.FindStringSubmatch(text)
+	if len(matches) < 2 {
+		return "", false
+	}
+	fact := strings.TrimRight(strings.TrimSpace(matches[1]), factTrailingChars)
+	if utf8.RuneCountInString(fact) < minFactRunes {
+		return "", false
+	}
+	return fact, true
+}
+
+// forgetKeyword 从遗忘内容中提取关键词，例如"我刚才说的地址"提取为"地址"
+func forgetKeyword(text string) string {
+	keyword := strings.TrimSpace(text)
+	keyword = forgetFillerPattern.ReplaceAllString(keyword, "")
+	keyword = strings.TrimRight(strings.TrimSpace(keyword), factTrailingChars)
+	return forgetSuffixPattern.ReplaceAllString(keyword, "")
+}
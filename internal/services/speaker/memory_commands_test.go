@@ -0,0 +1,123 @@
+package speaker
+
+import (
+	"testing"
+)
+
+func TestMemoryCommandsIgnoreOrdinaryQuestions(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	for _, text := range []string{
+		"傻妞忘记带钥匙了怎么办",
+		"请问忘记密码怎么办",
+		"傻妞，忘记了怎么办",
+		"傻妞记住单词的方法有哪些",
+		"你记住了吗",
+		"你记住了",
+		"傻妞记住了",
+		"请你记住了吗？",
+		"傻妞忘记我之前说的密码了怎么办",
+	} {
+		if result := eas.handleMessage(text); result.Route != MessageRouteAI {
+			t.Errorf("%q 应交给AI回答，实际路由到 %s: %s", text, result.Route, result.Answer)
+		}
+	}
+	if facts := eas.conversation.PinnedFacts(); len(facts) != 0 {
+		t.Errorf("普通问句不应记住事实，实际记住了 %d 条", len(facts))
+	}
+}
+
+func TestRememberFactCommand(t *testing.T) {
+	for text, want := range map[string]string{
+		"傻妞记住我周五要去体检":     "我周五要去体检",
+		"傻妞，我的生日是五月一日记住了": "",
+		"请你帮我记住：车停在B2层":   "车停在B2层",
+		"傻妞麻烦记住，我对花生过敏。":  "我对花生过敏",
+		"你记住明天要带伞":        "明天要带伞",
+	} {
+		eas, _ := newTestSpeaker(t)
+		result := eas.handleMessage(text)
+		facts := eas.conversation.PinnedFacts()
+		if want == "" {
+			if result.Route == MessageRouteCommand || len(facts) != 0 {
+				t.Errorf("%q 不是记住指令，实际路由到 %s", text, result.Route)
+			}
+			continue
+		}
+		if result.Route != MessageRouteCommand {
+			t.Errorf("%q 应由记住事实命令处理，实际路由到 %s: %s", text, result.Route, result.Answer)
+			continue
+		}
+		if len(facts) != 1 || facts[0].Text != want {
+			t.Errorf("%q 记住的事实 = %v, 期望 %q", text, facts, want)
+		}
+	}
+}
+
+func TestRememberFactRequiresCallKeyword(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	if result := eas.handleMessage("记住我周五要去体检"); result.Route != MessageRouteIgnored {
+		t.Errorf("没有召唤关键词时应交给小爱处理，实际路由到 %s", result.Route)
+	}
+
+	eas.conversation.SetKeepAlive(true)
+	if result := eas.handleMessage("记住我周五要去体检"); result.Route != MessageRouteCommand {
+		t.Errorf("连续对话中应由记住事实命令处理，实际路由到 %s: %s", result.Route, result.Answer)
+	}
+	if facts := eas.conversation.PinnedFacts(); len(facts) != 1 {
+		t.Errorf("期望记住1条事实，实际 %d 条", len(facts))
+	}
+}
+
+func TestForgetFactCommand(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	for _, text := range []string{"傻妞记住我家地址是幸福路1号", "傻妞记住我对花生过敏"} {
+		if result := eas.handleMessage(text); result.Route != MessageRouteCommand {
+			t.Fatalf("%q 应由记住事实命令处理，实际路由到 %s", text, result.Route)
+		}
+	}
+
+	result := eas.handleMessage("傻妞，忘掉我刚才说的地址")
+	if result.Route != MessageRouteCommand {
+		t.Fatalf("应由遗忘命令处理，实际路由到 %s: %s", result.Route, result.Answer)
+	}
+	facts := eas.conversation.PinnedFacts()
+	if len(facts) != 1 || facts[0].Text != "我对花生过敏" {
+		t.Errorf("遗忘后剩余事实 %v, 期望只剩花生过敏", facts)
+	}
+	if result.Answer != "好的，关于地址的内容我已经忘掉了" {
+		t.Errorf("遗忘后的回复 = %q", result.Answer)
+	}
+}
+
+func TestForgetKeyword(t *testing.T) {
+	for text, want := range map[string]string{
+		"忘掉我刚才说的地址":     "地址",
+		"请忘记关于我生日的事":    "我生日",
+		"忘掉我之前告诉你的密码":   "密码",
+		"帮我忘记我说的那个电话号码": "电话号码",
+	} {
+		matches := forgetFactPattern.FindStringSubmatch(text)
+		if len(matches) < 2 {
+			t.Errorf("%q 应匹配遗忘命令", text)
+			continue
+		}
+		if keyword := forgetKeyword(matches[1]); keyword != want {
+			t.Errorf("forgetKeyword(%q) = %q, 期望 %q", text, keyword, want)
+		}
+	}
+}
+
+func TestRecallFactsCommand(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	eas.handleMessage("傻妞记住我对花生过敏")
+	for _, text := range []string{"你记得我什么", "傻妞你还记得我哪些事"} {
+		result := eas.handleMessage(text)
+		if result.Route != MessageRouteCommand {
+			t.Errorf("%q 应由回顾事实命令处理，实际路由到 %s", text, result.Route)
+			continue
+		}
+		if result.Answer != "我记得1件事：我对花生过敏" {
+			t.Errorf("%q 的回复 = %q", text, result.Answer)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/services/miservice"
+	"mi-gpt-go/pkg/logger"
+	"regexp"
+	"strings"
+)
+
+var (
+	// botPersonaPattern "你是小红，你是个可爱的女孩"，开头的"你"可能已作为召唤关键词去掉
+	botPersonaPattern = regexp.MustCompile(`^你?是([^你，,。！!？?\s]{1,10})[，,。\s]*你(.+)$`)
+	// masterProfilePattern "我是小明，我喜欢踢球"，只匹配以"我是"开头的陈述
+	masterProfilePattern = regexp.MustCompile(`^我是([^我，,。！!？?\s]{1,8})[，,。\s]*我(.+)$`)
+	// identifyPattern 单独的"我是小明"，排除"我是不是""我是谁"等问句
+	identifyPattern = regexp.MustCompile(`^我是([^我不谁哪什怎，,。！!？?\s][^我，,。！!？?\s]{0,7})[。！!]?$`)
+	whoAmIPattern   = regexp.MustCompile(`^我是谁[？?呀啊]*$`)
+	// personaQuestionPattern 姓名中出现这些字时多半是问句，例如"你是不是觉得…""你是谁你知道吗"
+	personaQuestionPattern = regexp.MustCompile(`不|没|谁|什么|啥|哪|怎|吗|呢`)
+)
+
+// BotPersonaCommand 人设切换命令：你是[姓名]，你[描述]
+type BotPersonaCommand struct{}
+
+func (b *BotPersonaCommand) GetName() string        { return "人设切换" }
+func (b *BotPersonaCommand) GetDescription() string { return "修改机器人的名称和人设" }
+func (b *BotPersonaCommand) GetPatterns() []string  { return []string{botPersonaPattern.String()} }
+func (b *BotPersonaCommand) GetPriority() int       { return CommandPriorityHigh }
+func (b *BotPersonaCommand) IsConsuming() bool      { return true }
+
+// Accept 只处理明确的人设陈述，问句交给AI
+func (b *BotPersonaCommand) Accept(text string) bool {
+	return acceptPersona(botPersonaPattern, text)
+}
+
+func (b *BotPersonaCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	name, profile, ok := parsePersona(botPersonaPattern, msg.Text)
+	if !ok {
+		return SpeakerAnswer{Text: "姓名和描述都不能为空"}, nil
+	}
+
+	if err := speaker.conversation.UpdateBot(name, profile); err != nil {
+		logger.Errorf("%v", err)
+		return SpeakerAnswer{Text: "更新失败，请稍后再试"}, nil
+	}
+
+	logger.Infof("机器人人设已更新 - 姓名: %s, 描述: %s", name, profile)
+	return SpeakerAnswer{Text: fmt.Sprintf("好的，我现在是%s了！%s", name, profile)}, nil
+}
+
+// MasterProfileCommand 成员信息命令：我是[姓名]，我[描述]
+// 切换为该家庭成员并更新其介绍，只更新已有的成员，新成员需要先说"我是[姓名]"
+type MasterProfileCommand struct{}
+
+func (m *MasterProfileCommand) GetName() string        { return "主人信息" }
+func (m *MasterProfileCommand) GetDescription() string { return "切换家庭成员并更新其介绍" }
+func (m *MasterProfileCommand) GetPatterns() []string  { return []string{masterProfilePattern.String()} }
+func (m *MasterProfileCommand) GetPriority() int       { return CommandPriorityHigh }
+func (m *MasterProfileCommand) IsConsuming() bool      { return true }
+
+// Accept 只处理明确的自我介绍，问句交给AI
+func (m *MasterProfileCommand) Accept(text string) bool {
+	return acceptPersona(masterProfilePattern, text)
+}
+
+func (m *MasterProfileCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	name, profile, ok := parsePersona(masterProfilePattern, msg.Text)
+	if !ok {
+		return SpeakerAnswer{Text: "姓名和描述都不能为空"}, nil
+	}
+
+	known, err := speaker.conversation.IsMember(name)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return SpeakerAnswer{Text: "更新失败，请稍后再试"}, nil
+	}
+	if !known {
+		return SpeakerAnswer{Text: fmt.Sprintf("我还不认识%s，请先对我说\"我是%s\"", name, name)}, nil
+	}
+
+	user, _, err := speaker.conversation.SwitchUser(name, profile)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return SpeakerAnswer{Text: "更新失败，请稍后再试"}, nil
+	}
+
+	logger.Infof("👤 成员信息已更新 - 姓名: %s, 描述: %s", user.Name, profile)
+	return SpeakerAnswer{Text: fmt.Sprintf("知道了，%s！我已经记住你的信息了：%s", user.Name, profile)}, nil
+}
+
+// IdentifyCommand 身份切换命令：我是[姓名]
+// 之后的消息和记忆都归属于该家庭成员，空闲超过 bot.identityTimeout 后恢复为主人
+type IdentifyCommand struct{}
+
+func (i *IdentifyCommand) GetName() string        { return "身份切换" }
+func (i *IdentifyCommand) GetDescription() string { return "切换当前说话的家庭成员" }
+func (i *IdentifyCommand) GetPatterns() []string  { return []string{identifyPattern.String()} }
+func (i *IdentifyCommand) GetPriority() int       { return CommandPriorityHigh }
+func (i *IdentifyCommand) IsConsuming() bool      { return true }
+
+func (i *IdentifyCommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	matches := identifyPattern.FindStringSubmatch(strings.TrimSpace(msg.Text))
+	if len(matches) < 2 {
+		return SpeakerAnswer{Text: "你是谁呢？"}, nil
+	}
+
+	user, created, err := speaker.conversation.SwitchUser(matches[1], "")
+	if err != nil {
+		logger.Errorf("%v", err)
+		return SpeakerAnswer{Text: "切换失败，请稍后再试"}, nil
+	}
+
+	logger.Infof("👤 当前说话人: %s", user.Name)
+	if created {
+		return SpeakerAnswer{Text: fmt.Sprintf("你好%s，很高兴认识你！", user.Name)}, nil
+	}
+	return SpeakerAnswer{Text: fmt.Sprintf("你好%s！", user.Name)}, nil
+}
+
+// WhoAmICommand 身份查询命令：我是谁
+type WhoAmICommand struct{}
+
+func (w *WhoAmICommand) GetName() string        { return "身份查询" }
+func (w *WhoAmICommand) GetDescription() string { return "说出当前说话的家庭成员" }
+func (w *WhoAmICommand) GetPatterns() []string  { return []string{whoAmIPattern.String()} }
+func (w *WhoAmICommand) GetPriority() int       { return CommandPriorityHigh }
+func (w *WhoAmICommand) IsConsuming() bool      { return true }
+
+func (w *WhoAmICommand) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	user := speaker.conversation.CurrentUser()
+	if user.Profile != "" {
+		return SpeakerAnswer{Text: fmt.Sprintf("你是%s，%s", user.Name, user.Profile)}, nil
+	}
+	return SpeakerAnswer{Text: fmt.Sprintf("你是%s", user.Name)}, nil
+}
+
+// acceptPersona 姓名和描述都不为空，且不是问句
+func acceptPersona(pattern *regexp.Regexp, text string) bool {
+	name, _, ok := parsePersona(pattern, text)
+	return ok && !personaQuestionPattern.MatchString(name) && !questionPattern.MatchString(strings.TrimSpace(text))
+}
+
+// parsePersona 提取姓名和描述
+func parsePersona(pattern *regexp.Regexp, text string) (string, string, bool) {
+	matches := pattern.FindStringSubmatch(text)
+	if len(matches) < 3 {
+		return "", "", false
+	}
+	name := strings.TrimSpace(matches[1])
+	profile := strings.TrimSpace(matches[2])
+	return name, profile, name != "" && profile != ""
+}
@@ -0,0 +1,104 @@
+package speaker
+
+import (
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"testing"
+)
+
+func TestBotPersonaCommand(t *testing.T) {
+	for _, text := range []string{
+		"傻妞，你是小红，你是个可爱的女孩",
+		"你是小红你是个可爱的女孩",
+	} {
+		eas, _ := newTestSpeaker(t)
+		result := eas.handleMessage(text)
+		if result.Route != MessageRouteCommand {
+			t.Errorf("%q 应由人设切换命令处理，实际路由到 %s: %s", text, result.Route, result.Answer)
+			continue
+		}
+		if name := eas.conversation.BotName(); name != "小红" {
+			t.Errorf("%q 后机器人名称 = %q, 期望 小红", text, name)
+		}
+	}
+}
+
+func TestBotPersonaCommandIgnoresQuestions(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	for _, text := range []string{
+		"你是不是觉得你很聪明",
+		"你是谁你知道吗",
+		"傻妞你是什么你能做什么",
+		"你是小红你喜欢吃什么",
+	} {
+		if result := eas.handleMessage(text); result.Route != MessageRouteAI {
+			t.Errorf("%q 应交给AI回答，实际路由到 %s: %s", text, result.Route, result.Answer)
+		}
+	}
+	if name := eas.conversation.BotName(); name != "傻妞" {
+		t.Errorf("问句不应修改机器人名称，实际为 %q", name)
+	}
+}
+
+// memberCount 该名称的家庭成员数量
+func memberCount(t *testing.T, name string) int64 {
+	t.Helper()
+	var count int64
+	if err := database.GetDB().Model(&models.User{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestIdentifyCommand(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	if result := eas.handleMessage("我是小明"); result.Route != MessageRouteIgnored {
+		t.Errorf("没有召唤关键词时应交给小爱处理，实际路由到 %s", result.Route)
+	}
+
+	result := eas.handleMessage("傻妞，我是小明")
+	if result.Route != MessageRouteCommand {
+		t.Fatalf("应由身份切换命令处理，实际路由到 %s: %s", result.Route, result.Answer)
+	}
+	if user := eas.conversation.CurrentUser(); user.Name != "小明" {
+		t.Errorf("当前说话人 = %q, 期望 小明", user.Name)
+	}
+}
+
+func TestMasterProfileCommandIgnoresQuestions(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	for _, text := range []string{
+		"你说我是不是胖了我最近吃太多",
+		"傻妞我是不是胖了我最近吃太多",
+		"傻妞，我是谁我怎么想不起来了",
+	} {
+		if result := eas.handleMessage(text); result.Route != MessageRouteAI {
+			t.Errorf("%q 应交给AI回答，实际路由到 %s: %s", text, result.Route, result.Answer)
+		}
+	}
+	for _, name := range []string{"不是胖了", "是不是胖了", "谁"} {
+		if memberCount(t, name) != 0 {
+			t.Errorf("问句不应创建家庭成员 %q", name)
+		}
+	}
+	if user := eas.conversation.CurrentUser(); user.Name != "主人" {
+		t.Errorf("问句不应切换说话人，当前为 %q", user.Name)
+	}
+}
+
+func TestMasterProfileCommandRequiresKnownMember(t *testing.T) {
+	eas, _ := newTestSpeaker(t)
+	result := eas.handleMessage("傻妞，我是小刚，我喜欢踢球")
+	if result.Route != MessageRouteCommand {
+		t.Fatalf("应由成员信息命令处理，实际路由到 %s: %s", result.Route, result.Answer)
+	}
+	if memberCount(t, "小刚") != 0 {
+		t.Fatalf("不应根据自我介绍创建新成员")
+	}
+
+	eas.handleMessage("傻妞，我是小刚")
+	eas.handleMessage("傻妞，我是小刚，我喜欢踢球")
+	if user := eas.conversation.CurrentUser(); user.Name != "小刚" || user.Profile != "喜欢踢球" {
+		t.Errorf("当前说话人 = %s（%s），期望 小刚（喜欢踢球）", user.Name, user.Profile)
+	}
+}
@@ -0,0 +1,153 @@
+package speaker
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/miservice"
+	"mi-gpt-go/internal/services/plugin"
+	"mi-gpt-go/pkg/logger"
+	"time"
+)
+
+// PluginCommandHandler 将外部插件适配为命令处理器
+type PluginCommandHandler struct {
+	process     *plugin.Process
+	description plugin.Description
+}
+
+// NewPluginCommandHandler 根据已就绪的插件创建处理器
+func NewPluginCommandHandler(process *plugin.Process) (*PluginCommandHandler, error) {
+	description := process.Description()
+	if description == nil {
+		return nil, fmt.Errorf("插件 %s 尚未就绪", process.Name())
+	}
+	return &PluginCommandHandler{
+		process:     process,
+		description: *description,
+	}, nil
+}
+
+func (h *PluginCommandHandler) GetName() string        { return h.description.Name }
+func (h *PluginCommandHandler) GetDescription() string { return h.description.Description }
+func (h *PluginCommandHandler) GetPatterns() []string  { return h.description.Patterns }
+func (h *PluginCommandHandler) IsConsuming() bool      { return h.description.Consuming }
+
+func (h *PluginCommandHandler) GetPriority() int {
+	if h.description.Priority == 0 {
+		return CommandPriorityNormal
+	}
+	return h.description.Priority
+}
+
+// Handle 将查询发送给插件，并执行插件返回的动作
+func (h *PluginCommandHandler) Handle(ctx context.Context, msg miservice.QueryMessage, speaker *EnhancedAISpeaker) (SpeakerAnswer, error) {
+	resp, err := h.process.Query(ctx, plugin.Query{
+		Text:      msg.Text,
+		Timestamp: msg.Timestamp,
+	})
+	if err != nil {
+		return SpeakerAnswer{}, err
+	}
+
+	now := time.Now()
+	vars := map[string]string{
+		"query": msg.Text,
+		"time":  now.Format("15:04"),
+		"date":  now.Format("2006-01-02"),
+	}
+	for i, pluginAction := range resp.Actions {
+		action := models.CustomCommandAction{
+			Type:   pluginAction.Type,
+			Text:   pluginAction.Text,
+			URL:    pluginAction.URL,
+			Volume: pluginAction.Volume,
+			Method: pluginAction.Method,
+			Body:   pluginAction.Body,
+		}
+		if err := validateCustomAction(action); err != nil {
+			logger.Errorf("插件 %s 第%d个动作无效: %v", h.description.Name, i+1, err)
+			continue
+		}
+		if err := runCommandAction(ctx, action, vars, speaker); err != nil {
+			logger.Errorf("插件 %s 第%d个动作 (%s) 执行失败: %v", h.description.Name, i+1, action.Type, err)
+		}
+	}
+
+	var answer SpeakerAnswer
+	if resp.Answer != nil {
+		answer.Text = resp.Answer.Text
+		answer.KeepAlive = resp.Answer.KeepAlive
+	}
+	return answer, nil
+}
+
+// startPlugins 启动配置中的外部插件
+func (eas *EnhancedAISpeaker) startPlugins() {
+	if len(eas.config.Plugins) == 0 {
+		return
+	}
+	eas.pluginManager = plugin.NewManager(eas.config.Plugins, eas.reloadPluginCommands)
+	eas.pluginManager.Start()
+}
+
+// stopPlugins 停止外部插件并注销其命令
+func (eas *EnhancedAISpeaker) stopPlugins() {
+	if eas.pluginManager == nil {
+		return
+	}
+	eas.pluginManager.Stop()
+	eas.pluginManager = nil
+	eas.commandRouter.Registry().ReplaceWhere(isPluginHandler, nil)
+}
+
+// reloadPluginCommands 将已就绪插件的命令同步到命令路由器
+func (eas *EnhancedAISpeaker) reloadPluginCommands() {
+	eas.mutex.RLock()
+	manager := eas.pluginManager
+	eas.mutex.RUnlock()
+	if manager == nil {
+		return
+	}
+
+	registry := eas.commandRouter.Registry()
+	taken := make(map[string]bool)
+	for _, handler := range registry.GetAllHandlers() {
+		if !isPluginHandler(handler) {
+			taken[handler.GetName()] = true
+		}
+	}
+
+	var handlers []CommandHandler
+	for _, process := range manager.GetProcesses() {
+		handler, err := NewPluginCommandHandler(process)
+		if err != nil {
+			continue
+		}
+		if taken[handler.GetName()] {
+			logger.Warnf("插件命令 %s 与已有命令重名，已跳过", handler.GetName())
+			continue
+		}
+		taken[handler.GetName()] = true
+		handlers = append(handlers, handler)
+	}
+
+	registry.ReplaceWhere(isPluginHandler, handlers)
+	logger.Infof("已加载插件命令: %d 个", len(handlers))
+}
+
+// GetPluginStatus 获取插件状态
+func (eas *EnhancedAISpeaker) GetPluginStatus() []plugin.Status {
+	eas.mutex.RLock()
+	manager := eas.pluginManager
+	eas.mutex.RUnlock()
+	if manager == nil {
+		return []plugin.Status{}
+	}
+	return manager.GetStatus()
+}
+
+func isPluginHandler(handler CommandHandler) bool {
+	_, ok := handler.(*PluginCommandHandler)
+	return ok
+}
@@ -0,0 +1,165 @@
+package speaker
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CurrentSession 获取当前进行中的会话，没有会话或会话已空闲超时时返回空
+func (c *Conversation) CurrentSession() *models.Session {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.session == nil || c.sessionExpiredLocked() {
+		return nil
+	}
+	session := *c.session
+	return &session
+}
+
+// EndSession 结束当前会话，例如服务停止时
+func (c *Conversation) EndSession(reason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closeSessionLocked(reason)
+}
+
+// sessionTimeout 非连续对话会话的空闲超时时间，与上下文有效期一致
+func (c *Conversation) sessionTimeout() time.Duration {
+	window := c.config.Bot.ContextWindow
+	if window <= 0 {
+		window = 5
+	}
+	return time.Duration(window) * time.Minute
+}
+
+// sessionExpiredLocked 当前会话是否已空闲超时，连续对话只在退出时结束
+func (c *Conversation) sessionExpiredLocked() bool {
+	return !c.session.KeepAlive && time.Since(c.session.LastActiveAt) > c.sessionTimeout()
+}
+
+// activeSessionLocked 获取当前会话，已空闲超时的会话会被结束并返回空
+func (c *Conversation) activeSessionLocked() *models.Session {
+	if c.session != nil && c.sessionExpiredLocked() {
+		c.closeSessionLocked(models.SessionEndTimeout)
+	}
+	return c.session
+}
+
+// openSessionLocked 开始新的会话，对话实体未初始化时返回空
+func (c *Conversation) openSessionLocked() *models.Session {
+	db := database.GetDB()
+	if db == nil || c.room.ID == "" {
+		return nil
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		RoomID:       c.room.ID,
+		DeviceID:     c.config.Speaker.DeviceID,
+		KeepAlive:    c.keepAlive,
+		Model:        c.config.OpenAI.PrimaryModel(),
+		StartedAt:    now,
+		LastActiveAt: now,
+	}
+	if err := db.Create(session).Error; err != nil {
+		logger.Warnf("创建会话失败: %v", err)
+		return nil
+	}
+	c.session = session
+	logger.Debugf("会话 %d 已开始", session.ID)
+	return session
+}
+
+// closeSessionLocked 结束当前会话，空闲超时的会话以最后一次活动时间作为结束时间
+func (c *Conversation) closeSessionLocked(reason string) {
+	session := c.session
+	if session == nil {
+		return
+	}
+	c.session = nil
+
+	endedAt := time.Now()
+	if reason == models.SessionEndTimeout {
+		endedAt = session.LastActiveAt
+	}
+	if db := database.GetDB(); db != nil {
+		if err := db.Model(session).Updates(map[string]interface{}{
+			"ended_at":   endedAt,
+			"end_reason": reason,
+		}).Error; err != nil {
+			logger.Warnf("结束会话失败: %v", err)
+		}
+	}
+	logger.Debugf("会话 %d 已结束: %s", session.ID, reason)
+}
+
+// promoteSessionLocked 进入连续对话时，把进行中的会话转为连续对话，没有会话时新开一个
+func (c *Conversation) promoteSessionLocked() {
+	session := c.activeSessionLocked()
+	if session == nil {
+		c.openSessionLocked()
+		return
+	}
+	if session.KeepAlive {
+		return
+	}
+	session.KeepAlive = true
+	if db := database.GetDB(); db != nil {
+		if err := db.Model(session).Update("keep_alive", true).Error; err != nil {
+			logger.Warnf("更新会话失败: %v", err)
+		}
+	}
+}
+
+// touchSession 记录会话中的一条消息：刷新活动时间、统计提问次数、记录参与者和回答所用的模型
+// 返回消息所属的会话ID，没有可用的会话时返回空
+func (c *Conversation) touchSession(db *gorm.DB, senderID, model string) *int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session := c.activeSessionLocked()
+	if session == nil {
+		if session = c.openSessionLocked(); session == nil {
+			return nil
+		}
+	}
+
+	session.LastActiveAt = time.Now()
+	if senderID == c.bot.ID {
+		if model != "" {
+			session.Model = model
+		}
+	} else {
+		session.Turns++
+	}
+	if err := db.Model(session).Updates(map[string]interface{}{
+		"last_active_at": session.LastActiveAt,
+		"turns":          session.Turns,
+		"model":          session.Model,
+	}).Error; err != nil {
+		logger.Warnf("更新会话失败: %v", err)
+	}
+	if err := db.Exec("INSERT OR IGNORE INTO session_participants (session_id, user_id) VALUES (?, ?)",
+		session.ID, senderID).Error; err != nil {
+		logger.Warnf("记录会话参与者失败: %v", err)
+	}
+
+	id := session.ID
+	return &id
+}
+
+// closeStaleSessions 结束上次运行遗留的未结束会话
+func closeStaleSessions(db *gorm.DB) error {
+	if err := db.Model(&models.Session{}).Where("ended_at IS NULL").Updates(map[string]interface{}{
+		"ended_at":   gorm.Expr("last_active_at"),
+		"end_reason": models.SessionEndShutdown,
+	}).Error; err != nil {
+		return fmt.Errorf("结束遗留会话失败: %v", err)
+	}
+	return nil
+}
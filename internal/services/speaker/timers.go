@@ -0,0 +1,102 @@
+package speaker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimerInfo 定时器信息
+type TimerInfo struct {
+	ID      int       `json:"id"`
+	Message string    `json:"message"`
+	FireAt  time.Time `json:"fireAt"`
+}
+
+// TimerManager 定时提醒管理器
+type TimerManager struct {
+	mutex  sync.Mutex
+	timers map[int]*scheduledTimer
+	nextID int
+}
+
+type scheduledTimer struct {
+	info  TimerInfo
+	timer *time.Timer
+}
+
+// NewTimerManager 创建定时提醒管理器
+func NewTimerManager() *TimerManager {
+	return &TimerManager{
+		timers: make(map[int]*scheduledTimer),
+	}
+}
+
+// Add 添加定时提醒，到期后调用 fire
+func (tm *TimerManager) Add(delay time.Duration, message string, fire func(TimerInfo)) TimerInfo {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	tm.nextID++
+	info := TimerInfo{
+		ID:      tm.nextID,
+		Message: message,
+		FireAt:  time.Now().Add(delay),
+	}
+	tm.timers[info.ID] = &scheduledTimer{
+		info: info,
+		timer: time.AfterFunc(delay, func() {
+			tm.mutex.Lock()
+			_, ok := tm.timers[info.ID]
+			delete(tm.timers, info.ID)
+			tm.mutex.Unlock()
+
+			if ok {
+				fire(info)
+			}
+		}),
+	}
+	return info
+}
+
+// Cancel 取消指定定时提醒
+func (tm *TimerManager) Cancel(id int) bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	scheduled, ok := tm.timers[id]
+	if !ok {
+		return false
+	}
+	scheduled.timer.Stop()
+	delete(tm.timers, id)
+	return true
+}
+
+// CancelAll 取消所有定时提醒，返回取消的数量
+func (tm *TimerManager) CancelAll() int {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	count := len(tm.timers)
+	for id, scheduled := range tm.timers {
+		scheduled.timer.Stop()
+		delete(tm.timers, id)
+	}
+	return count
+}
+
+// List 列出所有未到期的定时提醒（按到期时间排序）
+func (tm *TimerManager) List() []TimerInfo {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	result := make([]TimerInfo, 0, len(tm.timers))
+	for _, scheduled := range tm.timers {
+		result = append(result, scheduled.info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FireAt.Before(result[j].FireAt)
+	})
+	return result
+}
@@ -0,0 +1,367 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/pkg/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxTimerDuration 定时提醒的最长时间
+const maxTimerDuration = 24 * time.Hour
+
+// ToolRegistry AI工具注册表
+type ToolRegistry struct {
+	mutex sync.RWMutex
+	tools []openai.Tool
+}
+
+// NewToolRegistry 创建工具注册表
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{}
+}
+
+// Register 注册工具，同名工具会被替换
+func (tr *ToolRegistry) Register(tool openai.Tool) {
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	for i, existing := range tr.tools {
+		if existing.Name == tool.Name {
+			tr.tools[i] = tool
+			return
+		}
+	}
+	tr.tools = append(tr.tools, tool)
+}
+
+// GetTools 获取所有工具
+func (tr *ToolRegistry) GetTools() []openai.Tool {
+	tr.mutex.RLock()
+	defer tr.mutex.RUnlock()
+
+	tools := make([]openai.Tool, len(tr.tools))
+	copy(tools, tr.tools)
+	return tools
+}
+
+// registerSpeakerTools 注册音箱和家居控制工具
+func (eas *EnhancedAISpeaker) registerSpeakerTools(registry *ToolRegistry) {
+	registry.Register(openai.Tool{
+		Name:        "get_current_time",
+		Description: "获取当前的日期、时间和星期",
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			now := time.Now()
+			weekdays := []string{"日", "一", "二", "三", "四", "五", "六"}
+			return fmt.Sprintf("%s 星期%s", now.Format("2006-01-02 15:04:05"), weekdays[now.Weekday()]), nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "get_volume",
+		Description: "获取音箱当前音量（0-100）",
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			volume, err := eas.xiaomiService.GetVolume(eas.config.Speaker.DeviceID)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("当前音量%d", volume), nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "set_volume",
+		Description: "设置音箱音量。volume为目标音量；delta为相对调整量，例如调小一点用-10",
+		Parameters: objectSchema(map[string]interface{}{
+			"volume": integerProperty("目标音量（0-100）"),
+			"delta":  integerProperty("相对调整量（-100到100），与volume二选一"),
+		}),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Volume *int `json:"volume"`
+				Delta  *int `json:"delta"`
+			}
+			if err := parseToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			deviceID := eas.config.Speaker.DeviceID
+			var volume int
+			switch {
+			case args.Volume != nil:
+				volume = *args.Volume
+			case args.Delta != nil:
+				current, err := eas.xiaomiService.GetVolume(deviceID)
+				if err != nil {
+					return "", fmt.Errorf("获取当前音量失败: %v", err)
+				}
+				volume = current + *args.Delta
+			default:
+				return "", fmt.Errorf("需要提供volume或delta")
+			}
+			volume = clampVolume(volume)
+
+			if err := eas.xiaomiService.SetVolume(deviceID, volume); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("音量已设置为%d", volume), nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "control_playback",
+		Description: "控制音箱的媒体播放",
+		Parameters: objectSchema(map[string]interface{}{
+			"action": enumProperty("播放控制：play继续播放，pause暂停，next下一首，previous上一首，toggle切换播放/暂停",
+				"play", "pause", "next", "previous", "toggle"),
+		}, "action"),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Action string `json:"action"`
+			}
+			if err := parseToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			deviceID := eas.config.Speaker.DeviceID
+			var err error
+			switch args.Action {
+			case "play":
+				err = eas.xiaomiService.Play(deviceID)
+			case "pause":
+				err = eas.xiaomiService.Pause(deviceID)
+			case "next":
+				err = eas.xiaomiService.Next(deviceID)
+			case "previous":
+				err = eas.xiaomiService.Previous(deviceID)
+			case "toggle":
+				err = eas.xiaomiService.TogglePlayState(deviceID)
+			default:
+				return "", fmt.Errorf("不支持的播放控制: %s", args.Action)
+			}
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("已执行%s", args.Action), nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "play_url",
+		Description: "让音箱播放指定URL的音频",
+		Parameters: objectSchema(map[string]interface{}{
+			"url": stringProperty("音频地址（http或https）"),
+		}, "url"),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				URL string `json:"url"`
+			}
+			if err := parseToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			if !strings.HasPrefix(args.URL, "http://") && !strings.HasPrefix(args.URL, "https://") {
+				return "", fmt.Errorf("URL无效: %s", args.URL)
+			}
+			if err := eas.xiaomiService.PlayURL(eas.config.Speaker.DeviceID, args.URL); err != nil {
+				return "", err
+			}
+			return "已开始播放", nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "set_timer",
+		Description: "设置定时提醒，到时间后音箱会播报提醒内容",
+		Parameters: objectSchema(map[string]interface{}{
+			"minutes": integerProperty("多少分钟后提醒"),
+			"seconds": integerProperty("多少秒后提醒，可与minutes叠加"),
+			"message": stringProperty("到时播报的提醒内容，例如：该关火了"),
+		}, "message"),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Minutes int    `json:"minutes"`
+				Seconds int    `json:"seconds"`
+				Message string `json:"message"`
+			}
+			if err := parseToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			delay := time.Duration(args.Minutes)*time.Minute + time.Duration(args.Seconds)*time.Second
+			if delay <= 0 || delay > maxTimerDuration {
+				return "", fmt.Errorf("提醒时间必须在0到24小时之间")
+			}
+			info := eas.timers.Add(delay, args.Message, eas.fireTimer)
+			return fmt.Sprintf("已设置%d号提醒，将在%s提醒：%s", info.ID, info.FireAt.Format("15:04:05"), info.Message), nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "cancel_timer",
+		Description: "取消定时提醒。提供id取消指定提醒，不提供则取消全部",
+		Parameters: objectSchema(map[string]interface{}{
+			"id": integerProperty("提醒编号"),
+		}),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				ID *int `json:"id"`
+			}
+			if err := parseToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			if args.ID == nil {
+				return fmt.Sprintf("已取消%d个提醒", eas.timers.CancelAll()), nil
+			}
+			if !eas.timers.Cancel(*args.ID) {
+				return "", fmt.Errorf("提醒%d不存在", *args.ID)
+			}
+			return fmt.Sprintf("已取消%d号提醒", *args.ID), nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "list_timers",
+		Description: "列出所有未到期的定时提醒",
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			timers := eas.timers.List()
+			if len(timers) == 0 {
+				return "当前没有提醒", nil
+			}
+			data, err := json.Marshal(timers)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+	})
+
+	registry.Register(openai.Tool{
+		Name:        "execute_directive",
+		Description: "把一句指令交给小爱同学原生能力执行，用于控制智能家居（如打开客厅灯、空调调到26度）或使用小爱自带的功能",
+		Parameters: objectSchema(map[string]interface{}{
+			"text":   stringProperty("交给小爱执行的指令原文"),
+			"silent": booleanProperty("是否静默执行（不播报小爱的回复）"),
+		}, "text"),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Text   string `json:"text"`
+				Silent bool   `json:"silent"`
+			}
+			if err := parseToolArguments(arguments, &args); err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(args.Text) == "" {
+				return "", fmt.Errorf("指令不能为空")
+			}
+			if err := eas.xiaomiService.ExecuteDirective(eas.config.Speaker.DeviceID, args.Text, args.Silent); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("已执行指令：%s", args.Text), nil
+		},
+	})
+}
+
+// fireTimer 定时提醒到期
+func (eas *EnhancedAISpeaker) fireTimer(info TimerInfo) {
+	logger.Infof("⏰ 定时提醒到期 [%d]: %s", info.ID, info.Message)
+	text := "时间到了"
+	if info.Message != "" {
+		text = fmt.Sprintf("时间到了，%s", info.Message)
+	}
+	if err := eas.xiaomiService.Say(text); err != nil {
+		logger.Errorf("播报定时提醒失败: %v", err)
+	}
+}
+
+// GetToolRegistry 获取AI工具注册表
+func (eas *EnhancedAISpeaker) GetToolRegistry() *ToolRegistry {
+	return eas.toolRegistry
+}
+
+// GetTimers 获取未到期的定时提醒
+func (eas *EnhancedAISpeaker) GetTimers() []TimerInfo {
+	return eas.timers.List()
+}
+
+// saveToolInvocations 保存工具调用记录
+func saveToolInvocations(query string, messageID *int, records []openai.ToolCallRecord) {
+	if len(records) == 0 {
+		return
+	}
+	db := database.GetDB()
+	if db == nil {
+		return
+	}
+
+	invocations := make([]models.ToolInvocation, 0, len(records))
+	for _, record := range records {
+		invocations = append(invocations, models.ToolInvocation{
+			MessageID:  messageID,
+			Query:      query,
+			Round:      record.Round,
+			CallID:     record.CallID,
+			Name:       record.Name,
+			Arguments:  record.Arguments,
+			Result:     record.Result,
+			Error:      record.Error,
+			DurationMs: record.Duration.Milliseconds(),
+		})
+	}
+	if err := db.Create(&invocations).Error; err != nil {
+		logger.Errorf("保存工具调用记录失败: %v", err)
+	}
+}
+
+// parseToolArguments 解析工具参数
+func parseToolArguments(arguments string, v interface{}) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), v); err != nil {
+		return fmt.Errorf("解析工具参数失败: %v", err)
+	}
+	return nil
+}
+
+func clampVolume(volume int) int {
+	if volume < 0 {
+		return 0
+	}
+	if volume > 100 {
+		return 100
+	}
+	return volume
+}
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func integerProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "description": description}
+}
+
+func booleanProperty(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "boolean", "description": description}
+}
+
+func enumProperty(description string, values ...string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description, "enum": values}
+}
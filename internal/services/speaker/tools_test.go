@@ -0,0 +1,114 @@
+package speaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/services/miservice"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeToolCallServer 模拟 OpenAI 对话接口：第一轮调用指定工具，之后返回普通回答
+func fakeToolCallServer(t *testing.T, tool, arguments string) *httptest.Server {
+	t.Helper()
+	var rounds int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+		message := map[string]interface{}{"role": "assistant", "content": "好的"}
+		finishReason := "stop"
+		if atomic.AddInt32(&rounds, 1) == 1 {
+			message = map[string]interface{}{
+				"role":    "assistant",
+				"content": "",
+				"tool_calls": []map[string]interface{}{{
+					"id":       "call_1",
+					"type":     "function",
+					"function": map[string]interface{}{"name": tool, "arguments": arguments},
+				}},
+			}
+			finishReason = "tool_calls"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      fmt.Sprintf("chatcmpl-%d", rounds),
+			"object":  "chat.completion",
+			"model":   "test-model",
+			"choices": []map[string]interface{}{{"index": 0, "message": message, "finish_reason": finishReason}},
+			"usage":   map[string]int{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTimerUtteranceReachesSetTimerTool(t *testing.T) {
+	logger.Init()
+	server := fakeToolCallServer(t, "set_timer", `{"minutes":5,"message":"该关火了"}`)
+
+	client, err := openai.NewClient(config.OpenAIConfig{
+		Profiles: []config.ProviderProfile{{
+			Name:         "test",
+			Kind:         "openai",
+			BaseURL:      server.URL + "/v1",
+			APIKey:       "test-key",
+			DefaultModel: "test-model",
+		}},
+		Profile: "test",
+	})
+	if err != nil {
+		t.Fatalf("创建AI客户端失败: %v", err)
+	}
+
+	eas := &EnhancedAISpeaker{timers: NewTimerManager()}
+	defer eas.timers.CancelAll()
+	tools := NewToolRegistry()
+	eas.registerSpeakerTools(tools)
+
+	text := "5分钟后提醒我关火"
+	router := NewCommandRouter(NewCommandRegistry())
+	if answer, consumed := router.Route(context.Background(), miservice.QueryMessage{Text: text}, eas); consumed {
+		t.Fatalf("定时提醒被命令消费，没有交给AI: %q", answer.Text)
+	}
+
+	if _, err := client.Chat(context.Background(), openai.ChatOptions{User: text, Tools: tools.GetTools()}); err != nil {
+		t.Fatalf("对话失败: %v", err)
+	}
+
+	timers := eas.GetTimers()
+	if len(timers) != 1 {
+		t.Fatalf("期望设置1个提醒，实际 %d 个", len(timers))
+	}
+	if timers[0].Message != "该关火了" {
+		t.Errorf("提醒内容 = %q, 期望 %q", timers[0].Message, "该关火了")
+	}
+}
+
+func TestDeviceUtterancesAreNotConsumedByCommands(t *testing.T) {
+	logger.Init()
+	router := NewCommandRouter(NewCommandRegistry())
+	eas := &EnhancedAISpeaker{timers: NewTimerManager()}
+
+	for _, text := range []string{
+		"5分钟后提醒我关火",
+		"设置10分钟定时器",
+		"把音量调到50",
+		"音量调大一点",
+		"打开客厅的灯",
+		"播放音乐",
+		"今天天气怎么样",
+	} {
+		if answer, consumed := router.Route(context.Background(), miservice.QueryMessage{Text: text}, eas); consumed {
+			t.Errorf("%q 被命令消费: %q", text, answer.Text)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package speech
+
+import (
+	"mi-gpt-go/internal/config"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// codeBlockText 代码块替换为的提示
+const codeBlockText = "代码就不念了，请在手机上查看"
+
+// sentenceEnds 可以作为句子结尾的标点
+const sentenceEnds = "。！？；：，…!?;:,"
+
+// Options 播报前的文本处理选项
+type Options struct {
+	Style   string // 处理方式：config.SpeechStyleNatural, SpeechStylePlain, SpeechStyleRaw，为空时按 natural 处理
+	URLText string // 网址替换为的短语，为空时删除网址
+}
+
+var (
+	codeBlockPattern  = regexp.MustCompile("(?s)```.*?(```|$)")
+	inlineCodePattern = regexp.MustCompile("`([^`\n]*)`")
+	imagePattern      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkPattern       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	urlPattern        = regexp.MustCompile(`<?(?:https?://|www\.)[^\s<>()（）\[\]"'，。！？、；]+>?`)
+	boldPattern       = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	italicPattern     = regexp.MustCompile(`\*([^*\s][^*\n]*?)\*`)
+	strikePattern     = regexp.MustCompile(`~~(.+?)~~`)
+	headingPattern    = regexp.MustCompile(`^#{1,6}\s+`)
+	quotePattern      = regexp.MustCompile(`^(>\s?)+`)
+	rulePattern       = regexp.MustCompile(`^([-*_]\s*){3,}$`)
+	tableRulePattern  = regexp.MustCompile(`^\|?(\s*:?-+:?\s*\|)+\s*:?-*:?\s*$`)
+	bulletPattern     = regexp.MustCompile(`^[-*+•·]\s+(.*)$`)
+	numberedPattern   = regexp.MustCompile(`^\d{1,2}[.)、．](.*)$`)
+	spacePattern      = regexp.MustCompile(`[ \t\x{3000}]+`)
+	punctRunPattern   = regexp.MustCompile(`([，。！？；：、,.!?;:])[，。、,.;；]+`)
+	cjkSpacePattern   = regexp.MustCompile(`(\p{Han}|[，。！？；：、]) +| +(\p{Han}|[，。！？；：、])`)
+)
+
+// Normalize 把AI的回复转换为适合音箱播报的文本
+// 代码块、Markdown标记、表情和网址不适合朗读，列表合并为自然的句子，natural 方式下单位、符号、日期和公式读作中文
+func Normalize(text string, options Options) string {
+	if options.Style == config.SpeechStyleRaw || strings.TrimSpace(text) == "" {
+		return text
+	}
+	natural := options.Style != config.SpeechStylePlain
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = codeBlockPattern.ReplaceAllString(text, "\n"+codeBlockText+"\n")
+	text = replaceFormulas(text)
+	text = imagePattern.ReplaceAllString(text, "$1")
+	text = linkPattern.ReplaceAllString(text, "$1")
+	text = urlPattern.ReplaceAllString(text, options.URLText)
+	text = removeEmoji(text)
+	text = inlineCodePattern.ReplaceAllString(text, "$1")
+	text = boldPattern.ReplaceAllString(text, "$1$2")
+	text = italicPattern.ReplaceAllString(text, "$1")
+	text = strikePattern.ReplaceAllString(text, "$1")
+
+	text = joinLines(text, natural)
+	if natural {
+		text = expand(text)
+	}
+	return cleanup(text)
+}
+
+// joinLines 逐行去掉标题、引用、分隔线和表格标记，列表和段落合并为连续的句子
+func joinLines(text string, natural bool) string {
+	var sentences []string
+	var items []string
+	numbered := false
+
+	flush := func() {
+		if len(items) > 0 {
+			sentences = append(sentences, joinList(items, numbered, natural)...)
+			items = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = quotePattern.ReplaceAllString(line, "")
+		if line == "" || rulePattern.MatchString(line) || tableRulePattern.MatchString(line) {
+			flush()
+			continue
+		}
+		line = headingPattern.ReplaceAllString(line, "")
+
+		if match := bulletPattern.FindStringSubmatch(line); match != nil {
+			if len(items) > 0 && numbered {
+				flush()
+			}
+			items, numbered = append(items, match[1]), false
+			continue
+		}
+		if item, ok := numberedItem(line); ok {
+			if len(items) > 0 && !numbered {
+				flush()
+			}
+			items, numbered = append(items, item), true
+			continue
+		}
+
+		flush()
+		if strings.HasPrefix(line, "|") {
+			line = tableRow(line)
+		}
+		if line != "" {
+			sentences = append(sentences, endSentence(line, "。"))
+		}
+	}
+	flush()
+	return strings.Join(sentences, "")
+}
+
+// numberedItem 解析编号列表项，例如"1. 苹果""2、香蕉"
+// 序号后紧跟数字时是小数（例如"12.5元就够了""3.14是圆周率"），不是列表项
+// RE2 不支持前瞻断言，只能在匹配后检查
+func numberedItem(line string) (string, bool) {
+	match := numberedPattern.FindStringSubmatch(line)
+	if match == nil {
+		return "", false
+	}
+	if r, _ := utf8.DecodeRuneInString(match[1]); unicode.IsDigit(r) {
+		return "", false
+	}
+	item := strings.TrimLeftFunc(match[1], unicode.IsSpace)
+	return item, item != ""
+}
+
+// joinList 合并列表项：natural 方式下编号列表读作"第一，…；第二，…。"，其余列表项用分号连接
+// plain 方式下每一项单独成句
+func joinList(items []string, numbered, natural bool) []string {
+	result := make([]string, 0, len(items))
+	for i, item := range items {
+		item = strings.TrimRight(strings.TrimSpace(item), "。；，;,.")
+		if item == "" {
+			continue
+		}
+		if !natural {
+			result = append(result, endSentence(item, "。"))
+			continue
+		}
+		if numbered {
+			item = "第" + readInteger(int64(i+1)) + "，" + item
+		}
+		if i == len(items)-1 {
+			result = append(result, item+"。")
+		} else {
+			result = append(result, item+"；")
+		}
+	}
+	return result
+}
+
+// tableRow 表格的一行读作用逗号分隔的单元格
+func tableRow(line string) string {
+	var cells []string
+	for _, cell := range strings.Split(strings.Trim(line, "|"), "|") {
+		if cell = strings.TrimSpace(cell); cell != "" {
+			cells = append(cells, cell)
+		}
+	}
+	return strings.Join(cells, "，")
+}
+
+// endSentence 没有结尾标点时补上
+func endSentence(line, punct string) string {
+	last := []rune(line)[len([]rune(line))-1]
+	if strings.ContainsRune(sentenceEnds, last) {
+		return line
+	}
+	return line + punct
+}
+
+// removeEmoji 去掉表情符号以及组合表情用的连接符和变体选择符
+func removeEmoji(text string) string {
+	return strings.Map(func(r rune) rune {
+		if isEmoji(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// isEmoji 是否为表情符号，不包括℃、№等可以朗读的符号
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // 表情、国旗、扑克牌等
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // 杂项符号和装饰符号，例如☀✅✨
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // 星星、方块等
+		return true
+	case r >= 0x231A && r <= 0x23FF: // 手表、沙漏、闹钟等
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r == 0x200D, r == 0x20E3: // 变体选择符、零宽连接符、键帽
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // 旗帜标签
+		return true
+	}
+	return false
+}
+
+// cleanup 去掉残留的Markdown符号，合并空白和重复的标点
+func cleanup(text string) string {
+	text = strings.Map(func(r rune) rune {
+		switch r {
+		case '*', '#', '|', '`', '\n':
+			return ' '
+		}
+		return r
+	}, text)
+	text = spacePattern.ReplaceAllString(text, " ")
+	// 中文之间不需要空格，英文单词之间保留
+	for cjkSpacePattern.MatchString(text) {
+		text = cjkSpacePattern.ReplaceAllString(text, "$1$2")
+	}
+	text = punctRunPattern.ReplaceAllString(text, "$1")
+	return strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '，' || r == '、'
+	})
+}
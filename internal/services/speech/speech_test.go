@@ -0,0 +1,78 @@
+package speech
+
+import (
+	"flag"
+	"mi-gpt-go/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update 重新生成 testdata 中的 .golden 文件：go test ./internal/services/speech -update
+var update = flag.Bool("update", false, "更新 .golden 文件")
+
+// TestNormalizeGolden 逐个处理 testdata 中的 .input 文件，与同名的 .golden 文件比较
+// 文件名以 plain_ 开头的按 plain 方式处理，其余按 natural 方式处理
+func TestNormalizeGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.input"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("testdata 中没有测试用例")
+	}
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			options := Options{Style: config.SpeechStyleNatural}
+			if strings.HasPrefix(name, "plain_") {
+				options.Style = config.SpeechStylePlain
+			}
+			got := Normalize(string(data), options) + "\n"
+
+			golden := strings.TrimSuffix(input, ".input") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("Normalize(%s)\n got: %s\nwant: %s", input, got, want)
+			}
+		})
+	}
+}
+
+func TestNumberedItem(t *testing.T) {
+	tests := []struct {
+		line string
+		item string
+		ok   bool
+	}{
+		{"1. 苹果", "苹果", true},
+		{"2.香蕉", "香蕉", true},
+		{"3、橙子", "橙子", true},
+		{"4) 葡萄", "葡萄", true},
+		{"12.5元就够了", "", false},
+		{"3.14是圆周率", "", false},
+		{"0.5", "", false},
+		{"1.", "", false},
+	}
+	for _, tt := range tests {
+		item, ok := numberedItem(tt.line)
+		if item != tt.item || ok != tt.ok {
+			t.Errorf("numberedItem(%q) = %q, %v, 期望 %q, %v", tt.line, item, ok, tt.item, tt.ok)
+		}
+	}
+}
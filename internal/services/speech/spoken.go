@@ -0,0 +1,317 @@
+package speech
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// chineseDigits 数字的中文读法
+var chineseDigits = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+
+// 单位的中文读法，按长度从长到短排列，避免 km 被当作 m
+var unitNames = []struct{ unit, name string }{
+	{"km/h", "公里每小时"}, {"m/s", "米每秒"}, {"kcal", "千卡"}, {"mAh", "毫安时"}, {"kWh", "千瓦时"},
+	{"min", "分钟"}, {"km", "公里"}, {"cm", "厘米"}, {"mm", "毫米"}, {"kg", "公斤"}, {"mg", "毫克"},
+	{"ml", "毫升"}, {"mL", "毫升"}, {"kW", "千瓦"}, {"Hz", "赫兹"}, {"ms", "毫秒"},
+	{"m", "米"}, {"g", "克"}, {"L", "升"}, {"W", "瓦"}, {"h", "小时"}, {"s", "秒"},
+}
+
+// 面积和体积单位，上标不是单词字符，单独匹配
+var areaUnits = map[string]string{
+	"km²": "平方公里", "m²": "平方米", "㎡": "平方米", "cm²": "平方厘米", "m³": "立方米", "cm³": "立方厘米",
+}
+
+// 货币符号
+var currencyNames = map[string]string{
+	"$": "美元", "＄": "美元", "¥": "元", "￥": "元", "€": "欧元", "£": "英镑",
+}
+
+// LaTeX 命令对应的符号，之后再按符号读作中文
+var latexCommands = strings.NewReplacer(
+	`\times`, "×", `\cdot`, "×", `\div`, "÷", `\pm`, "±",
+	`\leq`, "≤", `\le`, "≤", `\geq`, "≥", `\ge`, "≥", `\neq`, "≠", `\ne`, "≠", `\approx`, "≈",
+	`\infty`, "无穷大", `\pi`, "π", `\alpha`, "α", `\beta`, "β", `\theta`, "θ", `\Delta`, "Δ",
+	`\left`, "", `\right`, "", `\,`, " ", `\;`, " ", `\quad`, " ", `\ `, " ",
+)
+
+// 符号的中文读法
+var symbolNames = strings.NewReplacer(
+	"≥", "大于等于", ">=", "大于等于", "≤", "小于等于", "<=", "小于等于", "≠", "不等于", "!=", "不等于",
+	"≈", "约等于", "±", "正负", "×", "乘", "÷", "除以", "→", "到", "=", "等于", ">", "大于", "<", "小于",
+	"&", "和", "√", "根号", "²", "的平方", "³", "的立方", "°", "度",
+	"π", "派", "α", "阿尔法", "β", "贝塔", "θ", "西塔", "Δ", "德尔塔", "~", "", "～", "",
+)
+
+var (
+	displayMathPattern = regexp.MustCompile(`(?s)\$\$(.+?)\$\$|\\\[(.+?)\\\]`)
+	inlineMathPattern  = regexp.MustCompile(`\\\((.+?)\\\)|\$([^\s$](?:[^$\n]*[^\s$])?)\$(\D|$)`)
+	fracPattern        = regexp.MustCompile(`\\[dt]?frac\{([^{}]*)\}\{([^{}]*)\}`)
+	sqrtPattern        = regexp.MustCompile(`\\sqrt\{([^{}]*)\}`)
+	textCommandPattern = regexp.MustCompile(`\\(?:text|mathrm|mathbf|operatorname)\{([^{}]*)\}`)
+	powerBracePattern  = regexp.MustCompile(`\^\{([^{}]*)\}`)
+	subscriptPattern   = regexp.MustCompile(`_\{([^{}]*)\}|_(\w)`)
+	latexNamePattern   = regexp.MustCompile(`\\([A-Za-z]+)`)
+	parenPattern       = regexp.MustCompile(`\((\w+)\)`)
+	multiplyPattern    = regexp.MustCompile(`(\d)\s*\*\s*(\d)`)
+
+	datePattern        = regexp.MustCompile(`\b(\d{4})[-/.年](\d{1,2})[-/.月](\d{1,2})[日号]?`)
+	yearPattern        = regexp.MustCompile(`\b(\d{4})年`)
+	timePattern        = regexp.MustCompile(`\b(\d{1,2})[:：](\d{2})(?:[:：](\d{2}))?\b`)
+	minusPattern       = regexp.MustCompile(`(\d)\s*-\s*(\d+(?:\.\d+)?\s*=)`)
+	rangePattern       = regexp.MustCompile(`(\d)\s*[-~～–—]\s*(\d)`)
+	percentPattern     = regexp.MustCompile(`(-?)(\d+(?:\.\d+)?)\s*[%％]`)
+	temperaturePattern = regexp.MustCompile(`(-?)(\d+(?:\.\d+)?)\s*(°C|℃|°F|℉)`)
+	currencyPattern    = regexp.MustCompile(`([$＄¥￥€£])\s*(\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?)(元?)`)
+	areaPattern        = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?(km²|cm²|cm³|m²|m³|㎡)`)
+	unitPattern        = regexp.MustCompile(`(\d+(?:\.\d+)?)\s?(` + unitAlternation() + `)\b`)
+	fractionPattern    = regexp.MustCompile(`(\d+)/(\d+)`)
+	ratioPattern       = regexp.MustCompile(`(\d+)\s*[:：]\s*(\d+)`)
+	powerPattern       = regexp.MustCompile(`\^(\w+)`)
+	plusPattern        = regexp.MustCompile(`(\d)\s*\+\s*(\d)`)
+	negativePattern    = regexp.MustCompile(`(^|[^\w.])-(\d)`)
+	numberPattern      = regexp.MustCompile(`(?:[A-Za-z_]+-)?[A-Za-z_]*(?:\d{1,3}(?:,\d{3})+|\d+)(?:\.\d+)?[A-Za-z_]*`)
+)
+
+// unitAlternation 单位的正则表达式分支
+func unitAlternation() string {
+	units := make([]string, 0, len(unitNames))
+	for _, unit := range unitNames {
+		units = append(units, regexp.QuoteMeta(unit.unit))
+	}
+	return strings.Join(units, "|")
+}
+
+// replaceFormulas 去掉 LaTeX 公式的定界符和命令，转换为普通符号
+func replaceFormulas(text string) string {
+	text = displayMathPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := displayMathPattern.FindStringSubmatch(match)
+		return latexToText(groups[1] + groups[2])
+	})
+	text = inlineMathPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := inlineMathPattern.FindStringSubmatch(match)
+		return latexToText(groups[1]+groups[2]) + groups[3]
+	})
+	return multiplyPattern.ReplaceAllString(text, "$1×$2")
+}
+
+// latexToText 把公式内容转换为普通符号，分数写作 a/b，上标写作 ^n
+// 公式中的加号两侧可能是字母，不能等到 expand 中按数字相加处理，直接读作"加"
+func latexToText(formula string) string {
+	for fracPattern.MatchString(formula) || sqrtPattern.MatchString(formula) || textCommandPattern.MatchString(formula) {
+		formula = fracPattern.ReplaceAllString(formula, "($1)/($2)")
+		formula = sqrtPattern.ReplaceAllString(formula, "√($1)")
+		formula = textCommandPattern.ReplaceAllString(formula, "$1")
+	}
+	formula = latexCommands.Replace(formula)
+	formula = powerBracePattern.ReplaceAllString(formula, "^$1")
+	formula = strings.NewReplacer("^2", "²", "^3", "³").Replace(formula)
+	formula = subscriptPattern.ReplaceAllString(formula, "$1$2")
+	formula = latexNamePattern.ReplaceAllString(formula, "$1")
+	// 单个数字或字母的括号是多余的
+	formula = parenPattern.ReplaceAllString(formula, "$1")
+	return strings.NewReplacer("{", "", "}", "", "+", "加").Replace(strings.TrimSpace(formula))
+}
+
+// expand 把日期、时间、百分数、温度、货币、单位、分数和数学符号读作中文，最后把剩下的数字读作中文
+// 与字母相连的数字（型号、化学式等）保持不变
+func expand(text string) string {
+	text = replaceSubmatch(datePattern, text, func(groups []string) (string, bool) {
+		month, _ := strconv.Atoi(groups[2])
+		day, _ := strconv.Atoi(groups[3])
+		if month < 1 || month > 12 || day < 1 || day > 31 {
+			return "", false
+		}
+		return readDigits(groups[1]) + "年" + readInteger(int64(month)) + "月" + readInteger(int64(day)) + "日", true
+	})
+	text = replaceSubmatch(yearPattern, text, func(groups []string) (string, bool) {
+		return readDigits(groups[1]) + "年", true
+	})
+	text = replaceSubmatch(timePattern, text, func(groups []string) (string, bool) {
+		hour, _ := strconv.Atoi(groups[1])
+		minute, _ := strconv.Atoi(groups[2])
+		if hour > 24 || minute > 59 {
+			return "", false
+		}
+		spoken := readCount(groups[1]) + "点"
+		if minute > 0 {
+			if minute < 10 {
+				spoken += "零"
+			}
+			spoken += readInteger(int64(minute)) + "分"
+		}
+		if second, _ := strconv.Atoi(groups[3]); second > 0 {
+			spoken += readInteger(int64(second)) + "秒"
+		}
+		return spoken, true
+	})
+
+	text = minusPattern.ReplaceAllString(text, "${1}减$2")
+	text = rangePattern.ReplaceAllString(text, "${1}到$2")
+	text = replaceSubmatch(percentPattern, text, func(groups []string) (string, bool) {
+		return sign(groups[1], "负") + "百分之" + readNumber(groups[2]), true
+	})
+	text = replaceSubmatch(temperaturePattern, text, func(groups []string) (string, bool) {
+		if groups[3] == "°F" || groups[3] == "℉" {
+			return "华氏" + sign(groups[1], "零下") + readNumber(groups[2]) + "度", true
+		}
+		return sign(groups[1], "零下") + readNumber(groups[2]) + "摄氏度", true
+	})
+	text = replaceSubmatch(currencyPattern, text, func(groups []string) (string, bool) {
+		// 人民币符号后面常常还跟着"元"
+		name := currencyNames[groups[1]]
+		if groups[3] != "" && name != "元" {
+			name += groups[3]
+		}
+		return readCount(groups[2]) + name, true
+	})
+	text = replaceSubmatch(areaPattern, text, func(groups []string) (string, bool) {
+		return readNumber(groups[1]) + areaUnits[groups[2]], true
+	})
+	text = replaceSubmatch(unitPattern, text, func(groups []string) (string, bool) {
+		for _, unit := range unitNames {
+			if unit.unit == groups[2] {
+				return readCount(groups[1]) + unit.name, true
+			}
+		}
+		return "", false
+	})
+	text = replaceSubmatch(fractionPattern, text, func(groups []string) (string, bool) {
+		return readNumber(groups[2]) + "分之" + readNumber(groups[1]), true
+	})
+	text = ratioPattern.ReplaceAllString(text, "${1}比$2")
+	text = powerPattern.ReplaceAllString(text, "的${1}次方")
+	text = plusPattern.ReplaceAllString(text, "${1}加$2")
+	text = symbolNames.Replace(text)
+	text = negativePattern.ReplaceAllString(text, "${1}负$2")
+
+	return numberPattern.ReplaceAllStringFunc(text, func(number string) string {
+		if strings.IndexFunc(number, func(r rune) bool { return r == '_' || r > '9' }) >= 0 {
+			return number
+		}
+		return readNumber(number)
+	})
+}
+
+// replaceSubmatch 按分组替换匹配的文本，replace 返回 false 时保留原文
+func replaceSubmatch(pattern *regexp.Regexp, text string, replace func(groups []string) (string, bool)) string {
+	return pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if spoken, ok := replace(pattern.FindStringSubmatch(match)); ok {
+			return spoken
+		}
+		return match
+	})
+}
+
+// sign 负号的读法
+func sign(minus, spoken string) string {
+	if minus == "" {
+		return ""
+	}
+	return spoken
+}
+
+// readCount 读作数量，2读作"两"，例如两公里、两点
+func readCount(number string) string {
+	if number == "2" {
+		return "两"
+	}
+	return readNumber(number)
+}
+
+// readNumber 读数字，支持千分位和小数；电话号码等9位以上或以0开头的整数逐位读
+func readNumber(number string) string {
+	number = strings.ReplaceAll(number, ",", "")
+	integer, decimal, hasDecimal := strings.Cut(number, ".")
+
+	var spoken string
+	if len(integer) >= 9 || (len(integer) > 1 && integer[0] == '0' && !hasDecimal) {
+		spoken = readDigits(integer)
+	} else {
+		n, err := strconv.ParseInt(integer, 10, 64)
+		if err != nil {
+			return number
+		}
+		spoken = readInteger(n)
+	}
+	if hasDecimal && decimal != "" {
+		spoken += "点" + readDigits(decimal)
+	}
+	return spoken
+}
+
+// readDigits 逐位读数字，用于年份和编号
+func readDigits(digits string) string {
+	var builder strings.Builder
+	for _, r := range digits {
+		if r >= '0' && r <= '9' {
+			builder.WriteString(chineseDigits[r-'0'])
+		}
+	}
+	return builder.String()
+}
+
+// readInteger 读整数，例如 10 读作十，2005 读作两千零五，100000 读作十万
+func readInteger(n int64) string {
+	if n == 0 {
+		return chineseDigits[0]
+	}
+
+	sectionUnits := []string{"", "万", "亿", "万亿"}
+	var sections []int
+	for n > 0 {
+		sections = append(sections, int(n%10000))
+		n /= 10000
+	}
+
+	var builder strings.Builder
+	zero := false
+	for i := len(sections) - 1; i >= 0; i-- {
+		section := sections[i]
+		if section == 0 {
+			zero = builder.Len() > 0
+			continue
+		}
+		// 中间的节不足千位时补"零"，例如 10005 读作一万零五
+		if zero || (builder.Len() > 0 && section < 1000) {
+			builder.WriteString(chineseDigits[0])
+		}
+		builder.WriteString(readSection(section))
+		builder.WriteString(sectionUnits[i])
+		zero = false
+	}
+
+	spoken := builder.String()
+	// 十到十九省略开头的"一"，开头的二千、二万、二亿读作两
+	if strings.HasPrefix(spoken, "一十") {
+		spoken = strings.TrimPrefix(spoken, "一")
+	}
+	for _, unit := range []string{"千", "万", "亿"} {
+		if strings.HasPrefix(spoken, "二"+unit) {
+			spoken = "两" + strings.TrimPrefix(spoken, "二")
+			break
+		}
+	}
+	return spoken
+}
+
+// readSection 读0到9999之间的数
+func readSection(n int) string {
+	units := []string{"", "十", "百", "千"}
+	var builder strings.Builder
+	zero := false
+	for i, divisor := 3, 1000; i >= 0; i, divisor = i-1, divisor/10 {
+		digit := n / divisor % 10
+		if digit == 0 {
+			zero = builder.Len() > 0
+			continue
+		}
+		if zero {
+			builder.WriteString(chineseDigits[0])
+			zero = false
+		}
+		builder.WriteString(chineseDigits[digit])
+		builder.WriteString(units[i])
+	}
+	return builder.String()
+}
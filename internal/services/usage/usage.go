@@ -0,0 +1,228 @@
+package usage
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// dayLayout 用量记录中日期的格式
+const dayLayout = "2006-01-02"
+
+// 汇总维度对应的列
+var groupColumns = map[string]string{
+	"day":      "day",
+	"device":   "device_id",
+	"user":     "user_id",
+	"provider": "provider",
+	"model":    "model",
+	"purpose":  "purpose",
+}
+
+// Bucket 一组调用的用量汇总
+type Bucket struct {
+	Key              string  `json:"key"`
+	Label            string  `json:"label"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	TotalTokens      int64   `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"`
+}
+
+// Filter 用量的筛选条件
+type Filter struct {
+	From     time.Time
+	To       time.Time // 不包含
+	DeviceID string
+	UserID   string
+	Provider string
+}
+
+// BudgetStatus 费用预算的使用情况，预算为0表示不限制
+type BudgetStatus struct {
+	Daily        float64 `json:"daily"`
+	DailySpent   float64 `json:"dailySpent"`
+	Monthly      float64 `json:"monthly"`
+	MonthlySpent float64 `json:"monthlySpent"`
+	Exceeded     string  `json:"exceeded,omitempty"` // 已超出的预算：daily 或 monthly，未超出时为空
+}
+
+// NewRecorder 创建用量记录器，把每次模型调用的用量写入数据库
+// 费用按记录时的价格表计算，cfg 修改后立即生效
+func NewRecorder(cfg *config.Config) func(openai.Usage) {
+	return func(u openai.Usage) {
+		db := database.GetDB()
+		if db == nil {
+			return
+		}
+		if err := Record(db, cfg.OpenAI, u, time.Now()); err != nil {
+			logger.Warnf("%v", err)
+		}
+	}
+}
+
+// Record 保存一次模型调用的用量
+func Record(db *gorm.DB, cfg config.OpenAIConfig, u openai.Usage, now time.Time) error {
+	record := models.UsageRecord{
+		Provider:         u.Provider,
+		Model:            u.Model,
+		Purpose:          u.Purpose,
+		DeviceID:         u.DeviceID,
+		UserID:           u.UserID,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		Estimated:        u.Estimated,
+		LatencyMs:        u.Latency.Milliseconds(),
+		Day:              now.Format(dayLayout),
+		CreatedAt:        now,
+	}
+	if price, ok := cfg.PriceOf(u.Provider, u.Model); ok {
+		record.Cost = price.Cost(u.PromptTokens, u.CompletionTokens)
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return fmt.Errorf("保存用量记录失败: %v", err)
+	}
+	return nil
+}
+
+// filterQuery 按筛选条件构造用量查询
+func filterQuery(db *gorm.DB, filter Filter) *gorm.DB {
+	query := db.Model(&models.UsageRecord{})
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.DeviceID != "" {
+		query = query.Where("device_id = ?", filter.DeviceID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Provider != "" {
+		query = query.Where("provider = ?", filter.Provider)
+	}
+	return query
+}
+
+// aggregateColumns 汇总用的列
+const aggregateColumns = `COUNT(*) AS requests,
+	COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+	COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+	COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(cost), 0) AS cost,
+	COALESCE(AVG(latency_ms), 0) AS avg_latency_ms`
+
+// Totals 汇总筛选范围内的全部用量
+func Totals(db *gorm.DB, filter Filter) (Bucket, error) {
+	var total Bucket
+	if err := filterQuery(db, filter).Select(aggregateColumns).Scan(&total).Error; err != nil {
+		return Bucket{}, fmt.Errorf("汇总用量失败: %v", err)
+	}
+	return total, nil
+}
+
+// Aggregate 按维度汇总用量：day, device, user, provider, model, purpose
+// 按天汇总时按日期排序，其余按费用和token数从高到低排序；成员的名称作为标签
+func Aggregate(db *gorm.DB, filter Filter, groupBy string) ([]Bucket, error) {
+	column, ok := groupColumns[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("不支持的汇总维度: %s", groupBy)
+	}
+
+	order := "cost DESC, total_tokens DESC"
+	if groupBy == "day" {
+		order = "key ASC"
+	}
+	var buckets []Bucket
+	err := filterQuery(db, filter).
+		Select(column + " AS key, " + aggregateColumns).
+		Group(column).
+		Order(order).
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, fmt.Errorf("汇总用量失败: %v", err)
+	}
+
+	for i := range buckets {
+		buckets[i].Label = buckets[i].Key
+	}
+	if groupBy == "user" {
+		labelUsers(db, buckets)
+	}
+	return buckets, nil
+}
+
+// labelUsers 用成员名称作为按成员汇总的标签
+func labelUsers(db *gorm.DB, buckets []Bucket) {
+	ids := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		if bucket.Key != "" {
+			ids = append(ids, bucket.Key)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var users []models.User
+	if err := db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		logger.Warnf("读取成员名称失败: %v", err)
+		return
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.Name
+	}
+	for i := range buckets {
+		if name, ok := names[buckets[i].Key]; ok {
+			buckets[i].Label = name
+		}
+	}
+}
+
+// Spent 统计某一时间之后的费用
+func Spent(db *gorm.DB, since time.Time) (float64, error) {
+	var cost float64
+	err := db.Model(&models.UsageRecord{}).
+		Where("created_at >= ?", since).
+		Select("COALESCE(SUM(cost), 0)").
+		Scan(&cost).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计费用失败: %v", err)
+	}
+	return cost, nil
+}
+
+// CheckBudget 检查当日和当月的费用是否超出预算，日预算优先
+func CheckBudget(db *gorm.DB, cfg config.OpenAIConfig, now time.Time) (BudgetStatus, error) {
+	status := BudgetStatus{Daily: cfg.DailyBudget, Monthly: cfg.MonthlyBudget}
+
+	var err error
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if status.DailySpent, err = Spent(db, today); err != nil {
+		return status, err
+	}
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if status.MonthlySpent, err = Spent(db, month); err != nil {
+		return status, err
+	}
+
+	switch {
+	case status.Daily > 0 && status.DailySpent >= status.Daily:
+		status.Exceeded = "daily"
+	case status.Monthly > 0 && status.MonthlySpent >= status.Monthly:
+		status.Exceeded = "monthly"
+	}
+	return status, nil
+}
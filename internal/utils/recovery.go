@@ -2,9 +2,11 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mi-gpt-go/pkg/logger"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -145,13 +147,17 @@ func SafeGoWithContext(ctx context.Context, name string, fn func(context.Context
 	}()
 }
 
-// CircuitBreaker 断路器
+// ErrCircuitOpen 断路器已打开，调用被拒绝
+var ErrCircuitOpen = errors.New("断路器已打开，拒绝执行")
+
+// CircuitBreaker 断路器，可以在多个goroutine中共用
 type CircuitBreaker struct {
 	maxFailures int       // 最大失败次数
 	resetTime   time.Duration // 重置时间
 	failures    int       // 当前失败次数
 	lastFailure time.Time // 最后失败时间
 	state       string    // 状态: "closed", "open", "half-open"
+	mutex       sync.Mutex
 }
 
 // NewCircuitBreaker 创建断路器
@@ -167,7 +173,7 @@ func NewCircuitBreaker(maxFailures int, resetTime time.Duration) *CircuitBreaker
 func (cb *CircuitBreaker) Execute(fn func() error) error {
 	// 检查是否可以执行
 	if !cb.canExecute() {
-		return fmt.Errorf("断路器已打开，拒绝执行")
+		return ErrCircuitOpen
 	}
 	
 	err := fn()
@@ -184,6 +190,9 @@ func (cb *CircuitBreaker) Execute(fn func() error) error {
 
 // canExecute 检查是否可以执行
 func (cb *CircuitBreaker) canExecute() bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
 	switch cb.state {
 	case "closed":
 		return true
@@ -203,6 +212,9 @@ func (cb *CircuitBreaker) canExecute() bool {
 
 // onFailure 处理失败
 func (cb *CircuitBreaker) onFailure() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
 	cb.failures++
 	cb.lastFailure = time.Now()
 	
@@ -214,16 +226,23 @@ func (cb *CircuitBreaker) onFailure() {
 
 // onSuccess 处理成功
 func (cb *CircuitBreaker) onSuccess() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
 	cb.failures = 0
 	cb.state = "closed"
 }
 
 // GetState 获取状态
 func (cb *CircuitBreaker) GetState() string {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
 	return cb.state
 }
 
 // GetFailures 获取失败次数
 func (cb *CircuitBreaker) GetFailures() int {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
 	return cb.failures
 } 
\ No newline at end of file
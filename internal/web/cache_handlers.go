@@ -0,0 +1,91 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/services/cache"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// listCachedAnswers 分页列出缓存的回答并返回命中统计，最近使用的在前
+// 参数：q 问题或回答中的关键词，page 页码，pageSize 每页条数
+func (ws *WebServer) listCachedAnswers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	db := database.GetDB()
+	entries, total, err := cache.List(db, c.Query("q"), page, pageSize)
+	var stats cache.Stats
+	if err == nil {
+		stats, err = cache.GetStats(db, ws.config.OpenAI)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取回答缓存失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"items": entries,
+			"total": total,
+			"stats": stats,
+		},
+	})
+}
+
+// deleteCachedAnswer 删除一条缓存的回答
+func (ws *WebServer) deleteCachedAnswer(c *gin.Context) {
+	key := c.Param("key")
+	if err := cache.Delete(database.GetDB(), key); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status, err = http.StatusNotFound, fmt.Errorf("缓存的回答不存在: %s", key)
+		}
+		c.JSON(status, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "已删除缓存的回答",
+	})
+}
+
+// purgeCachedAnswers 清空回答缓存
+// 参数：expired=true 时只删除已过期的回答
+func (ws *WebServer) purgeCachedAnswers(c *gin.Context) {
+	expiredOnly := c.Query("expired") == "true"
+	count, err := cache.Purge(database.GetDB(), expiredOnly, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: fmt.Sprintf("已删除 %d 条缓存的回答", count),
+		Data:    map[string]interface{}{"deleted": count},
+	})
+}
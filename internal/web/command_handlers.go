@@ -0,0 +1,267 @@
+package web
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/speaker"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// customCommandRequest 自定义命令请求
+type customCommandRequest struct {
+	Name        string                       `json:"name" binding:"required"`
+	Description string                       `json:"description"`
+	MatchType   string                       `json:"matchType" binding:"required"`
+	Pattern     string                       `json:"pattern" binding:"required"`
+	Priority    *int                         `json:"priority"`
+	Enabled     *bool                        `json:"enabled"`
+	Actions     []models.CustomCommandAction `json:"actions"`
+}
+
+// toModel 转换为数据库模型，动作按提交顺序编号
+func (r *customCommandRequest) toModel() models.CustomCommand {
+	command := models.CustomCommand{
+		Name:        r.Name,
+		Description: r.Description,
+		MatchType:   r.MatchType,
+		Pattern:     r.Pattern,
+		Priority:    speaker.CommandPriorityHigh,
+		Enabled:     true,
+	}
+	if r.Priority != nil {
+		command.Priority = *r.Priority
+	}
+	if r.Enabled != nil {
+		command.Enabled = *r.Enabled
+	}
+	for i, action := range r.Actions {
+		action.ID = 0
+		action.CommandID = 0
+		action.Position = i
+		command.Actions = append(command.Actions, action)
+	}
+	return command
+}
+
+// listCustomCommands 获取自定义命令列表
+func (ws *WebServer) listCustomCommands(c *gin.Context) {
+	var commands []models.CustomCommand
+	err := database.GetDB().Preload("Actions", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("position ASC")
+	}).Order("priority DESC, id ASC").Find(&commands).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取自定义命令失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    commands,
+	})
+}
+
+// getCustomCommand 获取单个自定义命令
+func (ws *WebServer) getCustomCommand(c *gin.Context) {
+	command, ok := ws.findCustomCommand(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    command,
+	})
+}
+
+// createCustomCommand 创建自定义命令
+func (ws *WebServer) createCustomCommand(c *gin.Context) {
+	var request customCommandRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("请求数据格式错误: %v", err),
+		})
+		return
+	}
+
+	command := request.toModel()
+	if err := speaker.ValidateCustomCommand(command); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("自定义命令无效: %v", err),
+		})
+		return
+	}
+
+	if err := database.GetDB().Create(&command).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("创建自定义命令失败: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("已创建自定义命令: %s", command.Name)
+	ws.reloadCustomCommands()
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "自定义命令创建成功",
+		Data:    command,
+	})
+}
+
+// updateCustomCommand 更新自定义命令（动作列表整体替换）
+func (ws *WebServer) updateCustomCommand(c *gin.Context) {
+	existing, ok := ws.findCustomCommand(c)
+	if !ok {
+		return
+	}
+
+	var request customCommandRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("请求数据格式错误: %v", err),
+		})
+		return
+	}
+
+	command := request.toModel()
+	command.ID = existing.ID
+	command.CreatedAt = existing.CreatedAt
+	if err := speaker.ValidateCustomCommand(command); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("自定义命令无效: %v", err),
+		})
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("command_id = ?", command.ID).Delete(&models.CustomCommandAction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Actions").Save(&command).Error; err != nil {
+			return err
+		}
+		for i := range command.Actions {
+			command.Actions[i].CommandID = command.ID
+		}
+		return tx.Create(&command.Actions).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("更新自定义命令失败: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("已更新自定义命令: %s", command.Name)
+	ws.reloadCustomCommands()
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "自定义命令更新成功",
+		Data:    command,
+	})
+}
+
+// deleteCustomCommand 删除自定义命令
+func (ws *WebServer) deleteCustomCommand(c *gin.Context) {
+	command, ok := ws.findCustomCommand(c)
+	if !ok {
+		return
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("command_id = ?", command.ID).Delete(&models.CustomCommandAction{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.CustomCommand{}, command.ID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("删除自定义命令失败: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("已删除自定义命令: %s", command.Name)
+	ws.reloadCustomCommands()
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "自定义命令已删除",
+	})
+}
+
+// reloadCustomCommandsHandler 手动重新加载自定义命令
+func (ws *WebServer) reloadCustomCommandsHandler(c *gin.Context) {
+	if ws.aiSpeaker == nil {
+		c.JSON(http.StatusServiceUnavailable, ConfigResponse{
+			Success: false,
+			Message: "AI音箱服务未启动，自定义命令将在服务启动时加载",
+		})
+		return
+	}
+
+	if err := ws.aiSpeaker.ReloadCustomCommands(); err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("重新加载自定义命令失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "自定义命令已重新加载",
+	})
+}
+
+// findCustomCommand 根据路径参数查找自定义命令，未找到时直接写入响应
+func (ws *WebServer) findCustomCommand(c *gin.Context) (*models.CustomCommand, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "无效的命令ID",
+		})
+		return nil, false
+	}
+
+	var command models.CustomCommand
+	err = database.GetDB().Preload("Actions", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("position ASC")
+	}).First(&command, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("自定义命令不存在: %d", id),
+		})
+		return nil, false
+	}
+	return &command, true
+}
+
+// reloadCustomCommands 将自定义命令的变更同步到运行中的音箱服务
+func (ws *WebServer) reloadCustomCommands() {
+	if ws.aiSpeaker == nil {
+		return
+	}
+	if err := ws.aiSpeaker.ReloadCustomCommands(); err != nil {
+		logger.Warnf("同步自定义命令失败: %v", err)
+	}
+}
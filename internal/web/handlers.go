@@ -2,12 +2,20 @@ package web
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/memory"
 	"mi-gpt-go/internal/services/miservice"
 	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/internal/services/search"
+	"mi-gpt-go/internal/services/usage"
 	"mi-gpt-go/pkg/logger"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,36 +31,73 @@ type ConfigResponse struct {
 
 // SystemStatus 系统状态
 type SystemStatus struct {
-	IsRunning      bool      `json:"isRunning"`
-	StartTime      time.Time `json:"startTime"`
-	Version        string    `json:"version"`
-	GoVersion      string    `json:"goVersion"`
-	WebServerPort  int       `json:"webServerPort"`
-	DatabasePath   string    `json:"databasePath"`
-	ConcurrentMode bool      `json:"concurrentMode"`
+	IsRunning      bool                      `json:"isRunning"`
+	StartTime      time.Time                 `json:"startTime"`
+	Version        string                    `json:"version"`
+	GoVersion      string                    `json:"goVersion"`
+	WebServerPort  int                       `json:"webServerPort"`
+	DatabasePath   string                    `json:"databasePath"`
+	ConcurrentMode bool                      `json:"concurrentMode"`
+	Maintenance    *memory.MaintenanceStatus `json:"maintenance,omitempty"` // 记忆保留与维护任务的状态和执行记录
+	Providers      []openai.ProviderStatus   `json:"providers,omitempty"`   // AI服务商故障转移链的状态
 }
 
 // getConfig 获取配置
 func (ws *WebServer) getConfig(c *gin.Context) {
 	configData := map[string]interface{}{
 		"ai": map[string]interface{}{
-			"provider":        ws.config.OpenAI.Provider,
-			"model":           ws.config.OpenAI.Model,
-			"baseURL":         ws.config.OpenAI.BaseURL,
+			"profiles":        ws.config.OpenAI.Profiles, // 返回完整API密钥，由前端控制显示
+			"profile":         ws.config.OpenAI.Profile,
 			"proxyURL":        ws.config.OpenAI.ProxyURL,
-			"apiKey":          ws.config.OpenAI.APIKey,          // 返回完整API密钥，由前端控制显示
-			"azureAPIKey":     ws.config.OpenAI.AzureAPIKey,     // 返回完整API密钥，由前端控制显示
-			"azureEndpoint":   ws.config.OpenAI.AzureEndpoint,
-			"azureDeployment": ws.config.OpenAI.AzureDeployment,
-			"deepSeekAPIKey":  ws.config.OpenAI.DeepSeekAPIKey,  // 返回完整API密钥，由前端控制显示
+			"enableTools":     ws.config.OpenAI.EnableTools,
+			"enableEmbedding":          ws.config.OpenAI.EnableEmbedding,
+			"embeddingModel":           ws.config.OpenAI.EmbeddingModel,
+			"embeddingDimensions":      ws.config.OpenAI.EmbeddingDimensions,
+			"embeddingBaseURL":         ws.config.OpenAI.EmbeddingBaseURL,
+			"embeddingAPIKey":          ws.config.OpenAI.EmbeddingAPIKey, // 返回完整API密钥，由前端控制显示
+			"azureEmbeddingDeployment": ws.config.OpenAI.AzureEmbeddingDeployment,
+			"fallbacks":                ws.config.OpenAI.Fallbacks,
+			"breakerFailures":          ws.config.OpenAI.BreakerFailures,
+			"breakerResetSeconds":      ws.config.OpenAI.BreakerResetSeconds,
+			"prices":                   ws.config.OpenAI.Prices,
+			"dailyBudget":              ws.config.OpenAI.DailyBudget,
+			"monthlyBudget":            ws.config.OpenAI.MonthlyBudget,
+			"budgetModel":              ws.config.OpenAI.BudgetModel,
+			"enableCache":              ws.config.OpenAI.EnableCache,
+			"cacheTtlHours":            ws.config.OpenAI.CacheTTLHours,
+			"cacheMaxEntries":          ws.config.OpenAI.CacheMaxEntries,
+			"cacheBypass":              strings.Join(ws.config.OpenAI.CacheBypass, "\n"),
+			"enableSearch":             ws.config.OpenAI.EnableSearch,
+			"searchProvider":           ws.config.OpenAI.SearchProvider,
+			"searchBaseUrl":            ws.config.OpenAI.SearchBaseURL,
+			"searchApiKey":             ws.config.OpenAI.SearchAPIKey, // 返回完整API密钥，由前端控制显示
+			"searchTemplate":           ws.config.OpenAI.SearchTemplate,
+			"searchMaxResults":         ws.config.OpenAI.SearchMaxResults,
+			"searchTriggers":           strings.Join(ws.config.OpenAI.SearchTriggers, "\n"),
 		},
 		"bot": map[string]interface{}{
-			"name":            ws.config.Bot.Name,
-			"profile":         ws.config.Bot.Profile,
-			"masterName":      ws.config.Bot.Master.Name,
-			"masterProfile":   ws.config.Bot.Master.Profile,
-			"roomName":        ws.config.Bot.Room.Name,
-			"roomDescription": ws.config.Bot.Room.Description,
+			"name":             ws.config.Bot.Name,
+			"profile":          ws.config.Bot.Profile,
+			"masterName":       ws.config.Bot.Master.Name,
+			"masterProfile":    ws.config.Bot.Master.Profile,
+			"roomName":         ws.config.Bot.Room.Name,
+			"roomDescription":  ws.config.Bot.Room.Description,
+			"contextTurns":     ws.config.Bot.ContextTurns,
+			"contextMaxTokens": ws.config.Bot.ContextMaxTokens,
+			"contextWindow":    ws.config.Bot.ContextWindow,
+			"identityTimeout":  ws.config.Bot.IdentityTimeout,
+			"speechStyle":      ws.config.Bot.SpeechStyle,
+			"speechUrlText":    ws.config.Bot.SpeechURLText,
+			"enableMemory":         ws.config.Bot.EnableMemory,
+			"shortTermMemoryEvery": ws.config.Bot.ShortTermMemoryEvery,
+			"longTermMemoryAfter":  ws.config.Bot.LongTermMemoryAfter,
+			"memoryRecallLimit":    ws.config.Bot.MemoryRecallLimit,
+			"memoryRecallTokens":   ws.config.Bot.MemoryRecallTokens,
+			"messageRetentionDays":     ws.config.Bot.MessageRetentionDays,
+			"memoryRetentionDays":      ws.config.Bot.MemoryRetentionDays,
+			"shortTermRetentionDays":   ws.config.Bot.ShortTermRetentionDays,
+			"longTermRetentionDays":    ws.config.Bot.LongTermRetentionDays,
+			"maintenanceIntervalHours": ws.config.Bot.MaintenanceIntervalHours,
 		},
 		"speaker": map[string]interface{}{
 			"name":               ws.config.Speaker.Name,
@@ -62,8 +107,10 @@ func (ws *WebServer) getConfig(c *gin.Context) {
 			"onEnterAI":          strings.Join(ws.config.Speaker.OnEnterAI, ","),
 			"onExitAI":           strings.Join(ws.config.Speaker.OnExitAI, ","),
 			"onAIAsking":         strings.Join(ws.config.Speaker.OnAIAsking, ","),
+			"onAIThinking":       strings.Join(ws.config.Speaker.OnAIThinking, ","),
 			"onAIReplied":        strings.Join(ws.config.Speaker.OnAIReplied, ","),
 			"onAIError":          strings.Join(ws.config.Speaker.OnAIError, ","),
+			"onBudgetExceeded":   strings.Join(ws.config.Speaker.OnBudgetExceeded, ","),
 			"streamResponse":     ws.config.Speaker.StreamResponse,
 			"enableAudioLog":     ws.config.Speaker.EnableAudioLog,
 			"debugMode":          ws.config.Speaker.Debug,
@@ -90,6 +137,7 @@ func (ws *WebServer) getConfig(c *gin.Context) {
 			"batchTimeoutSeconds": ws.config.Speaker.BatchTimeoutSeconds,
 			"enableMetrics":       ws.config.Speaker.EnableMetrics,
 		},
+		"plugins": ws.config.Plugins,
 	}
 
 	c.JSON(http.StatusOK, ConfigResponse{
@@ -189,6 +237,10 @@ func (ws *WebServer) getSystemStatus(c *gin.Context) {
 		DatabasePath:   ws.config.Database.Path,
 		ConcurrentMode: ws.config.Speaker.EnableConcurrent,
 	}
+	if ws.aiSpeaker != nil {
+		status.Maintenance = ws.aiSpeaker.GetMaintenanceStatus()
+		status.Providers = ws.aiSpeaker.GetProviderStatus()
+	}
 
 	c.JSON(http.StatusOK, ConfigResponse{
 		Success: true,
@@ -318,7 +370,7 @@ func (ws *WebServer) playTTS(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 	
-	err := ws.aiSpeaker.ExecuteCommand(ctx, request.Text)
+	result, err := ws.aiSpeaker.ExecuteCommand(ctx, request.Text)
 	if err != nil {
 		logger.Errorf("TTS播放失败: %v", err)
 		c.JSON(http.StatusInternalServerError, ConfigResponse{
@@ -328,11 +380,12 @@ func (ws *WebServer) playTTS(c *gin.Context) {
 		return
 	}
 
-	logger.Infof("TTS播放成功: %s", request.Text)
+	logger.Infof("TTS播放成功: %s (%s)", request.Text, result.Route)
 
 	c.JSON(http.StatusOK, ConfigResponse{
 		Success: true,
 		Message: "TTS播放成功",
+		Data:    result,
 	})
 }
 
@@ -407,35 +460,136 @@ func (ws *WebServer) updateConcurrentConfig(c *gin.Context) {
 func (ws *WebServer) updateConfigFields(data map[string]interface{}) error {
 	// AI配置
 	if ai, ok := data["ai"].(map[string]interface{}); ok {
-		if provider, ok := ai["provider"].(string); ok {
-			ws.config.OpenAI.Provider = provider
-		}
-		if model, ok := ai["model"].(string); ok {
-			ws.config.OpenAI.Model = model
-		}
-		if baseURL, ok := ai["baseURL"].(string); ok {
-			ws.config.OpenAI.BaseURL = baseURL
+		if profile, ok := ai["profile"].(string); ok {
+			ws.config.OpenAI.Profile = profile
 		}
 		if proxyURL, ok := ai["proxyURL"].(string); ok {
 			ws.config.OpenAI.ProxyURL = proxyURL
 		}
-		if apiKey, ok := ai["apiKey"].(string); ok {
-			ws.config.OpenAI.APIKey = apiKey
+		if enableTools, ok := ai["enableTools"].(bool); ok {
+			ws.config.OpenAI.EnableTools = enableTools
+		}
+		if enableEmbedding, ok := ai["enableEmbedding"].(bool); ok {
+			ws.config.OpenAI.EnableEmbedding = enableEmbedding
+		}
+		if embeddingModel, ok := ai["embeddingModel"].(string); ok {
+			ws.config.OpenAI.EmbeddingModel = embeddingModel
+		}
+		if embeddingDimensions, ok := ai["embeddingDimensions"].(float64); ok {
+			ws.config.OpenAI.EmbeddingDimensions = int(embeddingDimensions)
+		}
+		if embeddingBaseURL, ok := ai["embeddingBaseURL"].(string); ok {
+			ws.config.OpenAI.EmbeddingBaseURL = embeddingBaseURL
+		}
+		if embeddingAPIKey, ok := ai["embeddingAPIKey"].(string); ok {
+			ws.config.OpenAI.EmbeddingAPIKey = embeddingAPIKey
+		}
+		if azureEmbeddingDeployment, ok := ai["azureEmbeddingDeployment"].(string); ok {
+			ws.config.OpenAI.AzureEmbeddingDeployment = azureEmbeddingDeployment
+		}
+		if breakerFailures, ok := ai["breakerFailures"].(float64); ok {
+			ws.config.OpenAI.BreakerFailures = int(breakerFailures)
+		}
+		if breakerResetSeconds, ok := ai["breakerResetSeconds"].(float64); ok {
+			ws.config.OpenAI.BreakerResetSeconds = int(breakerResetSeconds)
+		}
+		// 服务商列表（整体替换，重启音箱服务后生效）
+		if profiles, ok := ai["profiles"].([]interface{}); ok {
+			profileData, err := json.Marshal(profiles)
+			if err != nil {
+				return fmt.Errorf("序列化服务商配置失败: %v", err)
+			}
+			var parsed []config.ProviderProfile
+			if err := json.Unmarshal(profileData, &parsed); err != nil {
+				return fmt.Errorf("解析服务商配置失败: %v", err)
+			}
+			ws.config.OpenAI.Profiles = parsed
+		}
+		// 备用服务商名称，按顺序尝试
+		if fallbacks, ok := ai["fallbacks"].([]interface{}); ok {
+			names := make([]string, 0, len(fallbacks))
+			for _, fallback := range fallbacks {
+				if name, ok := fallback.(string); ok && name != "" {
+					names = append(names, name)
+				}
+			}
+			ws.config.OpenAI.Fallbacks = names
+		}
+		// 模型价格表（整体替换）和费用预算
+		if prices, ok := ai["prices"].([]interface{}); ok {
+			priceData, err := json.Marshal(prices)
+			if err != nil {
+				return fmt.Errorf("序列化模型价格表失败: %v", err)
+			}
+			var parsed []config.ModelPrice
+			if err := json.Unmarshal(priceData, &parsed); err != nil {
+				return fmt.Errorf("解析模型价格表失败: %v", err)
+			}
+			ws.config.OpenAI.Prices = parsed
 		}
-		if azureAPIKey, ok := ai["azureAPIKey"].(string); ok {
-			ws.config.OpenAI.AzureAPIKey = azureAPIKey
+		if dailyBudget, ok := ai["dailyBudget"].(float64); ok {
+			ws.config.OpenAI.DailyBudget = dailyBudget
 		}
-		if azureEndpoint, ok := ai["azureEndpoint"].(string); ok {
-			ws.config.OpenAI.AzureEndpoint = azureEndpoint
+		if monthlyBudget, ok := ai["monthlyBudget"].(float64); ok {
+			ws.config.OpenAI.MonthlyBudget = monthlyBudget
 		}
-		if azureDeployment, ok := ai["azureDeployment"].(string); ok {
-			ws.config.OpenAI.AzureDeployment = azureDeployment
+		if budgetModel, ok := ai["budgetModel"].(string); ok {
+			ws.config.OpenAI.BudgetModel = budgetModel
 		}
-		if deepSeekAPIKey, ok := ai["deepSeekAPIKey"].(string); ok {
-			ws.config.OpenAI.DeepSeekAPIKey = deepSeekAPIKey
+		// 回答缓存，跳过规则每行一条（正则表达式可能包含逗号）
+		if enableCache, ok := ai["enableCache"].(bool); ok {
+			ws.config.OpenAI.EnableCache = enableCache
 		}
-		if deepSeekBaseURL, ok := ai["deepSeekBaseURL"].(string); ok {
-			ws.config.OpenAI.DeepSeekBaseURL = deepSeekBaseURL
+		if cacheTTLHours, ok := ai["cacheTtlHours"].(float64); ok {
+			ws.config.OpenAI.CacheTTLHours = int(cacheTTLHours)
+		}
+		if cacheMaxEntries, ok := ai["cacheMaxEntries"].(float64); ok {
+			ws.config.OpenAI.CacheMaxEntries = int(cacheMaxEntries)
+		}
+		if cacheBypass, ok := ai["cacheBypass"].(string); ok {
+			var rules []string
+			for _, rule := range strings.Split(cacheBypass, "\n") {
+				if rule = strings.TrimSpace(rule); rule != "" {
+					rules = append(rules, rule)
+				}
+			}
+			ws.config.OpenAI.CacheBypass = rules
+		}
+		// 联网搜索，触发规则与缓存跳过规则一样每行一条
+		if enableSearch, ok := ai["enableSearch"].(bool); ok {
+			ws.config.OpenAI.EnableSearch = enableSearch
+		}
+		if searchProvider, ok := ai["searchProvider"].(string); ok {
+			ws.config.OpenAI.SearchProvider = searchProvider
+		}
+		if searchBaseURL, ok := ai["searchBaseUrl"].(string); ok {
+			ws.config.OpenAI.SearchBaseURL = strings.TrimSpace(searchBaseURL)
+		}
+		if searchAPIKey, ok := ai["searchApiKey"].(string); ok {
+			ws.config.OpenAI.SearchAPIKey = searchAPIKey
+		}
+		if searchTemplate, ok := ai["searchTemplate"].(map[string]interface{}); ok {
+			templateData, err := json.Marshal(searchTemplate)
+			if err != nil {
+				return fmt.Errorf("序列化搜索接口响应格式失败: %v", err)
+			}
+			var parsed config.SearchTemplate
+			if err := json.Unmarshal(templateData, &parsed); err != nil {
+				return fmt.Errorf("解析搜索接口响应格式失败: %v", err)
+			}
+			ws.config.OpenAI.SearchTemplate = parsed
+		}
+		if searchMaxResults, ok := ai["searchMaxResults"].(float64); ok {
+			ws.config.OpenAI.SearchMaxResults = int(searchMaxResults)
+		}
+		if searchTriggers, ok := ai["searchTriggers"].(string); ok {
+			var rules []string
+			for _, rule := range strings.Split(searchTriggers, "\n") {
+				if rule = strings.TrimSpace(rule); rule != "" {
+					rules = append(rules, rule)
+				}
+			}
+			ws.config.OpenAI.SearchTriggers = rules
 		}
 	}
 
@@ -459,6 +613,54 @@ func (ws *WebServer) updateConfigFields(data map[string]interface{}) error {
 		if roomDescription, ok := bot["roomDescription"].(string); ok {
 			ws.config.Bot.Room.Description = roomDescription
 		}
+		if contextTurns, ok := bot["contextTurns"].(float64); ok {
+			ws.config.Bot.ContextTurns = int(contextTurns)
+		}
+		if contextMaxTokens, ok := bot["contextMaxTokens"].(float64); ok {
+			ws.config.Bot.ContextMaxTokens = int(contextMaxTokens)
+		}
+		if contextWindow, ok := bot["contextWindow"].(float64); ok {
+			ws.config.Bot.ContextWindow = int(contextWindow)
+		}
+		if identityTimeout, ok := bot["identityTimeout"].(float64); ok {
+			ws.config.Bot.IdentityTimeout = int(identityTimeout)
+		}
+		if speechStyle, ok := bot["speechStyle"].(string); ok {
+			ws.config.Bot.SpeechStyle = speechStyle
+		}
+		if speechURLText, ok := bot["speechUrlText"].(string); ok {
+			ws.config.Bot.SpeechURLText = speechURLText
+		}
+		if enableMemory, ok := bot["enableMemory"].(bool); ok {
+			ws.config.Bot.EnableMemory = enableMemory
+		}
+		if shortTermMemoryEvery, ok := bot["shortTermMemoryEvery"].(float64); ok {
+			ws.config.Bot.ShortTermMemoryEvery = int(shortTermMemoryEvery)
+		}
+		if longTermMemoryAfter, ok := bot["longTermMemoryAfter"].(float64); ok {
+			ws.config.Bot.LongTermMemoryAfter = int(longTermMemoryAfter)
+		}
+		if memoryRecallLimit, ok := bot["memoryRecallLimit"].(float64); ok {
+			ws.config.Bot.MemoryRecallLimit = int(memoryRecallLimit)
+		}
+		if memoryRecallTokens, ok := bot["memoryRecallTokens"].(float64); ok {
+			ws.config.Bot.MemoryRecallTokens = int(memoryRecallTokens)
+		}
+		if messageRetentionDays, ok := bot["messageRetentionDays"].(float64); ok {
+			ws.config.Bot.MessageRetentionDays = int(messageRetentionDays)
+		}
+		if memoryRetentionDays, ok := bot["memoryRetentionDays"].(float64); ok {
+			ws.config.Bot.MemoryRetentionDays = int(memoryRetentionDays)
+		}
+		if shortTermRetentionDays, ok := bot["shortTermRetentionDays"].(float64); ok {
+			ws.config.Bot.ShortTermRetentionDays = int(shortTermRetentionDays)
+		}
+		if longTermRetentionDays, ok := bot["longTermRetentionDays"].(float64); ok {
+			ws.config.Bot.LongTermRetentionDays = int(longTermRetentionDays)
+		}
+		if maintenanceIntervalHours, ok := bot["maintenanceIntervalHours"].(float64); ok {
+			ws.config.Bot.MaintenanceIntervalHours = int(maintenanceIntervalHours)
+		}
 	}
 
 	// 音箱配置
@@ -484,12 +686,18 @@ func (ws *WebServer) updateConfigFields(data map[string]interface{}) error {
 		if onAIAsking, ok := speaker["onAIAsking"].(string); ok {
 			ws.config.Speaker.OnAIAsking = strings.Split(onAIAsking, ",")
 		}
+		if onAIThinking, ok := speaker["onAIThinking"].(string); ok {
+			ws.config.Speaker.OnAIThinking = strings.Split(onAIThinking, ",")
+		}
 		if onAIReplied, ok := speaker["onAIReplied"].(string); ok {
 			ws.config.Speaker.OnAIReplied = strings.Split(onAIReplied, ",")
 		}
 		if onAIError, ok := speaker["onAIError"].(string); ok {
 			ws.config.Speaker.OnAIError = strings.Split(onAIError, ",")
 		}
+		if onBudgetExceeded, ok := speaker["onBudgetExceeded"].(string); ok {
+			ws.config.Speaker.OnBudgetExceeded = strings.Split(onBudgetExceeded, ",")
+		}
 		if streamResponse, ok := speaker["streamResponse"].(bool); ok {
 			ws.config.Speaker.StreamResponse = streamResponse
 		}
@@ -551,6 +759,19 @@ func (ws *WebServer) updateConfigFields(data map[string]interface{}) error {
 		}
 	}
 
+	// 插件配置（整体替换，重启音箱服务后生效）
+	if plugins, ok := data["plugins"].([]interface{}); ok {
+		pluginData, err := json.Marshal(plugins)
+		if err != nil {
+			return fmt.Errorf("序列化插件配置失败: %v", err)
+		}
+		var pluginConfigs []config.PluginConfig
+		if err := json.Unmarshal(pluginData, &pluginConfigs); err != nil {
+			return fmt.Errorf("解析插件配置失败: %v", err)
+		}
+		ws.config.Plugins = pluginConfigs
+	}
+
 	// 数据库配置
 	if database, ok := data["database"].(map[string]interface{}); ok {
 		if path, ok := database["path"].(string); ok {
@@ -618,7 +839,7 @@ func (ws *WebServer) testAIConnection(c *gin.Context) {
 		return
 	}
 
-	logger.Infof("正在测试AI服务连接 [%s]...", ws.config.OpenAI.Provider)
+	logger.Infof("正在测试AI服务连接 [%s]...", ws.config.OpenAI.Profile)
 
 	// 创建AI客户端进行真实连接测试
 	aiClient, err := ws.createAIClient()
@@ -628,8 +849,8 @@ func (ws *WebServer) testAIConnection(c *gin.Context) {
 			Success: false,
 			Message: fmt.Sprintf("创建AI客户端失败: %v", err),
 			Data: map[string]interface{}{
-				"provider": ws.config.OpenAI.Provider,
-				"model":    ws.config.OpenAI.Model,
+				"provider": ws.config.OpenAI.Profile,
+				"model":    ws.config.OpenAI.PrimaryModel(),
 				"status":   "failed",
 				"error":    err.Error(),
 			},
@@ -642,10 +863,15 @@ func (ws *WebServer) testAIConnection(c *gin.Context) {
 	defer cancel()
 
 	testMessage := "请回复'连接测试成功'来确认服务正常"
+	var answeredBy string
 	response, err := aiClient.Chat(ctx, openai.ChatOptions{
-		User:   testMessage,
-		System: "你是一个AI助手。请简短回复确认连接正常。",
-		Trace:  true,
+		User:    testMessage,
+		System:  "你是一个AI助手。请简短回复确认连接正常。",
+		Trace:   true,
+		Purpose: openai.PurposeTest,
+		OnProvider: func(provider, model string) {
+			answeredBy = provider
+		},
 	})
 
 	if err != nil {
@@ -654,8 +880,8 @@ func (ws *WebServer) testAIConnection(c *gin.Context) {
 			Success: false,
 			Message: fmt.Sprintf("AI服务连接测试失败: %v", err),
 			Data: map[string]interface{}{
-				"provider":     ws.config.OpenAI.Provider,
-				"model":        ws.config.OpenAI.Model,
+				"provider":     ws.config.OpenAI.Profile,
+				"model":        ws.config.OpenAI.PrimaryModel(),
 				"status":       "failed",
 				"error":        err.Error(),
 				"testMessage":  testMessage,
@@ -664,14 +890,15 @@ func (ws *WebServer) testAIConnection(c *gin.Context) {
 		return
 	}
 
-	logger.Infof("AI服务连接测试成功 [%s]: %s", ws.config.OpenAI.Provider, response)
+	logger.Infof("AI服务连接测试成功 [%s]: %s", ws.config.OpenAI.Profile, response)
 
 	c.JSON(http.StatusOK, ConfigResponse{
 		Success: true,
 		Message: fmt.Sprintf("AI服务连接测试成功，收到回复：%s", response),
 		Data: map[string]interface{}{
-			"provider":     ws.config.OpenAI.Provider,
-			"model":        ws.config.OpenAI.Model,
+			"provider":     ws.config.OpenAI.Profile,
+			"model":        ws.config.OpenAI.PrimaryModel(),
+			"answeredBy":   answeredBy, // 主服务商不可用时为实际回答的备用服务商
 			"status":       "connected",
 			"testMessage":  testMessage,
 			"response":     response,
@@ -680,6 +907,44 @@ func (ws *WebServer) testAIConnection(c *gin.Context) {
 	})
 }
 
+// testSearch 使用当前的联网搜索配置搜索一次，返回压缩前的搜索结果
+func (ws *WebServer) testSearch(c *gin.Context) {
+	var request struct {
+		Query string `json:"query"`
+	}
+	// 请求体可以为空，使用默认的测试问题
+	_ = c.ShouldBindJSON(&request)
+	query := strings.TrimSpace(request.Query)
+	if query == "" {
+		query = "今天的新闻"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	results, err := search.NewSearcher(ws.config).Search(ctx, query)
+	if err != nil {
+		logger.Errorf("联网搜索测试失败: %v", err)
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("联网搜索测试失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: fmt.Sprintf("搜索「%s」返回 %d 条结果", query, len(results)),
+		Data: map[string]interface{}{
+			"query":    query,
+			"provider": ws.config.OpenAI.SearchProvider,
+			"results":  results,
+			"duration": time.Since(start).Milliseconds(),
+		},
+	})
+}
+
 // testMiConnection 测试小米设备连接
 func (ws *WebServer) testMiConnection(c *gin.Context) {
 	if err := ws.config.ValidateMi(); err != nil {
@@ -908,7 +1173,8 @@ func (ws *WebServer) executeVoiceCommand(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := ws.aiSpeaker.ExecuteCommand(ctx, commandText); err != nil {
+	result, err := ws.aiSpeaker.ExecuteCommand(ctx, commandText)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, ConfigResponse{
 			Success: false,
 			Message: fmt.Sprintf("执行语音命令失败: %v", err),
@@ -923,6 +1189,8 @@ func (ws *WebServer) executeVoiceCommand(c *gin.Context) {
 			"command": request.Command,
 			"needResponse": request.NeedResponse,
 			"method":  "tts_broadcast",
+			"route":   result.Route,
+			"answer":  result.Answer,
 		},
 	})
 }
@@ -988,9 +1256,129 @@ func (ws *WebServer) restartSpeaker(c *gin.Context) {
 	})
 }
 
+// getSpeakerCommands 获取已注册的命令处理器（按优先级排序）
+func (ws *WebServer) getSpeakerCommands(c *gin.Context) {
+	if ws.aiSpeaker == nil {
+		c.JSON(http.StatusServiceUnavailable, ConfigResponse{
+			Success: false,
+			Message: "AI音箱服务未启动",
+		})
+		return
+	}
+
+	var commands []map[string]interface{}
+	for _, handler := range ws.aiSpeaker.GetCommandRouter().Registry().GetAllHandlers() {
+		commands = append(commands, map[string]interface{}{
+			"name":        handler.GetName(),
+			"description": handler.GetDescription(),
+			"patterns":    handler.GetPatterns(),
+			"priority":    handler.GetPriority(),
+			"consuming":   handler.IsConsuming(),
+		})
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    commands,
+	})
+}
+
+// getRoutingDecisions 获取最近的命令路由决策
+func (ws *WebServer) getRoutingDecisions(c *gin.Context) {
+	if ws.aiSpeaker == nil {
+		c.JSON(http.StatusServiceUnavailable, ConfigResponse{
+			Success: false,
+			Message: "AI音箱服务未启动",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		limit = 50
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    ws.aiSpeaker.GetCommandRouter().GetRecentDecisions(limit),
+	})
+}
+
+// getPluginStatus 获取外部命令插件状态
+func (ws *WebServer) getPluginStatus(c *gin.Context) {
+	if ws.aiSpeaker == nil {
+		c.JSON(http.StatusServiceUnavailable, ConfigResponse{
+			Success: false,
+			Message: "AI音箱服务未启动",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    ws.aiSpeaker.GetPluginStatus(),
+	})
+}
+
+// getSpeakerTools 获取AI可调用的工具及未到期的定时提醒
+func (ws *WebServer) getSpeakerTools(c *gin.Context) {
+	if ws.aiSpeaker == nil {
+		c.JSON(http.StatusServiceUnavailable, ConfigResponse{
+			Success: false,
+			Message: "AI音箱服务未启动",
+		})
+		return
+	}
+
+	var tools []map[string]interface{}
+	for _, tool := range ws.aiSpeaker.GetToolRegistry().GetTools() {
+		tools = append(tools, map[string]interface{}{
+			"name":        tool.Name,
+			"description": tool.Description,
+			"parameters":  tool.Parameters,
+		})
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"enabled": ws.config.OpenAI.EnableTools,
+			"tools":   tools,
+			"timers":  ws.aiSpeaker.GetTimers(),
+		},
+	})
+}
+
+// getToolCalls 获取最近的工具调用记录
+func (ws *WebServer) getToolCalls(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	var invocations []models.ToolInvocation
+	if err := database.GetDB().Order("id DESC").Limit(limit).Find(&invocations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取工具调用记录失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    invocations,
+	})
+}
+
 // createAIClient 创建AI客户端用于测试
 func (ws *WebServer) createAIClient() (*openai.Client, error) {
-	return openai.NewClient(ws.config.OpenAI)
+	client, err := openai.NewClient(ws.config.OpenAI)
+	if err != nil {
+		return nil, err
+	}
+	client.SetUsageRecorder(usage.NewRecorder(ws.config))
+	return client, nil
 }
 
 
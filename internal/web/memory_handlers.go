@@ -0,0 +1,328 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/memory"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// searchMemories 按相关度检索消息和记忆
+// 参数：q 查询文本，kind 类型（message/short/long，逗号分隔），roomId 房间，limit 条数
+func (ws *WebServer) searchMemories(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "查询内容不能为空",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	options := memory.SearchOptions{
+		Query:  query,
+		RoomID: c.Query("roomId"),
+		Limit:  limit,
+	}
+	if kind := c.Query("kind"); kind != "" {
+		options.Kinds = strings.Split(kind, ",")
+	}
+
+	results, err := memory.Search(database.GetDB(), options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("检索失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    results,
+	})
+}
+
+// reindexMemories 重建检索索引
+func (ws *WebServer) reindexMemories(c *gin.Context) {
+	count, err := memory.RebuildSearchIndex(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("重建检索索引失败: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("检索索引已重建，共 %d 条", count)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: fmt.Sprintf("检索索引已重建，共 %d 条", count),
+		Data:    map[string]interface{}{"count": count},
+	})
+}
+
+// listMemories 分页列出记忆
+// 参数：type 类型（short/long/fact），ownerId 所有者，roomId 房间，from/to 日期（2006-01-02），page 页码，pageSize 每页条数
+func (ws *WebServer) listMemories(c *gin.Context) {
+	options := memory.ListOptions{
+		Kind:    c.DefaultQuery("type", memory.SearchKindShortTerm),
+		OwnerID: c.Query("ownerId"),
+		RoomID:  c.Query("roomId"),
+	}
+	options.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	options.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	var err error
+	if options.From, err = parseDateQuery(c, "from"); err == nil {
+		options.To, err = parseDateQuery(c, "to")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if !options.To.IsZero() {
+		options.To = options.To.AddDate(0, 0, 1) // 包含结束日期当天
+	}
+
+	items, total, err := memory.ListMemories(database.GetDB(), options)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取记忆失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"items": items,
+			"total": total,
+		},
+	})
+}
+
+// getMemoryScopes 获取记忆筛选可选的用户和房间
+func (ws *WebServer) getMemoryScopes(c *gin.Context) {
+	db := database.GetDB()
+
+	var users []models.User
+	var rooms []models.Room
+	err := db.Where("role = ?", models.UserRoleMember).Order("created_at ASC").Find(&users).Error
+	if err == nil {
+		err = db.Order("created_at ASC").Find(&rooms).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取用户和房间失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"users": users,
+			"rooms": rooms,
+		},
+	})
+}
+
+// getMemoryChain 获取记忆及其来源链
+func (ws *WebServer) getMemoryChain(c *gin.Context) {
+	kind, id, ok := parseMemoryParams(c)
+	if !ok {
+		return
+	}
+
+	chain, err := memory.GetMemoryChain(database.GetDB(), kind, id)
+	if err != nil {
+		respondMemoryError(c, err, "获取记忆失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    chain,
+	})
+}
+
+// updateMemory 修改记忆内容
+func (ws *WebServer) updateMemory(c *gin.Context) {
+	kind, id, ok := parseMemoryParams(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Text string `json:"text" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("请求数据格式错误: %v", err),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	if err := memory.UpdateMemoryText(db, kind, id, request.Text); err != nil {
+		respondMemoryError(c, err, "修改记忆失败")
+		return
+	}
+	if manager := ws.memoryManager(); manager != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := manager.RefreshEmbedding(ctx, kind, id); err != nil {
+				logger.Warnf("重新生成记忆向量失败: %v", err)
+			}
+		}()
+	}
+
+	chain, err := memory.GetMemoryChain(db, kind, id)
+	if err != nil {
+		respondMemoryError(c, err, "获取记忆失败")
+		return
+	}
+
+	logger.Infof("已修改记忆 %s#%d", kind, id)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "记忆已修改",
+		Data:    chain.Memory,
+	})
+}
+
+// deleteMemory 删除记忆
+func (ws *WebServer) deleteMemory(c *gin.Context) {
+	kind, id, ok := parseMemoryParams(c)
+	if !ok {
+		return
+	}
+
+	if err := memory.DeleteMemory(database.GetDB(), kind, id); err != nil {
+		respondMemoryError(c, err, "删除记忆失败")
+		return
+	}
+
+	logger.Infof("已删除记忆 %s#%d", kind, id)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "记忆已删除",
+	})
+}
+
+// resummarizeMemory 根据来源重新生成摘要，需要音箱服务已启动并启用记忆
+func (ws *WebServer) resummarizeMemory(c *gin.Context) {
+	kind, id, ok := parseMemoryParams(c)
+	if !ok {
+		return
+	}
+
+	manager := ws.memoryManager()
+	if manager == nil {
+		c.JSON(http.StatusServiceUnavailable, ConfigResponse{
+			Success: false,
+			Message: "音箱服务未启动或未启用记忆，无法重新总结",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+
+	summary, err := manager.Resummarize(ctx, kind, id)
+	if err != nil {
+		respondMemoryError(c, err, "重新总结失败")
+		return
+	}
+
+	logger.Infof("已重新总结记忆 %s#%d", kind, id)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "记忆已重新总结",
+		Data:    map[string]interface{}{"text": summary},
+	})
+}
+
+// memoryManager 获取运行中的音箱服务的记忆管理器
+func (ws *WebServer) memoryManager() *memory.MemoryManager {
+	if ws.aiSpeaker == nil || ws.aiSpeaker.GetConversation() == nil {
+		return nil
+	}
+	return ws.aiSpeaker.GetConversation().MemoryManager()
+}
+
+// parseMemoryParams 解析路径中的记忆类型和ID
+func parseMemoryParams(c *gin.Context) (string, int, bool) {
+	kind := c.Param("type")
+	switch kind {
+	case memory.SearchKindShortTerm, memory.SearchKindLongTerm, memory.KindFact:
+	default:
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("不支持的记忆类型: %s", kind),
+		})
+		return "", 0, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "无效的记忆ID",
+		})
+		return "", 0, false
+	}
+	return kind, id, true
+}
+
+// respondMemoryError 按错误类型返回对应的状态码
+func respondMemoryError(c *gin.Context, err error, message string) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = http.StatusNotFound
+		err = fmt.Errorf("记忆不存在")
+	case errors.Is(err, memory.ErrMemoryInUse):
+		status = http.StatusConflict
+	}
+	c.JSON(status, ConfigResponse{
+		Success: false,
+		Message: fmt.Sprintf("%s: %v", message, err),
+	})
+}
+
+// parseDateQuery 解析日期参数，未提供时返回零值
+func parseDateQuery(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("日期格式无效: %s", value)
+	}
+	return date, nil
+}
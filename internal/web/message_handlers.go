@@ -0,0 +1,150 @@
+package web
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/services/history"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listMessages 分页列出对话记录，新的在前
+// 参数：roomId 房间，senderId 发送者，deviceId 设备，q 消息内容，from/to 日期（2006-01-02），page 页码，pageSize 每页条数
+func (ws *WebServer) listMessages(c *gin.Context) {
+	filter, ok := parseMessageFilter(c)
+	if !ok {
+		return
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PageSize, _ = strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	records, total, err := history.List(database.GetDB(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取对话记录失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"items": records,
+			"total": total,
+		},
+	})
+}
+
+// exportMessages 导出符合条件的全部对话记录
+// 参数：format 格式（jsonl/csv/md，默认jsonl），其余筛选参数与列表相同
+func (ws *WebServer) exportMessages(c *gin.Context) {
+	format := c.DefaultQuery("format", history.FormatJSONL)
+	contentType, err := history.ContentType(format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	filter, ok := parseMessageFilter(c)
+	if !ok {
+		return
+	}
+
+	filename := fmt.Sprintf("messages-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// 响应已开始写出，出错时只能记录日志
+	count, err := history.Export(database.GetDB(), c.Writer, format, filter)
+	if err != nil {
+		logger.Errorf("导出对话记录失败: %v", err)
+		return
+	}
+	logger.Infof("已导出 %d 条对话记录（%s）", count, format)
+}
+
+// importMessages 导入导出的对话记录文件
+// 表单字段：file 文件，format 格式（jsonl/csv），未指定格式时按文件扩展名判断
+func (ws *WebServer) importMessages(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "请选择要导入的文件",
+		})
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+	}
+	switch format {
+	case "json", "ndjson":
+		format = history.FormatJSONL
+	case "markdown":
+		format = history.FormatMarkdown
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("读取文件失败: %v", err),
+		})
+		return
+	}
+	defer reader.Close()
+
+	result, err := history.Import(database.GetDB(), reader, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	logger.Infof("已导入 %d 条对话记录，跳过 %d 条已存在的记录", result.Messages, result.Skipped)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: fmt.Sprintf("已导入 %d 条对话记录，跳过 %d 条已存在的记录", result.Messages, result.Skipped),
+		Data:    result,
+	})
+}
+
+// parseMessageFilter 解析对话记录的筛选参数，参数无效时直接写入响应
+func parseMessageFilter(c *gin.Context) (history.Filter, bool) {
+	filter := history.Filter{
+		RoomID:   c.Query("roomId"),
+		SenderID: c.Query("senderId"),
+		DeviceID: c.Query("deviceId"),
+		Text:     c.Query("q"),
+	}
+
+	var err error
+	if filter.From, err = parseDateQuery(c, "from"); err == nil {
+		filter.To, err = parseDateQuery(c, "to")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return filter, false
+	}
+	if !filter.To.IsZero() {
+		filter.To = filter.To.AddDate(0, 0, 1) // 包含结束日期当天
+	}
+	return filter, true
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"mi-gpt-go/internal/config"
+	"mi-gpt-go/internal/services/openai"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// modelPullTimeout 下载一个模型的最长时间
+const modelPullTimeout = 2 * time.Hour
+
+// modelPullStatus 模型下载任务的状态
+type modelPullStatus struct {
+	Profile   string     `json:"profile"`
+	Model     string     `json:"model"`
+	Status    string     `json:"status"`
+	Total     int64      `json:"total"`
+	Completed int64      `json:"completed"`
+	Error     string     `json:"error,omitempty"`
+	Done      bool       `json:"done"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+}
+
+// modelPull 一次模型下载任务
+type modelPull struct {
+	mutex  sync.Mutex
+	status modelPullStatus
+}
+
+// snapshot 复制任务状态用于返回
+func (p *modelPull) snapshot() modelPullStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.status
+}
+
+// listProviderKinds 列出支持的服务商类型及其默认地址、推荐模型和默认能力
+func (ws *WebServer) listProviderKinds(c *gin.Context) {
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    config.ProviderKinds,
+	})
+}
+
+// listProviderModels 列出 Ollama 服务商上已下载的模型
+func (ws *WebServer) listProviderModels(c *gin.Context) {
+	profile, ok := ws.ollamaProfile(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	models, err := openai.ListOllamaModels(ctx, profile)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取模型列表失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    models,
+	})
+}
+
+// pullProviderModel 在后台让 Ollama 服务商下载模型，进度通过 listModelPulls 查询
+// 请求体：{"model": "qwen2.5:7b"}
+func (ws *WebServer) pullProviderModel(c *gin.Context) {
+	profile, ok := ws.ollamaProfile(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Model) == "" {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "请填写要下载的模型名称",
+		})
+		return
+	}
+	model := strings.TrimSpace(req.Model)
+
+	pull := &modelPull{status: modelPullStatus{
+		Profile:   profile.Name,
+		Model:     model,
+		Status:    "starting",
+		StartedAt: time.Now(),
+	}}
+	key := profile.Name + "/" + model
+	if existing, loaded := ws.modelPulls.LoadOrStore(key, pull); loaded {
+		if status := existing.(*modelPull).snapshot(); !status.Done {
+			c.JSON(http.StatusConflict, ConfigResponse{
+				Success: false,
+				Message: fmt.Sprintf("模型 %s 正在下载中", model),
+				Data:    status,
+			})
+			return
+		}
+		ws.modelPulls.Store(key, pull)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), modelPullTimeout)
+		defer cancel()
+
+		logger.Infof("📥 开始下载模型 %s [%s]", model, profile.Name)
+		err := openai.PullOllamaModel(ctx, profile, model, func(progress openai.OllamaPullProgress) {
+			pull.mutex.Lock()
+			pull.status.Status = progress.Status
+			if progress.Total > 0 {
+				pull.status.Total, pull.status.Completed = progress.Total, progress.Completed
+			}
+			pull.mutex.Unlock()
+		})
+
+		pull.mutex.Lock()
+		defer pull.mutex.Unlock()
+		now := time.Now()
+		pull.status.Done, pull.status.EndedAt = true, &now
+		if err != nil {
+			pull.status.Status, pull.status.Error = "failed", err.Error()
+			logger.Errorf("下载模型 %s 失败: %v", model, err)
+			return
+		}
+		pull.status.Completed = pull.status.Total
+		logger.Infof("✅ 模型 %s 下载完成 [%s]", model, profile.Name)
+	}()
+
+	c.JSON(http.StatusAccepted, ConfigResponse{
+		Success: true,
+		Message: fmt.Sprintf("已开始下载模型 %s", model),
+		Data:    pull.snapshot(),
+	})
+}
+
+// listModelPulls 列出服务商的模型下载任务，新的在前
+func (ws *WebServer) listModelPulls(c *gin.Context) {
+	name := c.Param("name")
+	pulls := []modelPullStatus{}
+	ws.modelPulls.Range(func(_, value interface{}) bool {
+		if status := value.(*modelPull).snapshot(); status.Profile == name {
+			pulls = append(pulls, status)
+		}
+		return true
+	})
+	sort.Slice(pulls, func(i, j int) bool {
+		return pulls[i].StartedAt.After(pulls[j].StartedAt)
+	})
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    pulls,
+	})
+}
+
+// ollamaProfile 按路径参数查找 Ollama 服务商配置，找不到或类型不符时直接写入响应
+func (ws *WebServer) ollamaProfile(c *gin.Context) (config.ProviderProfile, bool) {
+	profile, ok := ws.config.OpenAI.FindProfile(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Message: "服务商不存在",
+		})
+		return profile, false
+	}
+	if profile.Kind != "ollama" {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "只有 Ollama 服务商支持模型管理",
+		})
+		return profile, false
+	}
+	return profile, true
+}
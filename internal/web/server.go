@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -29,6 +30,7 @@ type WebServer struct {
 	aiSpeaker        *speaker.EnhancedAISpeaker
 	dbConfigService  DBConfigService
 	isRunning        bool
+	modelPulls       sync.Map // Ollama 模型下载任务，键为 服务商名称/模型名称
 }
 
 // NewWebServer 创建Web服务器
@@ -94,9 +96,19 @@ func (ws *WebServer) setupRoutes() {
 			config.POST("/validate", ws.validateConfig)
 			config.POST("/test-ai", ws.testAIConnection)
 			config.POST("/test-mi", ws.testMiConnection)
+			config.POST("/test-search", ws.testSearch)
 			config.POST("/start-speaker", ws.startSpeaker)
 		}
 
+		// AI服务商
+		providers := api.Group("/providers")
+		{
+			providers.GET("/kinds", ws.listProviderKinds)
+			providers.GET("/:name/models", ws.listProviderModels)  // Ollama 已下载的模型
+			providers.POST("/:name/pull", ws.pullProviderModel)    // Ollama 下载模型
+			providers.GET("/:name/pull", ws.listModelPulls)        // 模型下载进度
+		}
+
 		// 系统状态
 		system := api.Group("/system")
 		{
@@ -116,6 +128,70 @@ func (ws *WebServer) setupRoutes() {
 			speaker.POST("/stop", ws.stopSpeaker)
 			speaker.POST("/restart", ws.restartSpeaker)  // 配置热重载端点
 			speaker.POST("/execute", ws.executeVoiceCommand)  // 语音命令执行端点
+			speaker.GET("/commands", ws.getSpeakerCommands)   // 已注册的命令处理器
+			speaker.GET("/routing", ws.getRoutingDecisions)   // 命令路由决策记录
+			speaker.GET("/plugins", ws.getPluginStatus)       // 外部命令插件状态
+			speaker.GET("/tools", ws.getSpeakerTools)         // AI可调用的工具
+			speaker.GET("/tool-calls", ws.getToolCalls)       // 工具调用记录
+		}
+
+		// 自定义命令
+		commands := api.Group("/commands")
+		{
+			commands.GET("", ws.listCustomCommands)
+			commands.POST("", ws.createCustomCommand)
+			commands.POST("/reload", ws.reloadCustomCommandsHandler)
+			commands.GET("/:id", ws.getCustomCommand)
+			commands.PUT("/:id", ws.updateCustomCommand)
+			commands.DELETE("/:id", ws.deleteCustomCommand)
+		}
+
+		// 记忆检索与管理
+		memories := api.Group("/memories")
+		{
+			memories.GET("", ws.listMemories)
+			memories.GET("/scopes", ws.getMemoryScopes)
+			memories.GET("/search", ws.searchMemories)
+			memories.POST("/reindex", ws.reindexMemories)
+			memories.GET("/:type/:id", ws.getMemoryChain)
+			memories.PUT("/:type/:id", ws.updateMemory)
+			memories.DELETE("/:type/:id", ws.deleteMemory)
+			memories.POST("/:type/:id/resummarize", ws.resummarizeMemory)
+		}
+
+		// 家庭成员
+		users := api.Group("/users")
+		{
+			users.GET("", ws.listUsers)
+			users.POST("", ws.createUser)
+			users.PUT("/:id", ws.updateUser)
+			users.DELETE("/:id", ws.deleteUser)
+		}
+
+		// 会话
+		sessions := api.Group("/sessions")
+		{
+			sessions.GET("", ws.listSessions)
+			sessions.GET("/:id", ws.getSession)
+		}
+
+		// 对话记录
+		messages := api.Group("/messages")
+		{
+			messages.GET("", ws.listMessages)
+			messages.GET("/export", ws.exportMessages)
+			messages.POST("/import", ws.importMessages)
+		}
+
+		// 用量与费用
+		api.GET("/usage", ws.getUsage)
+
+		// 回答缓存
+		answerCache := api.Group("/cache")
+		{
+			answerCache.GET("", ws.listCachedAnswers)
+			answerCache.DELETE("", ws.purgeCachedAnswers)
+			answerCache.DELETE("/:key", ws.deleteCachedAnswer)
 		}
 
 		// 并发处理状态
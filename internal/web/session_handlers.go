@@ -0,0 +1,109 @@
+package web
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// listSessions 分页列出会话，新的在前
+// 参数：roomId 房间，deviceId 设备，userId 参与者，from/to 日期（2006-01-02），page 页码，pageSize 每页条数
+func (ws *WebServer) listSessions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	var from, to time.Time
+	var err error
+	if from, err = parseDateQuery(c, "from"); err == nil {
+		to, err = parseDateQuery(c, "to")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	query := database.GetDB().Model(&models.Session{})
+	if roomID := c.Query("roomId"); roomID != "" {
+		query = query.Where("room_id = ?", roomID)
+	}
+	if deviceID := c.Query("deviceId"); deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	if userID := c.Query("userId"); userID != "" {
+		query = query.Where("id IN (SELECT session_id FROM session_participants WHERE user_id = ?)", userID)
+	}
+	if !from.IsZero() {
+		query = query.Where("started_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("started_at < ?", to.AddDate(0, 0, 1)) // 包含结束日期当天
+	}
+
+	var total int64
+	var sessions []models.Session
+	err = query.Session(&gorm.Session{}).Count(&total).Error
+	if err == nil {
+		err = query.Preload("Room").Preload("Participants").
+			Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&sessions).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取会话失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"items": sessions,
+			"total": total,
+		},
+	})
+}
+
+// getSession 获取会话及其全部消息，用于回放
+func (ws *WebServer) getSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "无效的会话ID",
+		})
+		return
+	}
+
+	var session models.Session
+	err = database.GetDB().Preload("Room").Preload("Participants").
+		Preload("Messages", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("id ASC")
+		}).Preload("Messages.Sender").First(&session, id).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("会话不存在: %d", id),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    session,
+	})
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/services/usage"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUsageDays 未指定日期范围时汇总最近的天数
+const defaultUsageDays = 30
+
+// usageGroups 返回的汇总字段及其维度
+var usageGroups = map[string]string{
+	"byDay":      "day",
+	"byDevice":   "device",
+	"byUser":     "user",
+	"byProvider": "provider",
+	"byModel":    "model",
+	"byPurpose":  "purpose",
+}
+
+// getUsage 汇总AI调用的用量和费用，同时返回预算的使用情况
+// 参数：from/to 日期（2006-01-02，默认最近30天），deviceId 设备，userId 成员，provider 服务商
+func (ws *WebServer) getUsage(c *gin.Context) {
+	from, err := parseDateQuery(c, "from")
+	var to time.Time
+	if err == nil {
+		to, err = parseDateQuery(c, "to")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	if to.IsZero() {
+		to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, 1-defaultUsageDays)
+	}
+
+	filter := usage.Filter{
+		From:     from,
+		To:       to.AddDate(0, 0, 1), // 包含结束日期当天
+		DeviceID: c.Query("deviceId"),
+		UserID:   c.Query("userId"),
+		Provider: c.Query("provider"),
+	}
+
+	db := database.GetDB()
+	data := map[string]interface{}{
+		"from": from.Format("2006-01-02"),
+		"to":   to.Format("2006-01-02"),
+	}
+	totals, err := usage.Totals(db, filter)
+	if err == nil {
+		data["totals"] = totals
+		for key, groupBy := range usageGroups {
+			var buckets []usage.Bucket
+			if buckets, err = usage.Aggregate(db, filter, groupBy); err != nil {
+				break
+			}
+			data[key] = buckets
+		}
+	}
+	if err == nil {
+		data["budget"], err = usage.CheckBudget(db, ws.config.OpenAI, now)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取用量失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data:    data,
+	})
+}
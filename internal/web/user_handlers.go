@@ -0,0 +1,253 @@
+package web
+
+import (
+	"fmt"
+	"mi-gpt-go/internal/database"
+	"mi-gpt-go/internal/models"
+	"mi-gpt-go/internal/services/speaker"
+	"mi-gpt-go/pkg/logger"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userRequest 家庭成员请求
+type userRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Profile   string `json:"profile"`
+	Nicknames string `json:"nicknames"`
+	Persona   string `json:"persona"`
+	// 播报方式，为空时使用机器人的设置
+	SpeechStyle string `json:"speechStyle" binding:"omitempty,oneof=natural plain raw"`
+}
+
+// apply 把请求内容写入成员模型
+func (r *userRequest) apply(user *models.User) {
+	user.Name = strings.TrimSpace(r.Name)
+	user.Profile = strings.TrimSpace(r.Profile)
+	user.Nicknames = speaker.NormalizeNicknames(r.Nicknames)
+	user.Persona = strings.TrimSpace(r.Persona)
+	user.SpeechStyle = r.SpeechStyle
+	user.Role = models.UserRoleMember
+}
+
+// listUsers 获取家庭成员列表，同时返回主人和当前说话人的ID
+func (ws *WebServer) listUsers(c *gin.Context) {
+	var users []models.User
+	if err := database.GetDB().Where("role = ?", models.UserRoleMember).
+		Order("created_at ASC").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("获取家庭成员失败: %v", err),
+		})
+		return
+	}
+
+	masterID, currentID := ws.masterID(), ""
+	if conversation := ws.conversation(); conversation != nil {
+		currentID = conversation.CurrentUser().ID
+	}
+
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"items":     users,
+			"masterId":  masterID,
+			"currentId": currentID,
+		},
+	})
+}
+
+// createUser 添加家庭成员
+func (ws *WebServer) createUser(c *gin.Context) {
+	var request userRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("请求数据格式错误: %v", err),
+		})
+		return
+	}
+
+	var user models.User
+	request.apply(&user)
+	if !ws.checkUserName(c, &user) {
+		return
+	}
+
+	if err := database.GetDB().Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("添加家庭成员失败: %v", err),
+		})
+		return
+	}
+
+	logger.Infof("已添加家庭成员: %s", user.Name)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "家庭成员添加成功",
+		Data:    user,
+	})
+}
+
+// updateUser 修改家庭成员的名称、介绍、昵称和人设
+func (ws *WebServer) updateUser(c *gin.Context) {
+	user, ok := ws.findUser(c)
+	if !ok {
+		return
+	}
+	isMaster := user.ID == ws.masterID()
+
+	var request userRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("请求数据格式错误: %v", err),
+		})
+		return
+	}
+	request.apply(user)
+	if !ws.checkUserName(c, user) {
+		return
+	}
+
+	if err := database.GetDB().Model(user).Select("name", "profile", "nicknames", "persona", "speech_style").
+		Updates(user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("修改家庭成员失败: %v", err),
+		})
+		return
+	}
+	if conversation := ws.conversation(); conversation != nil {
+		conversation.RefreshUser(*user)
+	} else if isMaster {
+		ws.config.Bot.Master.Name = user.Name
+		ws.config.Bot.Master.Profile = user.Profile
+		if err := ws.dbConfigService.SaveConfig(ws.config); err != nil {
+			logger.Warnf("保存主人信息失败: %v", err)
+		}
+	}
+
+	logger.Infof("已修改家庭成员: %s", user.Name)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "家庭成员已修改",
+		Data:    user,
+	})
+}
+
+// deleteUser 删除家庭成员，主人和已有对话记录的成员不能删除
+func (ws *WebServer) deleteUser(c *gin.Context) {
+	user, ok := ws.findUser(c)
+	if !ok {
+		return
+	}
+
+	if user.ID == ws.masterID() {
+		c.JSON(http.StatusConflict, ConfigResponse{
+			Success: false,
+			Message: "主人不能删除，可以在配置中修改主人信息",
+		})
+		return
+	}
+
+	db := database.GetDB()
+	var messages int64
+	if err := db.Model(&models.Message{}).Where("sender_id = ?", user.ID).Count(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("读取对话记录失败: %v", err),
+		})
+		return
+	}
+	if messages > 0 {
+		c.JSON(http.StatusConflict, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("%s已有 %d 条对话记录，不能删除", user.Name, messages),
+		})
+		return
+	}
+
+	if err := db.Delete(user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("删除家庭成员失败: %v", err),
+		})
+		return
+	}
+	if conversation := ws.conversation(); conversation != nil {
+		conversation.ForgetUser(user.ID)
+	}
+
+	logger.Infof("已删除家庭成员: %s", user.Name)
+	c.JSON(http.StatusOK, ConfigResponse{
+		Success: true,
+		Message: "家庭成员已删除",
+	})
+}
+
+// findUser 根据路径参数查找家庭成员，未找到时直接写入响应
+func (ws *WebServer) findUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := database.GetDB().Where("id = ? AND role = ?", c.Param("id"), models.UserRoleMember).
+		First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("家庭成员不存在: %s", c.Param("id")),
+		})
+		return nil, false
+	}
+	return &user, true
+}
+
+// checkUserName 检查名称和昵称是否与其他成员重复，重复时直接写入响应
+func (ws *WebServer) checkUserName(c *gin.Context, user *models.User) bool {
+	if user.Name == "" {
+		c.JSON(http.StatusBadRequest, ConfigResponse{
+			Success: false,
+			Message: "名称不能为空",
+		})
+		return false
+	}
+
+	db := database.GetDB()
+	names := []string{user.Name}
+	if user.Nicknames != "" {
+		names = append(names, strings.Split(user.Nicknames, ",")...)
+	}
+	for _, name := range names {
+		existing, err := speaker.FindMember(db, name)
+		if err != nil || existing.ID == user.ID {
+			continue
+		}
+		c.JSON(http.StatusConflict, ConfigResponse{
+			Success: false,
+			Message: fmt.Sprintf("%s已被成员%s使用", name, existing.Name),
+		})
+		return false
+	}
+	return true
+}
+
+// conversation 获取运行中的对话引擎，服务未启动时返回空
+func (ws *WebServer) conversation() *speaker.Conversation {
+	if ws.aiSpeaker == nil {
+		return nil
+	}
+	return ws.aiSpeaker.GetConversation()
+}
+
+// masterID 获取主人的用户ID，服务未启动时按配置中的主人名称查找
+func (ws *WebServer) masterID() string {
+	if conversation := ws.conversation(); conversation != nil && conversation.MasterID() != "" {
+		return conversation.MasterID()
+	}
+	master, err := speaker.FindMember(database.GetDB(), ws.config.Bot.Master.Name)
+	if err != nil {
+		return ""
+	}
+	return master.ID
+}
@@ -43,7 +43,7 @@ func main() {
 	}
 
 	// 打印配置信息
-	logger.Infof("当前AI服务提供商: %s", cfg.OpenAI.Provider)
+	logger.Infof("当前AI服务商: %s", cfg.OpenAI.Profile)
 	if cfg.Speaker.EnableConcurrent {
 		logger.Infof("并发处理已启用 - 工作协程: %d, 队列大小: %d", 
 			cfg.Speaker.WorkerCount, cfg.Speaker.QueueSize)